@@ -8,6 +8,8 @@ import (
 )
 
 // The Attempt and AttemptStrategy types are copied from those in launchpad.net/goamz/aws.
+// New code should rather use the retry package, which supports contexts,
+// backoff functions and fatal errors.
 
 // AttemptStrategy represents a strategy for waiting for an action
 // to complete successfully.
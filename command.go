@@ -3,17 +3,209 @@
 
 package utils
 
-import "os/exec"
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
 
 type CommandRunner func(string, ...string) (string, error)
 
+// ContextCommandRunner runs commands. It can be replaced for testing
+// purposes by implementations which do not start processes.
+type ContextCommandRunner interface {
+	// RunCommand runs the given command until it completes, or until
+	// the given context is done or the timeout of the command elapses,
+	// in which case the command is killed along with the processes it
+	// spawned and the error of the context is returned.
+	//
+	// The result is returned whenever the command was started, along
+	// with an *exec.ExitError if it exited with a non-zero status.
+	RunCommand(ctx context.Context, args RunCommandArgs) (*CommandResult, error)
+}
+
+// RunCommandArgs holds a command to be run by a ContextCommandRunner.
+type RunCommandArgs struct {
+	// Command is the name or path of the command.
+	Command string
+
+	// Args holds the arguments of the command.
+	Args []string
+
+	// Dir is the working directory of the command. It is that of
+	// the calling process if empty.
+	Dir string
+
+	// Env holds the "NAME=value" variables which are added to, or
+	// override those of, the environment of the calling process.
+	Env []string
+
+	// Stdin, if set, is read as the standard input of the command.
+	Stdin io.Reader
+
+	// Stdout and Stderr, if set, get what the command writes to its
+	// standard output and error as it writes it, on top of it being
+	// recorded in the result.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// CombinedOutput records the standard error of the command along
+	// with its standard output, in CommandResult.Stdout, and writes
+	// both to Stdout.
+	CombinedOutput bool
+
+	// Timeout, if positive, limits the time the command may run for.
+	Timeout time.Duration
+}
+
+// CommandResult holds the outcome of a command run by a
+// ContextCommandRunner.
+type CommandResult struct {
+	// Stdout and Stderr hold what the command wrote to its
+	// standard output and error.
+	Stdout []byte
+	Stderr []byte
+
+	// ExitCode is the exit status of the command,
+	// or -1 if it was killed by a signal.
+	ExitCode int
+
+	// Duration is how long the command ran for.
+	Duration time.Duration
+}
+
+// DefaultCommandRunner runs commands as processes of the local machine.
+var DefaultCommandRunner ContextCommandRunner = localCommandRunner{}
+
+// localCommandRunner implements ContextCommandRunner with os/exec.
+type localCommandRunner struct{}
+
+// RunCommand implements ContextCommandRunner.
+func (localCommandRunner) RunCommand(ctx context.Context, args RunCommandArgs) (*CommandResult, error) {
+	if args.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, args.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.Command(args.Command, args.Args...)
+	cmd.Dir = args.Dir
+	if len(args.Env) > 0 {
+		cmd.Env = MergeEnvironment(os.Environ(), args.Env)
+	}
+	cmd.Stdin = args.Stdin
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = teeWriter(&stdout, args.Stdout)
+	if args.CombinedOutput {
+		// sharing the writer makes os/exec serialize the writes.
+		cmd.Stderr = cmd.Stdout
+	} else {
+		cmd.Stderr = teeWriter(&stderr, args.Stderr)
+	}
+
+	start := time.Now()
+	err := runContext(ctx, cmd)
+	if cmd.ProcessState == nil {
+		// the command could not be started.
+		return nil, err
+	}
+	return &CommandResult{
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		ExitCode: exitCode(cmd.ProcessState),
+		Duration: time.Since(start),
+	}, err
+}
+
+// runContext runs the given command until it completes or the given
+// context is done, in which case the command is killed along with the
+// processes it spawned and the error of the context is returned.
+func runContext(ctx context.Context, cmd *exec.Cmd) error {
+	if ctx.Done() == nil {
+		return cmd.Run()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if err := killProcessGroup(cmd.Process); err != nil {
+			logger.Warningf("failed to kill %q: %v", cmd.Args, err)
+		}
+		<-done
+		return ctx.Err()
+	}
+}
+
+// teeWriter returns a writer writing to the given buffer
+// and to the given writer, if any.
+func teeWriter(buf *bytes.Buffer, w io.Writer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	return io.MultiWriter(buf, w)
+}
+
+// exitCode returns the exit status of the process with the given
+// state, or -1 if it was killed by a signal.
+func exitCode(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok {
+		return status.ExitStatus()
+	}
+	if state.Success() {
+		return 0
+	}
+	return -1
+}
+
+// MergeEnvironment returns the given environment, of "NAME=value"
+// variables, with the given variables added to it or overriding
+// those of the same name.
+func MergeEnvironment(env, vars []string) []string {
+	res := append([]string(nil), env...)
+	for _, v := range vars {
+		name := strings.SplitN(v, "=", 2)[0]
+		replaced := false
+		for i, existing := range res {
+			if strings.SplitN(existing, "=", 2)[0] == name {
+				res[i] = v
+				replaced = true
+			}
+		}
+		if !replaced {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
 // RunCommand executes the command and return the combined output.
 func RunCommand(command string, args ...string) (output string, err error) {
-	cmd := exec.Command(command, args...)
-	out, err := cmd.CombinedOutput()
-	output = string(out)
-	if err != nil {
-		return output, err
+	result, err := DefaultCommandRunner.RunCommand(context.Background(), RunCommandArgs{
+		Command:        command,
+		Args:           args,
+		CombinedOutput: true,
+	})
+	if result != nil {
+		output = string(result.Stdout)
 	}
-	return output, nil
+	return output, err
 }
@@ -4,11 +4,18 @@
 package utils_test
 
 import (
+	"bytes"
+	"context"
 	"io/ioutil"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
 	"github.com/juju/utils"
@@ -30,6 +37,14 @@ type commandSuite struct {
 
 var _ = gc.Suite(&commandSuite{})
 
+// RunCommand can still be used as a CommandRunner.
+var _ utils.CommandRunner = utils.RunCommand
+
+func (s *commandSuite) TestMergeEnvironment(c *gc.C) {
+	env := utils.MergeEnvironment([]string{"A=1", "B=2"}, []string{"B=3", "C=4"})
+	c.Assert(env, jc.DeepEquals, []string{"A=1", "B=3", "C=4"})
+}
+
 func (s *commandSuite) TestRunCommandCombinesOutput(c *gc.C) {
 	var content string
 	var cmdName string
@@ -79,3 +94,61 @@ exit 42
 	c.Assert(err, gc.ErrorMatches, `exit status 42`)
 	c.Assert(output, gc.Equals, expect)
 }
+
+func (s *commandSuite) TestCommandRunnerResult(c *gc.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("test uses a bash script")
+	}
+	patchExecutable(s, c.MkDir(), "test-output", `#!/bin/bash --norc
+echo "stdout $FOO $PWD"
+read line
+echo "$line"
+echo stderr 1>&2
+exit 3
+`)
+	dir := c.MkDir()
+	var stderr bytes.Buffer
+	result, err := utils.DefaultCommandRunner.RunCommand(context.Background(), utils.RunCommandArgs{
+		Command: "test-output",
+		Dir:     dir,
+		Env:     []string{"FOO=bar"},
+		Stdin:   strings.NewReader("stdin\n"),
+		Stderr:  &stderr,
+	})
+	c.Assert(err, gc.ErrorMatches, "exit status 3")
+	c.Assert(err, gc.FitsTypeOf, &exec.ExitError{})
+	c.Assert(string(result.Stdout), gc.Equals, "stdout bar "+dir+"\nstdin\n")
+	c.Assert(string(result.Stderr), gc.Equals, "stderr\n")
+	c.Assert(result.ExitCode, gc.Equals, 3)
+	c.Assert(result.Duration > 0, jc.IsTrue)
+	c.Assert(stderr.String(), gc.Equals, "stderr\n")
+}
+
+func (s *commandSuite) TestCommandRunnerTimeout(c *gc.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("test uses a bash script")
+	}
+	patchExecutable(s, c.MkDir(), "test-sleep", `#!/bin/bash --norc
+echo started
+sleep 60
+`)
+	s.PatchEnvironment("PATH", os.Getenv("PATH")+":/bin:/usr/bin")
+	result, err := utils.DefaultCommandRunner.RunCommand(context.Background(), utils.RunCommandArgs{
+		Command:        "test-sleep",
+		CombinedOutput: true,
+		Timeout:        100 * time.Millisecond,
+	})
+	c.Assert(err, gc.Equals, context.DeadlineExceeded)
+	c.Assert(string(result.Stdout), gc.Equals, "started\n")
+	c.Assert(result.ExitCode, gc.Equals, -1)
+	c.Assert(result.Duration < 10*time.Second, jc.IsTrue)
+}
+
+func (s *commandSuite) TestCommandRunnerNotFound(c *gc.C) {
+	s.PatchEnvironment("PATH", c.MkDir())
+	result, err := utils.DefaultCommandRunner.RunCommand(context.Background(), utils.RunCommandArgs{
+		Command: "no-such-command",
+	})
+	c.Assert(err, gc.ErrorMatches, `exec: "no-such-command": executable file not found in \$PATH`)
+	c.Assert(result, gc.IsNil)
+}
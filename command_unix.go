@@ -0,0 +1,24 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// +build !windows
+
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup makes the given command run in its own process group,
+// so that killProcessGroup also kills the processes it spawns, such as
+// the dpkg and rpm processes run by apt-get and yum.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the process group led by the given process.
+func killProcessGroup(proc *os.Process) error {
+	return syscall.Kill(-proc.Pid, syscall.SIGKILL) // note the minus sign
+}
@@ -0,0 +1,19 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// +build windows
+
+package utils
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a noop on windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the given process.
+func killProcessGroup(proc *os.Process) error {
+	return proc.Kill()
+}
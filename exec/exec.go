@@ -35,12 +35,40 @@ type RunParams struct {
 	Clock       clock.Clock
 	KillProcess func(*os.Process) error
 
+	// Credential, if set, identifies the user the commands are run as.
+	// The commands still inherit the environment of the calling process
+	// unless Environment is set, including variables such as HOME and
+	// USER which describe the calling user.
+	Credential *Credential
+
+	// shellAndArgs, if set, replaces the package function of the
+	// same name to run the commands with another shell.
+	shellAndArgs func(tempDir, script string) (string, []string, error)
+
 	tempDir string
 	stdout  *bytes.Buffer
 	stderr  *bytes.Buffer
 	ps      *exec.Cmd
 }
 
+// Credential identifies a user commands are run as, instead of the user
+// of the calling process, which must be privileged enough to switch to it,
+// typically root dropping its privileges. UserCredential and
+// SudoCallerCredential return the credentials of existing users.
+type Credential struct {
+	// UID and GID are the user and group IDs of the user on unix.
+	UID uint32
+	GID uint32
+
+	// Groups holds the supplementary group IDs of the user on unix.
+	// The commands run without supplementary groups if it is empty.
+	Groups []uint32
+
+	// Token is the access token of the user on windows, as obtained with
+	// LogonUser, with which the commands are started by CreateProcessAsUser.
+	Token uintptr
+}
+
 // ExecResponse contains the return code and output generated by executing a
 // command.
 type ExecResponse struct {
@@ -124,7 +152,15 @@ func (r *RunParams) Run() error {
 		return err
 	}
 
-	shell, args, err := shellAndArgs(tempDir, r.Commands)
+	shellAndArgsFunc := shellAndArgs
+	if r.shellAndArgs != nil {
+		shellAndArgsFunc = r.shellAndArgs
+	}
+	shell, args, err := shellAndArgsFunc(tempDir, r.Commands)
+	if err == nil && r.Credential != nil {
+		// The script must be readable by the user.
+		err = r.Credential.chownAll(tempDir)
+	}
 	if err != nil {
 		if err := os.RemoveAll(tempDir); err != nil {
 			logger.Warningf("failed to remove temporary directory: %v", err)
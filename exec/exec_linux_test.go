@@ -4,6 +4,9 @@
 package exec_test
 
 import (
+	"os"
+
+	"github.com/juju/errors"
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
@@ -100,3 +103,61 @@ func (*execSuite) TestExecUnknownCommand(c *gc.C) {
 	// 127 is a special bash return code meaning command not found.
 	c.Assert(result.Code, gc.Equals, 127)
 }
+
+func (*execSuite) TestRunCommandsAsUser(c *gc.C) {
+	if os.Getuid() != 0 {
+		c.Skip("running commands as another user requires root")
+	}
+	result, err := exec.RunCommands(exec.RunParams{
+		Commands:   "id -u; id -g; id -G",
+		WorkingDir: "/",
+		Credential: &exec.Credential{
+			UID:    65534,
+			GID:    65534,
+			Groups: []uint32{65534},
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(result.Stderr), gc.Equals, "")
+	c.Assert(string(result.Stdout), gc.Equals, "65534\n65534\n65534\n")
+	c.Assert(result.Code, gc.Equals, 0)
+}
+
+func (*execSuite) TestUserCredential(c *gc.C) {
+	credential, err := exec.UserCredential("root")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(credential.UID, gc.Equals, uint32(0))
+	c.Assert(credential.GID, gc.Equals, uint32(0))
+	c.Assert(hasGroup(credential, 0), jc.IsTrue)
+
+	_, err = exec.UserCredential("no-such-user-i-hope")
+	c.Assert(err, jc.Satisfies, errors.IsUserNotFound)
+}
+
+func (s *execSuite) TestSudoCallerCredential(c *gc.C) {
+	s.PatchEnvironment("SUDO_UID", "")
+	s.PatchEnvironment("SUDO_GID", "")
+	_, err := exec.SudoCallerCredential()
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+
+	s.PatchEnvironment("SUDO_UID", "0")
+	s.PatchEnvironment("SUDO_GID", "0")
+	credential, err := exec.SudoCallerCredential()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(credential.UID, gc.Equals, uint32(0))
+	c.Assert(credential.GID, gc.Equals, uint32(0))
+	c.Assert(hasGroup(credential, 0), jc.IsTrue)
+
+	s.PatchEnvironment("SUDO_UID", "nobody")
+	_, err = exec.SudoCallerCredential()
+	c.Assert(err, gc.ErrorMatches, `invalid SUDO_UID "nobody": .*`)
+}
+
+func hasGroup(credential *exec.Credential, gid uint32) bool {
+	for _, group := range credential.Groups {
+		if group == gid {
+			return true
+		}
+	}
+	return false
+}
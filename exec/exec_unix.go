@@ -8,7 +8,14 @@ package exec
 
 import (
 	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
 	"syscall"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils"
 )
 
 // KillProcess tries to kill the process being ran by RunParams
@@ -28,4 +35,96 @@ func KillProcess(proc *os.Process) error {
 // can work correctly. For more information see Kill's comment.
 func (r *RunParams) populateSysProcAttr() {
 	r.ps.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if r.Credential != nil {
+		r.ps.SysProcAttr.Credential = &syscall.Credential{
+			Uid:    r.Credential.UID,
+			Gid:    r.Credential.GID,
+			Groups: r.Credential.Groups,
+		}
+	}
+}
+
+// chownAll gives the user the ownership of the given
+// directory and of everything it contains.
+func (c *Credential) chownAll(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chown(path, int(c.UID), int(c.GID))
+	})
+}
+
+// UserCredential returns the credential of the user with the given name,
+// including its supplementary groups.
+func UserCredential(username string) (*Credential, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return nil, errors.NewUserNotFound(err, "no such user")
+	}
+	return credentialForUser(u)
+}
+
+// SudoCallerCredential returns the credential of the user who ran sudo,
+// as given by the SUDO_UID and SUDO_GID environment variables, so that
+// commands can be run as that user rather than root. It returns an error
+// satisfying errors.IsNotFound if they are not set.
+//
+// Only the ids of the commands change: they inherit the environment of
+// the calling process unless RunParams.Environment is set, so that HOME,
+// USER, LOGNAME and the like still describe root.
+func SudoCallerCredential() (*Credential, error) {
+	uid, gid, err := utils.SudoCallerIds()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	credential := &Credential{
+		UID:    uint32(uid),
+		GID:    uint32(gid),
+		Groups: []uint32{uint32(gid)},
+	}
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		if groups, err := groupIds(u); err == nil {
+			credential.Groups = groups
+		}
+	}
+	return credential, nil
+}
+
+// credentialForUser returns the credential of the given user.
+func credentialForUser(u *user.User) (*Credential, error) {
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, errors.Errorf("invalid user id %q", u.Uid)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, errors.Errorf("invalid group id %q", u.Gid)
+	}
+	groups, err := groupIds(u)
+	if err != nil {
+		return nil, errors.Annotatef(err, "getting the groups of %q", u.Username)
+	}
+	return &Credential{
+		UID:    uint32(uid),
+		GID:    uint32(gid),
+		Groups: groups,
+	}, nil
+}
+
+// groupIds returns the IDs of the groups the given user belongs to.
+func groupIds(u *user.User) ([]uint32, error) {
+	ids, err := u.GroupIds()
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]uint32, 0, len(ids))
+	for _, id := range ids {
+		gid, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return nil, errors.Errorf("invalid group id %q", id)
+		}
+		groups = append(groups, uint32(gid))
+	}
+	return groups, nil
 }
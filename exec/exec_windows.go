@@ -8,6 +8,9 @@ package exec
 
 import (
 	"os"
+	"syscall"
+
+	"github.com/juju/errors"
 )
 
 // KillProcess tries to kill the process passed in.
@@ -15,5 +18,30 @@ func KillProcess(proc *os.Process) error {
 	return proc.Kill()
 }
 
-// populateSysProcAttr is a noop on windows
-func (r *RunParams) populateSysProcAttr() {}
+// populateSysProcAttr sets the token of the user the
+// commands are run as, if any.
+func (r *RunParams) populateSysProcAttr() {
+	if r.Credential != nil {
+		r.ps.SysProcAttr = &syscall.SysProcAttr{
+			Token: syscall.Token(r.Credential.Token),
+		}
+	}
+}
+
+// chownAll does nothing on windows, where the user is
+// granted access to files by their ACLs.
+func (c *Credential) chownAll(dir string) error {
+	return nil
+}
+
+// UserCredential is not supported on windows, where the
+// token of a user is obtained by logging it on.
+func UserCredential(username string) (*Credential, error) {
+	return nil, errors.NotSupportedf("user credentials on windows")
+}
+
+// SudoCallerCredential is not supported on windows,
+// where there is no sudo.
+func SudoCallerCredential() (*Credential, error) {
+	return nil, errors.NotSupportedf("sudo on windows")
+}
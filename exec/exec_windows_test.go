@@ -133,3 +133,39 @@ func (*execSuite) TestExecUnknownCommand(c *gc.C) {
 	// 1 is returned by RunCommands when powershell commands throw exceptions
 	c.Assert(result.Code, gc.Equals, 1)
 }
+
+func (*execSuite) TestRunPowerShellExitCodes(c *gc.C) {
+	for i, test := range []struct {
+		message  string
+		commands string
+		stdout   string
+		code     int
+	}{{
+		message:  "explicit exit",
+		commands: "echo 'before'\nexit 42\necho 'after'",
+		stdout:   "before\r\n",
+		code:     42,
+	}, {
+		message:  "exception",
+		commands: "throw 'oops'",
+		code:     1,
+	}, {
+		message:  "last native command",
+		commands: "cmd /c exit 3",
+		code:     3,
+	}, {
+		message:  "quotes",
+		commands: `echo "it's" 'a "test"'`,
+		stdout:   "it's\r\na \"test\"\r\n",
+	}} {
+		c.Logf("%v: %s", i, test.message)
+		result, err := exec.RunPowerShell(exec.PowerShellParams{
+			RunParams: exec.RunParams{Commands: test.commands},
+		})
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(result.Code, gc.Equals, test.code)
+		if test.stdout != "" {
+			c.Assert(string(result.Stdout), gc.Equals, test.stdout)
+		}
+	}
+}
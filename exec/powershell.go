@@ -0,0 +1,108 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package exec
+
+import (
+	"encoding/base64"
+	"runtime"
+	"unicode/utf16"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// DefaultExecutionPolicy is the execution policy of the PowerShell
+	// sessions run by RunPowerShell, which applies to the scripts
+	// the commands run.
+	DefaultExecutionPolicy = "RemoteSigned"
+
+	// maxEncodedCommandLength is the maximum length of the encoded
+	// commands, which leaves room for the rest of the command line
+	// within the limit of 32767 characters of windows.
+	maxEncodedCommandLength = 32000
+)
+
+// PowerShellParams holds the parameters of RunPowerShell. The embedded
+// RunParams hold the PowerShell commands and how they are run, and the
+// methods of RunParams can be used to run them.
+type PowerShellParams struct {
+	RunParams
+
+	// ExecutionPolicy is the execution policy of the PowerShell
+	// session. It defaults to DefaultExecutionPolicy.
+	ExecutionPolicy string
+
+	// Executable is the PowerShell executable. It defaults to
+	// "powershell.exe" on windows and to "pwsh", PowerShell Core,
+	// elsewhere.
+	Executable string
+}
+
+// Run starts PowerShell with the commands of the parameters passed as an
+// encoded command, which spares them from being quoted or written to a
+// file. The exit code of the commands is that of their explicit "exit",
+// or 1 if they throw an exception, or else that of the last native
+// command they run.
+func (p *PowerShellParams) Run() error {
+	p.RunParams.shellAndArgs = p.powerShellAndArgs
+	return p.RunParams.Run()
+}
+
+// powerShellAndArgs returns the PowerShell command and arguments
+// running the given script, as shellAndArgs does for the default shell.
+func (p *PowerShellParams) powerShellAndArgs(tempDir, script string) (string, []string, error) {
+	executable := p.Executable
+	if executable == "" {
+		executable = "pwsh"
+		if runtime.GOOS == "windows" {
+			executable = "powershell.exe"
+		}
+	}
+	policy := p.ExecutionPolicy
+	if policy == "" {
+		policy = DefaultExecutionPolicy
+	}
+	// Exceptions don't result in a non-zero exit code by default, and
+	// the exit code of native commands is only propagated when they
+	// are the last statement, so both are handled explicitly. An
+	// explicit "exit" ends the session with its own code.
+	script = "trap {Write-Error $_; exit 1}\n" +
+		"$global:LASTEXITCODE = 0\n" +
+		". {\n" + script + "\n}\n" +
+		"exit $global:LASTEXITCODE\n"
+	encoded := EncodePowerShellCommand(script)
+	if len(encoded) > maxEncodedCommandLength {
+		return "", nil, errors.Errorf("PowerShell commands too long to be encoded (%d bytes)", len(script))
+	}
+	return executable, []string{
+		"-NoProfile",
+		"-NonInteractive",
+		"-ExecutionPolicy", policy,
+		"-EncodedCommand", encoded,
+	}, nil
+}
+
+// EncodePowerShellCommand returns the given PowerShell commands encoded
+// as expected by the -EncodedCommand argument of PowerShell: the base64
+// encoding of their UTF-16LE representation.
+func EncodePowerShellCommand(commands string) string {
+	units := utf16.Encode([]rune(commands))
+	buf := make([]byte, 2*len(units))
+	for i, unit := range units {
+		buf[2*i] = byte(unit)
+		buf[2*i+1] = byte(unit >> 8)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// RunPowerShell executes the commands of the given parameters with
+// PowerShell, collecting stdout and stderr, as RunCommands does with the
+// default shell. If a non-zero return code is returned, this is collected
+// as the code for the response and this does not classify as an error.
+func RunPowerShell(params PowerShellParams) (*ExecResponse, error) {
+	if err := params.Run(); err != nil {
+		return nil, err
+	}
+	return params.Wait()
+}
@@ -0,0 +1,97 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package exec_test
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/exec"
+)
+
+func (*execSuite) TestEncodePowerShellCommand(c *gc.C) {
+	c.Assert(exec.EncodePowerShellCommand("Write-Output 'hi'"), gc.Equals,
+		"VwByAGkAdABlAC0ATwB1AHQAcAB1AHQAIAAnAGgAaQAnAA==")
+}
+
+// decodePowerShellCommand reverses exec.EncodePowerShellCommand.
+func decodePowerShellCommand(c *gc.C, encoded string) string {
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	c.Assert(err, jc.ErrorIsNil)
+	units := make([]uint16, len(buf)/2)
+	for i := range units {
+		units[i] = uint16(buf[2*i]) | uint16(buf[2*i+1])<<8
+	}
+	return string(utf16.Decode(units))
+}
+
+// fakePowerShell writes an executable which prints its
+// arguments, one per line, and exits with the given code.
+func fakePowerShell(c *gc.C, code string) string {
+	if runtime.GOOS == "windows" {
+		c.Skip("the fake PowerShell is a shell script")
+	}
+	executable := filepath.Join(c.MkDir(), "pwsh")
+	script := "#!/bin/bash\nprintf '%s\\n' \"$@\"\nexit " + code + "\n"
+	err := ioutil.WriteFile(executable, []byte(script), 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	return executable
+}
+
+func (*execSuite) TestRunPowerShellArgs(c *gc.C) {
+	result, err := exec.RunPowerShell(exec.PowerShellParams{
+		RunParams: exec.RunParams{
+			Commands: "Get-ChildItem \"C:\\Program Files\" | Select-Object -First 1",
+		},
+		ExecutionPolicy: "Bypass",
+		Executable:      fakePowerShell(c, "0"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result.Code, gc.Equals, 0)
+	args := strings.Split(strings.TrimSuffix(string(result.Stdout), "\n"), "\n")
+	c.Assert(args, gc.HasLen, 6)
+	c.Assert(args[:5], gc.DeepEquals, []string{
+		"-NoProfile",
+		"-NonInteractive",
+		"-ExecutionPolicy", "Bypass",
+		"-EncodedCommand",
+	})
+	c.Assert(decodePowerShellCommand(c, args[5]), gc.Equals, `trap {Write-Error $_; exit 1}
+$global:LASTEXITCODE = 0
+. {
+Get-ChildItem "C:\Program Files" | Select-Object -First 1
+}
+exit $global:LASTEXITCODE
+`)
+}
+
+func (*execSuite) TestRunPowerShellDefaultPolicy(c *gc.C) {
+	params := exec.PowerShellParams{
+		RunParams:  exec.RunParams{Commands: "exit 3"},
+		Executable: fakePowerShell(c, "3"),
+	}
+	err := params.Run()
+	c.Assert(err, jc.ErrorIsNil)
+	result, err := params.WaitWithCancel(nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result.Code, gc.Equals, 3)
+	c.Assert(string(result.Stdout), jc.Contains, "-ExecutionPolicy\nRemoteSigned\n")
+}
+
+func (*execSuite) TestRunPowerShellTooLong(c *gc.C) {
+	_, err := exec.RunPowerShell(exec.PowerShellParams{
+		RunParams: exec.RunParams{
+			Commands: strings.Repeat("#", 20000),
+		},
+		Executable: "pwsh",
+	})
+	c.Assert(err, gc.ErrorMatches, `PowerShell commands too long to be encoded \(\d+ bytes\)`)
+}
@@ -13,6 +13,10 @@ var (
 	Dial              = dial
 	NetDial           = &netDial
 	ResolveSudoByFunc = resolveSudo
+	UUIDNow           = &uuidNow
+	ULIDNow           = &ulidNow
+	InterfaceAddrs    = &interfaceAddrs
+	DigestCnonce      = &digestCnonce
 )
 
 func ExposeBackoffTimerDuration(bot *BackoffTimer) time.Duration {
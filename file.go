@@ -4,6 +4,7 @@
 package utils
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -107,7 +108,19 @@ func CopyFile(dest, source string) error {
 // given contents and calls the given function after the contents were
 // written, but before the file is renamed.
 func AtomicWriteFileAndChange(filename string, contents []byte, change func(*os.File) error) (err error) {
+	return atomicWriteFile(filename, bytes.NewReader(contents), change)
+}
+
+// atomicWriteFile writes the contents read from the given reader to a
+// temporary file in the directory of filename, calls the given function,
+// flushes the file to disk and renames it to filename.
+func atomicWriteFile(filename string, r io.Reader, change func(*os.File) error) (err error) {
 	dir, file := filepath.Split(filename)
+	if dir == "" {
+		// TempFile would otherwise create the file in the temporary
+		// directory, which may not be on the same filesystem.
+		dir = "."
+	}
 	f, err := ioutil.TempFile(dir, file)
 	if err != nil {
 		return fmt.Errorf("cannot create temp file: %v", err)
@@ -122,16 +135,24 @@ func AtomicWriteFileAndChange(filename string, contents []byte, change func(*os.
 			os.Remove(f.Name())
 		}
 	}()
-	if _, err := f.Write(contents); err != nil {
+	if _, err := io.Copy(f, r); err != nil {
 		return fmt.Errorf("cannot write %q contents: %v", filename, err)
 	}
 	if err := change(f); err != nil {
 		return err
 	}
+	// Make sure the contents are on disk before the rename is, lest a
+	// crash leaves an empty file behind.
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("cannot sync %q contents: %v", filename, err)
+	}
 	f.Close()
 	if err := ReplaceFile(f.Name(), filename); err != nil {
 		return fmt.Errorf("cannot replace %q with %q: %v", f.Name(), filename, err)
 	}
+	// The file was replaced anyway, so failing to make the rename
+	// durable is not reported.
+	syncDir(dir)
 	return nil
 }
 
@@ -139,11 +160,44 @@ func AtomicWriteFileAndChange(filename string, contents []byte, change func(*os.
 // contents and permissions, replacing any existing file at the same
 // path.
 func AtomicWriteFile(filename string, contents []byte, perms os.FileMode) (err error) {
-	return AtomicWriteFileAndChange(filename, contents, func(f *os.File) error {
+	return AtomicWriteFileAndChange(filename, contents, chmodChange(perms))
+}
+
+// AtomicWriteFileFromReader atomically writes the filename with the
+// contents read from the given reader and the given permissions,
+// replacing any existing file at the same path.
+func AtomicWriteFileFromReader(filename string, r io.Reader, perms os.FileMode) error {
+	return atomicWriteFile(filename, r, chmodChange(perms))
+}
+
+// AtomicUpdateFile atomically replaces the contents of the filename with
+// those the given function returns for its current contents, which are
+// nil if the file does not exist yet. An existing file keeps its
+// permissions, and a new one gets the given permissions. Concurrent
+// updates of the same file are not serialised: the last one wins.
+func AtomicUpdateFile(filename string, perms os.FileMode, update func([]byte) ([]byte, error)) error {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if info, err := os.Stat(filename); err == nil {
+		perms = info.Mode().Perm()
+	}
+	contents, err = update(contents)
+	if err != nil {
+		return err
+	}
+	return AtomicWriteFile(filename, contents, perms)
+}
+
+// chmodChange returns a function, to be given to AtomicWriteFileAndChange,
+// which sets the given permissions.
+func chmodChange(perms os.FileMode) func(*os.File) error {
+	return func(f *os.File) error {
 		// FileMod.Chmod() is not implemented on Windows, however, os.Chmod() is
 		if err := os.Chmod(f.Name(), perms); err != nil {
 			return fmt.Errorf("cannot set permissions: %v", err)
 		}
 		return nil
-	})
+	}
 }
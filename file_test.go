@@ -4,11 +4,13 @@
 package utils_test
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
+	"runtime"
 
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
@@ -131,6 +133,24 @@ var atomicWriteFileTests = []struct {
 		return utils.AtomicWriteFileAndChange(filename, contents, errChange)
 	},
 	expectErr: "pow!",
+}, {
+	summary: "atomic file write from reader",
+	change: func(filename string, contents []byte) error {
+		return utils.AtomicWriteFileFromReader(filename, bytes.NewReader(contents), 0640)
+	},
+	check: func(c *gc.C, fi os.FileInfo) {
+		c.Assert(fi.Mode(), gc.Equals, 0640)
+	},
+}, {
+	summary: "atomic file update",
+	change: func(filename string, contents []byte) error {
+		return utils.AtomicUpdateFile(filename, 0600, func([]byte) ([]byte, error) {
+			return contents, nil
+		})
+	},
+	check: func(c *gc.C, fi os.FileInfo) {
+		c.Assert(fi.Mode(), gc.Equals, 0600)
+	},
 }}
 
 func (*fileSuite) TestAtomicWriteFile(c *gc.C) {
@@ -184,6 +204,38 @@ func (*fileSuite) TestAtomicWriteFile(c *gc.C) {
 	}
 }
 
+func (*fileSuite) TestAtomicUpdateFile(c *gc.C) {
+	path := filepath.Join(c.MkDir(), "test.file")
+	appendLine := func(line string) func([]byte) ([]byte, error) {
+		return func(contents []byte) ([]byte, error) {
+			return append(contents, line+"\n"...), nil
+		}
+	}
+
+	err := utils.AtomicUpdateFile(path, 0600, appendLine("first"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(os.Chmod(path, 0640), jc.ErrorIsNil)
+	err = utils.AtomicUpdateFile(path, 0600, appendLine("second"))
+	c.Assert(err, jc.ErrorIsNil)
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, "first\nsecond\n")
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(path)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(fi.Mode(), gc.Equals, os.FileMode(0640))
+	}
+
+	err = utils.AtomicUpdateFile(path, 0600, func([]byte) ([]byte, error) {
+		return nil, fmt.Errorf("pow!")
+	})
+	c.Assert(err, gc.ErrorMatches, "pow!")
+	data, err = ioutil.ReadFile(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, "first\nsecond\n")
+}
+
 func (*fileSuite) TestMoveFile(c *gc.C) {
 	d := c.MkDir()
 	dest := filepath.Join(d, "foo")
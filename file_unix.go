@@ -48,6 +48,17 @@ func ReplaceFile(source, destination string) error {
 	return os.Rename(source, destination)
 }
 
+// syncDir flushes the entries of the given directory to disk,
+// making the files renamed into it survive crashes.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
 // MakeFileURL returns a file URL if a directory is passed in else it does nothing
 func MakeFileURL(in string) string {
 	if strings.HasPrefix(in, "/") {
@@ -73,3 +84,37 @@ func ChownPath(path, username string) error {
 	}
 	return os.Chown(path, uid, gid)
 }
+
+// SudoCallerIds returns the user and group ids of the user who ran
+// sudo, as given by the SUDO_UID and SUDO_GID environment variables.
+// It returns an error satisfying errors.IsNotFound if they are not set.
+func SudoCallerIds() (uid int, gid int, err error) {
+	sudoUID, sudoGID := os.Getenv("SUDO_UID"), os.Getenv("SUDO_GID")
+	if sudoUID == "" || sudoGID == "" {
+		return 0, 0, errors.NotFoundf("SUDO_UID and SUDO_GID")
+	}
+	id, err := strconv.ParseUint(sudoUID, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid SUDO_UID %q: %v", sudoUID, err)
+	}
+	uid = int(id)
+	id, err = strconv.ParseUint(sudoGID, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid SUDO_GID %q: %v", sudoGID, err)
+	}
+	gid = int(id)
+	return uid, gid, nil
+}
+
+// chownSudoUser sets the uid and gid of path to those of the user who
+// ran sudo, as returned by SudoCallerIds. It does nothing if sudo was
+// not used.
+func chownSudoUser(path string) error {
+	uid, gid, err := SudoCallerIds()
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return os.Chown(path, uid, gid)
+}
@@ -6,12 +6,16 @@
 package utils_test
 
 import (
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
 	"github.com/juju/utils"
 )
 
 type unixFileSuite struct {
+	testing.IsolationSuite
 }
 
 var _ = gc.Suite(&unixFileSuite{})
@@ -21,3 +25,19 @@ func (s *unixFileSuite) TestEnsureBaseDir(c *gc.C) {
 	c.Assert(utils.EnsureBaseDir(`/`, `/b/c`), gc.Equals, `/b/c`)
 	c.Assert(utils.EnsureBaseDir(``, `/b/c`), gc.Equals, `/b/c`)
 }
+
+func (s *unixFileSuite) TestSudoCallerIds(c *gc.C) {
+	_, _, err := utils.SudoCallerIds()
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+
+	s.PatchEnvironment("SUDO_UID", "1000")
+	s.PatchEnvironment("SUDO_GID", "1001")
+	uid, gid, err := utils.SudoCallerIds()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(uid, gc.Equals, 1000)
+	c.Assert(gid, gc.Equals, 1001)
+
+	s.PatchEnvironment("SUDO_GID", "-1")
+	_, _, err = utils.SudoCallerIds()
+	c.Assert(err, gc.ErrorMatches, `invalid SUDO_GID "-1": .*`)
+}
@@ -62,6 +62,12 @@ func ReplaceFile(source, destination string) error {
 	return nil
 }
 
+// syncDir does nothing on Windows, where directories cannot be flushed;
+// ReplaceFile already writes renames through to disk.
+func syncDir(dir string) error {
+	return nil
+}
+
 // MakeFileURL returns a proper file URL for the given path/directory
 func MakeFileURL(in string) string {
 	in = filepath.ToSlash(in)
@@ -140,3 +146,14 @@ func ChownPath(path, username string) error {
 	// way and hasn't yet been implemented.
 	return nil
 }
+
+// SudoCallerIds returns an error satisfying errors.IsNotFound
+// on Windows, where there is no sudo.
+func SudoCallerIds() (uid int, gid int, err error) {
+	return 0, 0, errors.NotFoundf("sudo on windows")
+}
+
+// chownSudoUser is not implemented for Windows, where there is no sudo.
+func chownSudoUser(path string) error {
+	return nil
+}
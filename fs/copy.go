@@ -6,6 +6,7 @@ package fs
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 )
@@ -106,3 +107,188 @@ func copyDir(src, dst string, mode os.FileMode) error {
 	}
 	return nil
 }
+
+// OverwritePolicy determines what CopyDir does with the files
+// which already exist at the destination.
+type OverwritePolicy int
+
+const (
+	// OverwriteNever makes CopyDir fail on existing files.
+	OverwriteNever OverwritePolicy = iota
+
+	// OverwriteSkip makes CopyDir leave existing files alone.
+	OverwriteSkip
+
+	// OverwriteAlways makes CopyDir replace existing files.
+	OverwriteAlways
+
+	// OverwriteIfNewer makes CopyDir replace the existing files
+	// which were modified before their source.
+	OverwriteIfNewer
+)
+
+// CopyOptions holds the options of CopyDir.
+type CopyOptions struct {
+	// Exclude holds the patterns, in the syntax of filepath.Match, of
+	// the entries which are not copied, along with their contents for
+	// directories. They are matched against both the base names of the
+	// entries and their paths relative to the source directory.
+	Exclude []string
+
+	// Overwrite determines what is done with existing files.
+	Overwrite OverwritePolicy
+
+	// PreserveTimes signals whether the copied files and
+	// directories get the modification times of their source.
+	PreserveTimes bool
+
+	// FollowSymlinks signals whether the targets of symbolic links
+	// are copied, rather than the links themselves.
+	FollowSymlinks bool
+}
+
+// CopyDir recursively copies the contents of the directory at src into
+// the directory at dst, which is created if it does not exist, according
+// to the given options. Permissions are always preserved.
+//
+// If the copy fails half way through, the destination might be left
+// partially written.
+func CopyDir(src, dst string, options CopyOptions) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%q is not a directory", src)
+	}
+	return copyTree(src, dst, "", info, options)
+}
+
+// copyTree copies the directory at src, whose path relative to
+// the source directory of CopyDir is rel, to dst.
+func copyTree(src, dst, rel string, info os.FileInfo, options CopyOptions) error {
+	dstInfo, err := os.Lstat(dst)
+	created := os.IsNotExist(err)
+	switch {
+	case created:
+		// Make sure we have permission to create the contents of the
+		// directory. We'll make the permissions match at the end.
+		if err := os.Mkdir(dst, 0700); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case !dstInfo.IsDir():
+		return fmt.Errorf("cannot overwrite %q with a directory", dst)
+	}
+
+	entries, err := ioutil.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("error reading directory %q: %v", src, err)
+	}
+	for _, entry := range entries {
+		entryRel := filepath.Join(rel, entry.Name())
+		excluded, err := options.excluded(entryRel)
+		if err != nil {
+			return err
+		} else if excluded {
+			continue
+		}
+
+		entrySrc := filepath.Join(src, entry.Name())
+		entryDst := filepath.Join(dst, entry.Name())
+		if entry.Mode()&os.ModeSymlink != 0 && options.FollowSymlinks {
+			if entry, err = os.Stat(entrySrc); err != nil {
+				return err
+			}
+		}
+		if entry.IsDir() {
+			err = copyTree(entrySrc, entryDst, entryRel, entry, options)
+		} else {
+			err = copyEntry(entrySrc, entryDst, entry, options)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if created {
+		if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return options.preserveTimes(dst, info)
+}
+
+// copyEntry copies the file or symbolic link at src to dst.
+func copyEntry(src, dst string, info os.FileInfo, options CopyOptions) error {
+	if write, err := options.overwrite(dst, info); err != nil || !write {
+		return err
+	}
+	switch mode := info.Mode(); mode & os.ModeType {
+	case os.ModeSymlink:
+		return copySymLink(src, dst)
+	case 0:
+		if err := copyFile(src, dst, mode); err != nil {
+			return err
+		}
+		return options.preserveTimes(dst, info)
+	default:
+		return fmt.Errorf("cannot copy file with mode %v", mode)
+	}
+}
+
+// excluded returns whether the entry at the given
+// relative path matches any of the excluded patterns.
+func (options CopyOptions) excluded(rel string) (bool, error) {
+	for _, pattern := range options.Exclude {
+		for _, name := range []string{filepath.Base(rel), rel} {
+			matched, err := filepath.Match(pattern, name)
+			if err != nil {
+				return false, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// overwrite returns whether the given source entry should be written
+// to dst, which is removed if it exists and must be overwritten.
+func (options CopyOptions) overwrite(dst string, info os.FileInfo) (bool, error) {
+	dstInfo, err := os.Lstat(dst)
+	if os.IsNotExist(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	switch options.Overwrite {
+	case OverwriteSkip:
+		return false, nil
+	case OverwriteAlways:
+	case OverwriteIfNewer:
+		if !info.ModTime().After(dstInfo.ModTime()) {
+			return false, nil
+		}
+	default:
+		return false, fmt.Errorf("will not overwrite %q", dst)
+	}
+	if dstInfo.IsDir() {
+		return false, fmt.Errorf("cannot overwrite directory %q", dst)
+	}
+	// Remove the existing file rather than writing through it, as
+	// it may be a symbolic link or lack write permission.
+	return true, os.Remove(dst)
+}
+
+// preserveTimes gives the entry at dst the modification
+// time of the given source entry, if required.
+func (options CopyOptions) preserveTimes(dst string, info os.FileInfo) error {
+	if !options.PreserveTimes {
+		return nil
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
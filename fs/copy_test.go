@@ -4,8 +4,10 @@
 package fs_test
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	ft "github.com/juju/testing/filetesting"
 	gc "gopkg.in/check.v1"
@@ -86,3 +88,188 @@ func (*copySuite) TestCopy(c *gc.C) {
 		}
 	}
 }
+
+var copyDirTests = []struct {
+	about   string
+	options fs.CopyOptions
+	src     ft.Entries
+	dst     ft.Entries
+	expect  ft.Entries
+	err     string
+}{{
+	about: "new destination",
+	src: []ft.Entry{
+		ft.File{"foo", "foodata", 0644},
+		ft.Dir{"next", 0721},
+		ft.Symlink{"next/link", "../foo"},
+	},
+	expect: []ft.Entry{
+		ft.File{"foo", "foodata", 0644},
+		ft.Dir{"next", 0721},
+		ft.Symlink{"next/link", "../foo"},
+	},
+}, {
+	about: "merge with destination",
+	src: []ft.Entry{
+		ft.Dir{"next", 0755},
+		ft.File{"next/foo", "foodata", 0644},
+	},
+	dst: []ft.Entry{
+		ft.Dir{"next", 0755},
+		ft.File{"next/bar", "bardata", 0600},
+	},
+	expect: []ft.Entry{
+		ft.File{"next/foo", "foodata", 0644},
+		ft.File{"next/bar", "bardata", 0600},
+	},
+}, {
+	about: "existing file",
+	src: []ft.Entry{
+		ft.File{"foo", "foodata", 0644},
+	},
+	dst: []ft.Entry{
+		ft.File{"foo", "old", 0644},
+	},
+	err: `will not overwrite ".+foo"`,
+}, {
+	about:   "existing file skipped",
+	options: fs.CopyOptions{Overwrite: fs.OverwriteSkip},
+	src: []ft.Entry{
+		ft.File{"foo", "foodata", 0644},
+		ft.File{"bar", "bardata", 0644},
+	},
+	dst: []ft.Entry{
+		ft.File{"foo", "old", 0600},
+	},
+	expect: []ft.Entry{
+		ft.File{"foo", "old", 0600},
+		ft.File{"bar", "bardata", 0644},
+	},
+}, {
+	about:   "existing file overwritten",
+	options: fs.CopyOptions{Overwrite: fs.OverwriteAlways},
+	src: []ft.Entry{
+		ft.File{"foo", "foodata", 0644},
+		ft.File{"link", "linkdata", 0644},
+	},
+	dst: []ft.Entry{
+		ft.File{"foo", "old", 0400},
+		ft.Symlink{"link", "foo"},
+	},
+	expect: []ft.Entry{
+		ft.File{"foo", "foodata", 0644},
+		ft.File{"link", "linkdata", 0644},
+	},
+}, {
+	about:   "existing directory not overwritten",
+	options: fs.CopyOptions{Overwrite: fs.OverwriteAlways},
+	src: []ft.Entry{
+		ft.File{"foo", "foodata", 0644},
+	},
+	dst: []ft.Entry{
+		ft.Dir{"foo", 0755},
+	},
+	err: `cannot overwrite directory ".+foo"`,
+}, {
+	about: "excluded entries",
+	options: fs.CopyOptions{
+		Exclude: []string{"*.pyc", "next/cache"},
+	},
+	src: []ft.Entry{
+		ft.File{"foo.py", "foodata", 0644},
+		ft.File{"foo.pyc", "foocode", 0644},
+		ft.Dir{"next", 0755},
+		ft.File{"next/bar.pyc", "barcode", 0644},
+		ft.Dir{"next/cache", 0755},
+		ft.File{"next/cache/data", "data", 0644},
+	},
+	expect: []ft.Entry{
+		ft.File{"foo.py", "foodata", 0644},
+		ft.Removed{"foo.pyc"},
+		ft.Dir{"next", 0755},
+		ft.Removed{"next/bar.pyc"},
+		ft.Removed{"next/cache"},
+	},
+}, {
+	about:   "followed symlinks",
+	options: fs.CopyOptions{FollowSymlinks: true},
+	src: []ft.Entry{
+		ft.File{"foo", "foodata", 0644},
+		ft.Symlink{"link", "foo"},
+	},
+	expect: []ft.Entry{
+		ft.File{"foo", "foodata", 0644},
+		ft.File{"link", "foodata", 0644},
+	},
+}, {
+	about:   "invalid pattern",
+	options: fs.CopyOptions{Exclude: []string{"["}},
+	src: []ft.Entry{
+		ft.File{"foo", "foodata", 0644},
+	},
+	err: `invalid pattern "\[": .*`,
+}}
+
+func (*copySuite) TestCopyDir(c *gc.C) {
+	for i, test := range copyDirTests {
+		c.Logf("test %d: %v", i, test.about)
+		src, dst := c.MkDir(), c.MkDir()
+		test.src.Create(c, src)
+		test.dst.Create(c, dst)
+		err := fs.CopyDir(src, filepath.Join(dst, "."), test.options)
+		if test.err != "" {
+			c.Check(err, gc.ErrorMatches, test.err)
+		} else {
+			c.Assert(err, gc.IsNil)
+			test.expect.Check(c, dst)
+		}
+	}
+}
+
+func (*copySuite) TestCopyDirNewDestination(c *gc.C) {
+	src := c.MkDir()
+	ft.Entries{ft.Dir{"dir", 0700}, ft.File{"dir/foo", "foodata", 0644}}.Create(c, src)
+	dst := filepath.Join(c.MkDir(), "copy")
+
+	err := fs.CopyDir(filepath.Join(src, "dir"), dst, fs.CopyOptions{})
+	c.Assert(err, gc.IsNil)
+	ft.Entries{ft.Dir{"copy", 0700}, ft.File{"copy/foo", "foodata", 0644}}.Check(c, filepath.Dir(dst))
+}
+
+func (*copySuite) TestCopyDirTimes(c *gc.C) {
+	src, dst := c.MkDir(), c.MkDir()
+	ft.Entries{ft.Dir{"dir", 0755}, ft.File{"dir/foo", "foodata", 0644}}.Create(c, src)
+	mtime := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, path := range []string{"dir/foo", "dir"} {
+		err := os.Chtimes(filepath.Join(src, path), mtime, mtime)
+		c.Assert(err, gc.IsNil)
+	}
+
+	err := fs.CopyDir(src, dst, fs.CopyOptions{PreserveTimes: true})
+	c.Assert(err, gc.IsNil)
+	for _, path := range []string{"dir/foo", "dir"} {
+		info, err := os.Stat(filepath.Join(dst, path))
+		c.Assert(err, gc.IsNil)
+		c.Check(info.ModTime().Equal(mtime), gc.Equals, true, gc.Commentf("%s", path))
+	}
+}
+
+func (*copySuite) TestCopyDirIfNewer(c *gc.C) {
+	src, dst := c.MkDir(), c.MkDir()
+	ft.Entries{ft.File{"old", "new", 0644}, ft.File{"new", "new", 0644}}.Create(c, src)
+	ft.Entries{ft.File{"old", "old", 0644}, ft.File{"new", "old", 0644}}.Create(c, dst)
+	older, newer := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	c.Assert(os.Chtimes(filepath.Join(src, "old"), older, older), gc.IsNil)
+	c.Assert(os.Chtimes(filepath.Join(src, "new"), newer, newer), gc.IsNil)
+
+	err := fs.CopyDir(src, dst, fs.CopyOptions{Overwrite: fs.OverwriteIfNewer})
+	c.Assert(err, gc.IsNil)
+	ft.Entries{ft.File{"old", "old", 0644}, ft.File{"new", "new", 0644}}.Check(c, dst)
+}
+
+func (*copySuite) TestCopyDirNotDirectory(c *gc.C) {
+	src := c.MkDir()
+	ft.Entries{ft.File{"foo", "foodata", 0644}}.Create(c, src)
+	err := fs.CopyDir(filepath.Join(src, "foo"), c.MkDir(), fs.CopyOptions{})
+	c.Assert(err, gc.ErrorMatches, `".+foo" is not a directory`)
+}
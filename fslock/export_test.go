@@ -5,6 +5,8 @@ package fslock
 
 type OnDisk onDisk
 
+var ProcessExists = &processExists
+
 func IsAlive(lock *Lock, PID int) bool {
 	return lock.isAlive(PID)
 }
@@ -16,3 +18,10 @@ func DeclareDead(lock *Lock) {
 func AliveFile(lock *Lock) string {
 	return lock.aliveFile(lock.PID)
 }
+
+// CloseLockFile closes the file locked by the given lock without
+// unlocking it, as happens when its process dies.
+func CloseLockFile(lock *Lock) {
+	lock.file.Close()
+	lock.file = nil
+}
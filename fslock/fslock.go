@@ -8,6 +8,14 @@
 // temporary directory into place.  We use temporary directories because for
 // all filesystems we believe that exactly one attempt to claim the lock will
 // succeed and the others will fail.
+//
+// Alternatively, with the OSBackend, a lock is represented by a file which
+// is locked with the advisory locks of the operating system, which are
+// released by the kernel when their holder exits, however it exits.
+//
+// A lock may also be held shared, by any number of holders at once, as long
+// as it is not held exclusively. Every shared holder is represented by an
+// information file in a directory next to the lock directory.
 package fslock
 
 import (
@@ -32,6 +40,12 @@ const (
 	// NameRegexp specifies the regular expression used to identify valid lock names.
 	NameRegexp   = "^[a-z]+[a-z0-9.-]*$"
 	heldFilename = "held"
+
+	// aliveRefreshDelays is the number of wait delays after which
+	// alive files are refreshed. Locks are not checked for staleness
+	// more often while they are waited for, as their holders could
+	// not be seen alive or dead any sooner.
+	aliveRefreshDelays = 5
 )
 
 var (
@@ -39,6 +53,10 @@ var (
 
 	// ErrLockNotHeld is returned by Unlock if the lock file is not held by this lock
 	ErrLockNotHeld = errors.New("lock not held")
+
+	// ErrLockHeld is returned when trying to acquire a lock shared while
+	// this lock holds it, or exclusively while this lock holds it shared.
+	ErrLockHeld = errors.New("lock already held")
 	// ErrTimeout is returned by LockWithTimeout if the lock could not be obtained before the given deadline
 	ErrTimeout = errors.New("lock timeout exceeded")
 
@@ -58,8 +76,30 @@ type LockConfig struct {
 	// ReadRetryTimeout is how long to wait after trying to examine a lock
 	// and not finding it before trying again.
 	ReadRetryTimeout time.Duration
+	// TTL, if positive, is how long after being acquired a lock is
+	// considered stale, whether its holder is still alive or not.
+	// It does not apply to the OSBackend.
+	TTL time.Duration
+	// Backend selects how the lock is represented on disk. All the
+	// users of a lock must use the same backend.
+	Backend Backend
 }
 
+// Backend identifies how locks are represented on disk.
+type Backend int
+
+const (
+	// DirectoryBackend represents a lock by a directory, which is
+	// left behind when its holder dies and is then broken once the
+	// holder is found dead. It is the default.
+	DirectoryBackend Backend = iota
+
+	// OSBackend represents a lock by a file locked with flock on
+	// unix and LockFileEx on windows, which the kernel releases when
+	// its holder dies. Locks held by other processes cannot be broken.
+	OSBackend
+)
+
 // Defaults generates a LockConfig pre-filled with sensible defaults.
 func Defaults() LockConfig {
 	return LockConfig{
@@ -82,12 +122,36 @@ type Lock struct {
 	waitDelay              time.Duration
 	lividityTimeout        time.Duration
 	readRetryTimeout       time.Duration
+	ttl                    time.Duration
+	backend                Backend
+	hostname               string
 	sanityCheck            chan struct{}
+
+	// file is the locked file of the OSBackend,
+	// and fileShared whether it is locked shared.
+	file       *os.File
+	fileShared bool
 }
 
 type onDisk struct {
-	Nonce   string
-	PID     int
+	Nonce    string
+	PID      int
+	Message  string
+	Hostname string    `yaml:",omitempty"`
+	Acquired time.Time `yaml:",omitempty"`
+}
+
+// LockInfo describes the holder of a lock.
+type LockInfo struct {
+	// PID is the process ID of the holder.
+	PID int
+	// Hostname is the name of the machine the holder runs on. It is
+	// empty for locks taken by older versions of this package.
+	Hostname string
+	// Acquired is when the lock was acquired. It is the zero time for
+	// locks taken by older versions of this package.
+	Acquired time.Time
+	// Message is the message the lock was taken with.
 	Message string
 }
 
@@ -112,8 +176,14 @@ func NewLock(lockDir, name string, cfg LockConfig) (*Lock, error) {
 		waitDelay:            cfg.WaitDelay,
 		lividityTimeout:      cfg.LividityTimeout,
 		readRetryTimeout:     cfg.ReadRetryTimeout,
+		ttl:                  cfg.TTL,
+		backend:              cfg.Backend,
 		sanityCheck:          make(chan struct{}),
 	}
+	// The host name tells whether the holder of a lock runs on this
+	// machine, where its process can be looked for. A lock directory
+	// shared between machines works without it.
+	lock.hostname, _ = os.Hostname()
 	// Ensure the parent exists.
 	if err := os.MkdirAll(lock.parent, 0755); err != nil {
 		return nil, err
@@ -149,7 +219,7 @@ func (lock *Lock) isAlive(PID int) bool {
 	for i := 0; i < 10; i++ {
 		aliveInfo, err := os.Lstat(lock.aliveFile(PID))
 		if err == nil {
-			return time.Now().Before(aliveInfo.ModTime().Add(lock.lividityTimeout))
+			return lock.clock.Now().Before(aliveInfo.ModTime().Add(lock.lividityTimeout))
 		}
 		time.Sleep(lock.readRetryTimeout)
 	}
@@ -159,20 +229,27 @@ func (lock *Lock) isAlive(PID int) bool {
 // createAliveFile kicks off a gorouteine that creates a proof of life file
 // and keeps its timestamp current.
 func (lock *Lock) createAliveFile() {
+	lock.keepAlive(lock.aliveFile(lock.PID), true)
+}
+
+// keepAlive kicks off a goroutine that keeps the timestamp of the given
+// proof of life file current, after creating it if create is true.
+func (lock *Lock) keepAlive(aliveFile string, create bool) {
 	lock.createAliveFileRunning.Add(1)
 	close(lock.sanityCheck)
 	go func() {
 		defer lock.createAliveFileRunning.Done()
 
-		aliveFile := lock.aliveFile(lock.PID)
-		if err := ioutil.WriteFile(aliveFile, []byte{}, 644); err != nil {
-			return
+		if create {
+			if err := ioutil.WriteFile(aliveFile, []byte{}, 644); err != nil {
+				return
+			}
 		}
 
 		for {
 			select {
-			case <-time.After(5 * lock.waitDelay):
-				now := time.Now()
+			case <-time.After(aliveRefreshDelays * lock.waitDelay):
+				now := lock.clock.Now()
 				if err := os.Chtimes(aliveFile, now, now); err != nil {
 					return
 				}
@@ -196,25 +273,88 @@ func (lock *Lock) declareDead() {
 // juju process that is older than the lock file, the lock is left in place, else
 // the lock is removed.
 func (lock *Lock) clean() error {
-	// If a lock exists, see if it is stale
+	_, err := lock.BreakIfStale()
+	return err
+}
+
+// isStale returns whether the lock described by the given information
+// is stale: its TTL expired, its holder did not prove it is alive, or
+// its holder runs on this machine and its process does not exist.
+func (lock *Lock) isStale(lockInfo onDisk) bool {
+	if lock.ttl > 0 && !lockInfo.Acquired.IsZero() &&
+		lock.clock.Now().After(lockInfo.Acquired.Add(lock.ttl)) {
+		logger.Debugf("Lock %q expired", lock.name)
+		return true
+	}
+	localHolder := lockInfo.Hostname == "" || lockInfo.Hostname == lock.hostname
+	if lockInfo.PID == lock.PID && localHolder {
+		return false
+	}
+	if lockInfo.Hostname != "" && localHolder && !processExists(lockInfo.PID) {
+		logger.Debugf("Lock %q holder process %d not found", lock.name, lockInfo.PID)
+		return true
+	}
+	return !lock.isAlive(lockInfo.PID)
+}
+
+// BreakIfStale breaks the lock if it is held and stale, which is when
+// its TTL expired, its holder stopped proving it is alive, or its holder
+// runs on this machine and its process does not exist anymore. It
+// returns whether the lock was broken.
+func (lock *Lock) BreakIfStale() (bool, error) {
+	if lock.backend == OSBackend {
+		// the lock was released if its holder died.
+		return false, nil
+	}
 	lockInfo, err := lock.readLock()
 	if err != nil {
-		return nil
+		// the lock is not held, or is being acquired or released.
+		return false, nil
 	}
-
-	if lock.isAlive(lockInfo.PID) {
-		// lock is current. Do nothing.
+	if !lock.isStale(lockInfo) {
 		logger.Debugf("Lock alive")
-		return nil
+		return false, nil
 	}
 
-	logger.Debugf("Lock dead")
-	return lock.BreakLock()
+	// The lock may have been released and acquired again since it was
+	// read, so the lock directory is first moved out of the way, which
+	// also keeps a holder still refreshing its alive file from writing
+	// into the lock directory, and only removed if it is the stale one.
+	// The temporary name is unique to the stale lock and the receiver,
+	// as other locks may be breaking it too.
+	tempLockName := fmt.Sprintf(".%s.%s.%s", lock.name, lockInfo.Nonce, lock.nonce)
+	tempDirName := path.Join(lock.parent, tempLockName)
+	if err := utils.ReplaceFile(lock.lockDir(), tempDirName); os.IsNotExist(err) {
+		// the lock was released or broken meanwhile.
+		return false, nil
+	} else if err != nil {
+		return false, errors.Annotatef(err, "breaking stale lock %q", lock.name)
+	}
+	moved, err := readLockFrom(path.Join(tempDirName, heldFilename))
+	if err != nil || moved.Nonce != lockInfo.Nonce {
+		// the lock was acquired again, and is put back.
+		if err := utils.ReplaceFile(tempDirName, lock.lockDir()); err != nil {
+			return false, errors.Annotatef(err, "restoring lock %q", lock.name)
+		}
+		return false, nil
+	}
+	logger.Infof("breaking stale lock %q held by process %d on %q since %v: %s",
+		lock.name, lockInfo.PID, lockInfo.Hostname, lockInfo.Acquired, lockInfo.Message)
+	if lockInfo.Nonce == lock.nonce {
+		lock.declareDead()
+	}
+	if err := os.RemoveAll(tempDirName); err != nil {
+		logger.Debugf("Failed to remove stale lock: %s", err)
+	}
+	return true, nil
 }
 
 // If message is set, it will write the message to the lock directory as the
 // lock is taken.
 func (lock *Lock) acquire(message string) (bool, error) {
+	if lock.backend == OSBackend {
+		return lock.acquireFile(message, true)
+	}
 	// If the lockDir exists, then the lock is held by someone else.
 	_, err := os.Stat(lock.lockDir())
 	if err == nil {
@@ -235,9 +375,11 @@ func (lock *Lock) acquire(message string) (bool, error) {
 
 	// write lock into the temp dir
 	l := onDisk{
-		PID:     lock.PID,
-		Nonce:   lock.nonce,
-		Message: message,
+		PID:      lock.PID,
+		Nonce:    lock.nonce,
+		Message:  message,
+		Hostname: lock.hostname,
+		Acquired: lock.clock.Now().UTC(),
 	}
 	lockInfo, err := goyaml.Marshal(&l)
 	if err != nil {
@@ -263,15 +405,34 @@ func (lock *Lock) acquire(message string) (bool, error) {
 // lockLoop tries to acquire the lock. If the acquisition fails, the
 // continueFunc is run to see if the function should continue waiting.
 func (lock *Lock) lockLoop(message string, continueFunc func() error) error {
+	if lock.IsSharedLockHeld() {
+		return ErrLockHeld
+	}
 	var heldMessage = ""
+	var staleChecked time.Time
 	for {
 		acquired, err := lock.acquire(message)
 		if err != nil {
 			return err
 		}
-		if acquired {
+		if acquired && lock.backend == OSBackend {
 			return nil
 		}
+		if acquired {
+			// New shared holders back off now, but those
+			// already there have to be waited for.
+			return lock.waitForSharedHolders(continueFunc)
+		}
+		// the holder may have died while the lock was waited for.
+		if now := lock.clock.Now(); staleChecked.IsZero() ||
+			now.Sub(staleChecked) >= aliveRefreshDelays*lock.waitDelay {
+			staleChecked = now
+			if broken, err := lock.BreakIfStale(); err != nil {
+				return err
+			} else if broken {
+				continue
+			}
+		}
 		if err = continueFunc(); err != nil {
 			return err
 		}
@@ -319,7 +480,15 @@ func (lock *Lock) LockWithFunc(message string, continueFunc func() error) error
 }
 
 func (lock *Lock) readLock() (lockInfo onDisk, err error) {
-	lockFile, err := ioutil.ReadFile(lock.heldFile())
+	if lock.backend == OSBackend {
+		return lock.readLockFile()
+	}
+	return readLockFrom(lock.heldFile())
+}
+
+// readLockFrom reads the information of a lock from the given held file.
+func readLockFrom(heldFile string) (lockInfo onDisk, err error) {
+	lockFile, err := ioutil.ReadFile(heldFile)
 	if err != nil {
 		return lockInfo, err
 	}
@@ -330,6 +499,9 @@ func (lock *Lock) readLock() (lockInfo onDisk, err error) {
 
 // IsLockHeld returns whether the lock is currently held by the receiver.
 func (lock *Lock) IsLockHeld() bool {
+	if lock.backend == OSBackend {
+		return lock.file != nil && !lock.fileShared
+	}
 	lockInfo, err := lock.readLock()
 	if err != nil {
 		return false
@@ -340,6 +512,9 @@ func (lock *Lock) IsLockHeld() bool {
 // Unlock releases a held lock.  If the lock is not held ErrLockNotHeld is
 // returned.
 func (lock *Lock) Unlock() error {
+	if lock.backend == OSBackend {
+		return lock.unlockFile(false)
+	}
 	if !lock.IsLockHeld() {
 		return ErrLockNotHeld
 	}
@@ -370,16 +545,40 @@ func (lock *Lock) Unlock() error {
 
 // IsLocked returns true if the lock is currently held by anyone.
 func (lock *Lock) IsLocked() bool {
+	if lock.backend == OSBackend {
+		return lock.isFileLocked()
+	}
 	_, err := os.Stat(lock.heldFile())
 	return err == nil
 }
 
-// BreakLock forcibly breaks the lock that is currently being held.
+// BreakLock forcibly breaks the lock that is currently being held. With
+// the OSBackend, only a lock held by the receiver can be broken.
 func (lock *Lock) BreakLock() error {
+	if lock.backend == OSBackend {
+		return lock.breakFileLock()
+	}
 	lock.declareDead()
 	return os.RemoveAll(lock.lockDir())
 }
 
+// Info returns the description of the holder of the lock. It returns
+// an error satisfying errors.IsNotFound if the lock is not held.
+func (lock *Lock) Info() (LockInfo, error) {
+	lockInfo, err := lock.readLock()
+	if os.IsNotExist(err) {
+		return LockInfo{}, errors.NotFoundf("lock %q", lock.name)
+	} else if err != nil {
+		return LockInfo{}, errors.Trace(err)
+	}
+	return LockInfo{
+		PID:      lockInfo.PID,
+		Hostname: lockInfo.Hostname,
+		Acquired: lockInfo.Acquired,
+		Message:  lockInfo.Message,
+	}, nil
+}
+
 // Message returns the saved message, or the empty string if there is no
 // saved message.
 func (lock *Lock) Message() string {
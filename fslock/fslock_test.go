@@ -9,9 +9,11 @@ import (
 	"os"
 	"path"
 	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/juju/errors"
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/utils"
@@ -392,6 +394,200 @@ func (s *fslockSuite) TestCleanNoMatchingProcess(c *gc.C) {
 	assertCanLock(c, lock)
 }
 
+func (s *fslockSuite) TestInfo(c *gc.C) {
+	dir := c.MkDir()
+	lock, err := fslock.NewLock(dir, "testing", s.lockConfig)
+	c.Assert(err, gc.IsNil)
+	_, err = lock.Info()
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+
+	before := time.Now().Add(-time.Second)
+	err = lock.Lock("held for testing")
+	c.Assert(err, gc.IsNil)
+	info, err := lock.Info()
+	c.Assert(err, gc.IsNil)
+	hostname, err := os.Hostname()
+	c.Assert(err, gc.IsNil)
+	c.Assert(info.PID, gc.Equals, lock.PID)
+	c.Assert(info.Hostname, gc.Equals, hostname)
+	c.Assert(info.Message, gc.Equals, "held for testing")
+	c.Assert(info.Acquired.After(before), jc.IsTrue)
+}
+
+func (s *fslockSuite) TestBreakIfStaleLiveLock(c *gc.C) {
+	lock, _, _ := newLockedLock(c, s.lockConfig)
+	broken, err := lock.BreakIfStale()
+	c.Assert(err, gc.IsNil)
+	c.Assert(broken, jc.IsFalse)
+	c.Assert(lock.IsLocked(), jc.IsTrue)
+}
+
+func (s *fslockSuite) TestBreakIfStaleUnlocked(c *gc.C) {
+	lock, err := fslock.NewLock(c.MkDir(), "testing", s.lockConfig)
+	c.Assert(err, gc.IsNil)
+	broken, err := lock.BreakIfStale()
+	c.Assert(err, gc.IsNil)
+	c.Assert(broken, jc.IsFalse)
+}
+
+func (s *fslockSuite) TestBreakIfStaleExpired(c *gc.C) {
+	s.lockConfig.TTL = time.Minute
+	lock, lockFile, _ := newLockedLock(c, s.lockConfig)
+
+	// The holder is alive but has held the lock for longer than the TTL.
+	changeLockfile(c, lockFile, func(l *fslock.OnDisk) {
+		l.Acquired = time.Now().Add(-time.Hour)
+	})
+	broken, err := lock.BreakIfStale()
+	c.Assert(err, gc.IsNil)
+	c.Assert(broken, jc.IsTrue)
+	c.Assert(lock.IsLockHeld(), jc.IsFalse)
+}
+
+func (s *fslockSuite) TestBreakIfStaleNotExpired(c *gc.C) {
+	s.lockConfig.TTL = time.Hour
+	lock, _, _ := newLockedLock(c, s.lockConfig)
+	broken, err := lock.BreakIfStale()
+	c.Assert(err, gc.IsNil)
+	c.Assert(broken, jc.IsFalse)
+	c.Assert(lock.IsLocked(), jc.IsTrue)
+}
+
+// deadPID is the PID of a process which does not exist.
+const deadPID = 0x7fffffff
+
+func (s *fslockSuite) TestBreakIfStaleDeadProcess(c *gc.C) {
+	lock, lockFile, dir := newLockedLock(c, s.lockConfig)
+
+	// The holder proved it was alive recently, but it runs
+	// on this machine and its process is gone.
+	aliveFile := path.Join(dir, "testing", fmt.Sprintf("alive.%d", deadPID))
+	err := ioutil.WriteFile(aliveFile, []byte{}, 0644)
+	c.Assert(err, gc.IsNil)
+	changeLockfilePID(c, lockFile, deadPID)
+
+	broken, err := lock.BreakIfStale()
+	c.Assert(err, gc.IsNil)
+	c.Assert(broken, jc.IsTrue)
+	c.Assert(lock.IsLockHeld(), jc.IsFalse)
+	assertCanLock(c, lock)
+}
+
+func (s *fslockSuite) TestBreakIfStaleRemoteHolder(c *gc.C) {
+	lock, lockFile, dir := newLockedLock(c, s.lockConfig)
+
+	// The process of a holder on another machine cannot be looked
+	// for, so it is alive as long as it proves it.
+	aliveFile := path.Join(dir, "testing", fmt.Sprintf("alive.%d", deadPID))
+	err := ioutil.WriteFile(aliveFile, []byte{}, 0644)
+	c.Assert(err, gc.IsNil)
+	changeLockfile(c, lockFile, func(l *fslock.OnDisk) {
+		l.PID = deadPID
+		l.Hostname = "elsewhere"
+	})
+
+	broken, err := lock.BreakIfStale()
+	c.Assert(err, gc.IsNil)
+	c.Assert(broken, jc.IsFalse)
+	c.Assert(lock.IsLockHeld(), jc.IsTrue)
+}
+
+func (s *fslockSuite) TestLockBreaksExpiredLock(c *gc.C) {
+	s.lockConfig.TTL = time.Minute
+	lock, lockFile, dir := newLockedLock(c, s.lockConfig)
+	changeLockfile(c, lockFile, func(l *fslock.OnDisk) {
+		l.Acquired = time.Now().Add(-time.Hour)
+	})
+
+	other, err := fslock.NewLock(dir, "testing", s.lockConfig)
+	c.Assert(err, gc.IsNil)
+	err = other.LockWithTimeout(longWait, "taking over")
+	c.Assert(err, gc.IsNil)
+	c.Assert(other.Message(), gc.Equals, "taking over")
+	c.Assert(lock.IsLockHeld(), jc.IsFalse)
+	c.Assert(other.IsLockHeld(), jc.IsTrue)
+}
+
+func (s *fslockSuite) TestBreakIfStaleReacquired(c *gc.C) {
+	lock, lockFile, dir := newLockedLock(c, s.lockConfig)
+	changeLockfilePID(c, lockFile, deadPID)
+	other, err := fslock.NewLock(dir, "testing", s.lockConfig)
+	c.Assert(err, gc.IsNil)
+
+	// The stale lock is released and acquired again
+	// while it is being checked.
+	restore := testing.PatchValue(fslock.ProcessExists, func(int) bool {
+		c.Check(lock.Unlock(), gc.IsNil)
+		c.Check(other.Lock("taking over"), gc.IsNil)
+		return false
+	})
+	defer restore()
+	broken, err := lock.BreakIfStale()
+	c.Assert(err, gc.IsNil)
+	c.Assert(broken, jc.IsFalse)
+	c.Assert(other.IsLockHeld(), jc.IsTrue)
+	c.Assert(other.Message(), gc.Equals, "taking over")
+}
+
+func (s *fslockSuite) TestIsAliveUsesClock(c *gc.C) {
+	lock, _, dir := newLockedLock(c, s.lockConfig)
+	aliveFile := path.Join(dir, "testing", fmt.Sprintf("alive.%d", deadPID))
+	err := ioutil.WriteFile(aliveFile, []byte{}, 0644)
+	c.Assert(err, gc.IsNil)
+	c.Assert(fslock.IsAlive(lock, deadPID), jc.IsTrue)
+
+	s.lockConfig.Clock = &steppingClock{now: time.Now().Add(time.Hour)}
+	later, err := fslock.NewLock(dir, "testing", s.lockConfig)
+	c.Assert(err, gc.IsNil)
+	c.Assert(fslock.IsAlive(later, deadPID), jc.IsFalse)
+}
+
+func (s *fslockSuite) TestLockLoopLimitsStaleChecks(c *gc.C) {
+	clk := &steppingClock{now: time.Now()}
+	s.lockConfig.Clock = clk
+	s.lockConfig.TTL = 2 * s.lockConfig.WaitDelay
+	_, _, dir := newLockedLock(c, s.lockConfig)
+	other, err := fslock.NewLock(dir, "testing", s.lockConfig)
+	c.Assert(err, gc.IsNil)
+
+	// The lock expires after 3 attempts, but is only checked
+	// again after the alive file could have been refreshed.
+	attempts := 0
+	err = other.LockWithFunc("taking over", func() error {
+		attempts++
+		return nil
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(attempts, gc.Equals, 5)
+	c.Assert(other.IsLockHeld(), jc.IsTrue)
+}
+
+// steppingClock is a clock.Clock whose time only passes
+// when it is waited for, which returns immediately.
+type steppingClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (clk *steppingClock) Now() time.Time {
+	clk.mu.Lock()
+	defer clk.mu.Unlock()
+	return clk.now
+}
+
+func (clk *steppingClock) After(d time.Duration) <-chan time.Time {
+	clk.mu.Lock()
+	defer clk.mu.Unlock()
+	clk.now = clk.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- clk.now
+	return ch
+}
+
+func (clk *steppingClock) AfterFunc(d time.Duration, f func()) clock.Timer {
+	return time.AfterFunc(d, f)
+}
+
 // TestProofOfLife checks that the alive file doesn't get older than 500ms. Normally
 // it can get older, but we crank up the refresh interval for testing.
 func (s *fslockSuite) TestProofOfLife(c *gc.C) {
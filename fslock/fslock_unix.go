@@ -0,0 +1,44 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// +build !windows
+
+package fslock
+
+import (
+	"os"
+	"syscall"
+)
+
+// processExists returns whether a process with the given PID exists.
+// It is a variable for testing purposes.
+var processExists = func(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	// EPERM means the process exists but belongs to another user.
+	return err == nil || err == syscall.EPERM
+}
+
+// tryLockFile tries to lock the given file with flock, exclusively or
+// shared, without blocking. It returns whether the file was locked.
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+	switch err {
+	case nil:
+		return true, nil
+	case syscall.EWOULDBLOCK:
+		return false, nil
+	}
+	return false, &os.PathError{Op: "flock", Path: f.Name(), Err: err}
+}
+
+// unlockFile unlocks the given file locked by tryLockFile.
+func unlockFile(f *os.File) error {
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+		return &os.PathError{Op: "flock", Path: f.Name(), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// +build windows
+
+package fslock
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	// The flags of LockFileEx.
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+
+	// errorLockViolation is the ERROR_LOCK_VIOLATION error
+	// of the files locked by another process.
+	errorLockViolation syscall.Errno = 33
+)
+
+// processExists returns whether a process with the given PID exists.
+// It is a variable for testing purposes.
+var processExists = func(pid int) bool {
+	// FindProcess opens the process on windows, which fails
+	// if it does not exist.
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	proc.Release()
+	return true
+}
+
+// lockRange returns the byte range locked by tryLockFile. Locks on windows
+// are mandatory, so a byte far past the end of the file is locked to
+// leave the information about the lock readable.
+func lockRange() *syscall.Overlapped {
+	return &syscall.Overlapped{
+		Offset:     0xffffffff,
+		OffsetHigh: 0x7fffffff,
+	}
+}
+
+// tryLockFile tries to lock the given file with LockFileEx, exclusively
+// or shared, without blocking. It returns whether the file was locked.
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	flags := uint32(lockfileFailImmediately)
+	if exclusive {
+		flags |= lockfileExclusiveLock
+	}
+	err := lockFileEx(syscall.Handle(f.Fd()), flags, 0, 1, 0, lockRange())
+	switch err {
+	case nil:
+		return true, nil
+	case errorLockViolation:
+		return false, nil
+	}
+	return false, &os.PathError{Op: "LockFileEx", Path: f.Name(), Err: err}
+}
+
+// unlockFile unlocks the given file locked by tryLockFile.
+func unlockFile(f *os.File) error {
+	if err := unlockFileEx(syscall.Handle(f.Fd()), 0, 1, 0, lockRange()); err != nil {
+		return &os.PathError{Op: "UnlockFileEx", Path: f.Name(), Err: err}
+	}
+	return nil
+}
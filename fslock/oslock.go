@@ -0,0 +1,121 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package fslock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/juju/errors"
+	goyaml "gopkg.in/yaml.v2"
+)
+
+// lockFile returns the file locked by the OSBackend. Its name
+// starts with "." so that it is not a valid lock name.
+func (lock *Lock) lockFile() string {
+	return path.Join(lock.parent, fmt.Sprintf(".%s.lock", lock.name))
+}
+
+// acquireFile tries to lock the file of the OSBackend, exclusively or
+// shared. When it is locked exclusively, the information about the lock
+// is written into it.
+func (lock *Lock) acquireFile(message string, exclusive bool) (bool, error) {
+	f, err := os.OpenFile(lock.lockFile(), os.O_RDWR|os.O_CREATE, 0664)
+	if err != nil {
+		return false, err
+	}
+	acquired, err := tryLockFile(f, exclusive)
+	if err != nil || !acquired {
+		f.Close()
+		return false, err
+	}
+	if exclusive {
+		l := onDisk{
+			PID:      lock.PID,
+			Nonce:    lock.nonce,
+			Message:  message,
+			Hostname: lock.hostname,
+			Acquired: lock.clock.Now().UTC(),
+		}
+		lockInfo, err := goyaml.Marshal(&l)
+		if err == nil {
+			err = f.Truncate(0)
+		}
+		if err == nil {
+			_, err = f.WriteAt(lockInfo, 0)
+		}
+		if err != nil {
+			unlockFile(f)
+			f.Close()
+			return false, err
+		}
+	}
+	lock.file = f
+	lock.fileShared = !exclusive
+	return true, nil
+}
+
+// unlockFile unlocks the file of the OSBackend if the receiver
+// locked it, exclusively or shared as given.
+func (lock *Lock) unlockFile(shared bool) error {
+	if lock.file == nil || lock.fileShared != shared {
+		return ErrLockNotHeld
+	}
+	f := lock.file
+	lock.file = nil
+	defer f.Close()
+	if !shared {
+		// the information is left behind when the holder dies,
+		// so it is only trusted while the file is locked.
+		if err := f.Truncate(0); err != nil {
+			logger.Debugf("Failed to clear lock file: %s", err)
+		}
+	}
+	return unlockFile(f)
+}
+
+// isFileLocked returns whether the file of the OSBackend is locked
+// exclusively, by trying to lock it shared.
+func (lock *Lock) isFileLocked() bool {
+	if lock.IsLockHeld() {
+		return true
+	}
+	f, err := os.Open(lock.lockFile())
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	acquired, err := tryLockFile(f, false)
+	if err != nil || !acquired {
+		return true
+	}
+	unlockFile(f)
+	return false
+}
+
+// readLockFile reads the information about the lock from the file of
+// the OSBackend, returning an error satisfying os.IsNotExist if the file
+// is not locked exclusively.
+func (lock *Lock) readLockFile() (lockInfo onDisk, err error) {
+	if !lock.isFileLocked() {
+		return lockInfo, &os.PathError{Op: "read", Path: lock.lockFile(), Err: os.ErrNotExist}
+	}
+	data, err := ioutil.ReadFile(lock.lockFile())
+	if err != nil {
+		return lockInfo, err
+	}
+	err = goyaml.Unmarshal(data, &lockInfo)
+	return lockInfo, err
+}
+
+// breakFileLock releases the lock of the OSBackend if the receiver holds
+// it, as the locks of other processes cannot be broken.
+func (lock *Lock) breakFileLock() error {
+	if lock.file == nil {
+		return errors.NotSupportedf("breaking a lock held by another process with the OS backend")
+	}
+	return lock.unlockFile(lock.fileShared)
+}
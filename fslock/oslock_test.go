@@ -0,0 +1,104 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package fslock_test
+
+import (
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/fslock"
+)
+
+func (s *fslockSuite) TestOSBackendLockUnlock(c *gc.C) {
+	s.lockConfig.Backend = fslock.OSBackend
+	_, locks := newLocks(c, s.lockConfig, 2)
+	c.Assert(locks[0].IsLocked(), jc.IsFalse)
+	c.Assert(locks[0].Message(), gc.Equals, "")
+
+	err := locks[0].Lock("exclusive")
+	c.Assert(err, gc.IsNil)
+	c.Assert(locks[0].IsLockHeld(), jc.IsTrue)
+	c.Assert(locks[1].IsLockHeld(), jc.IsFalse)
+	c.Assert(locks[1].IsLocked(), jc.IsTrue)
+	c.Assert(locks[1].Message(), gc.Equals, "exclusive")
+	info, err := locks[1].Info()
+	c.Assert(err, gc.IsNil)
+	c.Assert(info.PID, gc.Equals, locks[0].PID)
+
+	err = locks[1].LockWithTimeout(shortWait, "")
+	c.Assert(err, gc.Equals, fslock.ErrTimeout)
+	err = locks[1].Unlock()
+	c.Assert(err, gc.Equals, fslock.ErrLockNotHeld)
+
+	err = locks[0].Unlock()
+	c.Assert(err, gc.IsNil)
+	c.Assert(locks[0].IsLockHeld(), jc.IsFalse)
+	c.Assert(locks[1].IsLocked(), jc.IsFalse)
+	_, err = locks[1].Info()
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	err = locks[1].LockWithTimeout(shortWait, "")
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *fslockSuite) TestOSBackendReleasedOnClose(c *gc.C) {
+	s.lockConfig.Backend = fslock.OSBackend
+	_, locks := newLocks(c, s.lockConfig, 2)
+	err := locks[0].Lock("crashing")
+	c.Assert(err, gc.IsNil)
+
+	// The kernel releases the lock when its holder goes away,
+	// leaving its information behind.
+	fslock.CloseLockFile(locks[0])
+	c.Assert(locks[1].IsLocked(), jc.IsFalse)
+	c.Assert(locks[1].Message(), gc.Equals, "")
+	err = locks[1].LockWithTimeout(shortWait, "taking over")
+	c.Assert(err, gc.IsNil)
+	c.Assert(locks[1].Message(), gc.Equals, "taking over")
+}
+
+func (s *fslockSuite) TestOSBackendSharedLock(c *gc.C) {
+	s.lockConfig.Backend = fslock.OSBackend
+	_, locks := newLocks(c, s.lockConfig, 3)
+	err := locks[0].SharedLock("first reader")
+	c.Assert(err, gc.IsNil)
+	err = locks[1].SharedLockWithTimeout(shortWait, "second reader")
+	c.Assert(err, gc.IsNil)
+	c.Assert(locks[0].IsSharedLockHeld(), jc.IsTrue)
+	c.Assert(locks[0].IsLocked(), jc.IsFalse)
+	_, err = locks[0].SharedHolders()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+
+	err = locks[2].LockWithTimeout(shortWait, "writer")
+	c.Assert(err, gc.Equals, fslock.ErrTimeout)
+	err = locks[0].Unlock()
+	c.Assert(err, gc.Equals, fslock.ErrLockNotHeld)
+
+	err = locks[0].SharedUnlock()
+	c.Assert(err, gc.IsNil)
+	err = locks[1].SharedUnlock()
+	c.Assert(err, gc.IsNil)
+	err = locks[2].LockWithTimeout(shortWait, "writer")
+	c.Assert(err, gc.IsNil)
+	err = locks[0].SharedLockWithTimeout(shortWait, "reader")
+	c.Assert(err, gc.Equals, fslock.ErrTimeout)
+}
+
+func (s *fslockSuite) TestOSBackendBreakLock(c *gc.C) {
+	s.lockConfig.Backend = fslock.OSBackend
+	_, locks := newLocks(c, s.lockConfig, 2)
+	err := locks[0].Lock("")
+	c.Assert(err, gc.IsNil)
+
+	err = locks[1].BreakLock()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	broken, err := locks[1].BreakIfStale()
+	c.Assert(err, gc.IsNil)
+	c.Assert(broken, jc.IsFalse)
+	c.Assert(locks[0].IsLockHeld(), jc.IsTrue)
+
+	err = locks[0].BreakLock()
+	c.Assert(err, gc.IsNil)
+	c.Assert(locks[1].IsLocked(), jc.IsFalse)
+}
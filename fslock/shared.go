@@ -0,0 +1,299 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package fslock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils"
+	goyaml "gopkg.in/yaml.v2"
+)
+
+// sharedDir returns the directory holding an information
+// file for every shared holder of the lock.
+func (lock *Lock) sharedDir() string {
+	return path.Join(lock.parent, fmt.Sprintf(".%s.shared", lock.name))
+}
+
+// sharedFile returns the information file of the receiver
+// when it holds the lock shared.
+func (lock *Lock) sharedFile() string {
+	return path.Join(lock.sharedDir(), lock.nonce)
+}
+
+// sharedHolder describes a shared holder of the lock.
+type sharedHolder struct {
+	onDisk
+	file    string
+	modTime time.Time
+}
+
+// readSharedHolders returns the shared holders of the lock,
+// whether they are alive or not.
+func (lock *Lock) readSharedHolders() ([]sharedHolder, error) {
+	entries, err := ioutil.ReadDir(lock.sharedDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var holders []sharedHolder
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			// the file of a holder being added.
+			continue
+		}
+		holder := sharedHolder{
+			file:    path.Join(lock.sharedDir(), entry.Name()),
+			modTime: entry.ModTime(),
+		}
+		data, err := ioutil.ReadFile(holder.file)
+		if os.IsNotExist(err) {
+			// the holder released the lock since the directory was read.
+			continue
+		} else if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err := goyaml.Unmarshal(data, &holder.onDisk); err != nil {
+			logger.Warningf("ignoring invalid shared holder file %q: %v", holder.file, err)
+			continue
+		}
+		holders = append(holders, holder)
+	}
+	return holders, nil
+}
+
+// isSharedHolderStale returns whether the given shared holder is stale:
+// its TTL expired, it did not refresh its information file recently, or
+// it runs on this machine and its process does not exist.
+func (lock *Lock) isSharedHolderStale(holder sharedHolder) bool {
+	if lock.ttl > 0 && !holder.Acquired.IsZero() &&
+		lock.clock.Now().After(holder.Acquired.Add(lock.ttl)) {
+		return true
+	}
+	localHolder := holder.Hostname == "" || holder.Hostname == lock.hostname
+	if holder.PID == lock.PID && localHolder {
+		return false
+	}
+	if holder.Hostname != "" && localHolder && !processExists(holder.PID) {
+		return true
+	}
+	return lock.clock.Now().After(holder.modTime.Add(lock.lividityTimeout))
+}
+
+// SharedHolders returns the description of the live shared holders of
+// the lock. It is not supported by the OSBackend.
+func (lock *Lock) SharedHolders() ([]LockInfo, error) {
+	if lock.backend == OSBackend {
+		return nil, errors.NotSupportedf("listing the shared holders of a lock with the OS backend")
+	}
+	holders, err := lock.readSharedHolders()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var infos []LockInfo
+	for _, holder := range holders {
+		if lock.isSharedHolderStale(holder) {
+			continue
+		}
+		infos = append(infos, LockInfo{
+			PID:      holder.PID,
+			Hostname: holder.Hostname,
+			Acquired: holder.Acquired,
+			Message:  holder.Message,
+		})
+	}
+	return infos, nil
+}
+
+// waitForSharedHolders waits for the shared holders of the lock to
+// release it, once the receiver holds it exclusively, breaking the stale
+// ones. If the continueFunc returns an error, the lock is released and
+// the error is returned.
+func (lock *Lock) waitForSharedHolders(continueFunc func() error) error {
+	var heldMessage = ""
+	for {
+		holders, err := lock.readSharedHolders()
+		if err != nil {
+			lock.Unlock()
+			return errors.Trace(err)
+		}
+		var messages []string
+		for _, holder := range holders {
+			if lock.isSharedHolderStale(holder) {
+				logger.Infof("breaking stale shared lock %q held by process %d on %q since %v: %s",
+					lock.name, holder.PID, holder.Hostname, holder.Acquired, holder.Message)
+				if err := os.Remove(holder.file); err != nil && !os.IsNotExist(err) {
+					lock.Unlock()
+					return errors.Trace(err)
+				}
+				continue
+			}
+			messages = append(messages, holder.Message)
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+		if err := continueFunc(); err != nil {
+			lock.Unlock()
+			return err
+		}
+		currMessage := strings.Join(messages, ", ")
+		if currMessage != heldMessage {
+			logger.Infof("waiting for shared holders of lock %q: %s", lock.name, currMessage)
+			heldMessage = currMessage
+		}
+		<-lock.clock.After(lock.waitDelay)
+	}
+}
+
+// acquireShared tries to acquire the lock shared. The holder is added
+// first and then backs off if the lock is held exclusively, while an
+// exclusive holder waits for the shared holders once it holds the lock,
+// so that exactly one of them wins when they race.
+func (lock *Lock) acquireShared(message string) (bool, error) {
+	if lock.backend == OSBackend {
+		return lock.acquireFile(message, false)
+	}
+	if lock.IsLocked() {
+		return false, nil
+	}
+	if err := os.MkdirAll(lock.sharedDir(), 0755); err != nil {
+		return false, err
+	}
+	l := onDisk{
+		PID:      lock.PID,
+		Nonce:    lock.nonce,
+		Message:  message,
+		Hostname: lock.hostname,
+		Acquired: lock.clock.Now().UTC(),
+	}
+	lockInfo, err := goyaml.Marshal(&l)
+	if err != nil {
+		return false, err
+	}
+	// Write the file under a temporary name and move it into place,
+	// so that it is never seen incomplete.
+	tempFile, err := ioutil.TempFile(lock.sharedDir(), ".")
+	if err != nil {
+		return false, err
+	}
+	_, err = tempFile.Write(lockInfo)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = utils.ReplaceFile(tempFile.Name(), lock.sharedFile())
+	}
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return false, err
+	}
+	if lock.IsLocked() {
+		// Beaten to it by an exclusive holder.
+		if err := os.Remove(lock.sharedFile()); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	lock.keepAlive(lock.sharedFile(), false)
+	return true, nil
+}
+
+// sharedLockLoop tries to acquire the lock shared. If the acquisition
+// fails, the continueFunc is run to see if the function should continue
+// waiting.
+func (lock *Lock) sharedLockLoop(message string, continueFunc func() error) error {
+	if lock.IsLockHeld() || lock.IsSharedLockHeld() {
+		return ErrLockHeld
+	}
+	var heldMessage = ""
+	for {
+		acquired, err := lock.acquireShared(message)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+		// the exclusive holder may have died while the lock was waited for.
+		if broken, err := lock.BreakIfStale(); err != nil {
+			return err
+		} else if broken {
+			continue
+		}
+		if err = continueFunc(); err != nil {
+			return err
+		}
+		currMessage := lock.Message()
+		if currMessage != heldMessage {
+			logger.Infof("attempted shared lock failed %q, %s, currently held: %s", lock.name, message, currMessage)
+			heldMessage = currMessage
+		}
+		<-lock.clock.After(lock.waitDelay)
+	}
+}
+
+// SharedLock blocks until it is able to acquire the lock shared, which
+// happens as soon as the lock is not held exclusively: any number of
+// locks may hold it shared at once. Exclusive locking takes precedence,
+// so that new shared holders wait for a waiting exclusive one. See
+// `Lock` for information about the message.
+func (lock *Lock) SharedLock(message string) error {
+	continueFunc := func() error { return nil }
+	return lock.sharedLockLoop(message, continueFunc)
+}
+
+// SharedLockWithTimeout tries to acquire the lock shared. If it cannot
+// acquire the lock within the given duration, it returns ErrTimeout.
+func (lock *Lock) SharedLockWithTimeout(duration time.Duration, message string) error {
+	deadline := lock.clock.Now().Add(duration)
+	continueFunc := func() error {
+		if lock.clock.Now().After(deadline) {
+			return ErrTimeout
+		}
+		return nil
+	}
+	return lock.sharedLockLoop(message, continueFunc)
+}
+
+// SharedLockWithFunc blocks until it is able to acquire the lock shared.
+// If the lock is failed to be acquired, the continueFunc is called prior
+// to the sleeping. If the continueFunc returns an error, that error is
+// returned from SharedLockWithFunc.
+func (lock *Lock) SharedLockWithFunc(message string, continueFunc func() error) error {
+	return lock.sharedLockLoop(message, continueFunc)
+}
+
+// IsSharedLockHeld returns whether the lock is currently held shared by
+// the receiver.
+func (lock *Lock) IsSharedLockHeld() bool {
+	if lock.backend == OSBackend {
+		return lock.file != nil && lock.fileShared
+	}
+	_, err := os.Stat(lock.sharedFile())
+	return err == nil
+}
+
+// SharedUnlock releases a lock held shared. If the lock is not held shared
+// ErrLockNotHeld is returned.
+func (lock *Lock) SharedUnlock() error {
+	if lock.backend == OSBackend {
+		return lock.unlockFile(true)
+	}
+	if !lock.IsSharedLockHeld() {
+		return ErrLockNotHeld
+	}
+	lock.declareDead()
+	if err := os.Remove(lock.sharedFile()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package fslock_test
+
+import (
+	"path/filepath"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/fslock"
+)
+
+func newLocks(c *gc.C, cfg fslock.LockConfig, n int) (string, []*fslock.Lock) {
+	dir := c.MkDir()
+	locks := make([]*fslock.Lock, n)
+	for i := range locks {
+		lock, err := fslock.NewLock(dir, "testing", cfg)
+		c.Assert(err, gc.IsNil)
+		locks[i] = lock
+	}
+	return dir, locks
+}
+
+func (s *fslockSuite) TestSharedLockConcurrentHolders(c *gc.C) {
+	_, locks := newLocks(c, s.lockConfig, 2)
+	err := locks[0].SharedLock("first reader")
+	c.Assert(err, gc.IsNil)
+	err = locks[1].SharedLockWithTimeout(shortWait, "second reader")
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(locks[0].IsSharedLockHeld(), jc.IsTrue)
+	c.Assert(locks[1].IsSharedLockHeld(), jc.IsTrue)
+	c.Assert(locks[0].IsLocked(), jc.IsFalse)
+	holders, err := locks[0].SharedHolders()
+	c.Assert(err, gc.IsNil)
+	c.Assert(holders, gc.HasLen, 2)
+	var messages []string
+	for _, holder := range holders {
+		c.Assert(holder.PID, gc.Equals, locks[0].PID)
+		messages = append(messages, holder.Message)
+	}
+	c.Assert(messages, jc.SameContents, []string{"first reader", "second reader"})
+
+	err = locks[0].SharedUnlock()
+	c.Assert(err, gc.IsNil)
+	c.Assert(locks[0].IsSharedLockHeld(), jc.IsFalse)
+	holders, err = locks[0].SharedHolders()
+	c.Assert(err, gc.IsNil)
+	c.Assert(holders, gc.HasLen, 1)
+}
+
+func (s *fslockSuite) TestSharedLockBlocksExclusive(c *gc.C) {
+	_, locks := newLocks(c, s.lockConfig, 2)
+	err := locks[0].SharedLock("reader")
+	c.Assert(err, gc.IsNil)
+
+	err = locks[1].LockWithTimeout(shortWait, "writer")
+	c.Assert(err, gc.Equals, fslock.ErrTimeout)
+	// The writer gave up, so readers are not kept out.
+	c.Assert(locks[1].IsLocked(), jc.IsFalse)
+
+	err = locks[0].SharedUnlock()
+	c.Assert(err, gc.IsNil)
+	err = locks[1].LockWithTimeout(shortWait, "writer")
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *fslockSuite) TestExclusiveLockBlocksShared(c *gc.C) {
+	_, locks := newLocks(c, s.lockConfig, 2)
+	err := locks[0].Lock("writer")
+	c.Assert(err, gc.IsNil)
+
+	err = locks[1].SharedLockWithTimeout(shortWait, "reader")
+	c.Assert(err, gc.Equals, fslock.ErrTimeout)
+	c.Assert(locks[1].IsSharedLockHeld(), jc.IsFalse)
+
+	err = locks[0].Unlock()
+	c.Assert(err, gc.IsNil)
+	err = locks[1].SharedLockWithTimeout(shortWait, "reader")
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *fslockSuite) TestExclusiveLockWaitsForSharedHolders(c *gc.C) {
+	_, locks := newLocks(c, s.lockConfig, 3)
+	err := locks[0].SharedLock("reader")
+	c.Assert(err, gc.IsNil)
+
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- locks[1].Lock("writer")
+	}()
+
+	select {
+	case <-acquired:
+		c.Fatalf("Unexpected lock acquisition")
+	case <-time.After(shortWait):
+	}
+	// New readers wait for the waiting writer.
+	err = locks[2].SharedLockWithTimeout(shortWait, "late reader")
+	c.Assert(err, gc.Equals, fslock.ErrTimeout)
+
+	err = locks[0].SharedUnlock()
+	c.Assert(err, gc.IsNil)
+	select {
+	case err := <-acquired:
+		c.Assert(err, gc.IsNil)
+	case <-time.After(longWait):
+		c.Fatalf("Expected lock acquisition")
+	}
+	c.Assert(locks[1].IsLockHeld(), jc.IsTrue)
+}
+
+func (s *fslockSuite) TestSharedLockAlreadyHeld(c *gc.C) {
+	_, locks := newLocks(c, s.lockConfig, 1)
+	lock := locks[0]
+	err := lock.SharedLock("")
+	c.Assert(err, gc.IsNil)
+	err = lock.SharedLock("")
+	c.Assert(err, gc.Equals, fslock.ErrLockHeld)
+	err = lock.Lock("")
+	c.Assert(err, gc.Equals, fslock.ErrLockHeld)
+
+	err = lock.SharedUnlock()
+	c.Assert(err, gc.IsNil)
+	err = lock.Lock("")
+	c.Assert(err, gc.IsNil)
+	err = lock.SharedLock("")
+	c.Assert(err, gc.Equals, fslock.ErrLockHeld)
+}
+
+func (s *fslockSuite) TestSharedUnlockNotHeld(c *gc.C) {
+	_, locks := newLocks(c, s.lockConfig, 1)
+	err := locks[0].SharedUnlock()
+	c.Assert(err, gc.Equals, fslock.ErrLockNotHeld)
+
+	err = locks[0].Lock("")
+	c.Assert(err, gc.IsNil)
+	err = locks[0].SharedUnlock()
+	c.Assert(err, gc.Equals, fslock.ErrLockNotHeld)
+}
+
+func (s *fslockSuite) TestExclusiveLockBreaksStaleSharedHolder(c *gc.C) {
+	dir, locks := newLocks(c, s.lockConfig, 2)
+	err := locks[0].SharedLock("crashed reader")
+	c.Assert(err, gc.IsNil)
+
+	files, err := filepath.Glob(filepath.Join(dir, ".testing.shared", "*"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(files, gc.HasLen, 1)
+	changeLockfilePID(c, files[0], deadPID)
+	holders, err := locks[1].SharedHolders()
+	c.Assert(err, gc.IsNil)
+	c.Assert(holders, gc.HasLen, 0)
+
+	err = locks[1].LockWithTimeout(longWait, "writer")
+	c.Assert(err, gc.IsNil)
+	c.Assert(locks[0].IsSharedLockHeld(), jc.IsFalse)
+}
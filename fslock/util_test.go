@@ -14,12 +14,18 @@ import (
 )
 
 func changeLockfilePID(c *gc.C, lockFile string, PID int) {
+	changeLockfile(c, lockFile, func(l *fslock.OnDisk) {
+		l.PID = PID
+	})
+}
+
+func changeLockfile(c *gc.C, lockFile string, change func(*fslock.OnDisk)) {
 	var l fslock.OnDisk
 	heldLock, err := ioutil.ReadFile(lockFile)
 	c.Assert(err, gc.IsNil)
 	err = goyaml.Unmarshal(heldLock, &l)
 	c.Assert(err, gc.IsNil)
-	l.PID = PID
+	change(&l)
 	heldLock, err = goyaml.Marshal(l)
 	c.Assert(err, gc.IsNil)
 	err = ioutil.WriteFile(lockFile, heldLock, 644)
@@ -47,6 +47,9 @@ const (
 
 // GetHTTPClient returns either a standard http client or
 // non validating client depending on the value of verify.
+//
+// Deprecated: use NewHTTPClient, setting
+// HTTPClientConfig.InsecureSkipVerify if verify is false.
 func GetHTTPClient(verify SSLHostnameVerification) *http.Client {
 	if verify == VerifySSLHostnames {
 		return GetValidatingHTTPClient()
@@ -56,12 +59,17 @@ func GetHTTPClient(verify SSLHostnameVerification) *http.Client {
 
 // GetValidatingHTTPClient returns a new http.Client that
 // verifies the server's certificate chain and hostname.
+//
+// Deprecated: use NewHTTPClient.
 func GetValidatingHTTPClient() *http.Client {
 	return &http.Client{}
 }
 
 // GetNonValidatingHTTPClient returns a new http.Client that
 // does not verify the server's certificate chain and hostname.
+//
+// Deprecated: use NewHTTPClient with
+// HTTPClientConfig.InsecureSkipVerify set.
 func GetNonValidatingHTTPClient() *http.Client {
 	return &http.Client{
 		Transport: NewHttpTLSTransport(&tls.Config{
@@ -116,17 +124,26 @@ var OutgoingAccessAllowed = true
 var netDial = net.Dial
 
 func dial(network, addr string) (net.Conn, error) {
+	if err := checkOutgoingAccess(addr); err != nil {
+		return nil, err
+	}
+	return netDial(network, addr)
+}
+
+// checkOutgoingAccess returns an error if the given address may not
+// be dialled because of OutgoingAccessAllowed.
+func checkOutgoingAccess(addr string) error {
 	if !OutgoingAccessAllowed {
 		host, _, err := net.SplitHostPort(addr)
 		if err != nil {
-			return netDial(network, addr)
+			return nil
 		}
 		if host != "localhost" {
 			ip := net.ParseIP(host)
 			if ip == nil || !ip.IsLoopback() {
-				return nil, fmt.Errorf("access to address %q not allowed", addr)
+				return fmt.Errorf("access to address %q not allowed", addr)
 			}
 		}
 	}
-	return netDial(network, addr)
+	return nil
 }
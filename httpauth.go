@@ -0,0 +1,490 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package utils
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// BearerAuthHeader creates a header that contains just the
+// "Authorization" entry, holding the given bearer token as described by
+// RFC 6750.
+func BearerAuthHeader(token string) http.Header {
+	return http.Header{
+		"Authorization": {"Bearer " + token},
+	}
+}
+
+// ParseBearerAuthHeader attempts to find an Authorization header in the
+// supplied http.Header and if found parses it as a Bearer header,
+// returning its token.
+func ParseBearerAuthHeader(h http.Header) (token string, err error) {
+	auth, err := ParseAuthorizationHeader(h)
+	if err != nil || !strings.EqualFold(auth.Scheme, "Bearer") || auth.Token == "" {
+		return "", fmt.Errorf("invalid or missing HTTP bearer auth header")
+	}
+	return auth.Token, nil
+}
+
+// Authorization holds the contents of an Authorization header, made of
+// an authentication scheme followed by either a token, as with the
+// Basic and Bearer schemes, or a list of parameters, as with the Digest
+// scheme. See RFC 7235, section 2.1.
+type Authorization struct {
+	// Scheme is the authentication scheme, such as "Basic".
+	Scheme string
+
+	// Token holds the credentials of the schemes which are
+	// given as a single token.
+	Token string
+
+	// Params holds the credentials of the schemes which are given as
+	// parameters, by lower case name.
+	Params map[string]string
+}
+
+// ParseAuthorizationHeader parses the Authorization header of the
+// supplied http.Header, whatever its scheme.
+func ParseAuthorizationHeader(h http.Header) (Authorization, error) {
+	value := strings.TrimSpace(h.Get("Authorization"))
+	if value == "" {
+		return Authorization{}, fmt.Errorf("missing HTTP auth header")
+	}
+	scheme, rest := value, ""
+	if i := strings.IndexAny(value, " \t"); i != -1 {
+		scheme, rest = value[:i], strings.TrimSpace(value[i+1:])
+	}
+	auth := Authorization{Scheme: scheme}
+	if rest == "" || isToken68(rest) {
+		auth.Token = rest
+		return auth, nil
+	}
+	params, err := parseAuthParams(rest)
+	if err != nil {
+		return Authorization{}, errors.Trace(err)
+	}
+	auth.Params = params
+	return auth, nil
+}
+
+// isToken68 returns whether the given string is a token68 as defined by
+// RFC 7235: a run of characters of the base64 and base64url alphabets,
+// optionally followed by padding.
+func isToken68(s string) bool {
+	s = strings.TrimRight(s, "=")
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-._~+/", c) != -1:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseAuthParams parses the given comma separated list of auth-params,
+// of the form name=token or name="quoted string". The names are
+// returned in lower case.
+func parseAuthParams(s string) (map[string]string, error) {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params, nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid HTTP auth parameters")
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var buf strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				buf.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, fmt.Errorf("invalid HTTP auth parameters")
+			}
+			value, s = buf.String(), s[i+1:]
+		} else {
+			end := strings.IndexAny(s, ", \t")
+			if end == -1 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+		}
+		params[name] = value
+		s = strings.TrimLeft(s, " \t")
+		if s != "" && s[0] != ',' {
+			return nil, fmt.Errorf("invalid HTTP auth parameters")
+		}
+	}
+}
+
+// DigestAuthTransport is an http.RoundTripper which authenticates the
+// requests it sends with the Digest scheme of RFC 7616, answering the
+// challenges of the servers. It supports the MD5 and SHA-256 algorithms
+// and their session variants, with the "auth" quality of protection.
+//
+// The last challenge of each origin, made of the scheme, host and port
+// of the requests, is reused for the following requests to the same
+// origin, so that they are usually authenticated upfront. Requests to
+// other origins, as when following redirects, are only authenticated
+// once challenged. Requests with a body are only retried with
+// credentials if the body can be obtained again, as with those created
+// by http.NewRequest.
+type DigestAuthTransport struct {
+	// Username and Password are the credentials of the requests.
+	Username string
+	Password string
+
+	// Transport is used to send the requests. It defaults
+	// to http.DefaultTransport if nil.
+	Transport http.RoundTripper
+
+	mu sync.Mutex
+	// challenges holds the last challenge of each
+	// protection space, by origin and realm.
+	challenges map[digestScope]*digestChallenge
+	// realms holds the realm of the last challenge of each origin.
+	realms map[string]string
+}
+
+// digestScope identifies a protection space: the realm of an origin.
+type digestScope struct {
+	origin string
+	realm  string
+}
+
+// digestChallenge holds the parameters of a Digest challenge.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	userhash  bool
+
+	// count is the number of requests authenticated with
+	// the nonce of the challenge, guarded by the mutex of
+	// the transport.
+	count int
+}
+
+// digestAlgorithms maps the supported algorithms, in
+// increasing order of preference, to their hash functions.
+var digestAlgorithms = []struct {
+	name    string
+	newHash func() hash.Hash
+}{
+	{"MD5", md5.New},
+	{"MD5-SESS", md5.New},
+	{"SHA-256", sha256.New},
+	{"SHA-256-SESS", sha256.New},
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *DigestAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	canResend := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	origin := requestOrigin(req.URL)
+
+	sent := req
+	if challenge := t.lastChallenge(origin); challenge != nil {
+		if authorization, ok := t.authorize(req, challenge); ok {
+			sent = cloneRequestWithAuth(req, authorization)
+		}
+	}
+	resp, err := transport.RoundTrip(sent)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !canResend {
+		return resp, err
+	}
+	challenge := parseDigestChallenge(resp.Header["Www-Authenticate"])
+	if challenge == nil {
+		return resp, nil
+	}
+	t.setChallenge(origin, challenge)
+
+	authorization, ok := t.authorize(req, challenge)
+	if !ok {
+		return resp, nil
+	}
+	retried := cloneRequestWithAuth(req, authorization)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retried.Body = body
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return transport.RoundTrip(retried)
+}
+
+// lastChallenge returns the last challenge of the given origin,
+// or nil if there is none.
+func (t *DigestAuthTransport) lastChallenge(origin string) *digestChallenge {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	realm, ok := t.realms[origin]
+	if !ok {
+		return nil
+	}
+	return t.challenges[digestScope{origin, realm}]
+}
+
+// setChallenge records the given challenge as the last one of the
+// given origin, replacing the previous one of its realm.
+func (t *DigestAuthTransport) setChallenge(origin string, challenge *digestChallenge) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.challenges == nil {
+		t.challenges = make(map[digestScope]*digestChallenge)
+		t.realms = make(map[string]string)
+	}
+	t.challenges[digestScope{origin, challenge.realm}] = challenge
+	t.realms[origin] = challenge.realm
+}
+
+// requestOrigin returns the origin of the given URL, made
+// of its scheme, host and port, in a canonical form.
+func requestOrigin(u *url.URL) string {
+	scheme := strings.ToLower(u.Scheme)
+	port := u.Port()
+	if port == "" {
+		switch scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+	return scheme + "://" + net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// cloneRequestWithAuth returns a shallow copy of the given request
+// with the given Authorization header.
+func cloneRequestWithAuth(req *http.Request, authorization string) *http.Request {
+	clone := req.WithContext(req.Context())
+	clone.Header = make(http.Header, len(req.Header)+1)
+	for name, values := range req.Header {
+		clone.Header[name] = values
+	}
+	clone.Header.Set("Authorization", authorization)
+	return clone
+}
+
+// parseDigestChallenge returns the strongest supported Digest challenge
+// of the given WWW-Authenticate headers, or nil if there is none.
+func parseDigestChallenge(headers []string) *digestChallenge {
+	var best *digestChallenge
+	bestRank := -1
+	for _, header := range headers {
+		for _, challenge := range splitChallenges(header) {
+			if candidate := parseOneDigestChallenge(challenge); candidate != nil {
+				for rank, algorithm := range digestAlgorithms {
+					if algorithm.name == candidate.algorithm && rank > bestRank {
+						best, bestRank = candidate, rank
+					}
+				}
+			}
+		}
+	}
+	return best
+}
+
+// parseOneDigestChallenge parses the given challenge, returning nil if
+// it is not a Digest challenge supported by the transport.
+func parseOneDigestChallenge(header string) *digestChallenge {
+	if len(header) < len("Digest ") || !strings.EqualFold(header[:len("Digest ")], "Digest ") {
+		return nil
+	}
+	params, err := parseAuthParams(header[len("Digest "):])
+	if err != nil || params["nonce"] == "" {
+		return nil
+	}
+	challenge := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: strings.ToUpper(params["algorithm"]),
+		userhash:  strings.EqualFold(params["userhash"], "true"),
+	}
+	if challenge.algorithm == "" {
+		challenge.algorithm = "MD5"
+	}
+	if qop, ok := params["qop"]; ok {
+		for _, option := range strings.Split(qop, ",") {
+			if strings.TrimSpace(option) == "auth" {
+				challenge.qop = "auth"
+			}
+		}
+		if challenge.qop == "" {
+			// only auth-int is offered, which is not supported.
+			return nil
+		}
+	}
+	return challenge
+}
+
+// splitChallenges splits the given WWW-Authenticate header value into
+// its challenges, as several of them may be joined by commas. Each is
+// made of an authentication scheme followed by either a token or a
+// list of auth-params.
+func splitChallenges(header string) []string {
+	var challenges []string
+	for _, element := range splitAuthElements(header) {
+		if len(challenges) == 0 || startsChallenge(element) {
+			challenges = append(challenges, element)
+		} else {
+			challenges[len(challenges)-1] += ", " + element
+		}
+	}
+	return challenges
+}
+
+// splitAuthElements splits the given header value at the commas which
+// are not within quoted strings, leaving out the empty elements.
+func splitAuthElements(s string) []string {
+	var elements []string
+	start, quoted := 0, false
+	for i := 0; i <= len(s); i++ {
+		switch {
+		case i == len(s) || !quoted && s[i] == ',':
+			if element := strings.TrimSpace(s[start:i]); element != "" {
+				elements = append(elements, element)
+			}
+			start = i + 1
+		case s[i] == '"':
+			quoted = !quoted
+		case quoted && s[i] == '\\':
+			i++
+		}
+	}
+	return elements
+}
+
+// startsChallenge returns whether the given element of a header value
+// starts a new challenge, with its scheme, rather than being an
+// auth-param of the previous one.
+func startsChallenge(element string) bool {
+	i := strings.IndexAny(element, " \t=")
+	if i == -1 {
+		// a scheme without parameters.
+		return true
+	}
+	return !strings.HasPrefix(strings.TrimLeft(element[i:], " \t"), "=")
+}
+
+// digestCnonce returns a new client nonce.
+// It is a variable for testing purposes.
+var digestCnonce = func() (string, error) {
+	cnonce := make([]byte, 16)
+	if _, err := rand.Read(cnonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(cnonce), nil
+}
+
+// authorize returns the Authorization header answering the given
+// challenge for the given request, and whether it could be computed.
+func (t *DigestAuthTransport) authorize(req *http.Request, challenge *digestChallenge) (string, bool) {
+	t.mu.Lock()
+	challenge.count++
+	count := challenge.count
+	t.mu.Unlock()
+
+	var newHash func() hash.Hash
+	for _, algorithm := range digestAlgorithms {
+		if algorithm.name == challenge.algorithm {
+			newHash = algorithm.newHash
+		}
+	}
+	h := func(parts ...string) string {
+		hash := newHash()
+		io.WriteString(hash, strings.Join(parts, ":"))
+		return hex.EncodeToString(hash.Sum(nil))
+	}
+
+	cnonce, err := digestCnonce()
+	if err != nil {
+		return "", false
+	}
+	nc := fmt.Sprintf("%08x", count)
+	uri := req.URL.RequestURI()
+
+	ha1 := h(t.Username, challenge.realm, t.Password)
+	if strings.HasSuffix(challenge.algorithm, "-SESS") {
+		ha1 = h(ha1, challenge.nonce, cnonce)
+	}
+	ha2 := h(req.Method, uri)
+	var response string
+	if challenge.qop == "" {
+		response = h(ha1, challenge.nonce, ha2)
+	} else {
+		response = h(ha1, challenge.nonce, nc, cnonce, challenge.qop, ha2)
+	}
+
+	username := t.Username
+	if challenge.userhash {
+		username = h(t.Username, challenge.realm)
+	}
+	params := []string{
+		fmt.Sprintf("username=%s", quoteAuthParam(username)),
+		fmt.Sprintf("realm=%s", quoteAuthParam(challenge.realm)),
+		fmt.Sprintf("nonce=%s", quoteAuthParam(challenge.nonce)),
+		fmt.Sprintf("uri=%s", quoteAuthParam(uri)),
+		fmt.Sprintf("algorithm=%s", challenge.algorithm),
+		fmt.Sprintf("response=%s", quoteAuthParam(response)),
+	}
+	if challenge.qop != "" {
+		params = append(params,
+			"qop="+challenge.qop,
+			"nc="+nc,
+			fmt.Sprintf("cnonce=%s", quoteAuthParam(cnonce)),
+		)
+	}
+	if challenge.opaque != "" {
+		params = append(params, fmt.Sprintf("opaque=%s", quoteAuthParam(challenge.opaque)))
+	}
+	if challenge.userhash {
+		params = append(params, "userhash=true")
+	}
+	return "Digest " + strings.Join(params, ", "), true
+}
+
+// quoteAuthParam returns the given auth-param value as a quoted string.
+func quoteAuthParam(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
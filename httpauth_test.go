@@ -0,0 +1,293 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package utils_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils"
+)
+
+type httpAuthSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&httpAuthSuite{})
+
+func (s *httpAuthSuite) TestBearerAuthHeader(c *gc.C) {
+	header := utils.BearerAuthHeader("mF_9.B5f-4.1JqM")
+	c.Assert(header, jc.DeepEquals, http.Header{
+		"Authorization": {"Bearer mF_9.B5f-4.1JqM"},
+	})
+	token, err := utils.ParseBearerAuthHeader(header)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(token, gc.Equals, "mF_9.B5f-4.1JqM")
+}
+
+func (s *httpAuthSuite) TestParseBearerAuthHeaderInvalid(c *gc.C) {
+	for i, value := range []string{"", "Bearer", "Basic dXNlcjpwYXNz", `Bearer realm="x"`} {
+		c.Logf("test %d: %q", i, value)
+		_, err := utils.ParseBearerAuthHeader(http.Header{"Authorization": {value}})
+		c.Check(err, gc.ErrorMatches, "invalid or missing HTTP bearer auth header")
+	}
+}
+
+func (s *httpAuthSuite) TestParseAuthorizationHeader(c *gc.C) {
+	for i, test := range []struct {
+		value  string
+		expect utils.Authorization
+		err    string
+	}{{
+		value: "",
+		err:   "missing HTTP auth header",
+	}, {
+		value:  "Basic dXNlcjpwYXNz",
+		expect: utils.Authorization{Scheme: "Basic", Token: "dXNlcjpwYXNz"},
+	}, {
+		value:  "Bearer abc/def+ghi==",
+		expect: utils.Authorization{Scheme: "Bearer", Token: "abc/def+ghi=="},
+	}, {
+		value:  "Negotiate",
+		expect: utils.Authorization{Scheme: "Negotiate"},
+	}, {
+		value: `Digest username="Mufasa", Realm="a \"quoted\", realm", nc=00000001,qop=auth`,
+		expect: utils.Authorization{
+			Scheme: "Digest",
+			Params: map[string]string{
+				"username": "Mufasa",
+				"realm":    `a "quoted", realm`,
+				"nc":       "00000001",
+				"qop":      "auth",
+			},
+		},
+	}, {
+		value: `Digest username="Mufasa`,
+		err:   "invalid HTTP auth parameters",
+	}, {
+		value: `Digest username="Mufasa" realm="x"`,
+		err:   "invalid HTTP auth parameters",
+	}} {
+		c.Logf("test %d: %q", i, test.value)
+		auth, err := utils.ParseAuthorizationHeader(http.Header{"Authorization": {test.value}})
+		if test.err != "" {
+			c.Check(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		c.Check(err, jc.ErrorIsNil)
+		c.Check(auth, jc.DeepEquals, test.expect)
+	}
+}
+
+// digestServer returns a server which challenges the requests without
+// credentials with the given WWW-Authenticate headers, accepts those
+// with the given response digest, and records the Authorization
+// headers it gets.
+func digestServer(c *gc.C, challenges []string, response string) (*httptest.Server, *[]utils.Authorization) {
+	var auths []utils.Authorization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		c.Check(err, jc.ErrorIsNil)
+		c.Check(string(body), gc.Equals, "contents")
+		auth, err := utils.ParseAuthorizationHeader(r.Header)
+		if err != nil || auth.Params["response"] != response {
+			w.Header()["Www-Authenticate"] = challenges
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		auths = append(auths, auth)
+	}))
+	return server, &auths
+}
+
+// The challenge and response of RFC 7616, section 3.9.1.
+const (
+	rfcDigestChallenge = `realm="http-auth@example.org", qop="auth, auth-int", ` +
+		`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", ` +
+		`opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`
+	rfcDigestCnonce = "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+)
+
+func (s *httpAuthSuite) TestDigestAuthTransport(c *gc.C) {
+	s.PatchValue(utils.DigestCnonce, func() (string, error) {
+		return rfcDigestCnonce, nil
+	})
+	server, auths := digestServer(c, []string{
+		"Digest " + rfcDigestChallenge + ", algorithm=MD5",
+		"Digest " + rfcDigestChallenge + ", algorithm=SHA-256",
+		`Basic realm="http-auth@example.org"`,
+	}, "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1")
+	defer server.Close()
+
+	client := &http.Client{Transport: &utils.DigestAuthTransport{
+		Username: "Mufasa",
+		Password: "Circle of Life",
+	}}
+	req, err := http.NewRequest("GET", server.URL+"/dir/index.html", strings.NewReader("contents"))
+	c.Assert(err, jc.ErrorIsNil)
+	resp, err := client.Do(req)
+	c.Assert(err, jc.ErrorIsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusOK)
+	c.Assert(*auths, jc.DeepEquals, []utils.Authorization{{
+		Scheme: "Digest",
+		Params: map[string]string{
+			"username":  "Mufasa",
+			"realm":     "http-auth@example.org",
+			"nonce":     "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+			"uri":       "/dir/index.html",
+			"algorithm": "SHA-256",
+			"response":  "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+			"qop":       "auth",
+			"nc":        "00000001",
+			"cnonce":    rfcDigestCnonce,
+			"opaque":    "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+		},
+	}})
+}
+
+func (s *httpAuthSuite) TestDigestAuthTransportReusesChallenge(c *gc.C) {
+	s.PatchValue(utils.DigestCnonce, func() (string, error) {
+		return rfcDigestCnonce, nil
+	})
+	var statuses []int
+	transport := &utils.DigestAuthTransport{
+		Username: "Mufasa",
+		Password: "Circle of Life",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, err := utils.ParseAuthorizationHeader(r.Header)
+		if err != nil {
+			w.Header().Set("Www-Authenticate", "Digest "+rfcDigestChallenge)
+			w.WriteHeader(http.StatusUnauthorized)
+			statuses = append(statuses, http.StatusUnauthorized)
+			return
+		}
+		c.Check(auth.Params["algorithm"], gc.Equals, "MD5")
+		statuses = append(statuses, http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: transport}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL + "/dir/index.html")
+		c.Assert(err, jc.ErrorIsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, gc.Equals, http.StatusOK)
+	}
+	c.Assert(statuses, jc.DeepEquals, []int{
+		http.StatusUnauthorized, http.StatusOK, http.StatusOK,
+	})
+}
+
+func (s *httpAuthSuite) TestDigestAuthTransportMD5(c *gc.C) {
+	s.PatchValue(utils.DigestCnonce, func() (string, error) {
+		return rfcDigestCnonce, nil
+	})
+	server, auths := digestServer(c, []string{
+		"Digest " + rfcDigestChallenge + ", algorithm=MD5",
+	}, "8ca523f5e9506fed4657c9700eebdbec")
+	defer server.Close()
+
+	client := &http.Client{Transport: &utils.DigestAuthTransport{
+		Username: "Mufasa",
+		Password: "Circle of Life",
+	}}
+	req, err := http.NewRequest("GET", server.URL+"/dir/index.html", strings.NewReader("contents"))
+	c.Assert(err, jc.ErrorIsNil)
+	resp, err := client.Do(req)
+	c.Assert(err, jc.ErrorIsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusOK)
+	c.Assert(*auths, gc.HasLen, 1)
+	c.Assert((*auths)[0].Params["algorithm"], gc.Equals, "MD5")
+}
+
+func (s *httpAuthSuite) TestDigestAuthTransportUnsupportedChallenge(c *gc.C) {
+	server, auths := digestServer(c, []string{
+		`Digest realm="x", nonce="y", qop="auth-int"`,
+		`Digest realm="x", nonce="y", algorithm=SHA-512-256`,
+		`Basic realm="x"`,
+	}, "")
+	defer server.Close()
+
+	client := &http.Client{Transport: &utils.DigestAuthTransport{
+		Username: "Mufasa",
+		Password: "Circle of Life",
+	}}
+	req, err := http.NewRequest("GET", server.URL, strings.NewReader("contents"))
+	c.Assert(err, jc.ErrorIsNil)
+	resp, err := client.Do(req)
+	c.Assert(err, jc.ErrorIsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusUnauthorized)
+	c.Assert(*auths, gc.HasLen, 0)
+}
+
+func (s *httpAuthSuite) TestDigestAuthTransportJoinedChallenges(c *gc.C) {
+	s.PatchValue(utils.DigestCnonce, func() (string, error) {
+		return rfcDigestCnonce, nil
+	})
+	server, auths := digestServer(c, []string{
+		`Basic realm="http-auth@example.org", ` +
+			"Digest " + rfcDigestChallenge + ", algorithm=MD5, " +
+			"Digest " + rfcDigestChallenge + ", algorithm=SHA-256, " +
+			"Negotiate abc==",
+	}, "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1")
+	defer server.Close()
+
+	client := &http.Client{Transport: &utils.DigestAuthTransport{
+		Username: "Mufasa",
+		Password: "Circle of Life",
+	}}
+	req, err := http.NewRequest("GET", server.URL+"/dir/index.html", strings.NewReader("contents"))
+	c.Assert(err, jc.ErrorIsNil)
+	resp, err := client.Do(req)
+	c.Assert(err, jc.ErrorIsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusOK)
+	c.Assert(*auths, gc.HasLen, 1)
+	c.Assert((*auths)[0].Params["algorithm"], gc.Equals, "SHA-256")
+	c.Assert((*auths)[0].Params["realm"], gc.Equals, "http-auth@example.org")
+}
+
+func (s *httpAuthSuite) TestDigestAuthTransportScopesChallengesByOrigin(c *gc.C) {
+	var otherAuths []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherAuths = append(otherAuths, r.Header.Get("Authorization"))
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := utils.ParseAuthorizationHeader(r.Header); err != nil {
+			w.Header().Set("Www-Authenticate", "Digest "+rfcDigestChallenge)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, other.URL+"/elsewhere", http.StatusFound)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &utils.DigestAuthTransport{
+		Username: "Mufasa",
+		Password: "Circle of Life",
+	}}
+	// The redirection to the other server is not authenticated,
+	// neither are the later requests sent to it.
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL + "/dir/index.html")
+		c.Assert(err, jc.ErrorIsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, gc.Equals, http.StatusOK)
+	}
+	resp, err := client.Get(other.URL)
+	c.Assert(err, jc.ErrorIsNil)
+	resp.Body.Close()
+	c.Assert(otherAuths, jc.DeepEquals, []string{"", "", ""})
+}
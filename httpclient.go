@@ -0,0 +1,338 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils/clock"
+	"github.com/juju/utils/proxy"
+	"github.com/juju/utils/retry"
+)
+
+const (
+	// defaultDialTimeout is the default HTTPClientConfig.DialTimeout.
+	defaultDialTimeout = 30 * time.Second
+
+	// defaultTLSHandshakeTimeout is the default
+	// HTTPClientConfig.TLSHandshakeTimeout.
+	defaultTLSHandshakeTimeout = 10 * time.Second
+
+	// defaultIdleConnTimeout is the default
+	// HTTPClientConfig.IdleConnTimeout.
+	defaultIdleConnTimeout = 90 * time.Second
+
+	// defaultRetryDelay is the default HTTPClientConfig.RetryDelay.
+	defaultRetryDelay = time.Second
+)
+
+// HTTPClientConfig configures the clients returned by NewHTTPClient.
+// Its zero value is a valid configuration, of a client which verifies
+// the certificates of servers, takes its proxies from the environment
+// and does not retry requests.
+type HTTPClientConfig struct {
+	// Timeout limits the time requests take, from dialling to reading
+	// the response body and including retries. Requests have no time
+	// limit if it is zero.
+	Timeout time.Duration
+
+	// DialTimeout limits the time connecting to servers takes.
+	// It defaults to 30 seconds.
+	DialTimeout time.Duration
+
+	// TLSHandshakeTimeout limits the time TLS handshakes take.
+	// It defaults to 10 seconds.
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout, if positive, limits the time waited for
+	// the headers of responses once requests are written.
+	ResponseHeaderTimeout time.Duration
+
+	// TLSConfig is the TLS configuration of the client. It defaults
+	// to the settings of crypto/tls; SecureTLSConfig() restricts the
+	// protocol versions and cipher suites further. It is cloned, not
+	// modified.
+	TLSConfig *tls.Config
+
+	// RootCAs, if set, holds the certificate authorities servers'
+	// certificates are verified against, instead of those of the
+	// system or of TLSConfig.
+	RootCAs *x509.CertPool
+
+	// InsecureSkipVerify disables the verification of the certificate
+	// chains and host names of servers. It is only meant for servers
+	// using self-signed certificates which cannot be verified otherwise.
+	InsecureSkipVerify bool
+
+	// Proxy holds the proxy settings of the client. The proxies are
+	// taken from the environment if it is nil.
+	Proxy *proxy.Settings
+
+	// KeepAlive enables the reuse of connections across requests.
+	KeepAlive bool
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections
+	// kept per host when KeepAlive is set. It defaults to
+	// http.DefaultMaxIdleConnsPerHost.
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout is how long idle connections are kept when
+	// KeepAlive is set. It defaults to 90 seconds.
+	IdleConnTimeout time.Duration
+
+	// RetryAttempts is the maximum number of attempts made for
+	// idempotent requests which fail with a transient network error,
+	// such as a refused connection or a timeout, or get a
+	// 429, 502, 503 or 504 response. Requests are not retried if it
+	// is zero.
+	RetryAttempts int
+
+	// RetryDelay is the delay waited for before the first retry.
+	// It defaults to one second.
+	RetryDelay time.Duration
+
+	// RetryBackoff computes the delays waited for before the later
+	// retries. The delay stays the same if it is nil.
+	RetryBackoff retry.BackoffFunc
+
+	// Clock is used for waiting between retries. It defaults to
+	// clock.WallClock if nil.
+	Clock clock.Clock
+}
+
+// Validate returns an error satisfying errors.IsNotValid
+// if the configuration cannot be used by NewHTTPClient.
+func (config HTTPClientConfig) Validate() error {
+	for name, value := range map[string]time.Duration{
+		"Timeout":               config.Timeout,
+		"DialTimeout":           config.DialTimeout,
+		"TLSHandshakeTimeout":   config.TLSHandshakeTimeout,
+		"ResponseHeaderTimeout": config.ResponseHeaderTimeout,
+		"IdleConnTimeout":       config.IdleConnTimeout,
+		"RetryDelay":            config.RetryDelay,
+	} {
+		if value < 0 {
+			return errors.NotValidf("negative %s", name)
+		}
+	}
+	if config.MaxIdleConnsPerHost < 0 {
+		return errors.NotValidf("negative MaxIdleConnsPerHost")
+	}
+	if config.RetryAttempts < 0 {
+		return errors.NotValidf("negative RetryAttempts")
+	}
+	if config.InsecureSkipVerify && config.RootCAs != nil {
+		return errors.NotValidf("RootCAs with InsecureSkipVerify")
+	}
+	return nil
+}
+
+// NewHTTPClient returns a new http.Client with the given configuration.
+// Its connections honour OutgoingAccessAllowed, and it supports file://
+// URLs as well as HTTP ones.
+func NewHTTPClient(config HTTPClientConfig) (*http.Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	var transport http.RoundTripper = newHTTPTransport(config)
+	if config.RetryAttempts > 1 {
+		if config.RetryDelay == 0 {
+			config.RetryDelay = defaultRetryDelay
+		}
+		if config.Clock == nil {
+			config.Clock = clock.WallClock
+		}
+		transport = &retryTransport{
+			transport: transport,
+			config:    config,
+		}
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+	}, nil
+}
+
+// newHTTPTransport returns the http.Transport of
+// the clients with the given configuration.
+func newHTTPTransport(config HTTPClientConfig) *http.Transport {
+	tlsConfig := &tls.Config{}
+	if config.TLSConfig != nil {
+		tlsConfig = config.TLSConfig.Clone()
+	}
+	if config.RootCAs != nil {
+		tlsConfig.RootCAs = config.RootCAs
+	}
+	if config.InsecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	proxyFunc := http.ProxyFromEnvironment
+	if config.Proxy != nil {
+		proxyFunc = config.Proxy.ProxyForRequest
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   durationOrDefault(config.DialTimeout, defaultDialTimeout),
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:           proxyFunc,
+		TLSClientConfig: tlsConfig,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if err := checkOutgoingAccess(addr); err != nil {
+				return nil, err
+			}
+			return dialer.DialContext(ctx, network, addr)
+		},
+		TLSHandshakeTimeout:   durationOrDefault(config.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		DisableKeepAlives:     !config.KeepAlive,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		IdleConnTimeout:       durationOrDefault(config.IdleConnTimeout, defaultIdleConnTimeout),
+	}
+	registerFileProtocol(transport)
+	return transport
+}
+
+func durationOrDefault(d, defaultDuration time.Duration) time.Duration {
+	if d == 0 {
+		return defaultDuration
+	}
+	return d
+}
+
+// retryTransport is an http.RoundTripper which retries
+// the idempotent requests which fail transiently.
+type retryTransport struct {
+	transport http.RoundTripper
+	config    HTTPClientConfig
+}
+
+// errRetryableStatus is returned by the failed attempts of
+// retryTransport which got a response worth retrying.
+var errRetryableStatus = errors.New("retryable response status")
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isRetryableRequest(req) {
+		return t.transport.RoundTrip(req)
+	}
+	var resp *http.Response
+	attempt := 0
+	err := retry.Call(req.Context(), retry.CallArgs{
+		Func: func() error {
+			attempt++
+			discardResponse(resp)
+			resp = nil
+			attemptReq := req
+			if attempt > 1 && req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return errors.Trace(err)
+				}
+				attemptReq = req.WithContext(req.Context())
+				attemptReq.Body = body
+			}
+			var err error
+			resp, err = t.transport.RoundTrip(attemptReq)
+			if err != nil {
+				return err
+			}
+			if isRetryableStatus(resp.StatusCode) {
+				return errRetryableStatus
+			}
+			return nil
+		},
+		IsFatalError: func(err error) bool {
+			return err != errRetryableStatus && !isTransientError(err)
+		},
+		Attempts:    t.config.RetryAttempts,
+		Delay:       t.config.RetryDelay,
+		BackoffFunc: t.config.RetryBackoff,
+		Clock:       t.config.Clock,
+	})
+	switch {
+	case err == nil:
+		return resp, nil
+	case retry.IsAttemptsExceeded(err) && retry.LastError(err) == errRetryableStatus:
+		// the last response is returned as it is.
+		return resp, nil
+	}
+	discardResponse(resp)
+	if retry.IsRetryStopped(err) {
+		return nil, req.Context().Err()
+	}
+	return nil, errors.Cause(retry.LastError(err))
+}
+
+// isRetryableRequest returns whether the given request is idempotent
+// and can be sent again.
+func isRetryableRequest(req *http.Request) bool {
+	switch req.Method {
+	case "", "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// isTransientError returns whether the given error of a round trip
+// is a network failure worth retrying the request for. Errors such as
+// certificate verification failures or denied outgoing access are not,
+// as they would only happen again.
+func isTransientError(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	switch err := err.(type) {
+	case *net.DNSError:
+		return err.IsTimeout || err.IsTemporary
+	case *net.OpError:
+		if dnsErr, ok := err.Err.(*net.DNSError); ok {
+			return dnsErr.IsTimeout || dnsErr.IsTemporary
+		}
+		return true
+	case net.Error:
+		return err.Timeout()
+	}
+	return false
+}
+
+// isRetryableStatus returns whether the response status
+// is worth retrying the request for.
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// maxDiscardedBodySize is the maximum number of bytes
+// read from the bodies of the discarded responses.
+const maxDiscardedBodySize = 64 * 1024
+
+// discardResponse reads and closes the body of the given
+// response, if any, so that its connection can be reused.
+// Longer bodies are closed without being read to their end.
+func discardResponse(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDiscardedBodySize))
+	resp.Body.Close()
+}
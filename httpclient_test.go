@@ -0,0 +1,319 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package utils_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils"
+	"github.com/juju/utils/clock"
+	"github.com/juju/utils/proxy"
+	"github.com/juju/utils/retry"
+)
+
+type httpClientSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&httpClientSuite{})
+
+// instantClock is a clock.Clock which records the delays it is
+// asked to wait for and returns immediately.
+type instantClock struct {
+	clock.Clock
+
+	mu     sync.Mutex
+	delays []time.Duration
+}
+
+func (clk *instantClock) Now() time.Time {
+	return time.Now()
+}
+
+func (clk *instantClock) After(d time.Duration) <-chan time.Time {
+	clk.mu.Lock()
+	defer clk.mu.Unlock()
+	clk.delays = append(clk.delays, d)
+	ch := make(chan time.Time, 1)
+	ch <- time.Now()
+	return ch
+}
+
+// statusServer returns a server responding to the requests with the
+// given statuses in turn, and the methods of the requests it got.
+func statusServer(statuses ...int) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		status := statuses[len(methods)%len(statuses)]
+		methods = append(methods, r.Method)
+		w.WriteHeader(status)
+	}))
+	return server, &methods
+}
+
+func (s *httpClientSuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		config utils.HTTPClientConfig
+		err    string
+	}{{
+		config: utils.HTTPClientConfig{Timeout: -1},
+		err:    "negative Timeout not valid",
+	}, {
+		config: utils.HTTPClientConfig{RetryDelay: -1},
+		err:    "negative RetryDelay not valid",
+	}, {
+		config: utils.HTTPClientConfig{RetryAttempts: -1},
+		err:    "negative RetryAttempts not valid",
+	}, {
+		config: utils.HTTPClientConfig{MaxIdleConnsPerHost: -1},
+		err:    "negative MaxIdleConnsPerHost not valid",
+	}, {
+		config: utils.HTTPClientConfig{
+			InsecureSkipVerify: true,
+			RootCAs:            x509.NewCertPool(),
+		},
+		err: "RootCAs with InsecureSkipVerify not valid",
+	}} {
+		c.Logf("test %d: %s", i, test.err)
+		_, err := utils.NewHTTPClient(test.config)
+		c.Check(err, gc.ErrorMatches, test.err)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+	}
+}
+
+func (s *httpClientSuite) TestTimeout(c *gc.C) {
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{Timeout: time.Minute})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(client.Timeout, gc.Equals, time.Minute)
+}
+
+func (s *httpClientSuite) TestVerifiesCertificates(c *gc.C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.Get(server.URL)
+	c.Assert(err, gc.ErrorMatches, "(.|\n)*x509: certificate signed by unknown authority")
+
+	client, err = utils.NewHTTPClient(utils.HTTPClientConfig{InsecureSkipVerify: true})
+	c.Assert(err, jc.ErrorIsNil)
+	resp, err := client.Get(server.URL)
+	c.Assert(err, jc.ErrorIsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusOK)
+}
+
+func (s *httpClientSuite) TestRootCAs(c *gc.C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{RootCAs: pool})
+	c.Assert(err, jc.ErrorIsNil)
+	resp, err := client.Get(server.URL)
+	c.Assert(err, jc.ErrorIsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusOK)
+}
+
+func (s *httpClientSuite) TestProxy(c *gc.C) {
+	var proxied []string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+	}))
+	defer proxyServer.Close()
+
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{
+		Proxy: &proxy.Settings{
+			Http:    proxyServer.URL,
+			NoProxy: "direct.invalid",
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	resp, err := client.Get("http://example.invalid/path")
+	c.Assert(err, jc.ErrorIsNil)
+	resp.Body.Close()
+	c.Assert(proxied, jc.DeepEquals, []string{"http://example.invalid/path"})
+}
+
+func (s *httpClientSuite) TestOutgoingAccessNotAllowed(c *gc.C) {
+	s.PatchValue(&utils.OutgoingAccessAllowed, false)
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{
+		Proxy: &proxy.Settings{},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.Get("http://10.0.0.1:1234")
+	c.Assert(err, gc.ErrorMatches, `.*access to address "10.0.0.1:1234" not allowed`)
+}
+
+func (s *httpClientSuite) TestRetry(c *gc.C) {
+	server, methods := statusServer(http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK)
+	defer server.Close()
+
+	clk := &instantClock{}
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{
+		RetryAttempts: 5,
+		RetryDelay:    time.Second,
+		RetryBackoff:  retry.DoubleDelay,
+		Clock:         clk,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	resp, err := client.Get(server.URL)
+	c.Assert(err, jc.ErrorIsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusOK)
+	c.Assert(*methods, jc.DeepEquals, []string{"GET", "GET", "GET"})
+	c.Assert(clk.delays, jc.DeepEquals, []time.Duration{time.Second, 2 * time.Second})
+}
+
+func (s *httpClientSuite) TestRetryWithBody(c *gc.C) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		c.Check(err, jc.ErrorIsNil)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{
+		RetryAttempts: 2,
+		Clock:         &instantClock{},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	req, err := http.NewRequest("PUT", server.URL, strings.NewReader("contents"))
+	c.Assert(err, jc.ErrorIsNil)
+	resp, err := client.Do(req)
+	c.Assert(err, jc.ErrorIsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusOK)
+	c.Assert(bodies, jc.DeepEquals, []string{"contents", "contents"})
+}
+
+func (s *httpClientSuite) TestRetryAttemptsExceeded(c *gc.C) {
+	server, methods := statusServer(http.StatusServiceUnavailable)
+	defer server.Close()
+
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{
+		RetryAttempts: 3,
+		Clock:         &instantClock{},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	resp, err := client.Get(server.URL)
+	c.Assert(err, jc.ErrorIsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusServiceUnavailable)
+	c.Assert(*methods, gc.HasLen, 3)
+}
+
+func (s *httpClientSuite) TestNoRetryForNonIdempotentRequests(c *gc.C) {
+	server, methods := statusServer(http.StatusServiceUnavailable)
+	defer server.Close()
+
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{
+		RetryAttempts: 3,
+		Clock:         &instantClock{},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("contents"))
+	c.Assert(err, jc.ErrorIsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusServiceUnavailable)
+	c.Assert(*methods, jc.DeepEquals, []string{"POST"})
+}
+
+func (s *httpClientSuite) TestNoRetryForOtherStatuses(c *gc.C) {
+	server, methods := statusServer(http.StatusNotFound)
+	defer server.Close()
+
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{
+		RetryAttempts: 3,
+		Clock:         &instantClock{},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	resp, err := client.Get(server.URL)
+	c.Assert(err, jc.ErrorIsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusNotFound)
+	c.Assert(*methods, gc.HasLen, 1)
+}
+
+func (s *httpClientSuite) TestRetryNetworkErrors(c *gc.C) {
+	// Nothing listens on the address of a closed server.
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	server.Close()
+
+	clk := &instantClock{}
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{
+		RetryAttempts: 3,
+		Clock:         clk,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.Get(server.URL)
+	c.Assert(err, gc.ErrorMatches, ".*connection refused")
+	c.Assert(clk.delays, gc.HasLen, 2)
+}
+
+func (s *httpClientSuite) TestNoRetryForCertificateErrors(c *gc.C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	clk := &instantClock{}
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{
+		RetryAttempts: 3,
+		Clock:         clk,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.Get(server.URL)
+	c.Assert(err, gc.ErrorMatches, "(.|\n)*x509: certificate signed by unknown authority")
+	c.Assert(clk.delays, gc.HasLen, 0)
+}
+
+func (s *httpClientSuite) TestNoRetryForDeniedAccess(c *gc.C) {
+	s.PatchValue(&utils.OutgoingAccessAllowed, false)
+	clk := &instantClock{}
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{
+		Proxy:         &proxy.Settings{},
+		RetryAttempts: 3,
+		Clock:         clk,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.Get("http://10.0.0.1:1234")
+	c.Assert(err, gc.ErrorMatches, `.*access to address "10.0.0.1:1234" not allowed`)
+	c.Assert(clk.delays, gc.HasLen, 0)
+}
+
+func (s *httpClientSuite) TestDefaultTLSConfig(c *gc.C) {
+	client, err := utils.NewHTTPClient(utils.HTTPClientConfig{})
+	c.Assert(err, jc.ErrorIsNil)
+	tlsConfig := client.Transport.(*http.Transport).TLSClientConfig
+	c.Assert(tlsConfig.MinVersion, gc.Equals, uint16(0))
+	c.Assert(tlsConfig.CipherSuites, gc.IsNil)
+
+	client, err = utils.NewHTTPClient(utils.HTTPClientConfig{
+		TLSConfig: utils.SecureTLSConfig(),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	tlsConfig = client.Transport.(*http.Transport).TLSClientConfig
+	c.Assert(tlsConfig.MinVersion, gc.Equals, uint16(tls.VersionTLS12))
+}
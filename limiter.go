@@ -4,11 +4,17 @@
 package utils
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils/clock"
 )
 
 type empty struct{}
-type limiter chan empty
 
 // Limiter represents a limited resource (eg a semaphore).
 type Limiter interface {
@@ -19,41 +25,278 @@ type Limiter interface {
 	// AcquireWait requests a unit of resource, but blocks until one is
 	// available.
 	AcquireWait()
+	// AcquireContext requests a unit of resource, blocking until one is
+	// available or the given context is done, in which case it returns
+	// the error of the context and no unit is acquired.
+	AcquireContext(ctx context.Context) error
 	// Release returns a unit of the resource. Calling Release when there
 	// are no units Acquired is an error.
 	Release() error
+	// Stats returns the usage statistics of the resource.
+	Stats() LimiterStats
+}
+
+// LimiterStats holds the usage statistics of a Limiter.
+type LimiterStats struct {
+	// Capacity is the number of units of the resource.
+	Capacity int
+
+	// InUse is the number of units currently acquired.
+	InUse int
+
+	// Waiting is the number of callers currently blocked
+	// waiting for a unit.
+	Waiting int
+
+	// Acquired is the total number of units acquired.
+	Acquired uint64
+
+	// Rejected is the total number of times a unit could not be
+	// acquired, because Acquire found none available or the context
+	// given to AcquireContext was done first.
+	Rejected uint64
+
+	// Waits is the total number of times a caller had to wait
+	// for a unit, whether it acquired one in the end or not.
+	Waits uint64
+
+	// WaitTime is the total time callers spent waiting for units.
+	WaitTime time.Duration
+
+	// MaxWaitTime is the longest time a caller spent waiting for a unit.
+	MaxWaitTime time.Duration
+}
+
+// Saturated returns whether all the units of the resource were in use
+// when the statistics were taken.
+func (s LimiterStats) Saturated() bool {
+	return s.InUse >= s.Capacity
+}
+
+// limiter implements Limiter.
+type limiter struct {
+	units chan empty
+	clock clock.Clock
+
+	mu    sync.Mutex
+	stats LimiterStats
 }
 
+// NewLimiter returns a Limiter of the given number of units.
 func NewLimiter(max int) Limiter {
-	return make(limiter, max)
+	return NewLimiterWithClock(max, clock.WallClock)
+}
+
+// NewLimiterWithClock returns a Limiter of the given number of units,
+// which measures the time callers wait for them with the given clock.
+func NewLimiterWithClock(max int, clk clock.Clock) Limiter {
+	return &limiter{
+		units: make(chan empty, max),
+		clock: clk,
+		stats: LimiterStats{Capacity: max},
+	}
 }
 
 // Acquire requests some resources that you can return later
 // It returns 'true' if there are resources available, but false if they are
 // not. Callers are responsible for calling Release if this returns true, but
 // should not release if this returns false.
-func (l limiter) Acquire() bool {
-	e := empty{}
+func (l *limiter) Acquire() bool {
 	select {
-	case l <- e:
+	case l.units <- empty{}:
+		l.record(func(stats *LimiterStats) {
+			stats.Acquired++
+		})
 		return true
 	default:
+		l.record(func(stats *LimiterStats) {
+			stats.Rejected++
+		})
 		return false
 	}
 }
 
 // AcquireWait waits for the resource to become available before returning.
-func (l limiter) AcquireWait() {
-	e := empty{}
-	l <- e
+func (l *limiter) AcquireWait() {
+	l.AcquireContext(context.Background())
+}
+
+// AcquireContext waits for the resource to become available before
+// returning, unless the given context is done first. Callers are
+// responsible for calling Release if this returns nil, but should not
+// release otherwise.
+func (l *limiter) AcquireContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		l.record(func(stats *LimiterStats) {
+			stats.Rejected++
+		})
+		return err
+	}
+	select {
+	case l.units <- empty{}:
+		l.record(func(stats *LimiterStats) {
+			stats.Acquired++
+		})
+		return nil
+	default:
+	}
+
+	start := l.clock.Now()
+	l.record(func(stats *LimiterStats) {
+		stats.Waiting++
+		stats.Waits++
+	})
+	var err error
+	select {
+	case l.units <- empty{}:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	waited := l.clock.Now().Sub(start)
+	l.record(func(stats *LimiterStats) {
+		stats.Waiting--
+		stats.WaitTime += waited
+		if waited > stats.MaxWaitTime {
+			stats.MaxWaitTime = waited
+		}
+		if err == nil {
+			stats.Acquired++
+		} else {
+			stats.Rejected++
+		}
+	})
+	return err
 }
 
 // Release returns the resource to the available pool.
-func (l limiter) Release() error {
+func (l *limiter) Release() error {
 	select {
-	case <-l:
+	case <-l.units:
 		return nil
 	default:
 		return fmt.Errorf("Release without an associated Acquire")
 	}
 }
+
+// Stats implements Limiter.
+func (l *limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := l.stats
+	stats.InUse = len(l.units)
+	return stats
+}
+
+// record updates the statistics of the limiter with the given function.
+func (l *limiter) record(update func(*LimiterStats)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	update(&l.stats)
+}
+
+// KeyedLimiterConfig configures a KeyedLimiter.
+type KeyedLimiterConfig struct {
+	// Default is the number of units of the resource of the keys
+	// which are not in Limits.
+	Default int
+
+	// Limits holds the number of units of the resource of
+	// specific keys.
+	Limits map[string]int
+
+	// Clock measures the time callers wait for units. It defaults
+	// to clock.WallClock if nil.
+	Clock clock.Clock
+}
+
+// Validate returns an error satisfying errors.IsNotValid
+// if the configuration cannot be used by a KeyedLimiter.
+func (config KeyedLimiterConfig) Validate() error {
+	if config.Default < 0 {
+		return errors.NotValidf("negative Default")
+	}
+	for key, limit := range config.Limits {
+		if limit < 0 {
+			return errors.NotValidf("negative limit for %q", key)
+		}
+	}
+	return nil
+}
+
+// KeyedLimiter limits a resource separately for every key, such as the
+// host or the class of operation the resource is used for. The Limiter
+// of every key is created the first time the key is used, and is kept
+// for the lifetime of the KeyedLimiter, so the keys should come from a
+// bounded set.
+type KeyedLimiter struct {
+	config KeyedLimiterConfig
+
+	mu       sync.Mutex
+	limiters map[string]Limiter
+}
+
+// NewKeyedLimiter returns a KeyedLimiter with the given configuration.
+func NewKeyedLimiter(config KeyedLimiterConfig) (*KeyedLimiter, error) {
+	if err := config.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if config.Clock == nil {
+		config.Clock = clock.WallClock
+	}
+	return &KeyedLimiter{
+		config:   config,
+		limiters: make(map[string]Limiter),
+	}, nil
+}
+
+// Limiter returns the Limiter of the given key.
+func (kl *KeyedLimiter) Limiter(key string) Limiter {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	l, ok := kl.limiters[key]
+	if !ok {
+		max, ok := kl.config.Limits[key]
+		if !ok {
+			max = kl.config.Default
+		}
+		l = NewLimiterWithClock(max, kl.config.Clock)
+		kl.limiters[key] = l
+	}
+	return l
+}
+
+// Acquire acquires a unit of the resource of the given key
+// as Limiter.Acquire does.
+func (kl *KeyedLimiter) Acquire(key string) bool {
+	return kl.Limiter(key).Acquire()
+}
+
+// AcquireWait acquires a unit of the resource of the given key
+// as Limiter.AcquireWait does.
+func (kl *KeyedLimiter) AcquireWait(key string) {
+	kl.Limiter(key).AcquireWait()
+}
+
+// AcquireContext acquires a unit of the resource of the given key
+// as Limiter.AcquireContext does.
+func (kl *KeyedLimiter) AcquireContext(ctx context.Context, key string) error {
+	return kl.Limiter(key).AcquireContext(ctx)
+}
+
+// Release returns a unit of the resource of the given key
+// as Limiter.Release does.
+func (kl *KeyedLimiter) Release(key string) error {
+	return kl.Limiter(key).Release()
+}
+
+// Stats returns the usage statistics of the resource
+// of every key used so far.
+func (kl *KeyedLimiter) Stats() map[string]LimiterStats {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	stats := make(map[string]LimiterStats, len(kl.limiters))
+	for key, l := range kl.limiters {
+		stats[key] = l.Stats()
+	}
+	return stats
+}
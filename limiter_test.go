@@ -4,14 +4,18 @@
 package utils_test
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/juju/errors"
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
 	"github.com/juju/utils"
+	"github.com/juju/utils/clock"
 )
 
 const longWait = 10 * time.Second
@@ -79,3 +83,143 @@ func (*limiterSuite) TestAcquireWaitBlocksUntilRelease(c *gc.C) {
 	}
 	c.Check(calls, gc.DeepEquals, []string{"true", "true", "false", "waited", "false"})
 }
+
+// steppingClock is a clock.Clock whose time moves forward
+// by a second every time it is read.
+type steppingClock struct {
+	clock.Clock
+
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (clk *steppingClock) Now() time.Time {
+	clk.mu.Lock()
+	defer clk.mu.Unlock()
+	clk.now = clk.now.Add(time.Second)
+	return clk.now
+}
+
+// waitForWaiting waits until the given limiter has a blocked caller.
+func waitForWaiting(c *gc.C, l utils.Limiter) {
+	timeout := time.After(longWait)
+	for l.Stats().Waiting == 0 {
+		select {
+		case <-timeout:
+			c.Fatalf("timed out waiting for a blocked caller")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (*limiterSuite) TestAcquireContextBlocksUntilRelease(c *gc.C) {
+	l := utils.NewLimiterWithClock(1, &steppingClock{})
+	c.Assert(l.AcquireContext(context.Background()), jc.ErrorIsNil)
+
+	done := make(chan error)
+	go func() {
+		done <- l.AcquireContext(context.Background())
+	}()
+	waitForWaiting(c, l)
+	c.Assert(l.Release(), jc.ErrorIsNil)
+	select {
+	case err := <-done:
+		c.Assert(err, jc.ErrorIsNil)
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for AcquireContext")
+	}
+	c.Assert(l.Stats(), jc.DeepEquals, utils.LimiterStats{
+		Capacity:    1,
+		InUse:       1,
+		Acquired:    2,
+		Waits:       1,
+		WaitTime:    time.Second,
+		MaxWaitTime: time.Second,
+	})
+}
+
+func (*limiterSuite) TestAcquireContextCancelled(c *gc.C) {
+	l := utils.NewLimiterWithClock(1, &steppingClock{})
+	c.Assert(l.Acquire(), jc.IsTrue)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- l.AcquireContext(ctx)
+	}()
+	waitForWaiting(c, l)
+	cancel()
+	select {
+	case err := <-done:
+		c.Assert(err, gc.Equals, context.Canceled)
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for AcquireContext")
+	}
+
+	// the cancelled caller did not acquire a unit.
+	c.Assert(l.Release(), jc.ErrorIsNil)
+	c.Assert(l.Release(), gc.ErrorMatches, "Release without an associated Acquire")
+	stats := l.Stats()
+	c.Assert(stats.Acquired, gc.Equals, uint64(1))
+	c.Assert(stats.Rejected, gc.Equals, uint64(1))
+	c.Assert(stats.Waiting, gc.Equals, 0)
+	c.Assert(stats.Saturated(), jc.IsFalse)
+}
+
+func (*limiterSuite) TestAcquireContextAlreadyDone(c *gc.C) {
+	l := utils.NewLimiter(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Assert(l.AcquireContext(ctx), gc.Equals, context.Canceled)
+	c.Assert(l.Stats().InUse, gc.Equals, 0)
+}
+
+func (*limiterSuite) TestStatsSaturated(c *gc.C) {
+	l := utils.NewLimiter(2)
+	c.Check(l.Acquire(), jc.IsTrue)
+	c.Check(l.Stats().Saturated(), jc.IsFalse)
+	c.Check(l.Acquire(), jc.IsTrue)
+	c.Check(l.Acquire(), jc.IsFalse)
+	stats := l.Stats()
+	c.Check(stats.Saturated(), jc.IsTrue)
+	c.Check(stats, jc.DeepEquals, utils.LimiterStats{
+		Capacity: 2,
+		InUse:    2,
+		Acquired: 2,
+		Rejected: 1,
+	})
+}
+
+func (*limiterSuite) TestKeyedLimiter(c *gc.C) {
+	kl, err := utils.NewKeyedLimiter(utils.KeyedLimiterConfig{
+		Default: 1,
+		Limits:  map[string]int{"download": 2},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(kl.Acquire("host1"), jc.IsTrue)
+	c.Check(kl.Acquire("host1"), jc.IsFalse)
+	c.Check(kl.Acquire("host2"), jc.IsTrue)
+	c.Check(kl.Acquire("download"), jc.IsTrue)
+	c.Check(kl.Acquire("download"), jc.IsTrue)
+	c.Check(kl.Acquire("download"), jc.IsFalse)
+	c.Check(kl.Release("host1"), jc.ErrorIsNil)
+	c.Check(kl.AcquireContext(context.Background(), "host1"), jc.ErrorIsNil)
+	c.Check(kl.Release("host3"), gc.ErrorMatches, "Release without an associated Acquire")
+
+	stats := kl.Stats()
+	c.Check(stats, gc.HasLen, 4)
+	c.Check(stats["host1"].Acquired, gc.Equals, uint64(2))
+	c.Check(stats["host1"].Rejected, gc.Equals, uint64(1))
+	c.Check(stats["host2"].Saturated(), jc.IsTrue)
+	c.Check(stats["download"].Capacity, gc.Equals, 2)
+	c.Check(stats["host3"].InUse, gc.Equals, 0)
+}
+
+func (*limiterSuite) TestKeyedLimiterInvalidConfig(c *gc.C) {
+	_, err := utils.NewKeyedLimiter(utils.KeyedLimiterConfig{
+		Limits: map[string]int{"host1": -1},
+	})
+	c.Assert(err, gc.ErrorMatches, `negative limit for "host1" not valid`)
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
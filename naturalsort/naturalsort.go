@@ -0,0 +1,133 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// The naturalsort package sorts strings in natural order, comparing the
+// runs of digits they contain by their numeric value rather than byte by
+// byte, so that "sda2" sorts before "sda10" and version "1.9.2" before
+// "1.10.0".
+package naturalsort
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Sort sorts the given strings in natural order.
+func Sort(values []string) {
+	sort.Sort(naturally(values))
+}
+
+// SortBy sorts the given slice in the natural order of the strings the
+// given function returns for its elements, given their index in the
+// unsorted slice. The sort is stable, so that elements with the same key
+// keep their order. SortBy panics if slice is not a slice.
+func SortBy(slice interface{}, key func(i int) string) {
+	// the keys are computed upfront, as the elements move while sorting.
+	keys := make([]string, reflect.ValueOf(slice).Len())
+	for i := range keys {
+		keys[i] = key(i)
+	}
+	sort.Stable(keyedSlice{keys, reflect.Swapper(slice)})
+}
+
+// NaturalLess returns whether a sorts before b in natural order.
+func NaturalLess(a, b string) bool {
+	return Compare(a, b) < 0
+}
+
+// Compare compares the given strings in natural order, returning -1 if a
+// sorts before b, 1 if it sorts after it and 0 if they are equal.
+//
+// The strings are split into runs of digits and of other characters,
+// which are compared in turn. Runs of digits are compared by their
+// numeric value, however long they are, and runs of other characters
+// byte by byte. A string which is a prefix of the other sorts first, so
+// that "1.2" sorts before "1.2.1". Strings with numbers differing only by
+// their leading zeros, such as "v01" and "v1", sort by their number of
+// zeros, the fewer first.
+func Compare(a, b string) int {
+	zeros := 0
+	for a != "" && b != "" {
+		var chunkA, chunkB string
+		var digitsA, digitsB bool
+		chunkA, a, digitsA = nextChunk(a)
+		chunkB, b, digitsB = nextChunk(b)
+		if digitsA && digitsB {
+			trimmedA := strings.TrimLeft(chunkA, "0")
+			trimmedB := strings.TrimLeft(chunkB, "0")
+			if c := compareNumbers(trimmedA, trimmedB); c != 0 {
+				return c
+			}
+			if zeros == 0 {
+				zeros = compareInts(len(chunkA), len(chunkB))
+			}
+			continue
+		}
+		if c := strings.Compare(chunkA, chunkB); c != 0 {
+			return c
+		}
+	}
+	if c := compareInts(len(a), len(b)); c != 0 {
+		return c
+	}
+	return zeros
+}
+
+// nextChunk splits the given non-empty string after its first
+// run of digits or of other characters, and returns whether the
+// run is of digits.
+func nextChunk(s string) (chunk, rest string, digits bool) {
+	digits = isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i], s[i:], digits
+}
+
+// compareNumbers compares the given decimal numbers,
+// which have no leading zeros.
+func compareNumbers(a, b string) int {
+	if c := compareInts(len(a), len(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// naturally implements sort.Interface for sorting
+// strings in natural order.
+type naturally []string
+
+func (s naturally) Len() int           { return len(s) }
+func (s naturally) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s naturally) Less(i, j int) bool { return NaturalLess(s[i], s[j]) }
+
+// keyedSlice implements sort.Interface for sorting a slice
+// in the natural order of the keys of its elements.
+type keyedSlice struct {
+	keys []string
+	swap func(i, j int)
+}
+
+func (s keyedSlice) Len() int           { return len(s.keys) }
+func (s keyedSlice) Less(i, j int) bool { return NaturalLess(s.keys[i], s.keys[j]) }
+
+func (s keyedSlice) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.swap(i, j)
+}
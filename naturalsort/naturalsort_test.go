@@ -0,0 +1,95 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package naturalsort_test
+
+import (
+	"github.com/juju/testing"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/naturalsort"
+)
+
+type naturalSortSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&naturalSortSuite{})
+
+func (*naturalSortSuite) TestCompare(c *gc.C) {
+	for i, test := range []struct {
+		a, b   string
+		expect int
+	}{
+		{"", "", 0},
+		{"", "a", -1},
+		{"a", "a", 0},
+		{"a", "b", -1},
+		{"sda2", "sda10", -1},
+		{"sda10", "sda2", 1},
+		{"sda", "sda1", -1},
+		{"1.9.2", "1.10.0", -1},
+		{"1.2", "1.2.1", -1},
+		{"1.2.0", "1.2.0", 0},
+		{"12.04", "9.10", 1},
+		{"2.0-beta1", "2.0-beta10", -1},
+		{"2.0-alpha2", "2.0-beta1", -1},
+		{"v1", "v01", -1},
+		{"v01", "v001", -1},
+		{"v01a", "v1b", -1},
+		{"99999999999999999999999", "100000000000000000000000", -1},
+		{"a1b", "a1c", -1},
+		{"1a", "a", -1},
+	} {
+		c.Logf("test %d: %q %q", i, test.a, test.b)
+		c.Check(naturalsort.Compare(test.a, test.b), gc.Equals, test.expect)
+		c.Check(naturalsort.Compare(test.b, test.a), gc.Equals, -test.expect)
+		c.Check(naturalsort.NaturalLess(test.a, test.b), gc.Equals, test.expect < 0)
+	}
+}
+
+func (*naturalSortSuite) TestSort(c *gc.C) {
+	values := []string{
+		"sda10", "sdb", "sda2", "sda1", "sda", "nvme0n1p10", "nvme0n1p9",
+	}
+	naturalsort.Sort(values)
+	c.Assert(values, gc.DeepEquals, []string{
+		"nvme0n1p9", "nvme0n1p10", "sda", "sda1", "sda2", "sda10", "sdb",
+	})
+}
+
+func (*naturalSortSuite) TestSortVersions(c *gc.C) {
+	values := []string{"1.10.0", "1.2", "1.9.2", "1.2.1", "10.0", "1.25.6"}
+	naturalsort.Sort(values)
+	c.Assert(values, gc.DeepEquals, []string{
+		"1.2", "1.2.1", "1.9.2", "1.10.0", "1.25.6", "10.0",
+	})
+}
+
+func (*naturalSortSuite) TestSortBy(c *gc.C) {
+	type disk struct {
+		device string
+		id     int
+	}
+	disks := []disk{
+		{"sdb10", 0},
+		{"sdb2", 1},
+		{"sda1", 2},
+		{"sdb2", 3},
+	}
+	naturalsort.SortBy(disks, func(i int) string {
+		return disks[i].device
+	})
+	c.Assert(disks, gc.DeepEquals, []disk{
+		{"sda1", 2},
+		{"sdb2", 1},
+		{"sdb2", 3},
+		{"sdb10", 0},
+	})
+}
+
+func (*naturalSortSuite) TestSortByNotSlice(c *gc.C) {
+	c.Assert(func() {
+		naturalsort.SortBy("sda1", func(int) string { return "" })
+	}, gc.PanicMatches, `reflect: call of Swapper on string Value`)
+}
@@ -6,7 +6,10 @@ package utils
 import (
 	"fmt"
 	"net"
+	"sort"
+	"strings"
 
+	"github.com/juju/errors"
 	"github.com/juju/loggo"
 )
 
@@ -44,3 +47,192 @@ func GetAddressForInterface(interfaceName string) (string, error) {
 	}
 	return GetIPv4Address(addrs)
 }
+
+// interfaceAddrs returns the addresses of the named network interface.
+// It is a variable for testing purposes.
+var interfaceAddrs = func(interfaceName string) ([]net.Addr, error) {
+	iface, err := net.InterfaceByName(interfaceName)
+	if err != nil {
+		return nil, errors.Annotatef(err, "cannot find network interface %q", interfaceName)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, errors.Annotatef(err, "cannot get addresses for network interface %q", interfaceName)
+	}
+	return addrs, nil
+}
+
+// GetAddressesForInterface returns all the IPv4 and IPv6 addresses of
+// the named network interface. The IPv6 link-local addresses are zoned
+// to the interface, so that they can be dialled.
+func GetAddressesForInterface(interfaceName string) ([]net.IPAddr, error) {
+	addrs, err := interfaceAddrs(interfaceName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return ParseAddresses(addrs, interfaceName)
+}
+
+// ParseAddresses returns the IP addresses of the given network
+// addresses, in the format from
+// func (ifi *net.Interface) Addrs() ([]net.Addr, error)
+// or that of a net.IPAddr. The IPv6 link-local addresses which have no
+// zone are given the specified one, usually the name of their interface.
+func ParseAddresses(addresses []net.Addr, zone string) ([]net.IPAddr, error) {
+	result := make([]net.IPAddr, 0, len(addresses))
+	for _, addr := range addresses {
+		ipAddr, err := parseAddress(addr)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if ipAddr.Zone == "" && ipAddr.IP.To4() == nil && ipAddr.IP.IsLinkLocalUnicast() {
+			ipAddr.Zone = zone
+		}
+		result = append(result, ipAddr)
+	}
+	return result, nil
+}
+
+// parseAddress returns the IP address of the given network address.
+func parseAddress(addr net.Addr) (net.IPAddr, error) {
+	switch addr := addr.(type) {
+	case *net.IPNet:
+		return net.IPAddr{IP: addr.IP}, nil
+	case *net.IPAddr:
+		return *addr, nil
+	}
+	// "fe80::1%eth0/64" is neither accepted by net.ParseCIDR
+	// nor by net.ParseIP, so the zone is split first.
+	value, zone := addr.String(), ""
+	if i := strings.Index(value, "%"); i != -1 {
+		zone = value[i+1:]
+		value = value[:i]
+		if j := strings.Index(zone, "/"); j != -1 {
+			value += zone[j:]
+			zone = zone[:j]
+		}
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		var err error
+		if ip, _, err = net.ParseCIDR(value); err != nil {
+			return net.IPAddr{}, errors.NotValidf("address %q", addr.String())
+		}
+	}
+	return net.IPAddr{IP: ip, Zone: zone}, nil
+}
+
+// AddressScope classifies IP addresses by how widely they can be
+// reached. The scopes are ordered from the most to the least preferred.
+type AddressScope int
+
+const (
+	// ScopeGlobal is the scope of the globally routable unicast
+	// addresses.
+	ScopeGlobal AddressScope = iota
+
+	// ScopePrivate is the scope of the private addresses of RFC 1918
+	// and of the IPv6 unique local addresses of RFC 4193.
+	ScopePrivate
+
+	// ScopeLinkLocal is the scope of the addresses only reachable on
+	// their link, which are only usable with a zone in IPv6.
+	ScopeLinkLocal
+
+	// ScopeLoopback is the scope of the loopback addresses.
+	ScopeLoopback
+
+	// ScopeOther is the scope of the multicast and unspecified
+	// addresses, which are never selected.
+	ScopeOther
+)
+
+var privateNetworks = []*net.IPNet{
+	mustParseCIDR("10.0.0.0/8"),
+	mustParseCIDR("172.16.0.0/12"),
+	mustParseCIDR("192.168.0.0/16"),
+	mustParseCIDR("fc00::/7"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return ipNet
+}
+
+// AddressScopeOf returns the scope of the given IP address.
+func AddressScopeOf(ip net.IP) AddressScope {
+	switch {
+	case ip.IsLoopback():
+		return ScopeLoopback
+	case ip.IsLinkLocalUnicast():
+		return ScopeLinkLocal
+	case !ip.IsGlobalUnicast():
+		return ScopeOther
+	}
+	for _, ipNet := range privateNetworks {
+		if ipNet.Contains(ip) {
+			return ScopePrivate
+		}
+	}
+	return ScopeGlobal
+}
+
+// AddressSelection configures which addresses SelectAddresses keeps.
+type AddressSelection struct {
+	// Network is "ip4" or "ip6" to only keep the addresses of that
+	// family. Both families are kept if it is empty.
+	Network string
+
+	// LinkLocal keeps the link-local addresses.
+	LinkLocal bool
+
+	// Loopback keeps the loopback addresses.
+	Loopback bool
+}
+
+// SelectAddresses returns the given addresses which match the selection,
+// ordered by preference: global unicast addresses first, then private
+// ones, then link-local and finally loopback ones. Addresses of the same
+// scope keep their order.
+func SelectAddresses(addresses []net.IPAddr, selection AddressSelection) []net.IPAddr {
+	var result []net.IPAddr
+	for _, addr := range addresses {
+		isIPv4 := addr.IP.To4() != nil
+		switch {
+		case selection.Network == "ip4" && !isIPv4,
+			selection.Network == "ip6" && isIPv4:
+			continue
+		}
+		switch AddressScopeOf(addr.IP) {
+		case ScopeLinkLocal:
+			if !selection.LinkLocal {
+				continue
+			}
+		case ScopeLoopback:
+			if !selection.Loopback {
+				continue
+			}
+		case ScopeOther:
+			continue
+		}
+		result = append(result, addr)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return AddressScopeOf(result[i].IP) < AddressScopeOf(result[j].IP)
+	})
+	return result
+}
+
+// PreferredAddress returns the most preferred of the given addresses
+// which match the selection, as ordered by SelectAddresses. It returns
+// an error satisfying errors.IsNotFound if none match.
+func PreferredAddress(addresses []net.IPAddr, selection AddressSelection) (net.IPAddr, error) {
+	selected := SelectAddresses(addresses, selection)
+	if len(selected) == 0 {
+		return net.IPAddr{}, errors.NotFoundf("address matching selection")
+	}
+	return selected[0], nil
+}
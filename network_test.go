@@ -6,7 +6,9 @@ package utils_test
 import (
 	"net"
 
+	"github.com/juju/errors"
 	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
 	"github.com/juju/utils"
@@ -75,3 +77,122 @@ func (*networkSuite) TestGetIPv4Address(c *gc.C) {
 		}
 	}
 }
+
+func (*networkSuite) TestParseAddresses(c *gc.C) {
+	addrs, err := utils.ParseAddresses([]net.Addr{
+		&fakeAddress{"10.0.3.1/24"},
+		&fakeAddress{"fe80::90cf:9dff:fe6e:ece/64"},
+		&fakeAddress{"fe80::1%eth1/64"},
+		&fakeAddress{"2001:db8::1"},
+		&net.IPNet{IP: net.ParseIP("192.168.0.1"), Mask: net.CIDRMask(24, 32)},
+		&net.IPAddr{IP: net.ParseIP("fe80::2")},
+	}, "eth0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(addrs, jc.DeepEquals, []net.IPAddr{
+		{IP: net.ParseIP("10.0.3.1")},
+		{IP: net.ParseIP("fe80::90cf:9dff:fe6e:ece"), Zone: "eth0"},
+		{IP: net.ParseIP("fe80::1"), Zone: "eth1"},
+		{IP: net.ParseIP("2001:db8::1")},
+		{IP: net.ParseIP("192.168.0.1")},
+		{IP: net.ParseIP("fe80::2"), Zone: "eth0"},
+	})
+}
+
+func (*networkSuite) TestParseAddressesInvalid(c *gc.C) {
+	_, err := utils.ParseAddresses(makeAddresses("10.0.3.1/24", "nonsense"), "eth0")
+	c.Assert(err, gc.ErrorMatches, `address "nonsense" not valid`)
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *networkSuite) TestGetAddressesForInterface(c *gc.C) {
+	s.PatchValue(utils.InterfaceAddrs, func(name string) ([]net.Addr, error) {
+		c.Check(name, gc.Equals, "eth0")
+		return makeAddresses("10.0.3.1/24", "fe80::1/64"), nil
+	})
+	addrs, err := utils.GetAddressesForInterface("eth0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(addrs, jc.DeepEquals, []net.IPAddr{
+		{IP: net.ParseIP("10.0.3.1")},
+		{IP: net.ParseIP("fe80::1"), Zone: "eth0"},
+	})
+}
+
+func (s *networkSuite) TestGetAddressesForInterfaceError(c *gc.C) {
+	s.PatchValue(utils.InterfaceAddrs, func(name string) ([]net.Addr, error) {
+		return nil, errors.New("boom")
+	})
+	_, err := utils.GetAddressesForInterface("eth0")
+	c.Assert(err, gc.ErrorMatches, "boom")
+}
+
+func (*networkSuite) TestAddressScopeOf(c *gc.C) {
+	for _, test := range []struct {
+		ip    string
+		scope utils.AddressScope
+	}{
+		{"8.8.8.8", utils.ScopeGlobal},
+		{"2001:db8::1", utils.ScopeGlobal},
+		{"10.1.2.3", utils.ScopePrivate},
+		{"172.16.0.1", utils.ScopePrivate},
+		{"172.32.0.1", utils.ScopeGlobal},
+		{"192.168.1.1", utils.ScopePrivate},
+		{"fd00::1", utils.ScopePrivate},
+		{"169.254.1.1", utils.ScopeLinkLocal},
+		{"fe80::1", utils.ScopeLinkLocal},
+		{"127.0.0.1", utils.ScopeLoopback},
+		{"::1", utils.ScopeLoopback},
+		{"0.0.0.0", utils.ScopeOther},
+		{"ff02::1", utils.ScopeOther},
+	} {
+		c.Logf("ip %s", test.ip)
+		c.Check(utils.AddressScopeOf(net.ParseIP(test.ip)), gc.Equals, test.scope)
+	}
+}
+
+func makeIPAddrs(values ...string) (result []net.IPAddr) {
+	for _, v := range values {
+		result = append(result, net.IPAddr{IP: net.ParseIP(v)})
+	}
+	return
+}
+
+func (*networkSuite) TestSelectAddresses(c *gc.C) {
+	addrs := makeIPAddrs(
+		"127.0.0.1", "fe80::1", "10.0.0.1", "2001:db8::1",
+		"169.254.0.1", "8.8.8.8", "fd00::1", "ff02::1",
+	)
+	for i, test := range []struct {
+		selection utils.AddressSelection
+		expected  []net.IPAddr
+	}{{
+		expected: makeIPAddrs("2001:db8::1", "8.8.8.8", "10.0.0.1", "fd00::1"),
+	}, {
+		selection: utils.AddressSelection{LinkLocal: true, Loopback: true},
+		expected: makeIPAddrs(
+			"2001:db8::1", "8.8.8.8", "10.0.0.1", "fd00::1",
+			"fe80::1", "169.254.0.1", "127.0.0.1",
+		),
+	}, {
+		selection: utils.AddressSelection{Network: "ip4"},
+		expected:  makeIPAddrs("8.8.8.8", "10.0.0.1"),
+	}, {
+		selection: utils.AddressSelection{Network: "ip6", LinkLocal: true},
+		expected:  makeIPAddrs("2001:db8::1", "fd00::1", "fe80::1"),
+	}} {
+		c.Logf("test %d: %+v", i, test.selection)
+		c.Check(utils.SelectAddresses(addrs, test.selection), jc.DeepEquals, test.expected)
+	}
+}
+
+func (*networkSuite) TestPreferredAddress(c *gc.C) {
+	addr, err := utils.PreferredAddress(
+		makeIPAddrs("fe80::1", "192.168.0.2", "10.0.0.1"),
+		utils.AddressSelection{},
+	)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(addr, jc.DeepEquals, net.IPAddr{IP: net.ParseIP("192.168.0.2")})
+
+	_, err = utils.PreferredAddress(makeIPAddrs("fe80::1"), utils.AddressSelection{})
+	c.Assert(err, gc.ErrorMatches, "address matching selection not found")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
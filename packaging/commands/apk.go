@@ -0,0 +1,63 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands
+
+const (
+	// ApkRepositoriesFilePath is the file which lists all the repositories
+	// configured on an Alpine system, one per line.
+	ApkRepositoriesFilePath = "/etc/apk/repositories"
+
+	// ApkProxyConfigFilePath is the file holding the proxy settings used by
+	// apk, which honours the standard proxy environment variables.
+	ApkProxyConfigFilePath = "/etc/profile.d/juju-proxy.sh"
+)
+
+const (
+	// the basic command for all apk calls:
+	//		--no-progress to limit output verbosity
+	apk = "apk --no-progress"
+
+	// the basic format for specifying a proxy setting for apk.
+	apkProxySettingFormat = "export %s_proxy=%s"
+)
+
+// apkCmder is the packageCommander instantiation for apk-based systems.
+var apkCmder = packageCommander{
+	prereq:              "", // apk manages repositories natively
+	update:              buildCommand(apk, "update"),
+	upgrade:             buildCommand(apk, "upgrade"),
+	upgradeOnly:         buildCommand(apk, "upgrade"),
+	install:             buildCommand(apk, "add"),
+	pinnedPackage:       "%s=%s",
+	noRecommends:        "",
+	installLocal:        buildCommand(apk, "add --allow-untrusted"),
+	downgrade:           buildCommand(apk, "add"),
+	rollback:            "",
+	remove:              buildCommand(apk, "del"),
+	purge:               buildCommand(apk, "del --purge"),
+	fetch:               buildCommand(apk, "fetch --output %s"),
+	fetchWithDeps:       buildCommand(apk, "fetch --recursive --output %s"),
+	hold:                "",
+	unhold:              "",
+	simulate:            "",
+	search:              buildCommand(apk, "search --exact %s"),
+	searchPackages:      "",
+	depends:             "",
+	rdepends:            "",
+	listVersions:        "",
+	verify:              "",
+	verifyAll:           "",
+	owner:               "",
+	isInstalled:         buildCommand("apk", "info --installed %s"),
+	listAvailable:       buildCommand(apk, "search"),
+	listInstalled:       buildCommand(apk, "info -v"),
+	listRepositories:    buildCommand("grep -v ^#", ApkRepositoriesFilePath),
+	addRepository:       "", // done by editing ApkRepositoriesFilePath directly
+	removeRepository:    "", // done by editing ApkRepositoriesFilePath directly
+	cleanup:             buildCommand(apk, "cache clean"),
+	autoremove:          "", // apk del removes unneeded dependencies itself
+	getProxy:            buildCommand("grep _proxy=", ApkProxyConfigFilePath),
+	proxySettingsFormat: apkProxySettingFormat,
+	setProxy:            "", // see the manager
+}
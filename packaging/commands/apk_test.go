@@ -0,0 +1,39 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands_test
+
+import (
+	"github.com/juju/utils/packaging/commands"
+	"github.com/juju/utils/proxy"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&ApkSuite{})
+
+type ApkSuite struct {
+	paccmder commands.PackageCommander
+}
+
+func (s *ApkSuite) SetUpSuite(c *gc.C) {
+	s.paccmder = commands.NewApkPackageCommander()
+}
+
+func (s *ApkSuite) TestNewPackageCommander(c *gc.C) {
+	cmder, err := commands.NewPackageCommander("alpine")
+	c.Assert(err, gc.IsNil)
+	c.Assert(cmder, gc.Equals, s.paccmder)
+}
+
+func (s *ApkSuite) TestInstallCmd(c *gc.C) {
+	c.Assert(s.paccmder.InstallCmd("curl", "git"), gc.Equals, "apk --no-progress add curl git")
+}
+
+func (s *ApkSuite) TestProxyConfigContentsPartial(c *gc.C) {
+	sets := proxy.Settings{
+		Http: "dat-proxy.zone:8080",
+	}
+
+	output := s.paccmder.ProxyConfigContents(sets)
+	c.Assert(output, gc.Equals, "export http_proxy=dat-proxy.zone:8080")
+}
@@ -9,6 +9,10 @@ const (
 	// written by cloud-init and the machine environ worker.
 	AptConfFilePath = "/etc/apt/apt.conf.d/42-juju-proxy-settings"
 
+	// AptKeyringsDir is the directory holding the keyrings of repositories
+	// which are referenced by the signed-by option of their sources.
+	AptKeyringsDir = "/etc/apt/keyrings"
+
 	// the basic command for all dpkg calls:
 	dpkg = "dpkg"
 
@@ -40,18 +44,37 @@ var aptCmder = packageCommander{
 	prereq:              buildCommand(aptget, "install python-software-properties"),
 	update:              buildCommand(aptget, "update"),
 	upgrade:             buildCommand(aptget, "upgrade"),
+	upgradeOnly:         buildCommand(aptget, "install --only-upgrade"),
 	install:             buildCommand(aptget, "install"),
+	pinnedPackage:       "%s=%s",
+	noRecommends:        "--no-install-recommends",
+	installLocal:        buildCommand(aptget, "install"),
+	downgrade:           buildCommand(aptget, "install --allow-downgrades"),
+	rollback:            "",
 	remove:              buildCommand(aptget, "remove"),
 	purge:               buildCommand(aptget, "purge"),
+	fetch:               "", // apt-get download only downloads to the working directory
+	fetchWithDeps:       buildCommand(aptget, "--option=Dir::Cache::Archives=%s install --download-only"),
+	hold:                buildCommand("apt-mark", "hold"),
+	unhold:              buildCommand("apt-mark", "unhold"),
+	simulate:            "--simulate",
 	search:              buildCommand(aptcache, "search --names-only ^%s$"),
+	searchPackages:      buildCommand(aptcache, "search --full %s"),
+	depends:             buildCommand(aptcache, "depends --recurse --important %s"),
+	rdepends:            buildCommand(aptcache, "rdepends --recurse --important --installed %s"),
+	listVersions:        buildCommand(aptcache, "policy %s"),
+	verify:              buildCommand("debsums", "--silent"),
+	verifyAll:           buildCommand("debsums", "--silent"),
+	owner:               buildCommand(dpkg, "--search %s"),
 	isInstalled:         buildCommand(dpkgquery, "-s %s"),
 	listAvailable:       buildCommand(aptcache, "pkgnames"),
-	listInstalled:       buildCommand(dpkg, "--get-selections"),
+	listInstalled:       buildCommand(dpkgquery, `--show --showformat=${Status}\t${Package}\t${Version}\t${Architecture}\n`),
 	addRepository:       buildCommand(addaptrepo, "%q"),
 	listRepositories:    buildCommand(`sed -r -n "s|^deb(-src)? (.*)|\2|p"`, "/etc/apt/sources.list"),
 	removeRepository:    buildCommand(addaptrepo, "--remove ppa:%s"),
 	cleanup:             buildCommand(aptget, "autoremove"),
+	autoremove:          buildCommand(aptget, "autoremove"),
 	getProxy:            buildCommand(aptconfig, "Acquire::http::Proxy Acquire::https::Proxy Acquire::ftp::Proxy"),
 	proxySettingsFormat: aptProxySettingFormat,
-	setProxy:            buildCommand("echo %s >> ", AptConfFilePath),
+	setProxy:            "", // see the manager
 }
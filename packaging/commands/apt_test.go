@@ -47,3 +47,51 @@ Acquire::ftp::Proxy "gimme-files.zone";`
 	output := s.paccmder.ProxyConfigContents(sets)
 	c.Assert(output, gc.Equals, expected)
 }
+
+func (s *AptSuite) TestInstallVersionCmd(c *gc.C) {
+	output := s.paccmder.InstallVersionCmd("juju", "2.0.0-0ubuntu1")
+	c.Assert(output, gc.Equals, "apt-get --option=Dpkg::Options::=--force-confold --option=Dpkg::options::=--force-unsafe-io --assume-yes --quiet install juju=2.0.0-0ubuntu1")
+}
+
+func (s *AptSuite) TestHoldCmds(c *gc.C) {
+	c.Assert(s.paccmder.HoldCmd("linux-generic", "juju"), gc.Equals, "apt-mark hold linux-generic juju")
+	c.Assert(s.paccmder.UnholdCmd("linux-generic", "juju"), gc.Equals, "apt-mark unhold linux-generic juju")
+}
+
+func (s *AptSuite) TestSimulateCmds(c *gc.C) {
+	c.Assert(s.paccmder.SimulateInstallCmd("juju"), gc.Equals, s.paccmder.InstallCmd("--simulate", "juju"))
+	c.Assert(s.paccmder.SimulateRemoveCmd("juju"), gc.Equals, s.paccmder.RemoveCmd("--simulate", "juju"))
+	c.Assert(s.paccmder.SimulateUpgradeCmd(), gc.Equals, s.paccmder.UpgradeCmd()+" --simulate")
+}
+
+func (s *AptSuite) TestListVersionsCmd(c *gc.C) {
+	c.Assert(s.paccmder.ListVersionsCmd("juju"), gc.Equals, "apt-cache policy juju")
+}
+
+func (s *AptSuite) TestUpgradeOnlyCmd(c *gc.C) {
+	c.Assert(s.paccmder.UpgradeOnlyCmd("juju", "lxd"), gc.Equals,
+		"apt-get --option=Dpkg::Options::=--force-confold --option=Dpkg::options::=--force-unsafe-io --assume-yes --quiet install --only-upgrade juju lxd")
+}
+
+func (s *AptSuite) TestInstallNoRecommendsCmd(c *gc.C) {
+	c.Assert(s.paccmder.InstallNoRecommendsCmd("juju"), gc.Equals,
+		"apt-get --option=Dpkg::Options::=--force-confold --option=Dpkg::options::=--force-unsafe-io --assume-yes --quiet install --no-install-recommends juju")
+}
+
+func (s *AptSuite) TestDowngradeCmd(c *gc.C) {
+	c.Assert(s.paccmder.DowngradeCmd("juju", "1.25.6-0ubuntu1"), gc.Equals,
+		"apt-get --option=Dpkg::Options::=--force-confold --option=Dpkg::options::=--force-unsafe-io --assume-yes --quiet install --allow-downgrades juju=1.25.6-0ubuntu1")
+}
+
+func (s *AptSuite) TestRollbackCmdNotSupported(c *gc.C) {
+	c.Assert(s.paccmder.RollbackCmd("42"), gc.Equals, "")
+}
+
+func (s *AptSuite) TestVerifyCmd(c *gc.C) {
+	c.Assert(s.paccmder.VerifyCmd("juju"), gc.Equals, "debsums --silent juju")
+	c.Assert(s.paccmder.VerifyCmd(), gc.Equals, "debsums --silent")
+}
+
+func (s *AptSuite) TestOwnerCmd(c *gc.C) {
+	c.Assert(s.paccmder.OwnerCmd("/usr/bin/juju"), gc.Equals, "dpkg --search /usr/bin/juju")
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands
+
+const (
+	// the basic command for all brew calls.
+	brew = "brew"
+)
+
+// brewCmder is the packageCommander instantiation for Homebrew.
+// Taps are treated as repositories. Homebrew has no proxy configuration of
+// its own and relies on the proxy environment variables instead.
+var brewCmder = packageCommander{
+	prereq:              "",
+	update:              buildCommand(brew, "update"),
+	upgrade:             buildCommand(brew, "upgrade"),
+	upgradeOnly:         buildCommand(brew, "upgrade"),
+	install:             buildCommand(brew, "install"),
+	pinnedPackage:       "%s@%s",
+	noRecommends:        "",
+	installLocal:        "", // formulae are only installed from taps
+	downgrade:           "", // older versions are distinct formulae
+	rollback:            "",
+	remove:              buildCommand(brew, "uninstall"),
+	purge:               buildCommand(brew, "uninstall --force"), // removes all versions
+	fetch:               "",
+	fetchWithDeps:       "",
+	hold:                buildCommand(brew, "pin"),
+	unhold:              buildCommand(brew, "unpin"),
+	simulate:            "",
+	search:              buildCommand(brew, "info %s"),
+	searchPackages:      "",
+	depends:             "",
+	rdepends:            "",
+	listVersions:        "",
+	verify:              "",
+	verifyAll:           "",
+	owner:               "",
+	isInstalled:         buildCommand(brew, "list --versions %s"),
+	listAvailable:       buildCommand(brew, "search"),
+	listInstalled:       buildCommand(brew, "list --versions"),
+	listRepositories:    buildCommand(brew, "tap"),
+	addRepository:       buildCommand(brew, "tap %s"),
+	removeRepository:    buildCommand(brew, "untap %s"),
+	cleanup:             buildCommand(brew, "cleanup"),
+	autoremove:          buildCommand(brew, "autoremove"),
+	getProxy:            "",
+	proxySettingsFormat: "",
+	setProxy:            "",
+}
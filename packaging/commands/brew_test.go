@@ -0,0 +1,44 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands_test
+
+import (
+	"github.com/juju/utils/packaging/commands"
+	"github.com/juju/utils/proxy"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&BrewSuite{})
+
+type BrewSuite struct {
+	paccmder commands.PackageCommander
+}
+
+func (s *BrewSuite) SetUpSuite(c *gc.C) {
+	s.paccmder = commands.NewBrewPackageCommander()
+}
+
+func (s *BrewSuite) TestNewPackageCommander(c *gc.C) {
+	cmder, err := commands.NewPackageCommander("elcapitan")
+	c.Assert(err, gc.IsNil)
+	c.Assert(cmder, gc.Equals, s.paccmder)
+}
+
+func (s *BrewSuite) TestTapsAsRepositories(c *gc.C) {
+	c.Assert(s.paccmder.AddRepositoryCmd("homebrew/science"), gc.Equals, "brew tap homebrew/science")
+	c.Assert(s.paccmder.RemoveRepositoryCmd("homebrew/science"), gc.Equals, "brew untap homebrew/science")
+}
+
+func (s *BrewSuite) TestProxyNotSupported(c *gc.C) {
+	sets := proxy.Settings{
+		Http: "dat-proxy.zone:8080",
+	}
+
+	c.Assert(s.paccmder.ProxyConfigContents(sets), gc.Equals, "")
+	c.Assert(s.paccmder.SetProxyCmds(sets), gc.HasLen, 0)
+}
+
+func (s *BrewSuite) TestInstallLocalCmdNotSupported(c *gc.C) {
+	c.Assert(s.paccmder.InstallLocalCmd("/tmp/juju.tar.gz"), gc.Equals, "")
+}
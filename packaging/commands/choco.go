@@ -0,0 +1,55 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands
+
+const (
+	// the basic command for all chocolatey calls.
+	choco = "choco"
+
+	// the flags passed to all chocolatey calls which alter the system:
+	//		--yes to never prompt for confirmation
+	//		--no-progress to limit output verbosity
+	chocoFlags = "--yes --no-progress"
+)
+
+// chocoCmder is the packageCommander instantiation for Chocolatey on Windows.
+// NOTE: the commands are meant to be run from PowerShell.
+var chocoCmder = packageCommander{
+	prereq:              "", // Chocolatey manages its sources natively
+	update:              "", // Chocolatey always queries its sources directly
+	upgrade:             buildCommand(choco, "upgrade all", chocoFlags),
+	upgradeOnly:         buildCommand(choco, "upgrade", chocoFlags, "--fail-on-not-installed"),
+	install:             buildCommand(choco, "install", chocoFlags),
+	pinnedPackage:       "%s --version=%s",
+	noRecommends:        "",
+	installLocal:        "", // packages are only installed from sources
+	downgrade:           buildCommand(choco, "upgrade", chocoFlags, "--allow-downgrade"),
+	rollback:            "",
+	remove:              buildCommand(choco, "uninstall", chocoFlags),
+	purge:               buildCommand(choco, "uninstall", chocoFlags, "--remove-dependencies"),
+	fetch:               "",
+	fetchWithDeps:       "",
+	hold:                "",
+	unhold:              "",
+	simulate:            "",
+	search:              buildCommand(choco, "search --exact --limit-output %s"),
+	searchPackages:      "",
+	depends:             "",
+	rdepends:            "",
+	listVersions:        "",
+	verify:              "",
+	verifyAll:           "",
+	owner:               "",
+	isInstalled:         buildCommand(choco, "list --local-only --exact --limit-output %s"),
+	listAvailable:       buildCommand(choco, "search --limit-output"),
+	listInstalled:       buildCommand(choco, "list --local-only --limit-output"),
+	listRepositories:    buildCommand(choco, "source list --limit-output"),
+	addRepository:       buildCommand(choco, "source add %s"),
+	removeRepository:    buildCommand(choco, "source remove --name=%s"),
+	cleanup:             "", // Chocolatey cleans up after itself
+	autoremove:          "",
+	getProxy:            buildCommand(choco, "config get --name=proxy --limit-output"),
+	proxySettingsFormat: "", // Chocolatey has a single proxy setting, see the manager
+	setProxy:            "",
+}
@@ -0,0 +1,40 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands_test
+
+import (
+	"github.com/juju/utils/packaging/commands"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&ChocoSuite{})
+
+type ChocoSuite struct {
+	paccmder commands.PackageCommander
+}
+
+func (s *ChocoSuite) SetUpSuite(c *gc.C) {
+	s.paccmder = commands.NewChocoPackageCommander()
+}
+
+func (s *ChocoSuite) TestNewPackageCommander(c *gc.C) {
+	cmder, err := commands.NewPackageCommander("win2012r2")
+	c.Assert(err, gc.IsNil)
+	c.Assert(cmder, gc.Equals, s.paccmder)
+}
+
+func (s *ChocoSuite) TestInstallCmd(c *gc.C) {
+	c.Assert(s.paccmder.InstallCmd("git", "7zip"), gc.Equals, "choco install --yes --no-progress git 7zip")
+}
+
+func (s *ChocoSuite) TestRepositoryCmds(c *gc.C) {
+	c.Assert(s.paccmder.AddRepositoryCmd("--name=internal --source=https://choco.example.com"), gc.Equals,
+		"choco source add --name=internal --source=https://choco.example.com")
+	c.Assert(s.paccmder.RemoveRepositoryCmd("internal"), gc.Equals, "choco source remove --name=internal")
+}
+
+func (s *ChocoSuite) TestUnneededCmds(c *gc.C) {
+	c.Assert(s.paccmder.UpdateCmd(), gc.Equals, "")
+	c.Assert(s.paccmder.CleanupCmd(), gc.Equals, "")
+}
@@ -18,10 +18,28 @@ type packageCommander struct {
 	prereq              string // installs prerequisite repo management package
 	update              string // updates the local package list
 	upgrade             string // upgrades all packages
+	upgradeOnly         string // upgrades only the given packages
 	install             string // installs the given packages
+	pinnedPackage       string // format of a package pinned to a version
+	noRecommends        string // option which leaves out recommended packages from installs
+	installLocal        string // installs the given local package files
+	downgrade           string // downgrades the given package to a pinned version
+	rollback            string // reverts the given transaction
 	remove              string // removes the given packages
 	purge               string // removes the given packages along with all data
+	fetch               string // downloads the given packages into a directory
+	fetchWithDeps       string // downloads the given packages and their dependencies
+	hold                string // holds the given packages at their current version
+	unhold              string // releases the hold on the given packages
+	simulate            string // option which only reports the planned changes
 	search              string // searches for the given package
+	searchPackages      string // searches for packages matching the given term
+	depends             string // lists the dependencies of the given package
+	rdepends            string // lists the installed dependants of the given package
+	listVersions        string // lists the installed and available versions of the given package
+	verify              string // verifies the files of the given packages
+	verifyAll           string // verifies the files of all installed packages
+	owner               string // finds the installed packages owning the given path
 	isInstalled         string // checks if a given package is installed
 	listAvailable       string // lists all packes available
 	listInstalled       string // lists all installed packages
@@ -29,9 +47,14 @@ type packageCommander struct {
 	addRepository       string // adds the given repository
 	removeRepository    string // removes the given repository
 	cleanup             string // cleans up orhaned packages and the package cache
+	autoremove          string // removes automatically installed packages no longer needed
 	getProxy            string // command for getting the currently set packagemanager proxy
 	proxySettingsFormat string // format for proxy setting in package manager config file
 	setProxy            string // command for adding a proxy setting to the config file
+
+	// proxySettingName optionally transforms the name of a proxy setting
+	// (http, https or ftp) before it is formatted into the config file.
+	proxySettingName func(string) string
 }
 
 // InstallPrerequisiteCmd is defined on the PackageCommander interface.
@@ -49,11 +72,50 @@ func (p *packageCommander) UpgradeCmd() string {
 	return p.upgrade
 }
 
+// UpgradeOnlyCmd is defined on the PackageCommander interface.
+func (p *packageCommander) UpgradeOnlyCmd(packs ...string) string {
+	return addArgsToCommand(p.upgradeOnly, packs)
+}
+
 // InstallCmd is defined on the PackageCommander interface.
 func (p *packageCommander) InstallCmd(packs ...string) string {
 	return addArgsToCommand(p.install, packs)
 }
 
+// InstallNoRecommendsCmd is defined on the PackageCommander interface.
+func (p *packageCommander) InstallNoRecommendsCmd(packs ...string) string {
+	if p.noRecommends == "" || p.install == "" {
+		return ""
+	}
+	return addArgsToCommand(buildCommand(p.install, p.noRecommends), packs)
+}
+
+// InstallVersionCmd is defined on the PackageCommander interface.
+func (p *packageCommander) InstallVersionCmd(pack, version string) string {
+	if p.pinnedPackage == "" {
+		return ""
+	}
+	return addArgsToCommand(p.install, []string{fmt.Sprintf(p.pinnedPackage, pack, version)})
+}
+
+// DowngradeCmd is defined on the PackageCommander interface.
+func (p *packageCommander) DowngradeCmd(pack, version string) string {
+	if p.downgrade == "" || p.pinnedPackage == "" {
+		return ""
+	}
+	return addArgsToCommand(p.downgrade, []string{fmt.Sprintf(p.pinnedPackage, pack, version)})
+}
+
+// RollbackCmd is defined on the PackageCommander interface.
+func (p *packageCommander) RollbackCmd(transaction string) string {
+	return formatCommand(p.rollback, transaction)
+}
+
+// InstallLocalCmd is defined on the PackageCommander interface.
+func (p *packageCommander) InstallLocalCmd(paths ...string) string {
+	return addArgsToCommand(p.installLocal, paths)
+}
+
 // RemoveCmd is defined on the PackageCommander interface.
 func (p *packageCommander) RemoveCmd(packs ...string) string {
 	return addArgsToCommand(p.remove, packs)
@@ -64,14 +126,91 @@ func (p *packageCommander) PurgeCmd(packs ...string) string {
 	return addArgsToCommand(p.purge, packs)
 }
 
+// FetchCmd is defined on the PackageCommander interface.
+func (p *packageCommander) FetchCmd(dir string, packs ...string) string {
+	return addArgsToCommand(formatCommand(p.fetch, dir), packs)
+}
+
+// FetchWithDependenciesCmd is defined on the PackageCommander interface.
+func (p *packageCommander) FetchWithDependenciesCmd(dir string, packs ...string) string {
+	return addArgsToCommand(formatCommand(p.fetchWithDeps, dir), packs)
+}
+
+// HoldCmd is defined on the PackageCommander interface.
+func (p *packageCommander) HoldCmd(packs ...string) string {
+	return addArgsToCommand(p.hold, packs)
+}
+
+// UnholdCmd is defined on the PackageCommander interface.
+func (p *packageCommander) UnholdCmd(packs ...string) string {
+	return addArgsToCommand(p.unhold, packs)
+}
+
+// SimulateInstallCmd is defined on the PackageCommander interface.
+func (p *packageCommander) SimulateInstallCmd(packs ...string) string {
+	return p.simulateCmd(p.install, packs)
+}
+
+// SimulateRemoveCmd is defined on the PackageCommander interface.
+func (p *packageCommander) SimulateRemoveCmd(packs ...string) string {
+	return p.simulateCmd(p.remove, packs)
+}
+
+// SimulateUpgradeCmd is defined on the PackageCommander interface.
+func (p *packageCommander) SimulateUpgradeCmd() string {
+	return p.simulateCmd(p.upgrade, nil)
+}
+
+// simulateCmd is a helper function which returns the simulated
+// form of the given command with the given arguments.
+func (p *packageCommander) simulateCmd(cmd string, args []string) string {
+	if p.simulate == "" || cmd == "" {
+		return ""
+	}
+	return addArgsToCommand(buildCommand(cmd, p.simulate), args)
+}
+
 // SearchCmd is defined on the PackageCommander interface.
 func (p *packageCommander) SearchCmd(pack string) string {
-	return fmt.Sprintf(p.search, pack)
+	return formatCommand(p.search, pack)
+}
+
+// SearchPackagesCmd is defined on the PackageCommander interface.
+func (p *packageCommander) SearchPackagesCmd(term string) string {
+	return formatCommand(p.searchPackages, term)
+}
+
+// DependsCmd is defined on the PackageCommander interface.
+func (p *packageCommander) DependsCmd(pack string) string {
+	return formatCommand(p.depends, pack)
+}
+
+// RDependsCmd is defined on the PackageCommander interface.
+func (p *packageCommander) RDependsCmd(pack string) string {
+	return formatCommand(p.rdepends, pack)
+}
+
+// ListVersionsCmd is defined on the PackageCommander interface.
+func (p *packageCommander) ListVersionsCmd(pack string) string {
+	return formatCommand(p.listVersions, pack)
+}
+
+// VerifyCmd is defined on the PackageCommander interface.
+func (p *packageCommander) VerifyCmd(packs ...string) string {
+	if len(packs) == 0 {
+		return p.verifyAll
+	}
+	return addArgsToCommand(p.verify, packs)
+}
+
+// OwnerCmd is defined on the PackageCommander interface.
+func (p *packageCommander) OwnerCmd(path string) string {
+	return formatCommand(p.owner, path)
 }
 
 // IsInstalledCmd is defined on the PackageCommander interface.
 func (p *packageCommander) IsInstalledCmd(pack string) string {
-	return fmt.Sprintf(p.isInstalled, pack)
+	return formatCommand(p.isInstalled, pack)
 }
 
 // ListAvailableCmd is defined on the PackageCommander interface.
@@ -91,12 +230,12 @@ func (p *packageCommander) ListRepositoriesCmd() string {
 
 // AddRepositoryCmd is defined on the PackageCommander interface.
 func (p *packageCommander) AddRepositoryCmd(repo string) string {
-	return fmt.Sprintf(p.addRepository, repo)
+	return formatCommand(p.addRepository, repo)
 }
 
 // RemoveRepositoryCmd is defined on the PackageCommander interface.
 func (p *packageCommander) RemoveRepositoryCmd(repo string) string {
-	return fmt.Sprintf(p.removeRepository, repo)
+	return formatCommand(p.removeRepository, repo)
 }
 
 // CleanupCmd is defined on the PackageCommander interface.
@@ -104,6 +243,11 @@ func (p *packageCommander) CleanupCmd() string {
 	return p.cleanup
 }
 
+// AutoRemoveCmd is defined on the PackageCommander interface.
+func (p *packageCommander) AutoRemoveCmd() string {
+	return p.autoremove
+}
+
 // GetProxyCmd is defined on the PackageCommander interface.
 func (p *packageCommander) GetProxyCmd() string {
 	return p.getProxy
@@ -112,12 +256,18 @@ func (p *packageCommander) GetProxyCmd() string {
 // giveProxyOptions is a helper function which takes a possible proxy setting
 // and its value and returns the formatted option for it.
 func (p *packageCommander) giveProxyOption(setting, proxy string) string {
+	if p.proxySettingName != nil {
+		setting = p.proxySettingName(setting)
+	}
 	return fmt.Sprintf(p.proxySettingsFormat, setting, proxy)
 }
 
 // ProxyConfigContents is defined on the PackageCommander interface.
 func (p *packageCommander) ProxyConfigContents(settings proxy.Settings) string {
 	options := []string{}
+	if p.proxySettingsFormat == "" {
+		return ""
+	}
 
 	addOption := func(setting, proxy string) {
 		if proxy != "" {
@@ -135,6 +285,9 @@ func (p *packageCommander) ProxyConfigContents(settings proxy.Settings) string {
 // SetProxyCmds is defined on the PackageCommander interface.
 func (p *packageCommander) SetProxyCmds(settings proxy.Settings) []string {
 	cmds := []string{}
+	if p.setProxy == "" {
+		return cmds
+	}
 
 	addProxyCmd := func(setting, proxy string) {
 		if proxy != "" {
@@ -13,6 +13,8 @@ import (
 
 // PackageCommander is the interface which provides runnable shell
 // commands for various packaging-related operations.
+// An empty command is returned for operations which are not required by, or
+// not available on, the given package management system.
 type PackageCommander interface {
 	// InstallPrerequisiteCmd returns the command that installs the
 	// prerequisite package for repository-handling operations.
@@ -25,9 +27,39 @@ type PackageCommander interface {
 	// with available newer versions.
 	UpgradeCmd() string
 
+	// UpgradeOnlyCmd returns the command which upgrades only the given
+	// package(s), leaving the others at their installed versions. It
+	// returns an empty string if this is not supported.
+	UpgradeOnlyCmd(packs ...string) string
+
 	// InstallCmd returns a *single* command that installs the given package(s).
 	InstallCmd(...string) string
 
+	// InstallNoRecommendsCmd returns a *single* command that installs the
+	// given package(s) without the packages they only recommend, or weakly
+	// depend upon. It returns an empty string if the package management
+	// system does not install such packages in the first place.
+	InstallNoRecommendsCmd(packs ...string) string
+
+	// InstallVersionCmd returns the command that installs the given version
+	// of a package.
+	InstallVersionCmd(pack, version string) string
+
+	// DowngradeCmd returns the command that downgrades the given package
+	// to the given, older, version. It returns an empty string if this is
+	// not supported.
+	DowngradeCmd(pack, version string) string
+
+	// RollbackCmd returns the command that reverts the given transaction,
+	// as identified by the package management system. It returns an empty
+	// string if this is not supported.
+	RollbackCmd(transaction string) string
+
+	// InstallLocalCmd returns the command that installs the given package
+	// file(s), resolving their dependencies from the configured repositories.
+	// It returns an empty string if installing local files is not supported.
+	InstallLocalCmd(paths ...string) string
+
 	// RemoveCmd returns a *single* command that removes the given package(s).
 	RemoveCmd(...string) string
 
@@ -35,6 +67,46 @@ type PackageCommander interface {
 	// with any associated config files.
 	PurgeCmd(...string) string
 
+	// FetchCmd returns the command that downloads the given package(s) into
+	// the given directory without installing them.
+	FetchCmd(dir string, packs ...string) string
+
+	// FetchWithDependenciesCmd returns the command that downloads the given
+	// package(s), along with their dependencies, into the given directory
+	// without installing them.
+	FetchWithDependenciesCmd(dir string, packs ...string) string
+
+	// HoldCmd returns the command that holds the given package(s) at their
+	// currently installed version, excluding them from upgrades.
+	HoldCmd(packs ...string) string
+
+	// UnholdCmd returns the command that releases the hold on the given
+	// package(s), allowing them to be upgraded again.
+	UnholdCmd(packs ...string) string
+
+	// SimulateInstallCmd returns the command which reports the changes
+	// installing the given package(s) would make, without making them.
+	SimulateInstallCmd(packs ...string) string
+
+	// SimulateRemoveCmd returns the command which reports the changes
+	// removing the given package(s) would make, without making them.
+	SimulateRemoveCmd(packs ...string) string
+
+	// SimulateUpgradeCmd returns the command which reports the changes
+	// upgrading all packages would make, without making them.
+	SimulateUpgradeCmd() string
+
+	// VerifyCmd returns the command that checks the files of the given
+	// packages, or of all installed packages if none are given, against
+	// the package database. It returns an empty string if this is not
+	// supported.
+	VerifyCmd(packs ...string) string
+
+	// OwnerCmd returns the command that finds the installed package(s)
+	// owning the given path. It returns an empty string if this is not
+	// supported.
+	OwnerCmd(path string) string
+
 	// IsInstalledCmd returns the command which determines whether or not a
 	// package is currently installed on the system.
 	IsInstalledCmd(string) string
@@ -43,6 +115,27 @@ type PackageCommander interface {
 	// available for installation from the currently configured repositories.
 	SearchCmd(string) string
 
+	// SearchPackagesCmd returns the command that lists the packages matching
+	// the given term, along with their versions and descriptions, which are
+	// available for installation from the currently configured repositories.
+	SearchPackagesCmd(term string) string
+
+	// DependsCmd returns the command that lists the packages which the
+	// given package depends upon. It returns an empty string if listing
+	// dependencies is not supported.
+	DependsCmd(pack string) string
+
+	// RDependsCmd returns the command that lists the installed packages
+	// which depend upon the given package. It returns an empty string if
+	// listing reverse dependencies is not supported.
+	RDependsCmd(pack string) string
+
+	// ListVersionsCmd returns the command that lists the installed and
+	// available versions of the given package, along with the version
+	// which would be installed. It returns an empty string if listing
+	// versions is not supported.
+	ListVersionsCmd(pack string) string
+
 	// ListAvailableCmd returns the command which will list all packages
 	// available for installation from the currently configured repositories.
 	// NOTE: includes already installed packages.
@@ -74,6 +167,11 @@ type PackageCommander interface {
 	// left-over files and previously-cached packages.
 	CleanupCmd() string
 
+	// AutoRemoveCmd returns the command that removes the packages which
+	// were installed automatically, as dependencies, and are no longer
+	// needed. It returns an empty string if this is not supported.
+	AutoRemoveCmd() string
+
 	// GetProxyCmd returns the command which outputs the proxies set for the
 	// given package management system.
 	// NOTE: output may require some additional filtering.
@@ -96,6 +194,18 @@ func NewPackageCommander(series string) (PackageCommander, error) {
 	switch series {
 	case "centos7":
 		return NewYumPackageCommander(), nil
+	case "opensuseleap":
+		return NewZypperPackageCommander(), nil
+	case "arch":
+		return NewPacmanPackageCommander(), nil
+	case "alpine":
+		return NewApkPackageCommander(), nil
+	case "elcapitan", "yosemite", "mavericks":
+		return NewBrewPackageCommander(), nil
+	case "nixos":
+		return NewNixPackageCommander(), nil
+	case "win2012hvr2", "win2012hv", "win2012r2", "win2012", "win2016", "win7", "win8", "win81", "win10":
+		return NewChocoPackageCommander(), nil
 	default:
 		return NewAptPackageCommander(), nil
 	}
@@ -111,3 +221,38 @@ func NewAptPackageCommander() PackageCommander {
 func NewYumPackageCommander() PackageCommander {
 	return &yumCmder
 }
+
+// NewZypperPackageCommander returns a PackageCommander for zypper-based systems.
+func NewZypperPackageCommander() PackageCommander {
+	return &zypperCmder
+}
+
+// NewPacmanPackageCommander returns a PackageCommander for pacman-based systems.
+func NewPacmanPackageCommander() PackageCommander {
+	return &pacmanCmder
+}
+
+// NewApkPackageCommander returns a PackageCommander for apk-based systems.
+func NewApkPackageCommander() PackageCommander {
+	return &apkCmder
+}
+
+// NewSnapPackageCommander returns a PackageCommander for snapd.
+func NewSnapPackageCommander() PackageCommander {
+	return &snapCmder
+}
+
+// NewBrewPackageCommander returns a PackageCommander for Homebrew.
+func NewBrewPackageCommander() PackageCommander {
+	return &brewCmder
+}
+
+// NewChocoPackageCommander returns a PackageCommander for Chocolatey.
+func NewChocoPackageCommander() PackageCommander {
+	return &chocoCmder
+}
+
+// NewNixPackageCommander returns a PackageCommander for nix.
+func NewNixPackageCommander() PackageCommander {
+	return &nixCmder
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands
+
+const (
+	// the basic command for all nix-env calls:
+	//		--quiet to limit output verbosity
+	nixEnv = "nix-env --quiet"
+)
+
+// nixCmder is the packageCommander instantiation for the nix package manager,
+// operating on the profile of the current user.
+var nixCmder = packageCommander{
+	prereq:              "", // nix manages channels natively
+	update:              buildCommand("nix-channel", "--update"),
+	upgrade:             buildCommand(nixEnv, "--upgrade"),
+	upgradeOnly:         buildCommand(nixEnv, "--upgrade"),
+	install:             buildCommand(nixEnv, "--install"),
+	pinnedPackage:       "", // nix only installs the version in the channel
+	noRecommends:        "",
+	installLocal:        "", // derivations are only installed from channels
+	downgrade:           "",
+	rollback:            buildCommand(nixEnv, "--switch-generation %s"),
+	remove:              buildCommand(nixEnv, "--uninstall"),
+	purge:               buildCommand(nixEnv, "--uninstall"), // the store is cleaned up separately
+	fetch:               "",
+	fetchWithDeps:       "",
+	hold:                "",
+	unhold:              "",
+	simulate:            "",
+	search:              buildCommand(nixEnv, "--query --available %s"),
+	searchPackages:      "",
+	depends:             "",
+	rdepends:            "",
+	listVersions:        "",
+	verify:              "",
+	verifyAll:           "",
+	owner:               "",
+	isInstalled:         buildCommand(nixEnv, "--query %s"),
+	listAvailable:       buildCommand(nixEnv, "--query --available"),
+	listInstalled:       buildCommand(nixEnv, "--query"),
+	listRepositories:    buildCommand("nix-channel", "--list"),
+	addRepository:       buildCommand("nix-channel", "--add %s"),
+	removeRepository:    buildCommand("nix-channel", "--remove %s"),
+	cleanup:             buildCommand("nix-collect-garbage"), // keeps old generations for rollbacks
+	autoremove:          "",
+	getProxy:            "", // nix uses the proxy settings found in the environment
+	proxySettingsFormat: "",
+	setProxy:            "",
+}
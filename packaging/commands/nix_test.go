@@ -0,0 +1,31 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands_test
+
+import (
+	"github.com/juju/utils/packaging/commands"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&NixSuite{})
+
+type NixSuite struct {
+	paccmder commands.PackageCommander
+}
+
+func (s *NixSuite) SetUpSuite(c *gc.C) {
+	s.paccmder = commands.NewNixPackageCommander()
+}
+
+func (s *NixSuite) TestNewPackageCommander(c *gc.C) {
+	cmder, err := commands.NewPackageCommander("nixos")
+	c.Assert(err, gc.IsNil)
+	c.Assert(cmder, gc.Equals, s.paccmder)
+}
+
+func (s *NixSuite) TestChannelsAsRepositories(c *gc.C) {
+	c.Assert(s.paccmder.AddRepositoryCmd("https://nixos.org/channels/nixos-16.09 nixos"), gc.Equals,
+		"nix-channel --add https://nixos.org/channels/nixos-16.09 nixos")
+	c.Assert(s.paccmder.RemoveRepositoryCmd("nixos"), gc.Equals, "nix-channel --remove nixos")
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands
+
+const (
+	// PacmanConfigFilePath is the default configuration file for pacman,
+	// which also holds the definitions of all configured repositories.
+	PacmanConfigFilePath = "/etc/pacman.conf"
+
+	// PacmanProxyConfigFilePath is the file holding the proxy settings used
+	// by pacman. pacman downloads through libcurl, which honours the standard
+	// proxy environment variables, so they are set system-wide.
+	PacmanProxyConfigFilePath = "/etc/environment"
+)
+
+const (
+	// the basic command for all pacman calls:
+	//		--noconfirm to never prompt for confirmation
+	//		--noprogressbar to limit output verbosity
+	pacman = "pacman --noconfirm --noprogressbar"
+
+	// the basic format for specifying a proxy setting for pacman.
+	pacmanProxySettingFormat = "%s_proxy=%s"
+)
+
+// pacmanCmder is the packageCommander instantiation for pacman-based systems.
+//
+// Arch does not support partial upgrades, where packages are installed
+// from a refreshed database without upgrading the rest of the system.
+// The update command therefore only refreshes the databases, and the
+// installs upgrade the system along with the requested packages.
+var pacmanCmder = packageCommander{
+	prereq:              "", // pacman manages repositories natively
+	update:              buildCommand(pacman, "-Sy"),
+	upgrade:             buildCommand(pacman, "-Syu"),
+	upgradeOnly:         "", // partial upgrades are unsupported on Arch
+	install:             buildCommand(pacman, "-Syu --needed"),
+	pinnedPackage:       "", // pacman only installs the latest version
+	noRecommends:        "",
+	installLocal:        buildCommand(pacman, "-U --needed"),
+	downgrade:           "",
+	rollback:            "",
+	remove:              buildCommand(pacman, "-R"),
+	purge:               buildCommand(pacman, "-Rns"),
+	fetch:               buildCommand(pacman, "-Swdd --cachedir %s"),
+	fetchWithDeps:       buildCommand(pacman, "-Sw --cachedir %s"),
+	hold:                "",
+	unhold:              "",
+	simulate:            "",
+	search:              buildCommand(pacman, "-Si %s"),
+	searchPackages:      "",
+	depends:             "",
+	rdepends:            "",
+	listVersions:        "",
+	verify:              "",
+	verifyAll:           "",
+	owner:               "",
+	isInstalled:         buildCommand("pacman", "-Q %s"),
+	listAvailable:       buildCommand(pacman, "-Slq"),
+	listInstalled:       buildCommand(pacman, "-Q"),
+	listRepositories:    buildCommand("pacman-conf", "--repo-list"),
+	addRepository:       "", // done by editing PacmanConfigFilePath directly
+	removeRepository:    "", // done by editing PacmanConfigFilePath directly
+	cleanup:             buildCommand(pacman, "-Sc"),
+	autoremove:          "", // orphans are only listed by pacman -Qdt
+	getProxy:            buildCommand("grep _proxy=", PacmanProxyConfigFilePath),
+	proxySettingsFormat: pacmanProxySettingFormat,
+	setProxy:            "", // see the manager
+}
@@ -0,0 +1,62 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands_test
+
+import (
+	"github.com/juju/utils/packaging/commands"
+	"github.com/juju/utils/proxy"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&PacmanSuite{})
+
+type PacmanSuite struct {
+	paccmder commands.PackageCommander
+}
+
+func (s *PacmanSuite) SetUpSuite(c *gc.C) {
+	s.paccmder = commands.NewPacmanPackageCommander()
+}
+
+func (s *PacmanSuite) TestNewPackageCommander(c *gc.C) {
+	cmder, err := commands.NewPackageCommander("arch")
+	c.Assert(err, gc.IsNil)
+	c.Assert(cmder, gc.Equals, s.paccmder)
+}
+
+func (s *PacmanSuite) TestUpgradeCmd(c *gc.C) {
+	c.Assert(s.paccmder.UpgradeCmd(), gc.Equals, "pacman --noconfirm --noprogressbar -Syu")
+}
+
+func (s *PacmanSuite) TestUpdateCmd(c *gc.C) {
+	c.Assert(s.paccmder.UpdateCmd(), gc.Equals, "pacman --noconfirm --noprogressbar -Sy")
+}
+
+func (s *PacmanSuite) TestInstallCmdUpgradesSystem(c *gc.C) {
+	c.Assert(s.paccmder.InstallCmd("juju", "lxd"), gc.Equals, "pacman --noconfirm --noprogressbar -Syu --needed juju lxd")
+}
+
+func (s *PacmanSuite) TestRepositoryCmdsNotSupported(c *gc.C) {
+	c.Assert(s.paccmder.InstallPrerequisiteCmd(), gc.Equals, "")
+	c.Assert(s.paccmder.AddRepositoryCmd("[custom]"), gc.Equals, "")
+	c.Assert(s.paccmder.RemoveRepositoryCmd("custom"), gc.Equals, "")
+}
+
+func (s *PacmanSuite) TestProxyConfigContentsFull(c *gc.C) {
+	sets := proxy.Settings{
+		Http:  "dat-proxy.zone:8080",
+		Https: "https://much-security.com",
+		Ftp:   "gimme-files.zone",
+	}
+	expected := `http_proxy=dat-proxy.zone:8080
+https_proxy=https://much-security.com
+ftp_proxy=gimme-files.zone`
+
+	output := s.paccmder.ProxyConfigContents(sets)
+	c.Assert(output, gc.Equals, expected)
+}
+
+func (s *PacmanSuite) TestInstallVersionCmdNotSupported(c *gc.C) {
+	c.Assert(s.paccmder.InstallVersionCmd("juju", "2.0.0-1"), gc.Equals, "")
+}
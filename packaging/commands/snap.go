@@ -0,0 +1,54 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands
+
+const (
+	// the basic command for all snap calls.
+	snap = "snap"
+
+	// the basic format for specifying a proxy setting for snapd.
+	snapProxySettingFormat = "proxy.%s=%s"
+)
+
+// snapCmder is the packageCommander instantiation for snapd.
+// Snaps are all fetched from the store, hence the lack of any repository
+// or package list related commands.
+var snapCmder = packageCommander{
+	prereq:              "",
+	update:              "",
+	upgrade:             buildCommand(snap, "refresh"),
+	upgradeOnly:         buildCommand(snap, "refresh"),
+	install:             buildCommand(snap, "install"),
+	pinnedPackage:       "", // see SnapOptions.Revision
+	noRecommends:        "",
+	installLocal:        buildCommand(snap, "install --dangerous"),
+	downgrade:           "", // see SnapOptions.Revision
+	rollback:            buildCommand(snap, "revert %s"),
+	remove:              buildCommand(snap, "remove"),
+	purge:               buildCommand(snap, "remove --purge"),
+	fetch:               "",
+	fetchWithDeps:       "",
+	hold:                "",
+	unhold:              "",
+	simulate:            "",
+	search:              buildCommand(snap, "info %s"),
+	searchPackages:      "",
+	depends:             "",
+	rdepends:            "",
+	listVersions:        "",
+	verify:              "",
+	verifyAll:           "",
+	owner:               "",
+	isInstalled:         buildCommand(snap, "list %s"),
+	listAvailable:       "",
+	listInstalled:       buildCommand(snap, "list"),
+	listRepositories:    "",
+	addRepository:       "",
+	removeRepository:    "",
+	cleanup:             "",
+	autoremove:          "",
+	getProxy:            buildCommand(snap, "get -d system proxy"),
+	proxySettingsFormat: snapProxySettingFormat,
+	setProxy:            buildCommand(snap, "set system %s"),
+}
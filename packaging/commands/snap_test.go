@@ -0,0 +1,40 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands_test
+
+import (
+	"github.com/juju/utils/packaging/commands"
+	"github.com/juju/utils/proxy"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&SnapSuite{})
+
+type SnapSuite struct {
+	paccmder commands.PackageCommander
+}
+
+func (s *SnapSuite) SetUpSuite(c *gc.C) {
+	s.paccmder = commands.NewSnapPackageCommander()
+}
+
+func (s *SnapSuite) TestRepositoryCmdsNotSupported(c *gc.C) {
+	c.Assert(s.paccmder.UpdateCmd(), gc.Equals, "")
+	c.Assert(s.paccmder.ListRepositoriesCmd(), gc.Equals, "")
+	c.Assert(s.paccmder.AddRepositoryCmd("some-repo"), gc.Equals, "")
+	c.Assert(s.paccmder.RemoveRepositoryCmd("some-repo"), gc.Equals, "")
+}
+
+func (s *SnapSuite) TestSetProxyCmds(c *gc.C) {
+	sets := proxy.Settings{
+		Http:  "dat-proxy.zone:8080",
+		Https: "https://much-security.com",
+	}
+
+	cmds := s.paccmder.SetProxyCmds(sets)
+	c.Assert(cmds, gc.DeepEquals, []string{
+		"snap set system proxy.http=dat-proxy.zone:8080",
+		"snap set system proxy.https=https://much-security.com",
+	})
+}
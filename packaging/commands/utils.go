@@ -5,6 +5,7 @@
 package commands
 
 import (
+	"fmt"
 	"strings"
 )
 
@@ -16,6 +17,19 @@ func buildCommand(args ...string) string {
 // addArgsToCommand is a helper functions which simply joins all the arguments
 // to the supplied command.
 func addArgsToCommand(cmd string, args []string) string {
+	if cmd == "" {
+		return ""
+	}
 	res := append([]string{cmd}, args...)
 	return strings.Join(res, " ")
 }
+
+// formatCommand is a helper function which formats the given command with the
+// supplied arguments. An empty command, which denotes an operation that the
+// package manager does not support, is returned unaltered.
+func formatCommand(cmd string, args ...interface{}) string {
+	if cmd == "" {
+		return ""
+	}
+	return fmt.Sprintf(cmd, args...)
+}
@@ -18,6 +18,10 @@ const (
 
 	// YumConfigFile is the default configuration file for yum settings.
 	YumConfigFilePath = "/etc/yum.conf"
+
+	// DnfConfigFilePath is the configuration file for the settings of dnf,
+	// which supersedes yum on newer systems.
+	DnfConfigFilePath = "/etc/dnf/dnf.conf"
 )
 
 const (
@@ -29,6 +33,10 @@ const (
 	// the basic command for all yum repository configuration operations.
 	yumconf = "yum-config-manager"
 
+	// the command which lists all installed rpm packages as
+	// "<name>\t<version>-<release>\t<architecture>" lines.
+	rpmListInstalled = `rpm --query --all --queryformat=%{NAME}\t%{VERSION}-%{RELEASE}\t%{ARCH}\n`
+
 	// the basic format for specifying a proxy setting for yum.
 	// NOTE: only http(s) proxies are relevant.
 	yumProxySettingFormat = "%s_proxy=%s"
@@ -39,18 +47,37 @@ var yumCmder = packageCommander{
 	prereq:              buildCommand(yum, "install yum-utils"),
 	update:              buildCommand(yum, "clean expire-cache"),
 	upgrade:             buildCommand(yum, "update"),
+	upgradeOnly:         buildCommand(yum, "update"),
 	install:             buildCommand(yum, "install"),
+	pinnedPackage:       "%s-%s",
+	noRecommends:        "--setopt=install_weak_deps=False",
+	installLocal:        buildCommand(yum, "localinstall"),
+	downgrade:           buildCommand(yum, "downgrade"),
+	rollback:            buildCommand(yum, "history undo %s"),
 	remove:              buildCommand(yum, "remove"),
 	purge:               buildCommand(yum, "remove"), // purges by default
+	fetch:               buildCommand("yumdownloader", "--destdir=%s"),
+	fetchWithDeps:       buildCommand("yumdownloader", "--resolve --destdir=%s"),
+	hold:                buildCommand(yum, "versionlock add"),
+	unhold:              buildCommand(yum, "versionlock delete"),
+	simulate:            "--assumeno",
 	search:              buildCommand(yum, "list %s"),
+	searchPackages:      buildCommand("repoquery", `--queryformat=%%{name}\t%%{version}-%%{release}\t%%{arch}\t%%{summary} *%s*`),
+	depends:             buildCommand("repoquery", "--requires --resolve --queryformat=%%{name} %s"),
+	rdepends:            buildCommand("repoquery", "--installed --whatrequires --queryformat=%%{name} %s"),
+	listVersions:        buildCommand(yum, "--showduplicates list %s"),
+	verify:              buildCommand("rpm", "--verify"),
+	verifyAll:           buildCommand("rpm", "--verify --all"),
+	owner:               buildCommand("rpm", `--query --file --queryformat=%%{NAME}\n %s`),
 	isInstalled:         buildCommand(yum, "list installed %s"),
 	listAvailable:       buildCommand(yum, "list all"),
-	listInstalled:       buildCommand(yum, "list installed"),
+	listInstalled:       rpmListInstalled,
 	listRepositories:    buildCommand(yum, "repolist all"),
 	addRepository:       buildCommand(yumconf, "--add-repo %s"),
 	removeRepository:    buildCommand(yumconf, "--disable %s"),
 	cleanup:             buildCommand(yum, "clean all"),
-	getProxy:            buildCommand("grep -R \".*_proxy=\"", YumConfigFilePath),
+	autoremove:          buildCommand(yum, "autoremove"),
+	getProxy:            buildCommand("grep --no-messages --no-filename proxy", YumConfigFilePath, DnfConfigFilePath),
 	proxySettingsFormat: yumProxySettingFormat,
-	setProxy:            buildCommand("echo %s >>", YumConfigFilePath),
+	setProxy:            "", // see the manager
 }
@@ -47,3 +47,45 @@ ftp_proxy=gimme-files.zone`
 	output := s.paccmder.ProxyConfigContents(sets)
 	c.Assert(output, gc.Equals, expected)
 }
+
+func (s *YumSuite) TestInstallVersionCmd(c *gc.C) {
+	output := s.paccmder.InstallVersionCmd("juju", "2.0.0-1.el7")
+	c.Assert(output, gc.Equals, "yum --assumeyes --debuglevel=1 install juju-2.0.0-1.el7")
+}
+
+func (s *YumSuite) TestSearchPackagesCmd(c *gc.C) {
+	output := s.paccmder.SearchPackagesCmd("lxc")
+	c.Assert(output, gc.Equals, `repoquery --queryformat=%{name}\t%{version}-%{release}\t%{arch}\t%{summary} *lxc*`)
+}
+
+func (s *YumSuite) TestFetchCmds(c *gc.C) {
+	c.Assert(s.paccmder.FetchCmd("/tmp/pkgs", "juju"), gc.Equals, "yumdownloader --destdir=/tmp/pkgs juju")
+	c.Assert(s.paccmder.FetchWithDependenciesCmd("/tmp/pkgs", "juju"), gc.Equals, "yumdownloader --resolve --destdir=/tmp/pkgs juju")
+}
+
+func (s *YumSuite) TestInstallLocalCmd(c *gc.C) {
+	output := s.paccmder.InstallLocalCmd("/tmp/juju-2.0.0-1.el7.x86_64.rpm")
+	c.Assert(output, gc.Equals, "yum --assumeyes --debuglevel=1 localinstall /tmp/juju-2.0.0-1.el7.x86_64.rpm")
+}
+
+func (s *YumSuite) TestDependsCmds(c *gc.C) {
+	c.Assert(s.paccmder.DependsCmd("juju"), gc.Equals, "repoquery --requires --resolve --queryformat=%{name} juju")
+	c.Assert(s.paccmder.RDependsCmd("juju"), gc.Equals, "repoquery --installed --whatrequires --queryformat=%{name} juju")
+}
+
+func (s *YumSuite) TestInstallNoRecommendsCmd(c *gc.C) {
+	c.Assert(s.paccmder.InstallNoRecommendsCmd("juju"), gc.Equals, "yum --assumeyes --debuglevel=1 install --setopt=install_weak_deps=False juju")
+}
+
+func (s *YumSuite) TestDowngradeCmd(c *gc.C) {
+	c.Assert(s.paccmder.DowngradeCmd("juju", "1.25.6-1.el7"), gc.Equals, "yum --assumeyes --debuglevel=1 downgrade juju-1.25.6-1.el7")
+}
+
+func (s *YumSuite) TestRollbackCmd(c *gc.C) {
+	c.Assert(s.paccmder.RollbackCmd("42"), gc.Equals, "yum --assumeyes --debuglevel=1 history undo 42")
+}
+
+func (s *YumSuite) TestVerifyCmd(c *gc.C) {
+	c.Assert(s.paccmder.VerifyCmd("juju", "lxd"), gc.Equals, "rpm --verify juju lxd")
+	c.Assert(s.paccmder.VerifyCmd(), gc.Equals, "rpm --verify --all")
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands
+
+import (
+	"strings"
+)
+
+const (
+	// ZypperProxyConfigFilePath is the system-wide proxy configuration file
+	// which libzypp reads on SUSE-based systems.
+	ZypperProxyConfigFilePath = "/etc/sysconfig/proxy"
+
+	// ZypperReposDir is the default directory in which zypper repository
+	// files may be found.
+	ZypperReposDir = "/etc/zypp/repos.d"
+)
+
+const (
+	// the basic command for all zypper calls:
+	//		--non-interactive to never prompt for confirmation
+	//		--quiet to limit output verbosity
+	zypper = "zypper --non-interactive --quiet"
+
+	// the basic format for specifying a proxy setting for zypper.
+	// NOTE: the setting names are upper-cased, see zypperCmder below.
+	zypperProxySettingFormat = "%s_PROXY=%q"
+)
+
+// zypperCmder is the packageCommander instantiation for zypper-based systems.
+var zypperCmder = packageCommander{
+	prereq:              "", // zypper manages repositories natively
+	update:              buildCommand(zypper, "refresh"),
+	upgrade:             buildCommand(zypper, "update --auto-agree-with-licenses"),
+	upgradeOnly:         buildCommand(zypper, "update --auto-agree-with-licenses"),
+	install:             buildCommand(zypper, "install --auto-agree-with-licenses"),
+	pinnedPackage:       "%s=%s",
+	noRecommends:        "--no-recommends",
+	installLocal:        buildCommand(zypper, "install --auto-agree-with-licenses"),
+	downgrade:           buildCommand(zypper, "install --auto-agree-with-licenses --oldpackage"),
+	rollback:            "",
+	remove:              buildCommand(zypper, "remove"),
+	purge:               buildCommand(zypper, "remove --clean-deps"),
+	fetch:               buildCommand(zypper, "--pkg-cache-dir=%s download"),
+	fetchWithDeps:       buildCommand(zypper, "--pkg-cache-dir=%s install --download-only"),
+	hold:                buildCommand(zypper, "addlock"),
+	unhold:              buildCommand(zypper, "removelock"),
+	simulate:            "",
+	search:              buildCommand(zypper, "search --match-exact %s"),
+	searchPackages:      "",
+	depends:             "",
+	rdepends:            "",
+	listVersions:        "",
+	verify:              buildCommand("rpm", "--verify"),
+	verifyAll:           buildCommand("rpm", "--verify --all"),
+	owner:               buildCommand("rpm", `--query --file --queryformat=%%{NAME}\n %s`),
+	isInstalled:         buildCommand("rpm", "-q %s"),
+	listAvailable:       buildCommand(zypper, "packages"),
+	listInstalled:       rpmListInstalled,
+	listRepositories:    buildCommand(zypper, "repos --uri"),
+	addRepository:       buildCommand(zypper, "addrepo --refresh %s"),
+	removeRepository:    buildCommand(zypper, "removerepo %s"),
+	cleanup:             buildCommand(zypper, "clean --all"),
+	autoremove:          "", // zypper only lists unneeded packages
+	getProxy:            buildCommand("grep _PROXY=", ZypperProxyConfigFilePath),
+	proxySettingsFormat: zypperProxySettingFormat,
+	proxySettingName:    strings.ToUpper,
+	setProxy:            "", // see the manager
+}
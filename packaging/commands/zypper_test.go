@@ -0,0 +1,50 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package commands_test
+
+import (
+	"github.com/juju/utils/packaging/commands"
+	"github.com/juju/utils/proxy"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&ZypperSuite{})
+
+type ZypperSuite struct {
+	paccmder commands.PackageCommander
+}
+
+func (s *ZypperSuite) SetUpSuite(c *gc.C) {
+	s.paccmder = commands.NewZypperPackageCommander()
+}
+
+func (s *ZypperSuite) TestNewPackageCommander(c *gc.C) {
+	cmder, err := commands.NewPackageCommander("opensuseleap")
+	c.Assert(err, gc.IsNil)
+	c.Assert(cmder, gc.Equals, s.paccmder)
+}
+
+func (s *ZypperSuite) TestInstallCmd(c *gc.C) {
+	cmd := s.paccmder.InstallCmd("vim", "git")
+	c.Assert(cmd, gc.Equals, "zypper --non-interactive --quiet install --auto-agree-with-licenses vim git")
+}
+
+func (s *ZypperSuite) TestProxyConfigContentsEmpty(c *gc.C) {
+	out := s.paccmder.ProxyConfigContents(proxy.Settings{})
+	c.Assert(out, gc.Equals, "")
+}
+
+func (s *ZypperSuite) TestProxyConfigContentsFull(c *gc.C) {
+	sets := proxy.Settings{
+		Http:  "dat-proxy.zone:8080",
+		Https: "https://much-security.com",
+		Ftp:   "gimme-files.zone",
+	}
+	expected := `HTTP_PROXY="dat-proxy.zone:8080"
+HTTPS_PROXY="https://much-security.com"
+FTP_PROXY="gimme-files.zone"`
+
+	output := s.paccmder.ProxyConfigContents(sets)
+	c.Assert(output, gc.Equals, expected)
+}
@@ -13,6 +13,18 @@ const (
 	// sources for apt packages on an apt-based system.
 	AptSourcesFile = "/etc/apt/sources.list"
 
+	// AptSourcesDeb822File is the file which lists the core sources for apt
+	// packages in the deb822 format, which supersedes AptSourcesFile.
+	AptSourcesDeb822File = "/etc/apt/sources.list.d/ubuntu.sources"
+
+	// AptSourcesDirectory is the directory holding the additional
+	// sources for apt packages.
+	AptSourcesDirectory = "/etc/apt/sources.list.d"
+
+	// AptPreferencesDirectory is the directory holding the files which
+	// set the priorities of packages and sources for apt.
+	AptPreferencesDirectory = "/etc/apt/preferences.d"
+
 	// AptListsDirectory is the location of the APT sources list.
 	AptListsDirectory = "/var/lib/apt/lists"
 
@@ -20,6 +32,14 @@ const (
 	// apt configuration files are stored.
 	AptConfigDirectory = "/etc/apt/apt.conf.d"
 
+	// AptAutoUpgradesFile is the apt configuration file which enables the
+	// periodic updates and unattended upgrades of packages.
+	AptAutoUpgradesFile = AptConfigDirectory + "/20auto-upgrades"
+
+	// AptUnattendedUpgradesFile is the apt configuration file which
+	// configures the unattended-upgrades package.
+	AptUnattendedUpgradesFile = AptConfigDirectory + "/50unattended-upgrades"
+
 	// ExtractAptSource is a shell command that will extract the
 	// currently configured APT source location. We assume that
 	// the first source for "main" in the file is the one that
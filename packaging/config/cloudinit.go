@@ -0,0 +1,223 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// CloudInit describes the packaging of a machine as cloud-init applies it
+// on first boot. It is rendered into a fragment of cloud-config YAML for
+// either apt- or yum-based systems.
+type CloudInit struct {
+	// Update signals whether the package lists are updated.
+	Update bool
+
+	// Upgrade signals whether all packages are upgraded.
+	Upgrade bool
+
+	// RebootIfRequired signals whether the machine is rebooted
+	// if installed packages or upgrades require it.
+	RebootIfRequired bool
+
+	// Packages holds the packages to install, either as
+	// "<name>" or as "<name>=<version>".
+	Packages []string
+
+	// Mirror overrides the Ubuntu archives on apt-based systems.
+	// The zero value keeps those of the image.
+	Mirror Mirror
+
+	// Repositories holds the additional repositories to configure.
+	Repositories []Repository
+
+	// Keys maps the names of apt repositories to the ASCII-armored
+	// keys which cloud-init installs for them.
+	Keys map[string]string
+
+	// Snaps holds the snaps to install.
+	Snaps []Snap
+}
+
+// Snap describes a snap installed by cloud-init.
+type Snap struct {
+	// Name is the name of the snap.
+	Name string
+
+	// Channel is the channel the snap is installed from.
+	// It defaults to the stable channel.
+	Channel string
+
+	// Classic signals whether the snap is installed
+	// with classic confinement.
+	Classic bool
+}
+
+// cloudConfig is the part of cloud-config which CloudInit is rendered into.
+type cloudConfig struct {
+	PackageUpdate           bool                    `yaml:"package_update,omitempty"`
+	PackageUpgrade          bool                    `yaml:"package_upgrade,omitempty"`
+	PackageRebootIfRequired bool                    `yaml:"package_reboot_if_required,omitempty"`
+	Packages                []interface{}           `yaml:"packages,omitempty"`
+	Apt                     *cloudConfigApt         `yaml:"apt,omitempty"`
+	YumRepos                map[string]yumRepoEntry `yaml:"yum_repos,omitempty"`
+	Snap                    *cloudConfigSnap        `yaml:"snap,omitempty"`
+}
+
+// cloudConfigApt is the apt module configuration of cloud-config.
+type cloudConfigApt struct {
+	Primary  []aptMirrorEntry          `yaml:"primary,omitempty"`
+	Security []aptMirrorEntry          `yaml:"security,omitempty"`
+	Sources  map[string]aptSourceEntry `yaml:"sources,omitempty"`
+}
+
+// aptMirrorEntry is a mirror of the apt module configuration.
+type aptMirrorEntry struct {
+	Arches []string `yaml:"arches"`
+	URI    string   `yaml:"uri"`
+}
+
+// aptSourceEntry is a source of the apt module configuration.
+type aptSourceEntry struct {
+	Source string `yaml:"source"`
+	Key    string `yaml:"key,omitempty"`
+}
+
+// yumRepoEntry is a repository of the yum_repos module configuration.
+type yumRepoEntry struct {
+	Name     string `yaml:"name"`
+	BaseURL  string `yaml:"baseurl"`
+	Enabled  bool   `yaml:"enabled"`
+	GPGCheck bool   `yaml:"gpgcheck"`
+	GPGKey   string `yaml:"gpgkey,omitempty"`
+}
+
+// cloudConfigSnap is the snap module configuration of cloud-config.
+type cloudConfigSnap struct {
+	Commands [][]string `yaml:"commands"`
+}
+
+// Validate checks that the configuration is fit for rendering.
+func (c CloudInit) Validate() error {
+	for _, pack := range c.Packages {
+		if pack == "" || strings.ContainsAny(pack, " \t\n") || strings.HasPrefix(pack, "=") || strings.HasSuffix(pack, "=") {
+			return errors.NotValidf("package %q", pack)
+		}
+	}
+	for _, repo := range c.Repositories {
+		if err := repo.Validate(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	for _, snap := range c.Snaps {
+		if snap.Name == "" || strings.ContainsAny(snap.Name, " \t\n") {
+			return errors.NotValidf("snap %q", snap.Name)
+		}
+	}
+	return nil
+}
+
+// RenderApt returns the cloud-config fragment configuring
+// the packaging of apt-based systems.
+func (c CloudInit) RenderApt() (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	cfg := c.cloudConfig()
+	apt := &cloudConfigApt{}
+	if c.Mirror.Archive != "" {
+		apt.Primary = []aptMirrorEntry{{Arches: []string{"default"}, URI: c.Mirror.archive()}}
+	}
+	if c.Mirror.Security != "" {
+		apt.Security = []aptMirrorEntry{{Arches: []string{"default"}, URI: c.Mirror.security()}}
+	}
+	for _, repo := range c.Repositories {
+		if err := repo.validateApt(); err != nil {
+			return "", errors.Trace(err)
+		}
+		if apt.Sources == nil {
+			apt.Sources = make(map[string]aptSourceEntry)
+		}
+		// cloud-init names the sources.list.d file after the key.
+		apt.Sources[repo.Name+".list"] = aptSourceEntry{
+			Source: strings.TrimSuffix(repo.aptEntry().oneLine(), "\n"),
+			Key:    c.Keys[repo.Name],
+		}
+	}
+	if apt.Primary != nil || apt.Security != nil || apt.Sources != nil {
+		cfg.Apt = apt
+	}
+	return renderCloudConfig(cfg)
+}
+
+// RenderYum returns the cloud-config fragment configuring
+// the packaging of yum-based systems.
+func (c CloudInit) RenderYum() (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	cfg := c.cloudConfig()
+	for _, repo := range c.Repositories {
+		if cfg.YumRepos == nil {
+			cfg.YumRepos = make(map[string]yumRepoEntry)
+		}
+		// yum treats the further URIs as mirrors of the first one, which
+		// cloud-init cannot express.
+		cfg.YumRepos[repo.Name] = yumRepoEntry{
+			Name:     fmt.Sprintf("%s (added by Juju)", repo.Name),
+			BaseURL:  repo.URIs[0],
+			Enabled:  !repo.Disabled,
+			GPGCheck: repo.SignedBy != "",
+			GPGKey:   repo.SignedBy,
+		}
+	}
+	return renderCloudConfig(cfg)
+}
+
+// cloudConfig returns the parts of the cloud-config which
+// do not depend upon the package management system.
+func (c CloudInit) cloudConfig() cloudConfig {
+	cfg := cloudConfig{
+		PackageUpdate:           c.Update,
+		PackageUpgrade:          c.Upgrade,
+		PackageRebootIfRequired: c.RebootIfRequired,
+	}
+	for _, pack := range c.Packages {
+		// cloud-init installs specific versions given as pairs.
+		if parts := strings.SplitN(pack, "=", 2); len(parts) == 2 {
+			cfg.Packages = append(cfg.Packages, parts)
+		} else {
+			cfg.Packages = append(cfg.Packages, pack)
+		}
+	}
+	for _, snap := range c.Snaps {
+		if cfg.Snap == nil {
+			cfg.Snap = &cloudConfigSnap{}
+		}
+		cmd := []string{"snap", "install"}
+		if snap.Channel != "" {
+			cmd = append(cmd, "--channel="+snap.Channel)
+		}
+		if snap.Classic {
+			cmd = append(cmd, "--classic")
+		}
+		cfg.Snap.Commands = append(cfg.Snap.Commands, append(cmd, snap.Name))
+	}
+	return cfg
+}
+
+// renderCloudConfig marshals the given cloud-config.
+func renderCloudConfig(cfg cloudConfig) (string, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return string(data), nil
+}
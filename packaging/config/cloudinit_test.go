@@ -0,0 +1,109 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package config_test
+
+import (
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/packaging/config"
+)
+
+var _ = gc.Suite(&CloudInitSuite{})
+
+type CloudInitSuite struct{}
+
+var testCloudInit = config.CloudInit{
+	Update:   true,
+	Upgrade:  true,
+	Packages: []string{"curl", "juju=2.0.0-0ubuntu1"},
+	Mirror:   config.Mirror{Archive: "http://mirror.example.com/ubuntu/"},
+	Repositories: []config.Repository{{
+		Name:       "juju-stable",
+		URIs:       []string{"http://ppa.launchpad.net/juju/stable/ubuntu"},
+		Suites:     []string{"xenial"},
+		Components: []string{"main"},
+		SignedBy:   "/etc/apt/keyrings/juju-stable.gpg",
+	}},
+	Keys:  map[string]string{"juju-stable": "-----BEGIN PGP PUBLIC KEY BLOCK-----\n..."},
+	Snaps: []config.Snap{{Name: "lxd", Channel: "4.0/stable"}, {Name: "juju", Classic: true}},
+}
+
+func (s *CloudInitSuite) TestRenderApt(c *gc.C) {
+	out, err := testCloudInit.RenderApt()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(out, gc.Equals, `
+package_update: true
+package_upgrade: true
+packages:
+- curl
+- - juju
+  - 2.0.0-0ubuntu1
+apt:
+  primary:
+  - arches:
+    - default
+    uri: http://mirror.example.com/ubuntu
+  sources:
+    juju-stable.list:
+      source: deb [signed-by=/etc/apt/keyrings/juju-stable.gpg] http://ppa.launchpad.net/juju/stable/ubuntu
+        xenial main
+      key: |-
+        -----BEGIN PGP PUBLIC KEY BLOCK-----
+        ...
+snap:
+  commands:
+  - - snap
+    - install
+    - --channel=4.0/stable
+    - lxd
+  - - snap
+    - install
+    - --classic
+    - juju
+`[1:])
+}
+
+func (s *CloudInitSuite) TestRenderYum(c *gc.C) {
+	out, err := config.CloudInit{
+		Update:           true,
+		RebootIfRequired: true,
+		Packages:         []string{"juju"},
+		Repositories: []config.Repository{{
+			Name:     "juju",
+			URIs:     []string{"https://repo.example.com/el7"},
+			SignedBy: "https://repo.example.com/RPM-GPG-KEY-juju",
+		}},
+	}.RenderYum()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(out, gc.Equals, `
+package_update: true
+package_reboot_if_required: true
+packages:
+- juju
+yum_repos:
+  juju:
+    name: juju (added by Juju)
+    baseurl: https://repo.example.com/el7
+    enabled: true
+    gpgcheck: true
+    gpgkey: https://repo.example.com/RPM-GPG-KEY-juju
+`[1:])
+}
+
+func (s *CloudInitSuite) TestRenderNotValid(c *gc.C) {
+	_, err := config.CloudInit{Packages: []string{"juju="}}.RenderApt()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+
+	_, err = config.CloudInit{Snaps: []config.Snap{{}}}.RenderYum()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+
+	// yum repositories need neither suites nor components.
+	_, err = config.CloudInit{Repositories: []config.Repository{{
+		Name: "juju",
+		URIs: []string{"https://repo.example.com/el7"},
+	}}}.RenderApt()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
@@ -0,0 +1,185 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils"
+)
+
+// PinRule is a rule of an apt_preferences(5) file, which assigns a priority
+// to the versions of the given packages which match its pin. Exactly one of
+// Version, Origin or Release must be set.
+type PinRule struct {
+	// Explanation is a short explanation of the rule.
+	Explanation string
+
+	// Packages holds the packages the rule applies to, given as names,
+	// glob patterns such as "linux-*" or regular expressions enclosed
+	// in slashes. "*" applies the rule to all packages.
+	Packages []string
+
+	// Version pins the packages to the matching versions, given
+	// as a glob pattern such as "4.4.0-21*".
+	Version string
+
+	// Origin pins the packages to those served by the given host,
+	// such as "ppa.launchpad.net".
+	Origin string
+
+	// Release pins the packages to those from the matching releases.
+	Release *PinRelease
+
+	// Priority is the priority of the matching versions. Versions of
+	// priorities over 1000 are installed even if that is a downgrade,
+	// and those of negative priorities are never installed.
+	Priority int
+}
+
+// PinRelease matches the releases from which packages are pinned by their
+// Release files. Only the fields which are set are matched.
+type PinRelease struct {
+	// Archive is the archive, or suite, such as "xenial-backports".
+	Archive string
+
+	// Codename is the codename of the release, such as "xenial".
+	Codename string
+
+	// Version is the version of the release, such as "16.04".
+	Version string
+
+	// Origin is the originator of the release, such as "Ubuntu".
+	Origin string
+
+	// Label is the label of the release, such as "Ubuntu".
+	Label string
+
+	// Component is the component, such as "main".
+	Component string
+
+	// Architecture is the architecture, such as "amd64".
+	Architecture string
+}
+
+// String returns the release pin, as it follows "Pin: release".
+func (r PinRelease) String() string {
+	var fields []string
+	for _, field := range []struct {
+		key, value string
+	}{
+		{"a", r.Archive},
+		{"n", r.Codename},
+		{"v", r.Version},
+		{"o", r.Origin},
+		{"l", r.Label},
+		{"c", r.Component},
+		{"b", r.Architecture},
+	} {
+		if field.value != "" {
+			fields = append(fields, field.key+"="+field.value)
+		}
+	}
+	return strings.Join(fields, ", ")
+}
+
+// pin returns the pin of the rule.
+func (r PinRule) pin() (string, error) {
+	var pins []string
+	if r.Version != "" {
+		pins = append(pins, "version "+r.Version)
+	}
+	if r.Origin != "" {
+		pins = append(pins, fmt.Sprintf("origin %q", r.Origin))
+	}
+	if r.Release != nil {
+		release := r.Release.String()
+		if release == "" {
+			return "", errors.NotValidf("empty release pin")
+		}
+		pins = append(pins, "release "+release)
+	}
+	if len(pins) != 1 {
+		return "", errors.NotValidf("pin rule with %d pins", len(pins))
+	}
+	return pins[0], nil
+}
+
+// Validate checks that the rule is fit for rendering.
+func (r PinRule) Validate() error {
+	if len(r.Packages) == 0 {
+		return errors.NotValidf("pin rule without packages")
+	}
+	for _, pack := range r.Packages {
+		if pack == "" || strings.ContainsAny(pack, " \t\n") {
+			return errors.NotValidf("package %q", pack)
+		}
+	}
+	if strings.Contains(r.Explanation, "\n") {
+		return errors.NotValidf("multi-line explanation")
+	}
+	if r.Priority == 0 {
+		return errors.NotValidf("pin rule without priority")
+	}
+	_, err := r.pin()
+	return err
+}
+
+// RenderAptPins returns the full contents of the apt preferences file
+// holding the given pin rules.
+func RenderAptPins(rules []PinRule) (string, error) {
+	var stanzas []string
+	for i, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return "", errors.Annotatef(err, "pin rule %d", i)
+		}
+		pin, _ := rule.pin()
+
+		var lines []string
+		if rule.Explanation != "" {
+			lines = append(lines, "Explanation: "+rule.Explanation)
+		}
+		lines = append(lines,
+			"Package: "+strings.Join(rule.Packages, " "),
+			"Pin: "+pin,
+			fmt.Sprintf("Pin-Priority: %d", rule.Priority),
+		)
+		stanzas = append(stanzas, strings.Join(lines, "\n")+"\n")
+	}
+	return strings.Join(stanzas, "\n"), nil
+}
+
+// AptPinsFile returns the path of the apt preferences file which the pin
+// rules of the given name are written to. apt ignores the files of
+// AptPreferencesDirectory with extensions other than ".pref".
+func AptPinsFile(name string) string {
+	return filepath.Join(AptPreferencesDirectory, name+".pref")
+}
+
+// WriteAptPins atomically writes the apt preferences file at the given
+// path, usually given by AptPinsFile, to hold the given pin rules.
+func WriteAptPins(path string, rules []PinRule) error {
+	if len(rules) == 0 {
+		return errors.NotValidf("empty list of pin rules")
+	}
+	contents, err := RenderAptPins(rules)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(utils.AtomicWriteFile(path, []byte(contents), 0644))
+}
+
+// RemoveAptPins removes the apt preferences file at the given path. It is
+// not an error for the file not to exist.
+func RemoveAptPins(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Trace(err)
+	}
+	return nil
+}
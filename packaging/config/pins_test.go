@@ -0,0 +1,117 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/packaging/config"
+)
+
+var _ = gc.Suite(&PinsSuite{})
+
+type PinsSuite struct{}
+
+var testPinRules = []config.PinRule{{
+	Explanation: "Hold the kernel.",
+	Packages:    []string{"linux-image-*", "linux-headers-*"},
+	Version:     "4.4.0-21*",
+	Priority:    1001,
+}, {
+	Packages: []string{"*"},
+	Release:  &config.PinRelease{Archive: "xenial-backports", Origin: "Ubuntu"},
+	Priority: 500,
+}, {
+	Packages: []string{"/^juju/"},
+	Origin:   "ppa.launchpad.net",
+	Priority: -1,
+}}
+
+func (s *PinsSuite) TestRenderAptPins(c *gc.C) {
+	contents, err := config.RenderAptPins(testPinRules)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(contents, gc.Equals, `
+Explanation: Hold the kernel.
+Package: linux-image-* linux-headers-*
+Pin: version 4.4.0-21*
+Pin-Priority: 1001
+
+Package: *
+Pin: release a=xenial-backports, o=Ubuntu
+Pin-Priority: 500
+
+Package: /^juju/
+Pin: origin "ppa.launchpad.net"
+Pin-Priority: -1
+`[1:])
+}
+
+func (s *PinsSuite) TestRenderAptPinsInvalid(c *gc.C) {
+	for i, test := range []struct {
+		rule config.PinRule
+		err  string
+	}{{
+		rule: config.PinRule{Version: "1.0", Priority: 100},
+		err:  "pin rule 0: pin rule without packages not valid",
+	}, {
+		rule: config.PinRule{Packages: []string{"a b"}, Version: "1.0", Priority: 100},
+		err:  `pin rule 0: package "a b" not valid`,
+	}, {
+		rule: config.PinRule{Packages: []string{"juju"}, Version: "1.0"},
+		err:  "pin rule 0: pin rule without priority not valid",
+	}, {
+		rule: config.PinRule{Packages: []string{"juju"}, Priority: 100},
+		err:  "pin rule 0: pin rule with 0 pins not valid",
+	}, {
+		rule: config.PinRule{Packages: []string{"juju"}, Version: "1.0", Origin: "example.com", Priority: 100},
+		err:  "pin rule 0: pin rule with 2 pins not valid",
+	}, {
+		rule: config.PinRule{Packages: []string{"juju"}, Release: &config.PinRelease{}, Priority: 100},
+		err:  "pin rule 0: empty release pin not valid",
+	}} {
+		c.Logf("test %d", i)
+		_, err := config.RenderAptPins([]config.PinRule{test.rule})
+		c.Check(err, gc.ErrorMatches, test.err)
+		c.Check(errors.Cause(err), jc.Satisfies, errors.IsNotValid)
+	}
+}
+
+func (s *PinsSuite) TestAptPinsFile(c *gc.C) {
+	c.Assert(config.AptPinsFile("juju-kernel"), gc.Equals, "/etc/apt/preferences.d/juju-kernel.pref")
+}
+
+func (s *PinsSuite) TestWriteAndRemoveAptPins(c *gc.C) {
+	path := filepath.Join(c.MkDir(), "juju.pref")
+	err := config.WriteAptPins(path, testPinRules)
+	c.Assert(err, jc.ErrorIsNil)
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, jc.ErrorIsNil)
+	expected, err := config.RenderAptPins(testPinRules)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, expected)
+
+	err = config.RemoveAptPins(path)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = os.Stat(path)
+	c.Assert(err, jc.Satisfies, os.IsNotExist)
+
+	// removing the pins again is a no-op.
+	err = config.RemoveAptPins(path)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *PinsSuite) TestWriteAptPinsEmpty(c *gc.C) {
+	path := filepath.Join(c.MkDir(), "juju.pref")
+	err := config.WriteAptPins(path, nil)
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	_, err = os.Stat(path)
+	c.Assert(err, jc.Satisfies, os.IsNotExist)
+}
@@ -0,0 +1,466 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils"
+)
+
+// Mirror holds the mirrors which replace the Ubuntu archives.
+// Empty fields denote the official archives.
+type Mirror struct {
+	// Archive is the mirror of the primary archive,
+	// which serves all but the security updates.
+	Archive string
+
+	// Security is the mirror of the security archive.
+	Security string
+}
+
+// archive returns the url of the primary archive mirror.
+func (m Mirror) archive() string {
+	if m.Archive == "" {
+		return UbuntuArchiveURL
+	}
+	return strings.TrimSuffix(m.Archive, "/")
+}
+
+// security returns the url of the security archive mirror.
+func (m Mirror) security() string {
+	if m.Security == "" {
+		return UbuntuSecurityArchiveURL
+	}
+	return strings.TrimSuffix(m.Security, "/")
+}
+
+// Repository describes an additional package repository,
+// from which a sources.list.d entry or a .repo file is rendered.
+type Repository struct {
+	// Name identifies the repository. It names the file the
+	// repository is written to, and the section of .repo files.
+	Name string
+
+	// Types holds the types of apt archives the repository provides,
+	// either "deb" or "deb-src". It defaults to "deb" only.
+	Types []string
+
+	// URIs holds the URIs of the repository, which yum and dnf treat
+	// as mirrors of one another.
+	URIs []string
+
+	// Suites holds the suites of an apt repository. Suites of flat
+	// repositories end with a "/".
+	Suites []string
+
+	// Components holds the components of an apt repository. Flat
+	// repositories have none.
+	Components []string
+
+	// Architectures restricts an apt repository to the given
+	// architectures.
+	Architectures []string
+
+	// SignedBy is the keyring which signs an apt repository, or the
+	// URL of the key which signs a yum repository. Packages are not
+	// checked against any key if it is empty.
+	SignedBy string
+
+	// Disabled signals whether the repository is rendered disabled.
+	Disabled bool
+}
+
+// Validate checks that the repository is fit for rendering.
+func (r Repository) Validate() error {
+	if r.Name == "" || strings.ContainsAny(r.Name, "/ \t\n[]") {
+		return errors.NotValidf("repository name %q", r.Name)
+	}
+	if len(r.URIs) == 0 {
+		return errors.NotValidf("repository %q without URIs", r.Name)
+	}
+	for _, t := range r.Types {
+		if t != "deb" && t != "deb-src" {
+			return errors.NotValidf("archive type %q of repository %q", t, r.Name)
+		}
+	}
+	return nil
+}
+
+// validateApt checks that the repository is fit for rendering for apt.
+func (r Repository) validateApt() error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if len(r.Suites) == 0 {
+		return errors.NotValidf("apt repository %q without suites", r.Name)
+	}
+	for _, suite := range r.Suites {
+		flat := strings.HasSuffix(suite, "/")
+		if flat && len(r.Components) > 0 {
+			return errors.NotValidf("flat apt repository %q with components", r.Name)
+		}
+		if !flat && len(r.Components) == 0 {
+			return errors.NotValidf("apt repository %q without components", r.Name)
+		}
+	}
+	return nil
+}
+
+// aptEntry returns the sources entry of the repository.
+func (r Repository) aptEntry() aptEntry {
+	types := r.Types
+	if len(types) == 0 {
+		types = []string{"deb"}
+	}
+	return aptEntry{
+		types:         types,
+		uris:          r.URIs,
+		suites:        r.Suites,
+		components:    r.Components,
+		architectures: r.Architectures,
+		signedBy:      r.SignedBy,
+		disabled:      r.Disabled,
+	}
+}
+
+// aptEntry is an entry of an apt sources file, which
+// may span multiple lines in the one-line-style format.
+type aptEntry struct {
+	types         []string
+	uris          []string
+	suites        []string
+	components    []string
+	architectures []string
+	signedBy      string
+	disabled      bool
+}
+
+// oneLine renders the entry in the one-line-style sources.list format.
+func (e aptEntry) oneLine() string {
+	var options []string
+	if len(e.architectures) > 0 {
+		options = append(options, "arch="+strings.Join(e.architectures, ","))
+	}
+	if e.signedBy != "" {
+		options = append(options, "signed-by="+e.signedBy)
+	}
+
+	var lines []string
+	for _, t := range e.types {
+		for _, uri := range e.uris {
+			for _, suite := range e.suites {
+				fields := []string{t}
+				if e.disabled {
+					fields = append([]string{"#"}, fields...)
+				}
+				if len(options) > 0 {
+					fields = append(fields, "["+strings.Join(options, " ")+"]")
+				}
+				fields = append(fields, uri, suite)
+				fields = append(fields, e.components...)
+				lines = append(lines, strings.Join(fields, " "))
+			}
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// deb822 renders the entry as a stanza of the deb822-style format.
+func (e aptEntry) deb822() string {
+	lines := []string{
+		"Types: " + strings.Join(e.types, " "),
+		"URIs: " + strings.Join(e.uris, " "),
+		"Suites: " + strings.Join(e.suites, " "),
+	}
+	if len(e.components) > 0 {
+		lines = append(lines, "Components: "+strings.Join(e.components, " "))
+	}
+	if len(e.architectures) > 0 {
+		lines = append(lines, "Architectures: "+strings.Join(e.architectures, " "))
+	}
+	if e.signedBy != "" {
+		lines = append(lines, "Signed-By: "+e.signedBy)
+	}
+	if e.disabled {
+		lines = append(lines, "Enabled: no")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// ubuntuArchiveEntries returns the sources entries of the
+// Ubuntu archives for the given series and mirrors.
+func ubuntuArchiveEntries(series string, mirror Mirror) []aptEntry {
+	return []aptEntry{{
+		types:      []string{"deb"},
+		uris:       []string{mirror.archive()},
+		suites:     []string{series, series + "-updates", series + "-backports"},
+		components: UbuntuArchiveComponents,
+		signedBy:   UbuntuArchiveKeyring,
+	}, {
+		types:      []string{"deb"},
+		uris:       []string{mirror.security()},
+		suites:     []string{series + "-security"},
+		components: UbuntuArchiveComponents,
+		signedBy:   UbuntuArchiveKeyring,
+	}}
+}
+
+// RenderAptSources returns the full contents of the file listing the Ubuntu
+// archives for the given series, served by the given mirrors. The contents
+// are in the deb822-style format of AptSourcesDeb822File if deb822 is true,
+// and in the one-line-style format of AptSourcesFile otherwise.
+func RenderAptSources(series string, mirror Mirror, deb822 bool) string {
+	const header = "# Ubuntu archives (added by Juju)\n"
+
+	var stanzas []string
+	for _, entry := range ubuntuArchiveEntries(series, mirror) {
+		if deb822 {
+			stanzas = append(stanzas, entry.deb822())
+		} else {
+			stanzas = append(stanzas, entry.oneLine())
+		}
+	}
+	if deb822 {
+		return header + "\n" + strings.Join(stanzas, "\n")
+	}
+	return header + strings.Join(stanzas, "")
+}
+
+// RenderAptRepository returns the full contents of the sources.list.d file
+// for the given repository, in the deb822-style format if deb822 is true
+// and in the one-line-style format otherwise.
+func RenderAptRepository(repo Repository, deb822 bool) (string, error) {
+	if err := repo.validateApt(); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	header := fmt.Sprintf("# %s (added by Juju)\n", repo.Name)
+	if deb822 {
+		return header + repo.aptEntry().deb822(), nil
+	}
+	return header + repo.aptEntry().oneLine(), nil
+}
+
+// AptRepositoryFile returns the path of the sources.list.d file which the
+// given repository is written to, in the given format.
+func AptRepositoryFile(repo Repository, deb822 bool) string {
+	ext := ".list"
+	if deb822 {
+		ext = ".sources"
+	}
+	return filepath.Join(AptSourcesDirectory, repo.Name+ext)
+}
+
+// RenderYumRepository returns the full contents of the .repo file
+// for the given repository.
+func RenderYumRepository(repo Repository) (string, error) {
+	if err := repo.Validate(); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	enabled, gpgcheck := 1, 0
+	if repo.Disabled {
+		enabled = 0
+	}
+	if repo.SignedBy != "" {
+		gpgcheck = 1
+	}
+
+	lines := []string{
+		fmt.Sprintf("[%s]", repo.Name),
+		fmt.Sprintf("name=%s (added by Juju)", repo.Name),
+		// further URIs go on indented continuation lines.
+		"baseurl=" + strings.Join(repo.URIs, "\n        "),
+		fmt.Sprintf("enabled=%d", enabled),
+		fmt.Sprintf("gpgcheck=%d", gpgcheck),
+	}
+	if repo.SignedBy != "" {
+		lines = append(lines, "gpgkey="+repo.SignedBy)
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// YumRepositoryFile returns the path of the .repo file which
+// the given repository is written to.
+func YumRepositoryFile(repo Repository) string {
+	return filepath.Join(YumSourcesDir, repo.Name+".repo")
+}
+
+// SwapAptMirrors atomically replaces the Ubuntu archives listed in the given
+// sources file, which is in the deb822-style format if its name ends with
+// ".sources" and in the one-line-style format otherwise, with the given
+// mirrors. As with ExtractAptSource, the primary archive is taken to be the
+// URI of the first entry for a "main" component, and the security archive
+// that of the first entry for a "-security" suite. Other entries are kept.
+func SwapAptMirrors(path string, mirror Mirror) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	var contents string
+	if strings.HasSuffix(path, ".sources") {
+		contents, err = swapDeb822Mirrors(string(data), mirror)
+	} else {
+		contents, err = swapOneLineMirrors(string(data), mirror)
+	}
+	if err != nil {
+		return errors.Annotatef(err, "swapping mirrors in %q", path)
+	}
+
+	return errors.Trace(utils.AtomicWriteFile(path, []byte(contents), info.Mode().Perm()))
+}
+
+// archiveSwapper replaces the URIs of the Ubuntu archives with mirrors.
+type archiveSwapper struct {
+	mirror            Mirror
+	archive, security string
+}
+
+// observe records the given URI as that of the primary or security
+// archive if it is the first one found for the given suites and components.
+func (s *archiveSwapper) observe(uri string, suites, components []string) {
+	if isSecuritySuite(suites) {
+		if s.security == "" {
+			s.security = uri
+		}
+		return
+	}
+	for _, component := range components {
+		if component == "main" && s.archive == "" {
+			s.archive = uri
+		}
+	}
+}
+
+// swap returns the mirror replacing the given URI of an entry for the given
+// suites, or the URI itself if it is not that of an Ubuntu archive.
+func (s *archiveSwapper) swap(uri string, suites []string) string {
+	if uri != s.archive && uri != s.security {
+		return uri
+	}
+	if isSecuritySuite(suites) {
+		return s.mirror.security()
+	}
+	return s.mirror.archive()
+}
+
+// check returns an error if the primary archive could not be found.
+func (s *archiveSwapper) check() error {
+	if s.archive == "" {
+		return errors.NotFoundf("primary archive")
+	}
+	return nil
+}
+
+// isSecuritySuite returns whether all the given suites are security ones.
+func isSecuritySuite(suites []string) bool {
+	for _, suite := range suites {
+		if !strings.HasSuffix(suite, "-security") {
+			return false
+		}
+	}
+	return len(suites) > 0
+}
+
+// oneLineEntryFields splits the given line of a one-line-style sources file
+// into the index of its URI field and its fields, and reports whether it is
+// an entry at all.
+func oneLineEntryFields(line string) (int, []string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || (fields[0] != "deb" && fields[0] != "deb-src") {
+		return 0, nil, false
+	}
+
+	uri := 1
+	if strings.HasPrefix(fields[uri], "[") {
+		for uri < len(fields) && !strings.HasSuffix(fields[uri], "]") {
+			uri++
+		}
+		uri++
+	}
+	if uri+1 >= len(fields) {
+		return 0, nil, false
+	}
+	return uri, fields, true
+}
+
+// swapOneLineMirrors swaps the archives of a one-line-style sources file.
+func swapOneLineMirrors(contents string, mirror Mirror) (string, error) {
+	swapper := &archiveSwapper{mirror: mirror}
+	lines := strings.Split(contents, "\n")
+	for _, line := range lines {
+		if uri, fields, ok := oneLineEntryFields(line); ok {
+			swapper.observe(fields[uri], fields[uri+1:uri+2], fields[uri+2:])
+		}
+	}
+	if err := swapper.check(); err != nil {
+		return "", err
+	}
+
+	for i, line := range lines {
+		if uri, fields, ok := oneLineEntryFields(line); ok {
+			fields[uri] = swapper.swap(fields[uri], fields[uri+1:uri+2])
+			lines[i] = strings.Join(fields, " ")
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// deb822Field returns the values of the given field of the given stanza.
+func deb822Field(stanza []string, name string) []string {
+	for _, line := range stanza {
+		if strings.HasPrefix(line, name+":") {
+			return strings.Fields(strings.TrimPrefix(line, name+":"))
+		}
+	}
+	return nil
+}
+
+// swapDeb822Mirrors swaps the archives of a deb822-style sources file.
+func swapDeb822Mirrors(contents string, mirror Mirror) (string, error) {
+	var stanzas [][]string
+	for _, stanza := range strings.Split(contents, "\n\n") {
+		stanzas = append(stanzas, strings.Split(stanza, "\n"))
+	}
+
+	swapper := &archiveSwapper{mirror: mirror}
+	for _, stanza := range stanzas {
+		suites, components := deb822Field(stanza, "Suites"), deb822Field(stanza, "Components")
+		for _, uri := range deb822Field(stanza, "URIs") {
+			swapper.observe(uri, suites, components)
+		}
+	}
+	if err := swapper.check(); err != nil {
+		return "", err
+	}
+
+	var res []string
+	for _, stanza := range stanzas {
+		suites := deb822Field(stanza, "Suites")
+		for i, line := range stanza {
+			if !strings.HasPrefix(line, "URIs:") {
+				continue
+			}
+			var uris []string
+			for _, uri := range deb822Field(stanza, "URIs") {
+				uris = append(uris, swapper.swap(uri, suites))
+			}
+			stanza[i] = "URIs: " + strings.Join(uris, " ")
+		}
+		res = append(res, strings.Join(stanza, "\n"))
+	}
+	return strings.Join(res, "\n\n"), nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/packaging/config"
+)
+
+var _ = gc.Suite(&SourcesSuite{})
+
+type SourcesSuite struct{}
+
+var testMirror = config.Mirror{
+	Archive:  "http://mirror.example.com/ubuntu/",
+	Security: "http://security-mirror.example.com/ubuntu",
+}
+
+func (s *SourcesSuite) TestRenderAptSources(c *gc.C) {
+	c.Assert(config.RenderAptSources("xenial", testMirror, false), gc.Equals, `
+# Ubuntu archives (added by Juju)
+deb [signed-by=/usr/share/keyrings/ubuntu-archive-keyring.gpg] http://mirror.example.com/ubuntu xenial main restricted universe multiverse
+deb [signed-by=/usr/share/keyrings/ubuntu-archive-keyring.gpg] http://mirror.example.com/ubuntu xenial-updates main restricted universe multiverse
+deb [signed-by=/usr/share/keyrings/ubuntu-archive-keyring.gpg] http://mirror.example.com/ubuntu xenial-backports main restricted universe multiverse
+deb [signed-by=/usr/share/keyrings/ubuntu-archive-keyring.gpg] http://security-mirror.example.com/ubuntu xenial-security main restricted universe multiverse
+`[1:])
+}
+
+func (s *SourcesSuite) TestRenderAptSourcesDeb822(c *gc.C) {
+	c.Assert(config.RenderAptSources("xenial", config.Mirror{}, true), gc.Equals, `
+# Ubuntu archives (added by Juju)
+
+Types: deb
+URIs: http://archive.ubuntu.com/ubuntu
+Suites: xenial xenial-updates xenial-backports
+Components: main restricted universe multiverse
+Signed-By: /usr/share/keyrings/ubuntu-archive-keyring.gpg
+
+Types: deb
+URIs: http://security.ubuntu.com/ubuntu
+Suites: xenial-security
+Components: main restricted universe multiverse
+Signed-By: /usr/share/keyrings/ubuntu-archive-keyring.gpg
+`[1:])
+}
+
+var testRepository = config.Repository{
+	Name:          "juju",
+	Types:         []string{"deb", "deb-src"},
+	URIs:          []string{"http://repo.example.com/juju"},
+	Suites:        []string{"xenial"},
+	Components:    []string{"main"},
+	Architectures: []string{"amd64", "arm64"},
+	SignedBy:      "/etc/apt/keyrings/juju.gpg",
+}
+
+func (s *SourcesSuite) TestRenderAptRepository(c *gc.C) {
+	contents, err := config.RenderAptRepository(testRepository, false)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(contents, gc.Equals, `
+# juju (added by Juju)
+deb [arch=amd64,arm64 signed-by=/etc/apt/keyrings/juju.gpg] http://repo.example.com/juju xenial main
+deb-src [arch=amd64,arm64 signed-by=/etc/apt/keyrings/juju.gpg] http://repo.example.com/juju xenial main
+`[1:])
+	c.Assert(config.AptRepositoryFile(testRepository, false), gc.Equals, "/etc/apt/sources.list.d/juju.list")
+}
+
+func (s *SourcesSuite) TestRenderAptRepositoryDeb822(c *gc.C) {
+	repo := testRepository
+	repo.Disabled = true
+	contents, err := config.RenderAptRepository(repo, true)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(contents, gc.Equals, `
+# juju (added by Juju)
+Types: deb deb-src
+URIs: http://repo.example.com/juju
+Suites: xenial
+Components: main
+Architectures: amd64 arm64
+Signed-By: /etc/apt/keyrings/juju.gpg
+Enabled: no
+`[1:])
+	c.Assert(config.AptRepositoryFile(repo, true), gc.Equals, "/etc/apt/sources.list.d/juju.sources")
+}
+
+func (s *SourcesSuite) TestRenderAptRepositoryFlat(c *gc.C) {
+	contents, err := config.RenderAptRepository(config.Repository{
+		Name:   "flat",
+		URIs:   []string{"http://repo.example.com/flat"},
+		Suites: []string{"./"},
+	}, false)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(contents, gc.Equals, "# flat (added by Juju)\ndeb http://repo.example.com/flat ./\n")
+}
+
+func (s *SourcesSuite) TestRenderAptRepositoryInvalid(c *gc.C) {
+	for i, test := range []struct {
+		about string
+		repo  config.Repository
+		err   string
+	}{{
+		about: "missing name",
+		repo:  config.Repository{URIs: []string{"http://x"}, Suites: []string{"xenial"}, Components: []string{"main"}},
+		err:   `repository name "" not valid`,
+	}, {
+		about: "missing URIs",
+		repo:  config.Repository{Name: "x", Suites: []string{"xenial"}, Components: []string{"main"}},
+		err:   `repository "x" without URIs not valid`,
+	}, {
+		about: "bad type",
+		repo:  config.Repository{Name: "x", Types: []string{"rpm"}, URIs: []string{"http://x"}, Suites: []string{"xenial"}, Components: []string{"main"}},
+		err:   `archive type "rpm" of repository "x" not valid`,
+	}, {
+		about: "missing suites",
+		repo:  config.Repository{Name: "x", URIs: []string{"http://x"}},
+		err:   `apt repository "x" without suites not valid`,
+	}, {
+		about: "missing components",
+		repo:  config.Repository{Name: "x", URIs: []string{"http://x"}, Suites: []string{"xenial"}},
+		err:   `apt repository "x" without components not valid`,
+	}, {
+		about: "flat with components",
+		repo:  config.Repository{Name: "x", URIs: []string{"http://x"}, Suites: []string{"./"}, Components: []string{"main"}},
+		err:   `flat apt repository "x" with components not valid`,
+	}} {
+		c.Logf("test %d: %s", i, test.about)
+		_, err := config.RenderAptRepository(test.repo, false)
+		c.Check(err, gc.ErrorMatches, test.err)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+	}
+}
+
+func (s *SourcesSuite) TestRenderYumRepository(c *gc.C) {
+	repo := config.Repository{
+		Name:     "juju",
+		URIs:     []string{"http://repo.example.com/el7", "http://mirror.example.com/el7"},
+		SignedBy: "http://repo.example.com/RPM-GPG-KEY-juju",
+	}
+	contents, err := config.RenderYumRepository(repo)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(contents, gc.Equals, `
+[juju]
+name=juju (added by Juju)
+baseurl=http://repo.example.com/el7
+        http://mirror.example.com/el7
+enabled=1
+gpgcheck=1
+gpgkey=http://repo.example.com/RPM-GPG-KEY-juju
+`[1:])
+	c.Assert(config.YumRepositoryFile(repo), gc.Equals, "/etc/yum/repos.d/juju.repo")
+
+	repo.SignedBy = ""
+	repo.Disabled = true
+	contents, err = config.RenderYumRepository(repo)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(contents, jc.Contains, "enabled=0\ngpgcheck=0\n")
+}
+
+func (s *SourcesSuite) writeSources(c *gc.C, name, contents string) string {
+	path := filepath.Join(c.MkDir(), name)
+	err := ioutil.WriteFile(path, []byte(contents), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	return path
+}
+
+func (s *SourcesSuite) TestSwapAptMirrors(c *gc.C) {
+	path := s.writeSources(c, "sources.list", `
+# main archive
+deb http://archive.ubuntu.com/ubuntu xenial main restricted
+deb-src http://archive.ubuntu.com/ubuntu xenial main restricted
+deb [arch=amd64] http://archive.ubuntu.com/ubuntu xenial-updates main restricted
+# deb http://archive.ubuntu.com/ubuntu xenial-backports main
+deb http://archive.canonical.com/ubuntu xenial partner
+deb http://security.ubuntu.com/ubuntu xenial-security main restricted
+`[1:])
+
+	err := config.SwapAptMirrors(path, testMirror)
+	c.Assert(err, jc.ErrorIsNil)
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, `
+# main archive
+deb http://mirror.example.com/ubuntu xenial main restricted
+deb-src http://mirror.example.com/ubuntu xenial main restricted
+deb [arch=amd64] http://mirror.example.com/ubuntu xenial-updates main restricted
+# deb http://archive.ubuntu.com/ubuntu xenial-backports main
+deb http://archive.canonical.com/ubuntu xenial partner
+deb http://security-mirror.example.com/ubuntu xenial-security main restricted
+`[1:])
+
+	info, err := os.Stat(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode().Perm(), gc.Equals, os.FileMode(0644))
+}
+
+func (s *SourcesSuite) TestSwapAptMirrorsDeb822(c *gc.C) {
+	path := s.writeSources(c, "ubuntu.sources", config.RenderAptSources("xenial", config.Mirror{}, true))
+
+	err := config.SwapAptMirrors(path, testMirror)
+	c.Assert(err, jc.ErrorIsNil)
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, config.RenderAptSources("xenial", testMirror, true))
+}
+
+func (s *SourcesSuite) TestSwapAptMirrorsWithoutArchive(c *gc.C) {
+	contents := "deb http://archive.canonical.com/ubuntu xenial partner\n"
+	path := s.writeSources(c, "sources.list", contents)
+
+	err := config.SwapAptMirrors(path, testMirror)
+	c.Assert(err, gc.ErrorMatches, `swapping mirrors in ".*sources.list": primary archive not found`)
+	c.Assert(errors.Cause(err), jc.Satisfies, errors.IsNotFound)
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, contents)
+}
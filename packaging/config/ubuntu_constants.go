@@ -14,6 +14,15 @@ const (
 	// UbuntuCloudArchiveUrl is the url of the cloud archive on Ubuntu.
 	UbuntuCloudArchiveUrl = "http://ubuntu-cloud.archive.canonical.com/ubuntu"
 
+	// UbuntuArchiveURL is the url of the primary Ubuntu archive.
+	UbuntuArchiveURL = "http://archive.ubuntu.com/ubuntu"
+
+	// UbuntuSecurityArchiveURL is the url of the Ubuntu security archive.
+	UbuntuSecurityArchiveURL = "http://security.ubuntu.com/ubuntu"
+
+	// UbuntuArchiveKeyring is the keyring which signs the Ubuntu archives.
+	UbuntuArchiveKeyring = "/usr/share/keyrings/ubuntu-archive-keyring.gpg"
+
 	// CloudToolsPrefsPath defines the default location of
 	// apt_preferences(5) file for the cloud-tools pocket.
 	UbuntuCloudToolsPrefsPath = "/etc/apt/preferences.d/50-cloud-tools"
@@ -77,6 +86,10 @@ var UbuntuDefaultPackages = append(DefaultPackages, []string{
 	"python-software-properties",
 }...)
 
+// UbuntuArchiveComponents are the components of the Ubuntu archives
+// which are enabled in the sources rendered by RenderAptSources.
+var UbuntuArchiveComponents = []string{"main", "restricted", "universe", "multiverse"}
+
 // UbuntuDefaultRepositories is the default repository set we'd like to enable
 // on all Ubuntu machines.
 var UbuntuDefaultRepositories = []string{
@@ -0,0 +1,194 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils"
+)
+
+// RebootPolicy is the policy unattended-upgrades follows when
+// installed upgrades require rebooting the machine.
+type RebootPolicy string
+
+const (
+	// RebootNever leaves rebooting the machine to its operators.
+	RebootNever RebootPolicy = ""
+
+	// RebootWithoutUsers reboots the machine unless users are logged in.
+	RebootWithoutUsers RebootPolicy = "without-users"
+
+	// RebootAlways reboots the machine even if users are logged in.
+	RebootAlways RebootPolicy = "always"
+)
+
+// DefaultUnattendedOrigins are the origins from which unattended-upgrades
+// installs upgrades by default: the release and security pockets.
+var DefaultUnattendedOrigins = []string{
+	"${distro_id}:${distro_codename}",
+	"${distro_id}:${distro_codename}-security",
+}
+
+// rebootTimeRE matches the times at which unattended-upgrades may reboot.
+var rebootTimeRE = regexp.MustCompile(`^(now|([01]?[0-9]|2[0-3]):[0-5][0-9])$`)
+
+// UnattendedUpgrades configures the periodic updates and unattended
+// upgrades of packages on apt-based systems.
+type UnattendedUpgrades struct {
+	// Disabled turns off both the periodic updates of the package lists
+	// and the unattended upgrades.
+	Disabled bool
+
+	// Interval is the number of days between unattended upgrades.
+	// It defaults to 1.
+	Interval int
+
+	// AllowedOrigins holds the "<origin>:<archive>" patterns of the
+	// origins whose upgrades are installed. It defaults to
+	// DefaultUnattendedOrigins.
+	AllowedOrigins []string
+
+	// Blacklist holds the regular expressions matching the
+	// packages which are never upgraded.
+	Blacklist []string
+
+	// RemoveUnusedDependencies signals whether the packages which are no
+	// longer needed after an upgrade get removed.
+	RemoveUnusedDependencies bool
+
+	// Reboot is the policy for upgrades which require a reboot.
+	Reboot RebootPolicy
+
+	// RebootTime is the time, as "HH:MM" or "now", at which the machine
+	// is rebooted according to Reboot. It defaults to "now".
+	RebootTime string
+
+	// Mail is the address reports of the upgrades are mailed to.
+	// Reports are not mailed if it is empty.
+	Mail string
+}
+
+// Validate checks that the configuration is fit for rendering.
+func (u UnattendedUpgrades) Validate() error {
+	if u.Interval < 0 {
+		return errors.NotValidf("negative interval %d", u.Interval)
+	}
+	values := append(append([]string{u.Mail}, u.AllowedOrigins...), u.Blacklist...)
+	for _, value := range values {
+		// apt.conf values are quoted, without any escaping.
+		if strings.ContainsAny(value, "\"\n") {
+			return errors.NotValidf("value %q", value)
+		}
+	}
+	switch u.Reboot {
+	case RebootNever, RebootWithoutUsers, RebootAlways:
+	default:
+		return errors.NotValidf("reboot policy %q", u.Reboot)
+	}
+	if u.RebootTime != "" && !rebootTimeRE.MatchString(u.RebootTime) {
+		return errors.NotValidf("reboot time %q", u.RebootTime)
+	}
+	return nil
+}
+
+// RenderAutoUpgrades returns the full contents of AptAutoUpgradesFile.
+func (u UnattendedUpgrades) RenderAutoUpgrades() (string, error) {
+	if err := u.Validate(); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	interval := u.Interval
+	switch {
+	case u.Disabled:
+		interval = 0
+	case interval == 0:
+		interval = 1
+	}
+	return fmt.Sprintf(`
+// Generated by Juju; changes may be overwritten.
+APT::Periodic::Update-Package-Lists "%d";
+APT::Periodic::Unattended-Upgrade "%d";
+`[1:], interval, interval), nil
+}
+
+// RenderUnattendedUpgrades returns the full contents of
+// AptUnattendedUpgradesFile.
+func (u UnattendedUpgrades) RenderUnattendedUpgrades() (string, error) {
+	if err := u.Validate(); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	origins := u.AllowedOrigins
+	if len(origins) == 0 {
+		origins = DefaultUnattendedOrigins
+	}
+	rebootTime := u.RebootTime
+	if rebootTime == "" {
+		rebootTime = "now"
+	}
+
+	lines := []string{"// Generated by Juju; changes may be overwritten."}
+	lines = append(lines, aptConfList("Unattended-Upgrade::Allowed-Origins", origins)...)
+	lines = append(lines, aptConfList("Unattended-Upgrade::Package-Blacklist", u.Blacklist)...)
+	lines = append(lines,
+		aptConfValue("Unattended-Upgrade::Remove-Unused-Dependencies", u.RemoveUnusedDependencies),
+		aptConfValue("Unattended-Upgrade::Automatic-Reboot", u.Reboot != RebootNever),
+		aptConfValue("Unattended-Upgrade::Automatic-Reboot-WithUsers", u.Reboot == RebootAlways),
+		aptConfValue("Unattended-Upgrade::Automatic-Reboot-Time", rebootTime),
+	)
+	if u.Mail != "" {
+		lines = append(lines, aptConfValue("Unattended-Upgrade::Mail", u.Mail))
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// aptConfValue returns the apt.conf line setting the given option.
+func aptConfValue(name string, value interface{}) string {
+	return fmt.Sprintf("%s \"%v\";", name, value)
+}
+
+// aptConfList returns the apt.conf lines setting the given list option.
+func aptConfList(name string, values []string) []string {
+	lines := []string{name + " {"}
+	for _, value := range values {
+		lines = append(lines, fmt.Sprintf("\t\"%s\";", value))
+	}
+	return append(lines, "};")
+}
+
+// ApplyUnattendedUpgrades renders the given configuration and atomically
+// writes it to the auto-upgrades and unattended-upgrades files, named as
+// AptAutoUpgradesFile and AptUnattendedUpgradesFile, of the given apt
+// configuration directory, usually AptConfigDirectory. Neither file is
+// written if the configuration is not valid.
+func ApplyUnattendedUpgrades(dir string, u UnattendedUpgrades) error {
+	autoUpgrades, err := u.RenderAutoUpgrades()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	unattendedUpgrades, err := u.RenderUnattendedUpgrades()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	// the unattended upgrades are configured before being enabled.
+	files := []struct {
+		path, contents string
+	}{
+		{filepath.Join(dir, filepath.Base(AptUnattendedUpgradesFile)), unattendedUpgrades},
+		{filepath.Join(dir, filepath.Base(AptAutoUpgradesFile)), autoUpgrades},
+	}
+	for _, file := range files {
+		if err := utils.AtomicWriteFile(file.path, []byte(file.contents), 0644); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
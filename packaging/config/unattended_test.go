@@ -0,0 +1,135 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/packaging/config"
+)
+
+var _ = gc.Suite(&UnattendedSuite{})
+
+type UnattendedSuite struct{}
+
+func (s *UnattendedSuite) TestRenderAutoUpgrades(c *gc.C) {
+	contents, err := config.UnattendedUpgrades{}.RenderAutoUpgrades()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(contents, gc.Equals, `
+// Generated by Juju; changes may be overwritten.
+APT::Periodic::Update-Package-Lists "1";
+APT::Periodic::Unattended-Upgrade "1";
+`[1:])
+
+	contents, err = config.UnattendedUpgrades{Interval: 7}.RenderAutoUpgrades()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(contents, jc.Contains, `APT::Periodic::Unattended-Upgrade "7";`)
+
+	contents, err = config.UnattendedUpgrades{Disabled: true, Interval: 7}.RenderAutoUpgrades()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(contents, jc.Contains, `APT::Periodic::Unattended-Upgrade "0";`)
+}
+
+func (s *UnattendedSuite) TestRenderUnattendedUpgradesDefaults(c *gc.C) {
+	contents, err := config.UnattendedUpgrades{}.RenderUnattendedUpgrades()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(contents, gc.Equals, `
+// Generated by Juju; changes may be overwritten.
+Unattended-Upgrade::Allowed-Origins {
+	"${distro_id}:${distro_codename}";
+	"${distro_id}:${distro_codename}-security";
+};
+Unattended-Upgrade::Package-Blacklist {
+};
+Unattended-Upgrade::Remove-Unused-Dependencies "false";
+Unattended-Upgrade::Automatic-Reboot "false";
+Unattended-Upgrade::Automatic-Reboot-WithUsers "false";
+Unattended-Upgrade::Automatic-Reboot-Time "now";
+`[1:])
+}
+
+func (s *UnattendedSuite) TestRenderUnattendedUpgrades(c *gc.C) {
+	contents, err := config.UnattendedUpgrades{
+		AllowedOrigins:           []string{"${distro_id}:${distro_codename}-security", "LP-PPA-juju-stable:${distro_codename}"},
+		Blacklist:                []string{"linux-", "juju-db"},
+		RemoveUnusedDependencies: true,
+		Reboot:                   config.RebootAlways,
+		RebootTime:               "02:30",
+		Mail:                     "root",
+	}.RenderUnattendedUpgrades()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(contents, gc.Equals, `
+// Generated by Juju; changes may be overwritten.
+Unattended-Upgrade::Allowed-Origins {
+	"${distro_id}:${distro_codename}-security";
+	"LP-PPA-juju-stable:${distro_codename}";
+};
+Unattended-Upgrade::Package-Blacklist {
+	"linux-";
+	"juju-db";
+};
+Unattended-Upgrade::Remove-Unused-Dependencies "true";
+Unattended-Upgrade::Automatic-Reboot "true";
+Unattended-Upgrade::Automatic-Reboot-WithUsers "true";
+Unattended-Upgrade::Automatic-Reboot-Time "02:30";
+Unattended-Upgrade::Mail "root";
+`[1:])
+}
+
+func (s *UnattendedSuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		config config.UnattendedUpgrades
+		err    string
+	}{{
+		config: config.UnattendedUpgrades{Interval: -1},
+		err:    "negative interval -1 not valid",
+	}, {
+		config: config.UnattendedUpgrades{Blacklist: []string{`juju"`}},
+		err:    `value "juju\\"" not valid`,
+	}, {
+		config: config.UnattendedUpgrades{Reboot: "sometimes"},
+		err:    `reboot policy "sometimes" not valid`,
+	}, {
+		config: config.UnattendedUpgrades{Reboot: config.RebootWithoutUsers, RebootTime: "25:00"},
+		err:    `reboot time "25:00" not valid`,
+	}} {
+		c.Logf("test %d", i)
+		err := test.config.Validate()
+		c.Check(err, gc.ErrorMatches, test.err)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+	}
+}
+
+func (s *UnattendedSuite) TestApplyUnattendedUpgrades(c *gc.C) {
+	dir := c.MkDir()
+	u := config.UnattendedUpgrades{Reboot: config.RebootWithoutUsers}
+	err := config.ApplyUnattendedUpgrades(dir, u)
+	c.Assert(err, jc.ErrorIsNil)
+
+	for file, render := range map[string]func() (string, error){
+		"20auto-upgrades":       u.RenderAutoUpgrades,
+		"50unattended-upgrades": u.RenderUnattendedUpgrades,
+	} {
+		expected, err := render()
+		c.Assert(err, jc.ErrorIsNil)
+		data, err := ioutil.ReadFile(filepath.Join(dir, file))
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(string(data), gc.Equals, expected)
+	}
+}
+
+func (s *UnattendedSuite) TestApplyUnattendedUpgradesInvalid(c *gc.C) {
+	dir := c.MkDir()
+	err := config.ApplyUnattendedUpgrades(dir, config.UnattendedUpgrades{Interval: -1})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+
+	_, err = os.Stat(filepath.Join(dir, "50unattended-upgrades"))
+	c.Assert(err, jc.Satisfies, os.IsNotExist)
+}
@@ -0,0 +1,151 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils"
+	"github.com/juju/utils/packaging/commands"
+	"github.com/juju/utils/proxy"
+)
+
+var (
+	// apkRepositoriesFile is the file which repositories are added to and
+	// removed from. It is a variable for testing purposes.
+	apkRepositoriesFile = commands.ApkRepositoriesFilePath
+
+	// apkProxyConfigFile is the file which the proxy settings are written to.
+	// It is a variable for testing purposes.
+	apkProxyConfigFile = commands.ApkProxyConfigFilePath
+)
+
+// apk is the PackageManager implementation for Alpine-based systems.
+type apk struct {
+	basePackageManager
+}
+
+// ListInstalled is defined on the PackageManager interface.
+func (apk *apk) ListInstalled() ([]PackageInfo, error) {
+	out, _, err := apk.runCommand(apk.cmder.ListInstalledCmd(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// apk info -v outputs "<name>-<version>" lines.
+	var res []PackageInfo
+	for _, line := range strings.Split(out, "\n") {
+		name, version := splitNameVersion(strings.TrimSpace(line))
+		if version == "" {
+			continue
+		}
+		res = append(res, PackageInfo{Name: name, Version: version})
+	}
+
+	return res, nil
+}
+
+// Search is defined on the PackageManager interface.
+func (apk *apk) Search(pack string) (bool, error) {
+	out, _, err := apk.runCommand(apk.cmder.SearchCmd(pack), nil)
+	if err != nil {
+		return false, err
+	}
+
+	// apk search --exact returns no output if the search was unsuccessful.
+	return strings.TrimSpace(out) != "", nil
+}
+
+// AddRepository is defined on the PackageManager interface.
+// The repository is the URL of the repository, as it would
+// appear in the repositories file.
+func (apk *apk) AddRepository(repo string) error {
+	repo = strings.TrimSpace(repo)
+	if repo == "" || strings.Contains(repo, "\n") {
+		return errors.NotValidf("apk repository %q", repo)
+	}
+
+	lines, err := readApkRepositories()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, line := range lines {
+		if strings.TrimSpace(line) == repo {
+			return errors.AlreadyExistsf("apk repository %q", repo)
+		}
+	}
+
+	return writeApkRepositories(append(lines, repo))
+}
+
+// RemoveRepository is defined on the PackageManager interface.
+func (apk *apk) RemoveRepository(repo string) error {
+	repo = strings.TrimSpace(repo)
+
+	lines, err := readApkRepositories()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	var kept []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != repo {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) == len(lines) {
+		return errors.NotFoundf("apk repository %q", repo)
+	}
+
+	return writeApkRepositories(kept)
+}
+
+// readApkRepositories returns the lines of the apk repositories file.
+func readApkRepositories() ([]string, error) {
+	data, err := ioutil.ReadFile(apkRepositoriesFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	contents := strings.TrimRight(string(data), "\n")
+	if contents == "" {
+		return nil, nil
+	}
+	return strings.Split(contents, "\n"), nil
+}
+
+// writeApkRepositories replaces the apk repositories file with the given lines.
+func writeApkRepositories(lines []string) error {
+	contents := strings.Join(lines, "\n") + "\n"
+	return errors.Trace(utils.AtomicWriteFile(apkRepositoriesFile, []byte(contents), 0644))
+}
+
+// GetProxySettings is defined on the PackageManager interface.
+func (apk *apk) GetProxySettings() (proxy.Settings, error) {
+	var res proxy.Settings
+
+	args := strings.Fields(apk.cmder.GetProxyCmd())
+	if len(args) <= 1 {
+		return proxy.Settings{}, fmt.Errorf("expected at least 2 arguments, got %d %v", len(args), args)
+	}
+
+	out, err := apk.runCommandOnce(args...)
+
+	if err != nil {
+		// grep exits with 1 when no proxy has been configured.
+		if len(out) == 0 {
+			return res, nil
+		}
+		logger.Errorf("command failed: %v\nargs: %#v\n%s",
+			err, args, string(out))
+		return res, fmt.Errorf("command failed: %v", err)
+	}
+
+	return parseProxySettings(string(out)), nil
+}
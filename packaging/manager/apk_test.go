@@ -0,0 +1,130 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils/packaging/commands"
+	"github.com/juju/utils/packaging/manager"
+	"github.com/juju/utils/proxy"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&ApkSuite{})
+
+type ApkSuite struct {
+	testing.IsolationSuite
+	pacman    manager.PackageManager
+	reposFile string
+	proxyFile string
+}
+
+const testedApkRepositories = `http://dl-cdn.alpinelinux.org/alpine/v3.3/main
+#http://dl-cdn.alpinelinux.org/alpine/v3.3/community
+`
+
+func (s *ApkSuite) SetUpSuite(c *gc.C) {
+	s.IsolationSuite.SetUpSuite(c)
+	s.pacman = manager.NewApkPackageManager()
+}
+
+func (s *ApkSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	dir := c.MkDir()
+	s.reposFile = filepath.Join(dir, "repositories")
+	s.proxyFile = filepath.Join(dir, "juju-proxy.sh")
+	err := ioutil.WriteFile(s.reposFile, []byte(testedApkRepositories), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	s.PatchValue(manager.ApkRepositoriesFile, s.reposFile)
+	s.PatchValue(manager.ApkProxyConfigFile, s.proxyFile)
+}
+
+func (s *ApkSuite) readFile(c *gc.C, path string) string {
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, jc.ErrorIsNil)
+	return string(data)
+}
+
+func (s *ApkSuite) TestNewPackageManager(c *gc.C) {
+	pacman, err := manager.NewPackageManager("alpine")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(pacman, gc.FitsTypeOf, s.pacman)
+}
+
+func (s *ApkSuite) TestSearch(c *gc.C) {
+	var calledCommand string
+	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&calledCommand))
+
+	found, err := s.pacman.Search(testedPackageName)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(found, jc.IsFalse)
+	c.Assert(calledCommand, gc.Equals, "apk --no-progress search --exact "+testedPackageName)
+}
+
+func (s *ApkSuite) TestAddRepository(c *gc.C) {
+	err := s.pacman.AddRepository("http://dl-cdn.alpinelinux.org/alpine/edge/testing")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.readFile(c, s.reposFile), gc.Equals,
+		testedApkRepositories+"http://dl-cdn.alpinelinux.org/alpine/edge/testing\n")
+}
+
+func (s *ApkSuite) TestAddRepositoryExists(c *gc.C) {
+	err := s.pacman.AddRepository("http://dl-cdn.alpinelinux.org/alpine/v3.3/main")
+	c.Assert(err, jc.Satisfies, errors.IsAlreadyExists)
+}
+
+func (s *ApkSuite) TestRemoveRepository(c *gc.C) {
+	err := s.pacman.RemoveRepository("http://dl-cdn.alpinelinux.org/alpine/v3.3/main")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.readFile(c, s.reposFile), gc.Equals,
+		"#http://dl-cdn.alpinelinux.org/alpine/v3.3/community\n")
+}
+
+func (s *ApkSuite) TestRemoveRepositoryNotFound(c *gc.C) {
+	err := s.pacman.RemoveRepository("http://dl-cdn.alpinelinux.org/alpine/edge/testing")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *ApkSuite) TestSetProxy(c *gc.C) {
+	err := s.pacman.SetProxy(testedProxySettings)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.readFile(c, s.proxyFile), gc.Equals, `export http_proxy=http://some-proxy.domain
+export https_proxy=https://some-proxy.domain
+export ftp_proxy=ftp://some-proxy.domain
+`)
+}
+
+func (s *ApkSuite) TestProxySettingsRoundTrip(c *gc.C) {
+	initial := proxy.Settings{
+		Http:  "some-proxy.local:8080",
+		Https: "some-secure-proxy.local:9696",
+	}
+
+	expected := commands.NewApkPackageCommander().ProxyConfigContents(initial)
+	cmdChan := s.HookCommandOutput(&manager.CommandOutput, []byte(expected), nil)
+
+	result, err := s.pacman.GetProxySettings()
+	c.Assert(err, jc.ErrorIsNil)
+	<-cmdChan
+
+	c.Assert(result, gc.Equals, initial)
+}
+
+func (s *ApkSuite) TestListInstalled(c *gc.C) {
+	s.PatchValue(&manager.RunCommandWithRetry, func(string, func(string) error) (string, int, error) {
+		return "musl-1.1.14-r10\nca-certificates-20160104-r4\n", 0, nil
+	})
+
+	packages, err := s.pacman.ListInstalled()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(packages, jc.DeepEquals, []manager.PackageInfo{
+		{Name: "musl", Version: "1.1.14-r10"},
+		{Name: "ca-certificates", Version: "20160104-r4"},
+	})
+}
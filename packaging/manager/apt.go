@@ -6,27 +6,91 @@ package manager
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"os/exec"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/juju/errors"
+
+	"github.com/juju/utils"
+	"github.com/juju/utils/packaging/commands"
 	"github.com/juju/utils/proxy"
 )
 
+// aptKeyringsDir is the directory repository keys are installed into.
+// It is a variable for testing purposes.
+var aptKeyringsDir = commands.AptKeyringsDir
+
+// aptCacheStamps are the files whose modification time tells when the
+// package lists were last updated, in order of preference: the stamp
+// left by update-notifier's hook, then the lists directory itself.
+// It is a variable for testing purposes.
+var aptCacheStamps = []string{
+	"/var/lib/apt/periodic/update-success-stamp",
+	"/var/lib/apt/lists",
+}
+
+// aptHistoryFile is the log of the transactions apt made.
+// It is a variable for testing purposes.
+var aptHistoryFile = "/var/log/apt/history.log"
+
+// aptHistoryPackageRE matches the packages listed in apt history logs,
+// capturing their name, architecture and parenthesized versions, such as:
+//
+//	bash:amd64 (4.3-14ubuntu1, 4.3-14ubuntu1.1)
+var aptHistoryPackageRE = regexp.MustCompile(`([^\s,:]+)(?::(\S+))? \(([^)]*)\)`)
+
+// aptHistoryActions maps the fields of apt history logs
+// to the actions they list the packages of.
+var aptHistoryActions = map[string]PackageAction{
+	"Install":   PackageInstalled,
+	"Upgrade":   PackageUpgraded,
+	"Downgrade": PackageDowngraded,
+	"Reinstall": PackageReinstalled,
+	"Remove":    PackageRemoved,
+	"Purge":     PackagePurged,
+}
+
 // proxyRe is a regexp which matches all proxy-related configuration options in
 // the apt configuration file.
 var proxyRE = regexp.MustCompile(`(?im)^\s*Acquire::(?P<protocol>[a-z]+)::Proxy\s+"(?P<proxy>[^"]+)";\s*$`)
 
+// aptSimulateInstallRE matches the lines of simulated apt-get operations
+// which report a package getting installed, capturing the name, the currently
+// installed version if the package is being upgraded, the new version and the
+// architecture of the package. Such lines look like:
+//
+//	Inst juju [1.25.5-0ubuntu1] (2.0.0-0ubuntu1 Ubuntu:16.04/xenial [amd64])
+var aptSimulateInstallRE = regexp.MustCompile(`^Inst (\S+) (?:\[([^\]]+)\] )?\((\S+) .*\[(\S+)\]\)`)
+
+// aptSimulateRemoveRE matches the lines of simulated apt-get operations
+// which report a package getting removed, capturing the name and version
+// of the package. Such lines look like:
+//
+//	Remv juju [2.0.0-0ubuntu1]
+var aptSimulateRemoveRE = regexp.MustCompile(`^Remv (\S+)(?: \[([^\]]+)\])?`)
+
 // apt is the PackageManager implementation for deb-based systems.
 type apt struct {
 	basePackageManager
 }
 
+// UpdateIfOlderThan is defined on the PackageManager interface.
+func (apt *apt) UpdateIfOlderThan(d time.Duration) error {
+	if cacheUpdatedWithin(aptCacheStamps, d) {
+		return nil
+	}
+	return apt.Update()
+}
+
 // Search is defined on the PackageManager interface.
 func (apt *apt) Search(pack string) (bool, error) {
-	out, _, err := RunCommandWithRetry(apt.cmder.SearchCmd(pack), nil)
+	out, _, err := apt.runCommand(apt.cmder.SearchCmd(pack), nil)
 	if err != nil {
 		return false, err
 	}
@@ -39,20 +103,470 @@ func (apt *apt) Search(pack string) (bool, error) {
 	return true, nil
 }
 
+// SearchPackages is defined on the PackageManager interface.
+func (apt *apt) SearchPackages(term string) ([]PackageInfo, error) {
+	out, _, err := apt.runCommand(apt.cmder.SearchPackagesCmd(term), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// apt-cache search --full outputs the full package records, separated
+	// by blank lines, of which only the first line of each field is relevant.
+	var res []PackageInfo
+	for _, record := range strings.Split(out, "\n\n") {
+		var info PackageInfo
+		for _, line := range strings.Split(record, "\n") {
+			fields := strings.SplitN(line, ":", 2)
+			if len(fields) != 2 || strings.HasPrefix(line, " ") {
+				continue
+			}
+
+			value := strings.TrimSpace(fields[1])
+			switch fields[0] {
+			case "Package":
+				info.Name = value
+			case "Version":
+				info.Version = value
+			case "Architecture":
+				info.Architecture = value
+			case "Description", "Description-en":
+				info.Description = value
+			}
+		}
+		if info.Name != "" {
+			res = append(res, info)
+		}
+	}
+
+	return res, nil
+}
+
+// SimulateInstall is defined on the PackageManager interface.
+func (apt *apt) SimulateInstall(packs ...string) (*Transaction, error) {
+	return apt.simulate(apt.cmder.SimulateInstallCmd(packs...))
+}
+
+// SimulateRemove is defined on the PackageManager interface.
+func (apt *apt) SimulateRemove(packs ...string) (*Transaction, error) {
+	return apt.simulate(apt.cmder.SimulateRemoveCmd(packs...))
+}
+
+// SimulateUpgrade is defined on the PackageManager interface.
+func (apt *apt) SimulateUpgrade() (*Transaction, error) {
+	return apt.simulate(apt.cmder.SimulateUpgradeCmd())
+}
+
+// simulate runs the given simulated apt-get command and
+// parses the changes it reports into a Transaction.
+func (apt *apt) simulate(cmd string) (*Transaction, error) {
+	out, _, err := apt.runCommand(cmd, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var res Transaction
+	for _, line := range strings.Split(out, "\n") {
+		if match := aptSimulateInstallRE.FindStringSubmatch(line); match != nil {
+			info := PackageInfo{Name: match[1], Version: match[3], Architecture: match[4]}
+			if match[2] == "" {
+				res.Install = append(res.Install, info)
+			} else {
+				res.Upgrade = append(res.Upgrade, info)
+			}
+		} else if match := aptSimulateRemoveRE.FindStringSubmatch(line); match != nil {
+			res.Remove = append(res.Remove, PackageInfo{Name: match[1], Version: match[2]})
+		}
+	}
+
+	return &res, nil
+}
+
+// History is defined on the PackageManager interface.
+// Only the current log is read; apt compresses the older ones on rotation.
+func (apt *apt) History() ([]HistoryEntry, error) {
+	data, err := ioutil.ReadFile(aptHistoryFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return parseAptHistory(string(data))
+}
+
+// parseAptHistory parses the given apt history log, whose entries are
+// blank-line separated records such as:
+//
+//	Start-Date: 2016-04-21  14:33:07
+//	Commandline: apt-get install juju
+//	Requested-By: ubuntu (1000)
+//	Install: juju:amd64 (2.0.0-0ubuntu1), libjuju:amd64 (2.0.0-0ubuntu1, automatic)
+//	Upgrade: bash:amd64 (4.3-14ubuntu1, 4.3-14ubuntu1.1)
+//	End-Date: 2016-04-21  14:33:10
+func parseAptHistory(log string) ([]HistoryEntry, error) {
+	var res []HistoryEntry
+	for _, record := range strings.Split(log, "\n\n") {
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+
+		var entry HistoryEntry
+		for _, line := range strings.Split(record, "\n") {
+			fields := strings.SplitN(line, ":", 2)
+			if len(fields) != 2 {
+				continue
+			}
+			key, value := fields[0], strings.TrimSpace(fields[1])
+
+			var err error
+			switch key {
+			case "Start-Date":
+				entry.Start, err = parseAptHistoryDate(value)
+			case "End-Date":
+				entry.End, err = parseAptHistoryDate(value)
+			case "Commandline":
+				entry.CommandLine = value
+			case "Requested-By":
+				entry.RequestedBy = value
+			default:
+				if action, ok := aptHistoryActions[key]; ok {
+					entry.Changes = append(entry.Changes, parseAptHistoryChanges(action, value)...)
+				}
+			}
+			if err != nil {
+				return nil, errors.Annotatef(err, "parsing apt history")
+			}
+		}
+		if entry.Start.IsZero() {
+			return nil, errors.Errorf("parsing apt history: record without Start-Date: %q", record)
+		}
+		res = append(res, entry)
+	}
+	return res, nil
+}
+
+// parseAptHistoryDate parses the dates of apt history logs,
+// which are in local time.
+func parseAptHistoryDate(value string) (time.Time, error) {
+	// the date and time are separated by two spaces.
+	return time.ParseInLocation("2006-01-02 15:04:05", strings.Join(strings.Fields(value), " "), time.Local)
+}
+
+// parseAptHistoryChanges parses the packages listed for the given
+// action in an apt history log.
+func parseAptHistoryChanges(action PackageAction, value string) []PackageChange {
+	var res []PackageChange
+	for _, match := range aptHistoryPackageRE.FindAllStringSubmatch(value, -1) {
+		change := PackageChange{
+			Action:  action,
+			Package: PackageInfo{Name: match[1], Architecture: match[2]},
+		}
+		var versions []string
+		for _, version := range strings.Split(match[3], ",") {
+			version = strings.TrimSpace(version)
+			if version == "automatic" {
+				change.Automatic = true
+			} else if version != "" {
+				versions = append(versions, version)
+			}
+		}
+		switch len(versions) {
+		case 1:
+			change.Package.Version = versions[0]
+		case 2:
+			change.PreviousVersion, change.Package.Version = versions[0], versions[1]
+		}
+		res = append(res, change)
+	}
+	return res
+}
+
+// AvailableVersions is defined on the PackageManager interface.
+func (apt *apt) AvailableVersions(pack string) ([]PackageVersion, error) {
+	out, _, err := apt.runCommand(apt.cmder.ListVersionsCmd(pack), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, versions := parseAptPolicy(out)
+	return versions, nil
+}
+
+// CandidateVersion is defined on the PackageManager interface.
+func (apt *apt) CandidateVersion(pack string) (string, error) {
+	out, _, err := apt.runCommand(apt.cmder.ListVersionsCmd(pack), nil)
+	if err != nil {
+		return "", err
+	}
+
+	candidate, _ := parseAptPolicy(out)
+	if candidate == "" {
+		return "", errors.NotFoundf("candidate version of package %q", pack)
+	}
+	return candidate, nil
+}
+
+// parseAptPolicy parses the output of apt-cache policy, such as:
+//
+//	juju:
+//	  Installed: 2.0.0-0ubuntu1
+//	  Candidate: 2.0.2-0ubuntu1
+//	  Version table:
+//	     2.0.2-0ubuntu1 500
+//	        500 http://archive.ubuntu.com/ubuntu xenial-updates/main amd64 Packages
+//	 *** 2.0.0-0ubuntu1 100
+//	        100 /var/lib/dpkg/status
+//
+// into the candidate version, which is empty if there is none, and the
+// versions listed in the version table.
+func parseAptPolicy(out string) (string, []PackageVersion) {
+	var candidate string
+	var versions []PackageVersion
+
+	inTable := false
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "Candidate:" && len(fields) == 2:
+			if fields[1] != "(none)" {
+				candidate = fields[1]
+			}
+		case strings.TrimSpace(line) == "Version table:":
+			inTable = true
+		case !inTable:
+		case fields[0] == "***" && len(fields) >= 2:
+			versions = append(versions, PackageVersion{Version: fields[1], Installed: true})
+		case len(line)-len(strings.TrimLeft(line, " ")) < 8:
+			versions = append(versions, PackageVersion{Version: fields[0]})
+		case len(versions) > 0 && len(fields) >= 2:
+			// a source of the previous version, preceded by its priority.
+			last := &versions[len(versions)-1]
+			source := strings.Join(fields[1:], " ")
+			if source != "/var/lib/dpkg/status" {
+				last.Repositories = append(last.Repositories, source)
+			}
+		}
+	}
+
+	return candidate, versions
+}
+
+// Depends is defined on the PackageManager interface.
+func (apt *apt) Depends(pack string) (DependencyGraph, error) {
+	out, _, err := apt.runCommand(apt.cmder.DependsCmd(pack), nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseAptDependencies(out), nil
+}
+
+// RDepends is defined on the PackageManager interface.
+func (apt *apt) RDepends(pack string) (DependencyGraph, error) {
+	out, _, err := apt.runCommand(apt.cmder.RDependsCmd(pack), nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseAptDependencies(out), nil
+}
+
+// parseAptDependencies parses the output of apt-cache depends or rdepends
+// with the --recurse option, which lists every package reached on an
+// unindented line followed by the packages it is related to, such as:
+//
+//	juju
+//	  Depends: juju-2.0
+//	 |Depends: <lxd>
+//	    lxd
+//
+// Virtual packages are enclosed in angle brackets and followed by their
+// providers on further indented lines, which the virtual packages are
+// considered to depend upon.
+func parseAptDependencies(out string) DependencyGraph {
+	graph := make(DependencyGraph)
+
+	var node, related string
+	for _, line := range strings.Split(out, "\n") {
+		entry := strings.TrimLeft(line, " |")
+		if i := strings.Index(entry, ": "); i >= 0 {
+			entry = entry[i+2:]
+		}
+		entry = strings.Trim(entry, "<>")
+
+		switch indent := len(line) - len(strings.TrimLeft(line, " ")); {
+		case entry == "" || line == "Reverse Depends:":
+		case indent == 0 && !strings.HasPrefix(line, "|"):
+			node, related = entry, ""
+			if _, ok := graph[node]; !ok {
+				graph[node] = nil
+			}
+		case node == "":
+		case indent <= 2:
+			related = entry
+			graph[node] = appendUnique(graph[node], related)
+		case related != "" && entry != related:
+			// a provider of the virtual package on the previous line.
+			graph[related] = appendUnique(graph[related], entry)
+		}
+	}
+
+	return graph
+}
+
 // Install is defined on the PackageManager interface.
 func (apt *apt) Install(packs ...string) error {
-	fatalErr := func(output string) error {
-		// If we couldn't find the package don't retry.
-		// apt-get will report "Unable to locate package"
-		if strings.Contains(output, "Unable to locate package") {
-			return errors.New("unable to locate package")
+	return apt.InstallContext(context.Background(), packs...)
+}
+
+// InstallContext is defined on the PackageManager interface.
+func (apt *apt) InstallContext(ctx context.Context, packs ...string) error {
+	return apt.runWithProgress(ctx, apt.installCmd(packs...), aptLocateFatalError)
+}
+
+// aptLocateFatalError returns an error if the given output of apt-get
+// reports a package which could not be found, as retrying is then futile.
+func aptLocateFatalError(output string) error {
+	// If we couldn't find the package don't retry.
+	// apt-get will report "Unable to locate package"
+	if strings.Contains(output, "Unable to locate package") {
+		return errors.New("unable to locate package")
+	}
+	return nil
+}
+
+// Upgrade is defined on the PackageManager interface.
+func (apt *apt) Upgrade() error {
+	return apt.UpgradeContext(context.Background())
+}
+
+// UpgradeContext is defined on the PackageManager interface.
+func (apt *apt) UpgradeContext(ctx context.Context) error {
+	return apt.runWithProgress(ctx, apt.cmder.UpgradeCmd(), nil)
+}
+
+// UpgradeOnly is defined on the PackageManager interface.
+func (apt *apt) UpgradeOnly(packs ...string) error {
+	cmd, err := upgradeOnlyCmd(apt.cmder, packs)
+	if err != nil {
+		return err
+	}
+	return apt.runWithProgress(context.Background(), cmd, aptLocateFatalError)
+}
+
+// runWithProgress runs the given apt-get command, reporting its
+// progress to the registered progress callback, if any.
+func (apt *apt) runWithProgress(ctx context.Context, cmd string, getFatalError func(string) error) error {
+	if apt.progress == nil {
+		_, _, err := apt.runCommandContext(ctx, cmd, getFatalError)
+		return err
+	}
+
+	// make apt-get report its progress in a machine-readable
+	// form on stdout, alongside its regular output.
+	cmd += " --option=APT::Status-Fd=1"
+	run := apt.withEnvironment(outputWithProgress(ctx, func(line string) {
+		if event, ok := parseAptStatus(line); ok {
+			apt.progress(event)
 		}
-		return nil
+	}))
+	out, _, err := runCommandWithRetry(ctx, cmd, getFatalError, run, apt.retry)
+	apt.reportOutput(cmd, out, err)
+	return err
+}
+
+// ListInstalled is defined on the PackageManager interface.
+func (apt *apt) ListInstalled() ([]PackageInfo, error) {
+	out, _, err := apt.runCommand(apt.cmder.ListInstalledCmd(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// dpkg-query outputs "<status>\t<name>\t<version>\t<architecture>"
+	// lines, including packages which were removed but whose config files
+	// remain on the system.
+	var res []PackageInfo
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 || !strings.HasSuffix(fields[0], " installed") {
+			continue
+		}
+		res = append(res, PackageInfo{
+			Name:         fields[1],
+			Version:      fields[2],
+			Architecture: fields[3],
+		})
 	}
-	_, _, err := RunCommandWithRetry(apt.cmder.InstallCmd(packs...), fatalErr)
+
+	return res, nil
+}
+
+// Fetch is defined on the PackageManager interface.
+func (apt *apt) Fetch(dir string, packs ...string) error {
+	if dir == "" {
+		return errors.NotValidf("empty download directory")
+	}
+	if len(packs) == 0 {
+		return errors.NotValidf("no packages to download")
+	}
+
+	// apt-get download always downloads into the working directory.
+	cmd := strings.Join(append([]string{"apt-get", "--quiet", "download"}, packs...), " ")
+	_, _, err := apt.runCommandInDir(context.Background(), dir, cmd, nil)
 	return err
 }
 
+// FetchWithDependencies is defined on the PackageManager interface.
+func (apt *apt) FetchWithDependencies(dir string, packs ...string) error {
+	if dir == "" {
+		return errors.NotValidf("empty download directory")
+	}
+
+	// apt-get refuses to download into an archives directory
+	// which lacks the directory for partial downloads.
+	if err := os.MkdirAll(filepath.Join(dir, "partial"), 0755); err != nil {
+		return errors.Trace(err)
+	}
+
+	return apt.basePackageManager.FetchWithDependencies(dir, packs...)
+}
+
+// AddRepositoryKey is defined on the PackageManager interface.
+// The key is installed as the keyring <AptKeyringsDir>/<name>.gpg, which
+// the sources of the repositories it signs are expected to reference via
+// their signed-by option.
+func (apt *apt) AddRepositoryKey(name string, key RepositoryKey) error {
+	if err := validateKeyName(name); err != nil {
+		return errors.Trace(err)
+	}
+	_, entities, err := readRepositoryKey(key)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	keyring, err := serializeKeyRing(entities)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := os.MkdirAll(aptKeyringsDir, 0755); err != nil {
+		return errors.Trace(err)
+	}
+	path := filepath.Join(aptKeyringsDir, name+".gpg")
+	return errors.Trace(utils.AtomicWriteFile(path, keyring, 0644))
+}
+
+// RemoveRepositoryKey is defined on the PackageManager interface.
+func (apt *apt) RemoveRepositoryKey(name string) error {
+	if err := validateKeyName(name); err != nil {
+		return errors.Trace(err)
+	}
+
+	err := os.Remove(filepath.Join(aptKeyringsDir, name+".gpg"))
+	if os.IsNotExist(err) {
+		return errors.NotFoundf("repository key %q", name)
+	}
+	return errors.Trace(err)
+}
+
 // GetProxySettings is defined on the PackageManager interface.
 func (apt *apt) GetProxySettings() (proxy.Settings, error) {
 	var res proxy.Settings
@@ -62,8 +576,7 @@ func (apt *apt) GetProxySettings() (proxy.Settings, error) {
 		return proxy.Settings{}, fmt.Errorf("expected at least 2 arguments, got %d %v", len(args), args)
 	}
 
-	cmd := exec.Command(args[0], args[1:]...)
-	out, err := CommandOutput(cmd)
+	out, err := apt.runCommandOnce(args...)
 
 	if err != nil {
 		logger.Errorf("command failed: %v\nargs: %#v\n%s",
@@ -0,0 +1,210 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils"
+	"github.com/juju/utils/packaging/config"
+)
+
+var (
+	// aptSourcesDir is the directory AddRepositorySources writes the
+	// sources of repositories into. It is a variable for testing purposes.
+	aptSourcesDir = config.AptSourcesDirectory
+
+	// launchpadAPI is the root of the Launchpad API PPAs are resolved
+	// through. It is a variable for testing purposes.
+	launchpadAPI = "https://api.launchpad.net/1.0"
+
+	// ppaArchive is the root of the archive serving PPAs.
+	ppaArchive = "https://ppa.launchpadcontent.net"
+
+	// ppaKeyserver is the keyserver the keys signing PPAs are retrieved
+	// from. It is a variable for testing purposes.
+	ppaKeyserver = DefaultKeyserver
+)
+
+// AptManager is the PackageManager for apt, extended with the operations
+// which are specific to apt.
+type AptManager interface {
+	PackageManager
+
+	// AddRepositorySources adds the given repository, as accepted by
+	// AddRepository, by writing its sources.list.d file itself rather than
+	// running add-apt-repository, which minimal images do not provide.
+	// PPAs are resolved through the Launchpad API for the series of the
+	// running system, and the keyring signing them is installed too.
+	AddRepositorySources(repo string) error
+
+	// RemoveRepositorySources removes the sources.list.d file, and the
+	// keyring of PPAs, written by AddRepositorySources for the given
+	// repository.
+	RemoveRepositorySources(repo string) error
+}
+
+// AddRepositorySources is defined on the AptManager interface.
+func (apt *apt) AddRepositorySources(repo string) error {
+	spec, err := ParseRepositorySpec(repo)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	var contents string
+	switch spec.Kind {
+	case RepositoryPPA:
+		if contents, err = apt.ppaSources(spec); err != nil {
+			return errors.Annotatef(err, "cannot add %s", spec)
+		}
+	case RepositoryDeb:
+		contents = fmt.Sprintf("# %s (added by Juju)\n%s\n", aptSourcesName(spec), spec)
+	default:
+		return errors.NotValidf("apt repository %q", repo)
+	}
+
+	if err := os.MkdirAll(aptSourcesDir, 0755); err != nil {
+		return errors.Trace(err)
+	}
+	path := filepath.Join(aptSourcesDir, aptSourcesName(spec)+".list")
+	return errors.Trace(utils.AtomicWriteFile(path, []byte(contents), 0644))
+}
+
+// RemoveRepositorySources is defined on the AptManager interface.
+func (apt *apt) RemoveRepositorySources(repo string) error {
+	spec, err := ParseRepositorySpec(repo)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if spec.Kind == RepositoryURL {
+		return errors.NotValidf("apt repository %q", repo)
+	}
+
+	name := aptSourcesName(spec)
+	err = os.Remove(filepath.Join(aptSourcesDir, name+".list"))
+	if os.IsNotExist(err) {
+		return errors.NotFoundf("sources of repository %q", repo)
+	} else if err != nil {
+		return errors.Trace(err)
+	}
+
+	if spec.Kind == RepositoryPPA {
+		if err := apt.RemoveRepositoryKey(name); err != nil && !errors.IsNotFound(err) {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// ppaSources installs the keyring signing the given PPA and returns
+// the contents of its sources.list.d file.
+func (apt *apt) ppaSources(spec RepositorySpec) (string, error) {
+	series, err := hostSeries()
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	fingerprint, err := ppaSigningKey(spec)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	// The key is checked against the fingerprint reported by
+	// Launchpad before it is installed.
+	armored, _, err := readRepositoryKey(RepositoryKey{Fingerprint: fingerprint, Keyserver: ppaKeyserver})
+	if err != nil {
+		return "", errors.Annotatef(err, "cannot retrieve signing key of %s", spec.String())
+	}
+	name := aptSourcesName(spec)
+	if err := apt.AddRepositoryKey(name, RepositoryKey{Armored: armored}); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	return config.RenderAptRepository(config.Repository{
+		Name:       name,
+		URIs:       []string{fmt.Sprintf("%s/%s/%s/ubuntu", ppaArchive, spec.Owner, spec.Name)},
+		Suites:     []string{series},
+		Components: []string{"main"},
+		SignedBy:   filepath.Join(aptKeyringsDir, name+".gpg"),
+	}, false)
+}
+
+// ppaSigningKey returns the fingerprint of the key signing the given
+// PPA, as reported by the Launchpad API.
+func ppaSigningKey(spec RepositorySpec) (string, error) {
+	location := fmt.Sprintf("%s/~%s/+archive/ubuntu/%s", strings.TrimRight(launchpadAPI, "/"), spec.Owner, spec.Name)
+	resp, err := utils.GetValidatingHTTPClient().Get(location)
+	if err != nil {
+		return "", errors.Annotatef(err, "cannot resolve PPA from %q", location)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", errors.NotFoundf("PPA %q", spec.String())
+	default:
+		return "", errors.Errorf("cannot resolve PPA from %q: %s", location, resp.Status)
+	}
+
+	var archive struct {
+		SigningKeyFingerprint string `json:"signing_key_fingerprint"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&archive); err != nil {
+		return "", errors.Annotatef(err, "cannot resolve PPA from %q", location)
+	}
+	if archive.SigningKeyFingerprint == "" {
+		return "", errors.Errorf("PPA %q has no signing key", spec.String())
+	}
+	return archive.SigningKeyFingerprint, nil
+}
+
+// hostSeries returns the codename of the series of the running system,
+// as found in its os-release file.
+func hostSeries() (string, error) {
+	values, err := readOSRelease(osReleaseFile)
+	if err != nil {
+		return "", errors.Annotate(err, "cannot determine the series of the running system")
+	}
+	// derivatives such as Linux Mint give the Ubuntu series they are
+	// based on, which their PPAs are built for, separately.
+	for _, field := range []string{"UBUNTU_CODENAME", "VERSION_CODENAME"} {
+		if series := values[field]; series != "" {
+			return series, nil
+		}
+	}
+	return "", errors.NotFoundf("series of the running system")
+}
+
+// nonNameCharsRE matches the runs of characters which aptSourcesName
+// replaces, as they are best kept out of file names.
+var nonNameCharsRE = regexp.MustCompile(`[^a-zA-Z0-9.]+`)
+
+// aptSourcesName returns the name of the sources.list.d file, and of the
+// keyring of PPAs, which AddRepositorySources writes for the given
+// repository. The names follow those used by add-apt-repository.
+func aptSourcesName(spec RepositorySpec) string {
+	if spec.Kind == RepositoryPPA {
+		return fmt.Sprintf("%s-ubuntu-%s", spec.Owner, spec.Name)
+	}
+
+	uri := spec.URI
+	if i := strings.Index(uri, "://"); i != -1 {
+		uri = uri[i+len("://"):]
+	}
+	name := fmt.Sprintf("archive_uri-%s-%s",
+		strings.Trim(nonNameCharsRE.ReplaceAllString(uri, "_"), "_"),
+		strings.Trim(nonNameCharsRE.ReplaceAllString(spec.Suite, "_"), "_"),
+	)
+	if spec.Type == "deb-src" {
+		name += "-src"
+	}
+	return name
+}
@@ -0,0 +1,147 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/packaging/manager"
+)
+
+var _ = gc.Suite(&AptSourcesSuite{})
+
+type AptSourcesSuite struct {
+	testing.IsolationSuite
+	armored     string
+	fingerprint string
+	sourcesDir  string
+	keysDir     string
+	server      *httptest.Server
+	requests    []string
+}
+
+func (s *AptSourcesSuite) SetUpSuite(c *gc.C) {
+	s.IsolationSuite.SetUpSuite(c)
+
+	entity, err := openpgp.NewEntity("Test PPA", "", "ppa@example.com", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(entity.Serialize(w), jc.ErrorIsNil)
+	c.Assert(w.Close(), jc.ErrorIsNil)
+	s.armored = buf.String()
+	s.fingerprint = fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint[:])
+}
+
+func (s *AptSourcesSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+
+	s.requests = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.requests = append(s.requests, req.URL.Path)
+		switch req.URL.Path {
+		case "/~juju/+archive/ubuntu/stable":
+			fmt.Fprintf(w, `{"name": "stable", "signing_key_fingerprint": %q}`, s.fingerprint)
+		case "/pks/lookup":
+			fmt.Fprint(w, s.armored)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.PatchValue(manager.LaunchpadAPI, s.server.URL)
+	s.PatchValue(manager.PPAKeyserver, s.server.URL)
+
+	dir := c.MkDir()
+	s.sourcesDir = filepath.Join(dir, "sources.list.d")
+	s.keysDir = filepath.Join(dir, "keyrings")
+	s.PatchValue(manager.AptSourcesDir, s.sourcesDir)
+	s.PatchValue(manager.AptKeyringsDir, s.keysDir)
+
+	osRelease := filepath.Join(dir, "os-release")
+	err := ioutil.WriteFile(osRelease, []byte("ID=ubuntu\nVERSION_CODENAME=xenial\nUBUNTU_CODENAME=xenial\n"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	s.PatchValue(manager.OSReleaseFile, osRelease)
+}
+
+func (s *AptSourcesSuite) TestAddRepositorySourcesPPA(c *gc.C) {
+	err := manager.NewAptPackageManager().AddRepositorySources("ppa:juju/stable")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.requests, jc.DeepEquals, []string{"/~juju/+archive/ubuntu/stable", "/pks/lookup"})
+
+	keyring := filepath.Join(s.keysDir, "juju-ubuntu-stable.gpg")
+	c.Assert(keyring, jc.IsNonEmptyFile)
+	contents, err := ioutil.ReadFile(filepath.Join(s.sourcesDir, "juju-ubuntu-stable.list"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(contents), gc.Equals, fmt.Sprintf(`# juju-ubuntu-stable (added by Juju)
+deb [signed-by=%s] https://ppa.launchpadcontent.net/juju/stable/ubuntu xenial main
+`, keyring))
+}
+
+func (s *AptSourcesSuite) TestAddRepositorySourcesUnknownPPA(c *gc.C) {
+	err := manager.NewAptPackageManager().AddRepositorySources("ppa:juju/missing")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(err, gc.ErrorMatches, `cannot add ppa:juju/missing: PPA "ppa:juju/missing" not found`)
+	c.Assert(filepath.Join(s.sourcesDir, "juju-ubuntu-missing.list"), jc.DoesNotExist)
+}
+
+func (s *AptSourcesSuite) TestAddRepositorySourcesPPAKeyMismatch(c *gc.C) {
+	// Launchpad reports another signing key than the one of the keyserver.
+	s.PatchValue(&s.fingerprint, strings.Repeat("0123", 10))
+	err := manager.NewAptPackageManager().AddRepositorySources("ppa:juju/stable")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, `cannot add ppa:juju/stable: cannot retrieve signing key of ppa:juju/stable: `+
+		`repository key with fingerprint [0-9A-F]{40} instead of 0123.* not valid`)
+	c.Assert(filepath.Join(s.keysDir, "juju-ubuntu-stable.gpg"), jc.DoesNotExist)
+	c.Assert(filepath.Join(s.sourcesDir, "juju-ubuntu-stable.list"), jc.DoesNotExist)
+}
+
+func (s *AptSourcesSuite) TestAddRepositorySourcesDeb(c *gc.C) {
+	err := manager.NewAptPackageManager().AddRepositorySources(
+		"deb [arch=amd64] https://download.docker.com/linux/ubuntu/ xenial stable")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.requests, gc.HasLen, 0)
+
+	contents, err := ioutil.ReadFile(filepath.Join(s.sourcesDir, "archive_uri-download.docker.com_linux_ubuntu-xenial.list"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(contents), gc.Equals, `# archive_uri-download.docker.com_linux_ubuntu-xenial (added by Juju)
+deb [arch=amd64] https://download.docker.com/linux/ubuntu xenial stable
+`)
+}
+
+func (s *AptSourcesSuite) TestAddRepositorySourcesNotValid(c *gc.C) {
+	err := manager.NewAptPackageManager().AddRepositorySources("https://example.com/juju.repo")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+
+	err = manager.NewAptPackageManager().AddRepositorySources("ppa:")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *AptSourcesSuite) TestRemoveRepositorySources(c *gc.C) {
+	apt := manager.NewAptPackageManager()
+	err := apt.AddRepositorySources("ppa:juju/stable")
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = apt.RemoveRepositorySources("ppa:juju/stable")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(filepath.Join(s.sourcesDir, "juju-ubuntu-stable.list"), jc.DoesNotExist)
+	c.Assert(filepath.Join(s.keysDir, "juju-ubuntu-stable.gpg"), jc.DoesNotExist)
+
+	err = apt.RemoveRepositorySources("ppa:juju/stable")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
@@ -0,0 +1,62 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils/proxy"
+)
+
+// brew is the PackageManager implementation for Homebrew on OS X.
+type brew struct {
+	basePackageManager
+}
+
+// Search is defined on the PackageManager interface.
+func (brew *brew) Search(pack string) (bool, error) {
+	_, code, err := brew.runCommand(brew.cmder.SearchCmd(pack), nil)
+
+	// brew info returns 1 when it cannot find the formula.
+	if code == 1 {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// ListInstalled is defined on the PackageManager interface.
+func (brew *brew) ListInstalled() ([]PackageInfo, error) {
+	out, _, err := brew.runCommand(brew.cmder.ListInstalledCmd(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// brew list --versions outputs "<name> <version>..." lines, listing
+	// all the installed versions of a formula, the latest one last.
+	var res []PackageInfo
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		res = append(res, PackageInfo{Name: fields[0], Version: fields[len(fields)-1]})
+	}
+
+	return res, nil
+}
+
+// GetProxySettings is defined on the PackageManager interface.
+// Homebrew has no proxy configuration of its own; it downloads through curl,
+// which uses the proxy settings found in the environment.
+func (brew *brew) GetProxySettings() (proxy.Settings, error) {
+	return DetectProxies(), nil
+}
+
+// SetProxy is defined on the PackageManager interface.
+func (brew *brew) SetProxy(proxy.Settings) error {
+	return errors.NotSupportedf("setting a proxy for Homebrew")
+}
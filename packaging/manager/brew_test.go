@@ -0,0 +1,70 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager_test
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils/packaging/manager"
+	"github.com/juju/utils/proxy"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&BrewSuite{})
+
+type BrewSuite struct {
+	testing.IsolationSuite
+	pacman manager.PackageManager
+}
+
+func (s *BrewSuite) SetUpSuite(c *gc.C) {
+	s.IsolationSuite.SetUpSuite(c)
+	s.pacman = manager.NewBrewPackageManager()
+}
+
+func (s *BrewSuite) TestNewPackageManager(c *gc.C) {
+	pacman, err := manager.NewPackageManager("elcapitan")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(pacman, gc.FitsTypeOf, s.pacman)
+}
+
+func (s *BrewSuite) TestAddRepository(c *gc.C) {
+	var calledCommand string
+	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&calledCommand))
+
+	err := s.pacman.AddRepository("homebrew/science")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(calledCommand, gc.Equals, "brew tap homebrew/science")
+}
+
+func (s *BrewSuite) TestSearchNotFound(c *gc.C) {
+	s.PatchValue(&manager.RunCommandWithRetry, func(string, func(string) error) (string, int, error) {
+		return "", 1, errors.New("packaging command failed: exit status 1")
+	})
+
+	found, err := s.pacman.Search(testedPackageName)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(found, jc.IsFalse)
+}
+
+func (s *BrewSuite) TestGetProxySettings(c *gc.C) {
+	s.PatchValue(&manager.DetectProxies, func() proxy.Settings {
+		return testedProxySettings
+	})
+
+	settings, err := s.pacman.GetProxySettings()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(settings, gc.Equals, testedProxySettings)
+}
+
+func (s *BrewSuite) TestSetProxyNotSupported(c *gc.C) {
+	err := s.pacman.SetProxy(testedProxySettings)
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *BrewSuite) TestFetchNotSupported(c *gc.C) {
+	err := s.pacman.Fetch(c.MkDir(), testedPackageName)
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
@@ -0,0 +1,273 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils/proxy"
+)
+
+const (
+	// chocoExitRebootInitiated is the exit code Chocolatey passes on from
+	// installers which successfully completed and initiated a reboot.
+	chocoExitRebootInitiated = 1641
+
+	// chocoExitRebootRequired is the exit code Chocolatey passes on from
+	// installers which successfully completed but require a reboot.
+	chocoExitRebootRequired = 3010
+)
+
+// powershellCommand wraps the given command in a non-interactive PowerShell
+// invocation which exits with the exit code of the command itself, rather
+// than PowerShell's own success indicator.
+func powershellCommand(cmd string) string {
+	return fmt.Sprintf("powershell.exe -NoProfile -NonInteractive -ExecutionPolicy Bypass -Command %s; exit $LASTEXITCODE", cmd)
+}
+
+// choco is the PackageManager implementation for Chocolatey on Windows.
+type choco struct {
+	basePackageManager
+}
+
+// run executes the given Chocolatey command through PowerShell, treating
+// the exit codes which signal a pending reboot as success.
+func (choco *choco) run(cmd string) (string, error) {
+	return choco.runContext(context.Background(), cmd)
+}
+
+// runContext is like run, but aborts the command
+// when the given context is canceled.
+func (choco *choco) runContext(ctx context.Context, cmd string) (string, error) {
+	out, code, err := choco.runCommandContext(ctx, powershellCommand(cmd), nil)
+	if code == chocoExitRebootInitiated || code == chocoExitRebootRequired {
+		logger.Warningf("a reboot is required to complete: %s", cmd)
+		return out, nil
+	}
+	return out, err
+}
+
+// Update is defined on the PackageManager interface.
+func (choco *choco) Update() error {
+	return choco.UpdateContext(context.Background())
+}
+
+// UpdateIfOlderThan is defined on the PackageManager interface.
+func (choco *choco) UpdateIfOlderThan(time.Duration) error {
+	return nil
+}
+
+// UpdateContext is defined on the PackageManager interface.
+func (choco *choco) UpdateContext(context.Context) error {
+	// Chocolatey always queries its sources; there is no local list.
+	return nil
+}
+
+// Upgrade is defined on the PackageManager interface.
+func (choco *choco) Upgrade() error {
+	return choco.UpgradeContext(context.Background())
+}
+
+// UpgradeContext is defined on the PackageManager interface.
+func (choco *choco) UpgradeContext(ctx context.Context) error {
+	_, err := choco.runContext(ctx, choco.cmder.UpgradeCmd())
+	return err
+}
+
+// UpgradeOnly is defined on the PackageManager interface.
+func (choco *choco) UpgradeOnly(packs ...string) error {
+	cmd, err := upgradeOnlyCmd(choco.cmder, packs)
+	if err != nil {
+		return err
+	}
+
+	_, err = choco.run(cmd)
+	return err
+}
+
+// Install is defined on the PackageManager interface.
+func (choco *choco) Install(packs ...string) error {
+	return choco.InstallContext(context.Background(), packs...)
+}
+
+// InstallContext is defined on the PackageManager interface.
+func (choco *choco) InstallContext(ctx context.Context, packs ...string) error {
+	_, err := choco.runContext(ctx, choco.cmder.InstallCmd(packs...))
+	return err
+}
+
+// InstallVersion is defined on the PackageManager interface.
+func (choco *choco) InstallVersion(pack, version string) error {
+	cmd, err := installVersionCmd(choco.cmder, pack, version)
+	if err != nil {
+		return err
+	}
+
+	out, err := choco.run(cmd)
+	return versionNotAvailableError(pack, version, out, err)
+}
+
+// Downgrade is defined on the PackageManager interface.
+func (choco *choco) Downgrade(pack, version string) error {
+	cmd, err := downgradeCmd(choco.cmder, pack, version)
+	if err != nil {
+		return err
+	}
+
+	out, err := choco.run(cmd)
+	return versionNotAvailableError(pack, version, out, err)
+}
+
+// Remove is defined on the PackageManager interface.
+func (choco *choco) Remove(packs ...string) error {
+	return choco.RemoveContext(context.Background(), packs...)
+}
+
+// RemoveContext is defined on the PackageManager interface.
+func (choco *choco) RemoveContext(ctx context.Context, packs ...string) error {
+	_, err := choco.runContext(ctx, choco.cmder.RemoveCmd(packs...))
+	return err
+}
+
+// Purge is defined on the PackageManager interface.
+func (choco *choco) Purge(packs ...string) error {
+	return choco.PurgeContext(context.Background(), packs...)
+}
+
+// PurgeContext is defined on the PackageManager interface.
+func (choco *choco) PurgeContext(ctx context.Context, packs ...string) error {
+	_, err := choco.runContext(ctx, choco.cmder.PurgeCmd(packs...))
+	return err
+}
+
+// Search is defined on the PackageManager interface.
+func (choco *choco) Search(pack string) (bool, error) {
+	out, err := choco.run(choco.cmder.SearchCmd(pack))
+	if err != nil {
+		return false, err
+	}
+
+	// choco search succeeds with no output when it cannot find the package.
+	return strings.TrimSpace(out) != "", nil
+}
+
+// IsInstalled is defined on the PackageManager interface.
+func (choco *choco) IsInstalled(pack string) bool {
+	out, err := choco.run(choco.cmder.IsInstalledCmd(pack))
+	return err == nil && strings.TrimSpace(out) != ""
+}
+
+// ListInstalled is defined on the PackageManager interface.
+func (choco *choco) ListInstalled() ([]PackageInfo, error) {
+	out, err := choco.run(choco.cmder.ListInstalledCmd())
+	if err != nil {
+		return nil, err
+	}
+
+	// choco list --limit-output outputs "<name>|<version>" lines.
+	var res []PackageInfo
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		if len(fields) != 2 {
+			continue
+		}
+		res = append(res, PackageInfo{Name: fields[0], Version: fields[1]})
+	}
+
+	return res, nil
+}
+
+// AddRepository is defined on the PackageManager interface.
+// The repository is expected to be given as the arguments of
+// "choco source add", such as "--name=internal --source=https://...".
+func (choco *choco) AddRepository(repo string) error {
+	_, err := choco.run(choco.cmder.AddRepositoryCmd(repo))
+	return err
+}
+
+// RemoveRepository is defined on the PackageManager interface.
+// The repository is given by its name.
+func (choco *choco) RemoveRepository(repo string) error {
+	_, err := choco.run(choco.cmder.RemoveRepositoryCmd(repo))
+	return err
+}
+
+// Cleanup is defined on the PackageManager interface.
+func (choco *choco) Cleanup() error {
+	// Chocolatey does not keep a package cache to be cleaned up.
+	return nil
+}
+
+// GetProxySettings is defined on the PackageManager interface.
+func (choco *choco) GetProxySettings() (proxy.Settings, error) {
+	out, err := choco.run(choco.cmder.GetProxyCmd())
+	if err != nil {
+		return proxy.Settings{}, err
+	}
+
+	// Chocolatey uses the same proxy for all protocols.
+	value := strings.TrimSpace(out)
+	return proxy.Settings{
+		Http:  value,
+		Https: value,
+	}, nil
+}
+
+// SetProxy is defined on the PackageManager interface.
+// Chocolatey supports a single proxy for all protocols, for which the
+// https proxy is preferred, falling back to the http one. As Chocolatey
+// does not take the credentials of proxies from their URL, they are set
+// separately.
+func (choco *choco) SetProxy(settings proxy.Settings) error {
+	value := settings.Https
+	if value == "" {
+		value = settings.Http
+	}
+	if value == "" {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		// proxies may be given without a scheme.
+		u, err = url.Parse("http://" + value)
+	}
+	if err != nil {
+		return errors.NotValidf("proxy URL")
+	}
+	config := [][2]string{{"proxy", value}}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		user := u.User.Username()
+		u.User = nil
+		config = [][2]string{
+			{"proxy", u.String()},
+			{"proxyUser", user},
+			{"proxyPassword", password},
+		}
+	}
+	if settings.NoProxy != "" {
+		config = append(config, [2]string{"proxyBypassList", settings.NoProxy})
+	}
+
+	for _, setting := range config {
+		cmd := fmt.Sprintf("choco config set --name=%s --value=%s", setting[0], powershellQuote(setting[1]))
+		if _, err := choco.run(cmd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// powershellQuote quotes the given value as a verbatim PowerShell string.
+func powershellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
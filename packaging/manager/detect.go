@@ -0,0 +1,113 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+var (
+	// osReleaseFile is the file which identifies the running Linux
+	// distribution.
+	osReleaseFile = "/etc/os-release"
+
+	// hostGOOS is the operating system Detect assumes to be running.
+	hostGOOS = runtime.GOOS
+
+	// lookPath is used by Detect for finding package management binaries.
+	lookPath = exec.LookPath
+)
+
+// distroManagers maps the IDs of Linux distributions, as reported in the
+// ID and ID_LIKE fields of os-release, to their package managers.
+var distroManagers = map[string]func() PackageManager{
+	"ubuntu":              func() PackageManager { return NewAptPackageManager() },
+	"debian":              func() PackageManager { return NewAptPackageManager() },
+	"centos":              NewYumPackageManager,
+	"rhel":                NewYumPackageManager,
+	"fedora":              NewYumPackageManager,
+	"opensuse":            NewZypperPackageManager,
+	"opensuse-leap":       NewZypperPackageManager,
+	"opensuse-tumbleweed": NewZypperPackageManager,
+	"suse":                NewZypperPackageManager,
+	"sles":                NewZypperPackageManager,
+	"arch":                NewPacmanPackageManager,
+	"alpine":              NewApkPackageManager,
+	"nixos":               func() PackageManager { return NewNixPackageManager() },
+}
+
+// binaryManagers lists the package management binaries Detect looks
+// for, in order, when the distribution cannot be identified.
+var binaryManagers = []struct {
+	binary  string
+	manager func() PackageManager
+}{
+	{"apt-get", func() PackageManager { return NewAptPackageManager() }},
+	// dnf provides a yum-compatible command line.
+	{"dnf", NewYumPackageManager},
+	{"yum", NewYumPackageManager},
+	{"zypper", NewZypperPackageManager},
+	{"apk", NewApkPackageManager},
+	{"pacman", NewPacmanPackageManager},
+	{"nix-env", func() PackageManager { return NewNixPackageManager() }},
+}
+
+// Detect returns the appropriate PackageManager implementation for the
+// running system. Linux distributions are identified by the ID and ID_LIKE
+// fields of their os-release file, falling back on the package management
+// binaries found in the PATH if that fails. The returned error satisfies
+// errors.IsNotFound if no supported package manager could be found.
+func Detect() (PackageManager, error) {
+	switch hostGOOS {
+	case "windows":
+		return NewChocoPackageManager(), nil
+	case "darwin":
+		return NewBrewPackageManager(), nil
+	}
+
+	// a missing or unreadable os-release is not fatal,
+	// as the binaries may still be found.
+	if values, err := readOSRelease(osReleaseFile); err == nil {
+		ids := append([]string{values["ID"]}, strings.Fields(values["ID_LIKE"])...)
+		for _, id := range ids {
+			if newManager, ok := distroManagers[id]; ok {
+				return newManager(), nil
+			}
+		}
+	}
+
+	for _, candidate := range binaryManagers {
+		if _, err := lookPath(candidate.binary); err == nil {
+			return candidate.manager(), nil
+		}
+	}
+	return nil, errors.NotFoundf("package manager for this system")
+}
+
+// readOSRelease returns the values set in the given os-release file.
+func readOSRelease(path string) (map[string]string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	values := make(map[string]string)
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		values[parts[0]] = strings.ToLower(strings.Trim(parts[1], "\t '\""))
+	}
+	return values, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/packaging/manager"
+)
+
+var _ = gc.Suite(&DetectSuite{})
+
+type DetectSuite struct {
+	testing.IsolationSuite
+	binaries []string
+}
+
+func (s *DetectSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.binaries = nil
+	s.PatchValue(manager.HostGOOS, "linux")
+	s.PatchValue(manager.OSReleaseFile, filepath.Join(c.MkDir(), "os-release"))
+	s.PatchValue(manager.LookPath, func(file string) (string, error) {
+		for _, binary := range s.binaries {
+			if binary == file {
+				return "/usr/bin/" + file, nil
+			}
+		}
+		return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+	})
+}
+
+func (s *DetectSuite) writeOSRelease(c *gc.C, contents string) {
+	err := ioutil.WriteFile(*manager.OSReleaseFile, []byte(contents), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *DetectSuite) assertDetected(c *gc.C, expected string) {
+	pm, err := manager.Detect()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(fmt.Sprintf("%T", pm), gc.Equals, "*manager."+expected)
+}
+
+func (s *DetectSuite) TestDetectOSRelease(c *gc.C) {
+	for i, test := range []struct {
+		osRelease string
+		expected  string
+	}{{
+		osRelease: "NAME=\"Ubuntu\"\nID=ubuntu\nID_LIKE=debian\nVERSION_ID=\"16.04\"\n",
+		expected:  "apt",
+	}, {
+		osRelease: "NAME=\"CentOS Linux\"\nID=\"centos\"\nID_LIKE=\"rhel fedora\"\n",
+		expected:  "yum",
+	}, {
+		osRelease: "ID=opensuse-leap\nID_LIKE=\"suse opensuse\"\n",
+		expected:  "zypper",
+	}, {
+		osRelease: "ID=arch\n",
+		expected:  "pacman",
+	}, {
+		osRelease: "ID=alpine\n",
+		expected:  "apk",
+	}, {
+		osRelease: "ID=nixos\n",
+		expected:  "nix",
+	}, {
+		// derivatives are identified by ID_LIKE.
+		osRelease: "ID=linuxmint\nID_LIKE=\"ubuntu debian\"\n",
+		expected:  "apt",
+	}, {
+		osRelease: "ID=\"Rocky\"\nID_LIKE=\"RHEL centos fedora\"\n",
+		expected:  "yum",
+	}} {
+		c.Logf("test %d: %q", i, test.osRelease)
+		s.writeOSRelease(c, test.osRelease)
+		s.assertDetected(c, test.expected)
+	}
+}
+
+func (s *DetectSuite) TestDetectBinaries(c *gc.C) {
+	s.writeOSRelease(c, "ID=unknown\n")
+	for i, test := range []struct {
+		binaries []string
+		expected string
+	}{{
+		binaries: []string{"apt-get"},
+		expected: "apt",
+	}, {
+		binaries: []string{"dnf"},
+		expected: "yum",
+	}, {
+		binaries: []string{"zypper"},
+		expected: "zypper",
+	}, {
+		binaries: []string{"pacman", "apk"},
+		expected: "apk",
+	}} {
+		c.Logf("test %d: %v", i, test.binaries)
+		s.binaries = test.binaries
+		s.assertDetected(c, test.expected)
+	}
+}
+
+func (s *DetectSuite) TestDetectWithoutOSRelease(c *gc.C) {
+	s.binaries = []string{"yum"}
+	s.assertDetected(c, "yum")
+}
+
+func (s *DetectSuite) TestDetectNotFound(c *gc.C) {
+	_, err := manager.Detect()
+	c.Assert(err, gc.ErrorMatches, "package manager for this system not found")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *DetectSuite) TestDetectOtherOS(c *gc.C) {
+	s.PatchValue(manager.HostGOOS, "windows")
+	s.assertDetected(c, "choco")
+
+	s.PatchValue(manager.HostGOOS, "darwin")
+	s.assertDetected(c, "brew")
+}
@@ -0,0 +1,156 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// VersionNotAvailableError is returned when the requested version of a
+// package cannot be found in the currently configured repositories.
+type VersionNotAvailableError struct {
+	Package string
+	Version string
+}
+
+// Error implements error.
+func (e *VersionNotAvailableError) Error() string {
+	return fmt.Sprintf("version %q of package %q is not available", e.Version, e.Package)
+}
+
+// IsVersionNotAvailable returns whether the given error, or its cause,
+// is a *VersionNotAvailableError.
+func IsVersionNotAvailable(err error) bool {
+	_, ok := errors.Cause(err).(*VersionNotAvailableError)
+	return ok
+}
+
+// ErrorKind classifies the failure of a packaging command.
+type ErrorKind string
+
+const (
+	// ErrorLockHeld signals that another process holds the lock
+	// of the package management system.
+	ErrorLockHeld ErrorKind = "lock-held"
+
+	// ErrorNetwork signals that the repositories could not be reached.
+	ErrorNetwork ErrorKind = "network-failure"
+
+	// ErrorUnknownPackage signals that a package could not be found in
+	// the currently configured repositories.
+	ErrorUnknownPackage ErrorKind = "unknown-package"
+
+	// ErrorHashSumMismatch signals that downloaded repository metadata or
+	// packages did not match their expected checksums, which is usually
+	// caused by a mirror being updated mid-operation.
+	ErrorHashSumMismatch ErrorKind = "hash-sum-mismatch"
+
+	// ErrorCanceled signals that the command was aborted because
+	// its context was canceled or its deadline was exceeded.
+	ErrorCanceled ErrorKind = "canceled"
+
+	// ErrorFatal signals any other failure.
+	ErrorFatal ErrorKind = "fatal"
+)
+
+// errorKindMessages maps the kinds of failures to the messages which the
+// supported package management systems output upon encountering them.
+var errorKindMessages = []struct {
+	kind     ErrorKind
+	messages []string
+}{{
+	ErrorLockHeld, []string{
+		"Could not get lock",                // apt-get
+		"Unable to lock the administration", // apt-get
+		"holding the yum lock",              // yum
+		"System management is locked",       // zypper
+		"unable to lock database",           // pacman, apk
+	},
+}, {
+	ErrorHashSumMismatch, []string{
+		"Hash Sum mismatch",          // apt-get
+		"does not match checksum",    // yum
+		"Digest verification failed", // zypper
+		"BAD signature",              // apk
+	},
+}, {
+	ErrorNetwork, []string{
+		"Temporary failure resolving",         // apt-get
+		"Could not resolve",                   // apt-get, yum, zypper
+		"Failed to fetch",                     // apt-get
+		"Cannot find a valid baseurl",         // yum
+		"Cannot retrieve repository metadata", // yum
+		"Download (curl) error",               // zypper
+		"failed retrieving file",              // pacman
+		"temporary error (try again later)",   // apk
+		"Network is unreachable",              // all
+		"Connection timed out",                // all
+	},
+}, {
+	ErrorUnknownPackage, []string{
+		"Unable to locate package",      // apt-get
+		"has no installation candidate", // apt-get
+		"No package",                    // yum
+		"No provider of",                // zypper
+		"target not found",              // pacman
+		"unsatisfiable constraints",     // apk
+		"No available formula",          // brew
+	},
+}}
+
+// classifyOutput returns the kind of failure the given output of a failed
+// packaging command reports.
+func classifyOutput(output string) ErrorKind {
+	for _, kind := range errorKindMessages {
+		for _, msg := range kind.messages {
+			if strings.Contains(output, msg) {
+				return kind.kind
+			}
+		}
+	}
+	return ErrorFatal
+}
+
+// Error is the error returned by RunCommandWithRetry when a packaging
+// command fails. Use errors.Cause to retrieve it from annotated errors.
+type Error struct {
+	// Command is the packaging command which failed.
+	Command string
+
+	// ExitCode is the exit code of the command.
+	ExitCode int
+
+	// Output is the combined standard output and error of the command.
+	Output string
+
+	// Kind is the classification of the failure.
+	Kind ErrorKind
+
+	// LockHolder is the process which held the lock of the package
+	// management system, if known, for failures of kind ErrorLockHeld.
+	LockHolder *LockHolder
+
+	// err is the underlying error.
+	err error
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	if e.LockHolder != nil {
+		return fmt.Sprintf("packaging command failed: %v: lock held by %v", e.err, e.LockHolder)
+	}
+	return fmt.Sprintf("packaging command failed: %v", e.err)
+}
+
+// ErrorKindOf returns the kind of the given error if its cause is an *Error,
+// and an empty ErrorKind otherwise.
+func ErrorKindOf(err error) ErrorKind {
+	if e, ok := errors.Cause(err).(*Error); ok {
+		return e.Kind
+	}
+	return ""
+}
@@ -0,0 +1,31 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+var (
+	PacmanConfigFile    = &pacmanConfigFile
+	ApkRepositoriesFile = &apkRepositoriesFile
+	ApkProxyConfigFile  = &apkProxyConfigFile
+	AptKeyringsDir      = &aptKeyringsDir
+	AptHistoryFile      = &aptHistoryFile
+	YumKeyfileDir       = &yumKeyfileDir
+	OutputContext       = outputContext
+	LockPIDFiles        = &lockPIDFiles
+	ProcDir             = &procDir
+	AptSourcesDir       = &aptSourcesDir
+	AptCacheStamps      = &aptCacheStamps
+	AptProxyConfigFile  = &aptProxyConfigFile
+	ZypperProxyConfig   = &zypperProxyConfigFile
+	YumProxyConfigFiles = &yumProxyConfigFiles
+	YumCacheStamps      = &yumCacheStamps
+	LaunchpadAPI        = &launchpadAPI
+	PPAKeyserver        = &ppaKeyserver
+	KeyLocation         = RepositoryKey.location
+)
+
+var (
+	OSReleaseFile = &osReleaseFile
+	HostGOOS      = &hostGOOS
+	LookPath      = &lookPath
+)
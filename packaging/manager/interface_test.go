@@ -10,3 +10,6 @@ import (
 
 var _ manager.PackageManager = manager.NewAptPackageManager()
 var _ manager.PackageManager = manager.NewYumPackageManager()
+var _ manager.PackageManager = manager.NewSnapPackageManager()
+var _ manager.PackageManager = manager.NewChocoPackageManager()
+var _ manager.PackageManager = manager.NewNixPackageManager()
@@ -0,0 +1,179 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/juju/errors"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/juju/utils"
+)
+
+// DefaultKeyserver is the keyserver repository keys given by their
+// fingerprint are retrieved from if no other keyserver is specified.
+const DefaultKeyserver = "https://keyserver.ubuntu.com"
+
+// maxRepositoryKeySize is the size beyond which retrieved
+// repository keys are rejected.
+const maxRepositoryKeySize = 1 << 20
+
+// fingerprintRE matches the normalized fingerprints of OpenPGP keys.
+var fingerprintRE = regexp.MustCompile(`^[0-9A-F]{40}$`)
+
+// RepositoryKey describes the key which signs a package repository.
+// Exactly one of Armored, URL or Fingerprint must be set.
+type RepositoryKey struct {
+	// Armored is the ASCII-armored public key.
+	Armored string
+
+	// URL is the location the ASCII-armored public key is retrieved from.
+	URL string
+
+	// Fingerprint is the full fingerprint of the public key, which
+	// is retrieved from the keyserver. The key is rejected unless it
+	// has this fingerprint. Spaces and a leading "0x" are ignored.
+	Fingerprint string
+
+	// Keyserver is the keyserver the key given by its fingerprint is
+	// retrieved from. It defaults to DefaultKeyserver. Keyservers
+	// given with the hkp and hkps schemes are both queried over HTTPS.
+	Keyserver string
+}
+
+// Validate returns an error if the RepositoryKey is not valid.
+func (key RepositoryKey) Validate() error {
+	set := 0
+	for _, field := range []string{key.Armored, key.URL, key.Fingerprint} {
+		if field != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.NotValidf("repository key with %d of armored key, URL and fingerprint set", set)
+	}
+	if key.Keyserver != "" && key.Fingerprint == "" {
+		return errors.NotValidf("keyserver without fingerprint")
+	}
+	if key.Fingerprint != "" && !fingerprintRE.MatchString(normalizeFingerprint(key.Fingerprint)) {
+		return errors.NotValidf("fingerprint %q", key.Fingerprint)
+	}
+	return nil
+}
+
+// normalizeFingerprint returns the given fingerprint in upper case,
+// without spaces nor leading "0x".
+func normalizeFingerprint(fingerprint string) string {
+	fingerprint = strings.ToUpper(strings.Replace(fingerprint, " ", "", -1))
+	return strings.TrimPrefix(fingerprint, "0X")
+}
+
+// location returns the location the key is retrieved from.
+func (key RepositoryKey) location() string {
+	if key.Fingerprint == "" {
+		return key.URL
+	}
+	keyserver := key.Keyserver
+	if keyserver == "" {
+		keyserver = DefaultKeyserver
+	}
+	// HKP is HTTP on a well-known port, which keyservers also serve
+	// with TLS on the standard HTTPS port; the latter is always used.
+	keyserver = strings.Replace(keyserver, "hkp://", "https://", 1)
+	keyserver = strings.Replace(keyserver, "hkps://", "https://", 1)
+	return fmt.Sprintf("%s/pks/lookup?op=get&options=mr&search=%s",
+		strings.TrimRight(keyserver, "/"), url.QueryEscape("0x"+normalizeFingerprint(key.Fingerprint)))
+}
+
+// armored returns the ASCII-armored public key, retrieving it if necessary.
+func (key RepositoryKey) armored() (string, error) {
+	if key.Armored != "" {
+		return key.Armored, nil
+	}
+
+	location := key.location()
+	resp, err := utils.GetValidatingHTTPClient().Get(location)
+	if err != nil {
+		return "", errors.Annotatef(err, "cannot retrieve repository key from %q", location)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("cannot retrieve repository key from %q: %s", location, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRepositoryKeySize+1))
+	if err != nil {
+		return "", errors.Annotatef(err, "cannot retrieve repository key from %q", location)
+	}
+	if len(data) > maxRepositoryKeySize {
+		return "", errors.Errorf("cannot retrieve repository key from %q: key too large", location)
+	}
+	return string(data), nil
+}
+
+// readRepositoryKey validates the given key, retrieving it if necessary,
+// and returns its ASCII-armored form along with the entities it holds.
+// The key given by its fingerprint is rejected with an error satisfying
+// errors.IsNotValid unless all its entities have this fingerprint.
+func readRepositoryKey(key RepositoryKey) (string, openpgp.EntityList, error) {
+	if err := key.Validate(); err != nil {
+		return "", nil, errors.Trace(err)
+	}
+
+	armored, err := key.armored()
+	if err != nil {
+		return "", nil, errors.Trace(err)
+	}
+
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armored))
+	if err != nil {
+		return "", nil, errors.NewNotValid(err, "invalid repository key")
+	}
+	if key.Fingerprint != "" {
+		if err := checkFingerprint(entities, key.Fingerprint); err != nil {
+			return "", nil, errors.Trace(err)
+		}
+	}
+	return armored, entities, nil
+}
+
+// checkFingerprint returns an error satisfying errors.IsNotValid
+// unless all the given entities have the given fingerprint.
+func checkFingerprint(entities openpgp.EntityList, fingerprint string) error {
+	want := normalizeFingerprint(fingerprint)
+	for _, entity := range entities {
+		if got := fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint[:]); got != want {
+			return errors.NotValidf("repository key with fingerprint %s instead of %s", got, want)
+		}
+	}
+	return nil
+}
+
+// validateKeyName returns an error if the given name of a repository key
+// cannot be used as part of a file name.
+func validateKeyName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\ `) || name == "." || name == ".." {
+		return errors.NotValidf("repository key name %q", name)
+	}
+	return nil
+}
+
+// serializeKeyRing returns the binary keyring holding the given entities.
+func serializeKeyRing(entities openpgp.EntityList) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, entity := range entities {
+		if err := entity.Serialize(&buf); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/packaging/manager"
+)
+
+var _ = gc.Suite(&KeysSuite{})
+
+type KeysSuite struct {
+	testing.IsolationSuite
+	entity  *openpgp.Entity
+	armored string
+}
+
+func (s *KeysSuite) SetUpSuite(c *gc.C) {
+	s.IsolationSuite.SetUpSuite(c)
+
+	entity, err := openpgp.NewEntity("Test Repository", "", "repo@example.com", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(entity.Serialize(w), jc.ErrorIsNil)
+	c.Assert(w.Close(), jc.ErrorIsNil)
+
+	s.entity = entity
+	s.armored = buf.String()
+}
+
+func (s *KeysSuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		key manager.RepositoryKey
+		err string
+	}{{
+		key: manager.RepositoryKey{Armored: "key"},
+	}, {
+		key: manager.RepositoryKey{URL: "https://example.com/key.asc"},
+	}, {
+		key: manager.RepositoryKey{Fingerprint: "0x6A15 7DB3 6D8B 5ECB 3BE6 7BCC CBF2 BA2E 3C41 6B41", Keyserver: "hkp://keyserver.example.com"},
+	}, {
+		key: manager.RepositoryKey{Fingerprint: "ABCD"},
+		err: `fingerprint "ABCD" not valid`,
+	}, {
+		key: manager.RepositoryKey{},
+		err: "repository key with 0 of armored key, URL and fingerprint set not valid",
+	}, {
+		key: manager.RepositoryKey{Armored: "key", URL: "https://example.com/key.asc"},
+		err: "repository key with 2 of armored key, URL and fingerprint set not valid",
+	}, {
+		key: manager.RepositoryKey{URL: "https://example.com/key.asc", Keyserver: "hkp://keyserver.example.com"},
+		err: "keyserver without fingerprint not valid",
+	}} {
+		c.Logf("test %d: %+v", i, test.key)
+		err := test.key.Validate()
+		if test.err == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, gc.ErrorMatches, test.err)
+		}
+	}
+}
+
+func (s *KeysSuite) TestAptAddRepositoryKey(c *gc.C) {
+	dir := c.MkDir()
+	s.PatchValue(manager.AptKeyringsDir, dir)
+
+	err := manager.NewAptPackageManager().AddRepositoryKey("juju", manager.RepositoryKey{Armored: s.armored})
+	c.Assert(err, jc.ErrorIsNil)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "juju.gpg"))
+	c.Assert(err, jc.ErrorIsNil)
+	entities, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(entities, gc.HasLen, 1)
+	c.Assert(entities[0].PrimaryKey.KeyId, gc.Equals, s.entity.PrimaryKey.KeyId)
+
+	err = manager.NewAptPackageManager().RemoveRepositoryKey("juju")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(filepath.Join(dir, "juju.gpg"), jc.DoesNotExist)
+
+	err = manager.NewAptPackageManager().RemoveRepositoryKey("juju")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *KeysSuite) TestAddRepositoryKeyFromKeyserver(c *gc.C) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query = req.URL.RawQuery
+		fmt.Fprint(w, s.armored)
+	}))
+	defer server.Close()
+	dir := c.MkDir()
+	s.PatchValue(manager.AptKeyringsDir, dir)
+
+	fingerprint := fmt.Sprintf("%X", s.entity.PrimaryKey.Fingerprint[:])
+	err := manager.NewAptPackageManager().AddRepositoryKey("juju", manager.RepositoryKey{
+		Fingerprint: strings.ToLower(fingerprint),
+		Keyserver:   server.URL,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(query, gc.Equals, "op=get&options=mr&search=0x"+fingerprint)
+	c.Assert(filepath.Join(dir, "juju.gpg"), jc.IsNonEmptyFile)
+}
+
+func (s *KeysSuite) TestAddRepositoryKeyFromKeyserverFingerprintMismatch(c *gc.C) {
+	other, err := openpgp.NewEntity("Other Repository", "", "other@example.com", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// the keyserver answers with another key than the one requested.
+		fmt.Fprint(w, s.armored)
+	}))
+	defer server.Close()
+	dir := c.MkDir()
+	s.PatchValue(manager.AptKeyringsDir, dir)
+
+	err = manager.NewAptPackageManager().AddRepositoryKey("juju", manager.RepositoryKey{
+		Fingerprint: fmt.Sprintf("%X", other.PrimaryKey.Fingerprint[:]),
+		Keyserver:   server.URL,
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, "repository key with fingerprint [0-9A-F]{40} instead of [0-9A-F]{40} not valid")
+	c.Assert(filepath.Join(dir, "juju.gpg"), jc.DoesNotExist)
+}
+
+func (s *KeysSuite) TestAddRepositoryKeyTooLarge(c *gc.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write(bytes.Repeat([]byte("x"), 2<<20))
+	}))
+	defer server.Close()
+	s.PatchValue(manager.AptKeyringsDir, c.MkDir())
+
+	err := manager.NewAptPackageManager().AddRepositoryKey("juju", manager.RepositoryKey{URL: server.URL + "/key.asc"})
+	c.Assert(err, gc.ErrorMatches, `cannot retrieve repository key from ".*/key.asc": key too large`)
+}
+
+func (s *KeysSuite) TestKeyserverLocation(c *gc.C) {
+	for i, keyserver := range []string{
+		"", "hkp://keyserver.ubuntu.com", "hkps://keyserver.ubuntu.com/", "https://keyserver.ubuntu.com",
+	} {
+		c.Logf("test %d: %q", i, keyserver)
+		location := manager.KeyLocation(manager.RepositoryKey{
+			Fingerprint: "6a15 7db3 6d8b 5ecb 3be6 7bcc cbf2 ba2e 3c41 6b41",
+			Keyserver:   keyserver,
+		})
+		c.Check(location, gc.Equals, "https://keyserver.ubuntu.com/pks/lookup?op=get&options=mr&search=0x6A157DB36D8B5ECB3BE67BCCCBF2BA2E3C416B41")
+	}
+}
+
+func (s *KeysSuite) TestAddRepositoryKeyFromURLError(c *gc.C) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	s.PatchValue(manager.AptKeyringsDir, c.MkDir())
+
+	err := manager.NewAptPackageManager().AddRepositoryKey("juju", manager.RepositoryKey{URL: server.URL + "/key.asc"})
+	c.Assert(err, gc.ErrorMatches, `cannot retrieve repository key from ".*/key.asc": 404 Not Found`)
+}
+
+func (s *KeysSuite) TestAddRepositoryKeyInvalid(c *gc.C) {
+	s.PatchValue(manager.AptKeyringsDir, c.MkDir())
+
+	err := manager.NewAptPackageManager().AddRepositoryKey("juju", manager.RepositoryKey{Armored: "not a key"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+
+	err = manager.NewAptPackageManager().AddRepositoryKey("../juju", manager.RepositoryKey{Armored: s.armored})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *KeysSuite) TestYumRepositoryKey(c *gc.C) {
+	dir := c.MkDir()
+	s.PatchValue(manager.YumKeyfileDir, dir)
+	var calledCommand string
+	s.PatchValue(&manager.CommandOutput, getMockCommandOutput(&calledCommand))
+	path := filepath.Join(dir, "RPM-GPG-KEY-juju")
+
+	err := manager.NewYumPackageManager().AddRepositoryKey("juju", manager.RepositoryKey{Armored: s.armored})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(calledCommand, gc.Equals, "rpm --import "+path)
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, s.armored)
+
+	err = manager.NewYumPackageManager().RemoveRepositoryKey("juju")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(calledCommand, gc.Equals, fmt.Sprintf("rpm --erase --allmatches gpg-pubkey-%08x", uint32(s.entity.PrimaryKey.KeyId)))
+	c.Assert(path, jc.DoesNotExist)
+}
+
+func (s *KeysSuite) TestRepositoryKeyNotSupported(c *gc.C) {
+	err := manager.NewPacmanPackageManager().AddRepositoryKey("juju", manager.RepositoryKey{Armored: s.armored})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
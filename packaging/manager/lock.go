@@ -0,0 +1,115 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// lockPIDFiles are the files in which package management systems
+	// record the PID of the process holding their lock.
+	lockPIDFiles = []string{
+		"/var/run/yum.pid",  // yum
+		"/var/run/zypp.pid", // zypper
+	}
+
+	// procDir is where the information about running processes is found.
+	procDir = "/proc"
+)
+
+// lockHolderREs match the holders of the lock of the package management
+// system reported in the output of the commands which failed to take it.
+var lockHolderREs = []*regexp.Regexp{
+	// apt-get: Could not get lock /var/lib/dpkg/lock-frontend. It is held by process 1234 (unattended-upgr)
+	regexp.MustCompile(`held by process (?P<pid>\d+)(?: \((?P<name>[^)]+)\))?`),
+	// yum: The other application is: PackageKit ... State  : Sleeping, pid: 1234
+	regexp.MustCompile(`(?s)The other application is: (?P<name>\S+).*?pid: (?P<pid>\d+)`),
+	// zypper: System management is locked by the application with pid 1234 (/usr/bin/zypper).
+	regexp.MustCompile(`with pid (?P<pid>\d+)(?: \((?P<name>[^)]+)\))?`),
+}
+
+// LockHolder describes the process which holds the lock
+// of the package management system.
+type LockHolder struct {
+	// PID is the process ID of the holder.
+	PID int
+
+	// Name is the name of the holder's command, if known.
+	Name string
+}
+
+// String returns a description of the holder.
+func (h LockHolder) String() string {
+	if h.Name == "" {
+		return fmt.Sprintf("process %d", h.PID)
+	}
+	return fmt.Sprintf("process %d (%s)", h.PID, h.Name)
+}
+
+// findLockHolder returns the holder of the lock of the package management
+// system, as reported in the given output of a command which failed to take
+// it or else as recorded in the lock PID files. It returns nil if the holder
+// cannot be determined.
+func findLockHolder(output string) *LockHolder {
+	holder := lockHolderFromOutput(output)
+	if holder == nil {
+		holder = lockHolderFromPIDFiles()
+	}
+	if holder != nil && holder.Name == "" {
+		holder.Name = processName(holder.PID)
+	}
+	return holder
+}
+
+// lockHolderFromOutput returns the holder reported in the given output.
+func lockHolderFromOutput(output string) *LockHolder {
+	for _, re := range lockHolderREs {
+		match := re.FindStringSubmatch(output)
+		if match == nil {
+			continue
+		}
+		var holder LockHolder
+		for i, name := range re.SubexpNames() {
+			switch name {
+			case "pid":
+				holder.PID, _ = strconv.Atoi(match[i])
+			case "name":
+				holder.Name = match[i]
+			}
+		}
+		return &holder
+	}
+	return nil
+}
+
+// lockHolderFromPIDFiles returns the holder recorded in the
+// first of the lock PID files which exists.
+func lockHolderFromPIDFiles() *LockHolder {
+	for _, path := range lockPIDFiles {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid > 0 {
+			return &LockHolder{PID: pid}
+		}
+	}
+	return nil
+}
+
+// processName returns the name of the command of the given process,
+// or an empty string if it cannot be determined.
+func processName(pid int) string {
+	data, err := ioutil.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -5,9 +5,16 @@
 package manager
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/exec"
 	"strings"
+	"time"
 
+	"github.com/juju/errors"
+
+	"github.com/juju/utils"
 	"github.com/juju/utils/packaging/commands"
 	"github.com/juju/utils/proxy"
 )
@@ -15,78 +22,506 @@ import (
 // basePackageManager is the struct which executes various
 // packaging-related operations.
 type basePackageManager struct {
-	cmder commands.PackageCommander
+	cmder    commands.PackageCommander
+	progress ProgressFunc
+	output   OutputFunc
+	retry    *RetryStrategy
+
+	// env holds the "NAME=value" variables which are added to,
+	// or override those of, the environment of commands.
+	env []string
+
+	// autoRemoveOnCleanup signals whether Cleanup also runs AutoRemove.
+	autoRemoveOnCleanup bool
+
+	// noRecommends signals whether Install leaves out the packages
+	// which are only recommended by the installed ones.
+	noRecommends bool
+}
+
+// runCommand runs the given command, retrying it according to the
+// retry strategy of the package manager, if one was set.
+func (pm *basePackageManager) runCommand(cmd string, getFatalError func(string) error) (string, int, error) {
+	return pm.runCommandContext(context.Background(), cmd, getFatalError)
+}
+
+// runCommandContext is like runCommand, but aborts the command
+// when the given context is canceled.
+func (pm *basePackageManager) runCommandContext(ctx context.Context, cmd string, getFatalError func(string) error) (string, int, error) {
+	return pm.runCommandInDir(ctx, "", cmd, getFatalError)
+}
+
+// runCommandInDir is like runCommandContext, but runs the
+// command in the given working directory, if not empty.
+func (pm *basePackageManager) runCommandInDir(ctx context.Context, dir, cmd string, getFatalError func(string) error) (string, int, error) {
+	out, code, err := pm.execCommandContext(ctx, dir, cmd, getFatalError)
+	pm.reportOutput(cmd, out, err)
+	return out, code, err
+}
+
+// execCommandContext implements runCommandInDir, leaving
+// the reporting of the output of the command to it.
+func (pm *basePackageManager) execCommandContext(ctx context.Context, dir, cmd string, getFatalError func(string) error) (string, int, error) {
+	if ctx.Done() != nil {
+		return runCommandWithRetry(ctx, cmd, getFatalError, pm.inDir(dir, outputContext(ctx)), pm.retry)
+	}
+	if pm.retry == nil && len(pm.env) == 0 && dir == "" {
+		return RunCommandWithRetry(cmd, getFatalError)
+	}
+	return runCommandWithRetry(ctx, cmd, getFatalError, pm.inDir(dir, CommandOutput), pm.retry)
+}
+
+// inDir returns a function which runs commands with the given function,
+// in the given working directory, if not empty, and in the environment
+// set by SetEnvironment, if any.
+func (pm *basePackageManager) inDir(dir string, run func(*exec.Cmd) ([]byte, error)) func(*exec.Cmd) ([]byte, error) {
+	run = pm.withEnvironment(run)
+	if dir == "" {
+		return run
+	}
+	return func(cmd *exec.Cmd) ([]byte, error) {
+		cmd.Dir = dir
+		return run(cmd)
+	}
+}
+
+// withEnvironment returns a function which runs commands with the given
+// function, in the environment set by SetEnvironment, if any.
+func (pm *basePackageManager) withEnvironment(run func(*exec.Cmd) ([]byte, error)) func(*exec.Cmd) ([]byte, error) {
+	if len(pm.env) == 0 {
+		return run
+	}
+	return func(cmd *exec.Cmd) ([]byte, error) {
+		cmd.Env = utils.MergeEnvironment(os.Environ(), pm.env)
+		return run(cmd)
+	}
+}
+
+// runCommandOnce runs the command made of the given arguments once,
+// without retrying it, in the environment set by SetEnvironment, if any,
+// and reports its output like runCommand.
+func (pm *basePackageManager) runCommandOnce(args ...string) ([]byte, error) {
+	out, err := pm.withEnvironment(CommandOutput)(exec.Command(args[0], args[1:]...))
+	pm.reportOutput(strings.Join(args, " "), string(out), err)
+	return out, err
+}
+
+// reportOutput passes the output of the given command, which completed
+// with the given error, to the registered output callback, if any.
+func (pm *basePackageManager) reportOutput(cmd, output string, err error) {
+	if pm.output != nil {
+		pm.output(cmd, output, err)
+	}
 }
 
 // InstallPrerequisite is defined on the PackageManager interface.
 func (pm *basePackageManager) InstallPrerequisite() error {
-	_, _, err := RunCommandWithRetry(pm.cmder.InstallPrerequisiteCmd(), nil)
+	cmd := pm.cmder.InstallPrerequisiteCmd()
+	if cmd == "" {
+		// nothing needs installing for this package manager.
+		return nil
+	}
+	_, _, err := pm.runCommand(cmd, nil)
 	return err
 }
 
 // Update is defined on the PackageManager interface.
 func (pm *basePackageManager) Update() error {
-	_, _, err := RunCommandWithRetry(pm.cmder.UpdateCmd(), nil)
+	return pm.UpdateContext(context.Background())
+}
+
+// UpdateIfOlderThan is defined on the PackageManager interface.
+func (pm *basePackageManager) UpdateIfOlderThan(time.Duration) error {
+	// the age of the package lists is unknown.
+	return pm.Update()
+}
+
+// UpdateContext is defined on the PackageManager interface.
+func (pm *basePackageManager) UpdateContext(ctx context.Context) error {
+	_, _, err := pm.runCommandContext(ctx, pm.cmder.UpdateCmd(), nil)
 	return err
 }
 
 // Upgrade is defined on the PackageManager interface.
 func (pm *basePackageManager) Upgrade() error {
-	_, _, err := RunCommandWithRetry(pm.cmder.UpgradeCmd(), nil)
+	return pm.UpgradeContext(context.Background())
+}
+
+// UpgradeContext is defined on the PackageManager interface.
+func (pm *basePackageManager) UpgradeContext(ctx context.Context) error {
+	_, _, err := pm.runCommandContext(ctx, pm.cmder.UpgradeCmd(), nil)
+	return err
+}
+
+// UpgradeOnly is defined on the PackageManager interface.
+func (pm *basePackageManager) UpgradeOnly(packs ...string) error {
+	cmd, err := upgradeOnlyCmd(pm.cmder, packs)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = pm.runCommand(cmd, nil)
 	return err
 }
 
 // Install is defined on the PackageManager interface.
 func (pm *basePackageManager) Install(packs ...string) error {
-	_, _, err := RunCommandWithRetry(pm.cmder.InstallCmd(packs...), nil)
+	return pm.InstallContext(context.Background(), packs...)
+}
+
+// InstallContext is defined on the PackageManager interface.
+func (pm *basePackageManager) InstallContext(ctx context.Context, packs ...string) error {
+	_, _, err := pm.runCommandContext(ctx, pm.installCmd(packs...), nil)
+	return err
+}
+
+// installCmd returns the command which installs the given packages,
+// leaving out the recommended ones if SetInstallRecommends disabled them.
+func (pm *basePackageManager) installCmd(packs ...string) string {
+	if pm.noRecommends {
+		if cmd := pm.cmder.InstallNoRecommendsCmd(packs...); cmd != "" {
+			return cmd
+		}
+	}
+	return pm.cmder.InstallCmd(packs...)
+}
+
+// InstallVersion is defined on the PackageManager interface.
+func (pm *basePackageManager) InstallVersion(pack, version string) error {
+	cmd, err := installVersionCmd(pm.cmder, pack, version)
+	if err != nil {
+		return err
+	}
+
+	out, _, err := pm.runCommand(cmd, versionNotAvailableFatalError)
+	return versionNotAvailableError(pack, version, out, err)
+}
+
+// Downgrade is defined on the PackageManager interface.
+func (pm *basePackageManager) Downgrade(pack, version string) error {
+	cmd, err := downgradeCmd(pm.cmder, pack, version)
+	if err != nil {
+		return err
+	}
+
+	out, _, err := pm.runCommand(cmd, versionNotAvailableFatalError)
+	return versionNotAvailableError(pack, version, out, err)
+}
+
+// Rollback is defined on the PackageManager interface.
+func (pm *basePackageManager) Rollback(transaction string) error {
+	cmd, err := rollbackCmd(pm.cmder, transaction)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = pm.runCommand(cmd, nil)
+	return err
+}
+
+// InstallLocal is defined on the PackageManager interface.
+func (pm *basePackageManager) InstallLocal(paths ...string) error {
+	cmd, err := installLocalCmd(pm.cmder, paths)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = pm.runCommand(cmd, nil)
 	return err
 }
 
 // Remove is defined on the PackageManager interface.
 func (pm *basePackageManager) Remove(packs ...string) error {
-	_, _, err := RunCommandWithRetry(pm.cmder.RemoveCmd(packs...), nil)
+	return pm.RemoveContext(context.Background(), packs...)
+}
+
+// RemoveContext is defined on the PackageManager interface.
+func (pm *basePackageManager) RemoveContext(ctx context.Context, packs ...string) error {
+	_, _, err := pm.runCommandContext(ctx, pm.cmder.RemoveCmd(packs...), nil)
 	return err
 }
 
 // Purge is defined on the PackageManager interface.
 func (pm *basePackageManager) Purge(packs ...string) error {
-	_, _, err := RunCommandWithRetry(pm.cmder.PurgeCmd(packs...), nil)
+	return pm.PurgeContext(context.Background(), packs...)
+}
+
+// PurgeContext is defined on the PackageManager interface.
+func (pm *basePackageManager) PurgeContext(ctx context.Context, packs ...string) error {
+	_, _, err := pm.runCommandContext(ctx, pm.cmder.PurgeCmd(packs...), nil)
+	return err
+}
+
+// Fetch is defined on the PackageManager interface.
+func (pm *basePackageManager) Fetch(dir string, packs ...string) error {
+	return pm.runFetchCmd(dir, pm.cmder.FetchCmd(dir, packs...))
+}
+
+// FetchWithDependencies is defined on the PackageManager interface.
+func (pm *basePackageManager) FetchWithDependencies(dir string, packs ...string) error {
+	return pm.runFetchCmd(dir, pm.cmder.FetchWithDependenciesCmd(dir, packs...))
+}
+
+// runFetchCmd is a helper method which runs the given command
+// downloading packages into the given directory.
+func (pm *basePackageManager) runFetchCmd(dir, cmd string) error {
+	if dir == "" {
+		return errors.NotValidf("empty download directory")
+	}
+	if cmd == "" {
+		return errors.NotSupportedf("downloading packages")
+	}
+
+	_, _, err := pm.runCommand(cmd, nil)
+	return err
+}
+
+// Hold is defined on the PackageManager interface.
+func (pm *basePackageManager) Hold(packs ...string) error {
+	cmd := pm.cmder.HoldCmd(packs...)
+	if cmd == "" {
+		return errors.NotSupportedf("holding packages")
+	}
+
+	_, _, err := pm.runCommand(cmd, nil)
+	return err
+}
+
+// Unhold is defined on the PackageManager interface.
+func (pm *basePackageManager) Unhold(packs ...string) error {
+	cmd := pm.cmder.UnholdCmd(packs...)
+	if cmd == "" {
+		return errors.NotSupportedf("unholding packages")
+	}
+
+	_, _, err := pm.runCommand(cmd, nil)
 	return err
 }
 
+// SimulateInstall is defined on the PackageManager interface.
+func (pm *basePackageManager) SimulateInstall(...string) (*Transaction, error) {
+	return nil, errors.NotSupportedf("simulating packaging operations")
+}
+
+// SimulateRemove is defined on the PackageManager interface.
+func (pm *basePackageManager) SimulateRemove(...string) (*Transaction, error) {
+	return nil, errors.NotSupportedf("simulating packaging operations")
+}
+
+// SimulateUpgrade is defined on the PackageManager interface.
+func (pm *basePackageManager) SimulateUpgrade() (*Transaction, error) {
+	return nil, errors.NotSupportedf("simulating packaging operations")
+}
+
+// OwnerOf is defined on the PackageManager interface.
+func (pm *basePackageManager) OwnerOf(string) (string, error) {
+	return "", errors.NotSupportedf("finding the owners of paths")
+}
+
+// Verify is defined on the PackageManager interface.
+func (pm *basePackageManager) Verify(...string) ([]VerifyResult, error) {
+	return nil, errors.NotSupportedf("verifying packages")
+}
+
+// History is defined on the PackageManager interface.
+func (pm *basePackageManager) History() ([]HistoryEntry, error) {
+	return nil, errors.NotSupportedf("listing the package history")
+}
+
+// AvailableVersions is defined on the PackageManager interface.
+func (pm *basePackageManager) AvailableVersions(string) ([]PackageVersion, error) {
+	return nil, errors.NotSupportedf("listing package versions")
+}
+
+// CandidateVersion is defined on the PackageManager interface.
+func (pm *basePackageManager) CandidateVersion(string) (string, error) {
+	return "", errors.NotSupportedf("listing package versions")
+}
+
+// SearchPackages is defined on the PackageManager interface.
+func (pm *basePackageManager) SearchPackages(term string) ([]PackageInfo, error) {
+	cmd := pm.cmder.SearchPackagesCmd(term)
+	if cmd == "" {
+		return nil, errors.NotSupportedf("searching for packages")
+	}
+
+	out, _, err := pm.runCommand(cmd, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// the output consists of "<name>\t<version>\t<architecture>\t<description>" lines.
+	var res []PackageInfo
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		res = append(res, PackageInfo{
+			Name:         fields[0],
+			Version:      fields[1],
+			Architecture: fields[2],
+			Description:  strings.TrimSpace(fields[3]),
+		})
+	}
+
+	return res, nil
+}
+
+// Depends is defined on the PackageManager interface.
+func (pm *basePackageManager) Depends(pack string) (DependencyGraph, error) {
+	return pm.dependencyGraph(pack, pm.cmder.DependsCmd)
+}
+
+// RDepends is defined on the PackageManager interface.
+func (pm *basePackageManager) RDepends(pack string) (DependencyGraph, error) {
+	return pm.dependencyGraph(pack, pm.cmder.RDependsCmd)
+}
+
+// dependencyGraph is a helper method which builds the graph rooted at the
+// given package by running the command returned by the given function, which
+// lists the packages a package is directly related to one per line, for every
+// package reached.
+func (pm *basePackageManager) dependencyGraph(pack string, cmd func(string) string) (DependencyGraph, error) {
+	if cmd(pack) == "" {
+		return nil, errors.NotSupportedf("listing package dependencies")
+	}
+
+	graph := make(DependencyGraph)
+	queue := []string{pack}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, ok := graph[name]; ok {
+			continue
+		}
+
+		out, _, err := pm.runCommand(cmd(name), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var related []string
+		for _, line := range strings.Split(out, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && line != name {
+				related = appendUnique(related, line)
+			}
+		}
+		graph[name] = related
+		queue = append(queue, related...)
+	}
+
+	return graph, nil
+}
+
 // IsInstalled is defined on the PackageManager interface.
 func (pm *basePackageManager) IsInstalled(pack string) bool {
 	args := strings.Fields(pm.cmder.IsInstalledCmd(pack))
 
-	_, err := RunCommand(args[0], args[1:]...)
+	_, err := pm.runCommandOnce(args...)
 	return err == nil
 }
 
+// ListInstalled is defined on the PackageManager interface.
+func (pm *basePackageManager) ListInstalled() ([]PackageInfo, error) {
+	out, _, err := pm.runCommand(pm.cmder.ListInstalledCmd(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseInstalledPackages(out), nil
+}
+
 // AddRepository is defined on the PackageManager interface.
 func (pm *basePackageManager) AddRepository(repo string) error {
-	_, _, err := RunCommandWithRetry(pm.cmder.AddRepositoryCmd(repo), nil)
+	_, _, err := pm.runCommand(pm.cmder.AddRepositoryCmd(repo), nil)
 	return err
 }
 
 // RemoveRepository is defined on the PackageManager interface.
 func (pm *basePackageManager) RemoveRepository(repo string) error {
-	_, _, err := RunCommandWithRetry(pm.cmder.RemoveRepositoryCmd(repo), nil)
+	_, _, err := pm.runCommand(pm.cmder.RemoveRepositoryCmd(repo), nil)
 	return err
 }
 
+// AddRepositoryKey is defined on the PackageManager interface.
+func (pm *basePackageManager) AddRepositoryKey(string, RepositoryKey) error {
+	return errors.NotSupportedf("adding repository keys")
+}
+
+// RemoveRepositoryKey is defined on the PackageManager interface.
+func (pm *basePackageManager) RemoveRepositoryKey(string) error {
+	return errors.NotSupportedf("removing repository keys")
+}
+
 // Cleanup is defined on the PackageManager interface.
 func (pm *basePackageManager) Cleanup() error {
-	_, _, err := RunCommandWithRetry(pm.cmder.CleanupCmd(), nil)
+	cmd := pm.cmder.CleanupCmd()
+	if _, _, err := pm.runCommand(cmd, nil); err != nil {
+		return err
+	}
+
+	autoRemove := pm.cmder.AutoRemoveCmd()
+	if !pm.autoRemoveOnCleanup || autoRemove == "" || autoRemove == cmd {
+		return nil
+	}
+	_, _, err := pm.runCommand(autoRemove, nil)
+	return err
+}
+
+// AutoRemove is defined on the PackageManager interface.
+func (pm *basePackageManager) AutoRemove() error {
+	cmd := pm.cmder.AutoRemoveCmd()
+	if cmd == "" {
+		return errors.NotSupportedf("removing unneeded packages")
+	}
+
+	_, _, err := pm.runCommand(cmd, nil)
 	return err
 }
 
+// SetAutoRemoveOnCleanup is defined on the PackageManager interface.
+func (pm *basePackageManager) SetAutoRemoveOnCleanup(enabled bool) {
+	pm.autoRemoveOnCleanup = enabled
+}
+
+// SetInstallRecommends is defined on the PackageManager interface.
+func (pm *basePackageManager) SetInstallRecommends(enabled bool) {
+	pm.noRecommends = !enabled
+}
+
+// SetEnvironment is defined on the PackageManager interface.
+func (pm *basePackageManager) SetEnvironment(env []string) {
+	pm.env = append([]string(nil), env...)
+}
+
+// SetOutputCallback is defined on the PackageManager interface.
+func (pm *basePackageManager) SetOutputCallback(callback OutputFunc) {
+	pm.output = callback
+}
+
+// SetProgressCallback is defined on the PackageManager interface.
+func (pm *basePackageManager) SetProgressCallback(callback ProgressFunc) {
+	pm.progress = callback
+}
+
+// SetRetryStrategy is defined on the PackageManager interface.
+func (pm *basePackageManager) SetRetryStrategy(strategy RetryStrategy) {
+	pm.retry = &strategy
+}
+
 // SetProxy is defined on the PackageManager interface.
 func (pm *basePackageManager) SetProxy(settings proxy.Settings) error {
-	cmds := pm.cmder.SetProxyCmds(settings)
+	settings, err := normalizeProxySettings(settings)
+	if err != nil {
+		return errors.Trace(err)
+	}
 
-	for _, cmd := range cmds {
-		args := []string{"bash", "-c", fmt.Sprintf("%q", cmd)}
-		out, err := RunCommand(args[0], args[1:]...)
+	// the settings are safe to split, and are
+	// not passed through a shell to be interpreted.
+	for _, cmd := range pm.cmder.SetProxyCmds(settings) {
+		args := strings.Fields(cmd)
+		out, err := pm.runCommandOnce(args...)
 		if err != nil {
 			logger.Errorf("command failed: %v\nargs: %#v\n%s", err, args, string(out))
 			return fmt.Errorf("command failed: %v", err)
@@ -5,10 +5,14 @@
 package manager_test
 
 import (
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/juju/errors"
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/utils/proxy"
@@ -57,6 +61,10 @@ var (
 	// single-package testing scenarios.
 	testedPackageName = "test-package"
 
+	// testedPackageVersion is the package version used in all
+	// single-package versioned installation tests.
+	testedPackageVersion = "1.0.0-1"
+
 	// testedRepoName is the repository name used in all
 	// repository-related tests.
 	testedRepoName = "some-repo"
@@ -88,13 +96,13 @@ func getMockRunCommandWithRetry(stor *string) func(string, func(string) error) (
 	}
 }
 
-// getMockRunCommand returns a function with the same signature as RunCommand
-// which saves the command it revieves in the provided string whilst always
-// returning empty output and no error.
-func getMockRunCommand(stor *string) func(string, ...string) (string, error) {
-	return func(cmd string, args ...string) (string, error) {
-		*stor = strings.Join(append([]string{cmd}, args...), " ")
-		return "", nil
+// getMockCommandOutput returns a function with the same signature as
+// CommandOutput which saves the command it recieves in the provided string
+// whilst always returning empty output and no error.
+func getMockCommandOutput(stor *string) func(*exec.Cmd) ([]byte, error) {
+	return func(cmd *exec.Cmd) ([]byte, error) {
+		*stor = strings.Join(cmd.Args, " ")
+		return nil, nil
 	}
 }
 
@@ -162,6 +170,16 @@ var simpleTestCases = []*simpleTestCase{
 			return nil, pacman.Install(testedPackageNames...)
 		},
 	},
+	&simpleTestCase{
+		"Test install a version of a package.",
+		aptCmder.InstallVersionCmd(testedPackageName, testedPackageVersion),
+		nil,
+		yumCmder.InstallVersionCmd(testedPackageName, testedPackageVersion),
+		nil,
+		func(pacman manager.PackageManager) (interface{}, error) {
+			return nil, pacman.InstallVersion(testedPackageName, testedPackageVersion)
+		},
+	},
 	&simpleTestCase{
 		"Test remove packages.",
 		aptCmder.RemoveCmd(testedPackageNames...),
@@ -182,6 +200,26 @@ var simpleTestCases = []*simpleTestCase{
 			return nil, pacman.Purge(testedPackageNames...)
 		},
 	},
+	&simpleTestCase{
+		"Test hold packages.",
+		aptCmder.HoldCmd(testedPackageNames...),
+		nil,
+		yumCmder.HoldCmd(testedPackageNames...),
+		nil,
+		func(pacman manager.PackageManager) (interface{}, error) {
+			return nil, pacman.Hold(testedPackageNames...)
+		},
+	},
+	&simpleTestCase{
+		"Test unhold packages.",
+		aptCmder.UnholdCmd(testedPackageNames...),
+		nil,
+		yumCmder.UnholdCmd(testedPackageNames...),
+		nil,
+		func(pacman manager.PackageManager) (interface{}, error) {
+			return nil, pacman.Unhold(testedPackageNames...)
+		},
+	},
 	&simpleTestCase{
 		"Test repository addition.",
 		aptCmder.AddRepositoryCmd(testedRepoName),
@@ -241,7 +279,7 @@ var searchingTestCases = []*simpleTestCase{
 }
 
 func (s *ManagerSuite) TestSimpleCases(c *gc.C) {
-	s.PatchValue(&manager.RunCommand, getMockRunCommand(&s.calledCommand))
+	s.PatchValue(&manager.CommandOutput, getMockCommandOutput(&s.calledCommand))
 	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&s.calledCommand))
 
 	testCases := append(simpleTestCases, searchingTestCases...)
@@ -293,3 +331,331 @@ func (s *ManagerSuite) TestSimpleErrorCases(c *gc.C) {
 		c.Assert(strings.Join(cmd.Args, " "), gc.DeepEquals, testCase.expectedYumCmd)
 	}
 }
+
+func (s *ManagerSuite) TestInstallVersionNotAvailable(c *gc.C) {
+	const expectedErrMsg = `E: Version '1.0.0-1' for 'test-package' was not found`
+	state := os.ProcessState{}
+	cmdError := &exec.ExitError{ProcessState: &state}
+	s.PatchValue(&manager.ProcessStateSys, func(*os.ProcessState) interface{} {
+		return mockExitStatuser(100)
+	})
+
+	cmdChan := s.HookCommandOutput(&manager.CommandOutput, []byte(expectedErrMsg), error(cmdError))
+
+	err := s.apt.InstallVersion(testedPackageName, testedPackageVersion)
+	c.Assert(err, jc.Satisfies, manager.IsVersionNotAvailable)
+	c.Assert(err, gc.ErrorMatches, `version "1.0.0-1" of package "test-package" is not available`)
+
+	// the installation must not have been retried.
+	<-cmdChan
+	select {
+	case <-cmdChan:
+		c.Fatalf("unavailable version installation was retried")
+	default:
+	}
+}
+
+func (s *ManagerSuite) TestInstallVersionNotValid(c *gc.C) {
+	err := s.apt.InstallVersion(testedPackageName, "")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+
+	err = s.apt.InstallVersion("test package", testedPackageVersion)
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *ManagerSuite) TestInstallVersionNotSupported(c *gc.C) {
+	err := manager.NewPacmanPackageManager().InstallVersion(testedPackageName, testedPackageVersion)
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *ManagerSuite) TestDowngrade(c *gc.C) {
+	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&s.calledCommand))
+
+	err := s.apt.Downgrade(testedPackageName, testedPackageVersion)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, aptCmder.DowngradeCmd(testedPackageName, testedPackageVersion))
+
+	err = s.yum.Downgrade(testedPackageName, testedPackageVersion)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, yumCmder.DowngradeCmd(testedPackageName, testedPackageVersion))
+
+	err = s.apt.Downgrade(testedPackageName, "")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+
+	err = manager.NewPacmanPackageManager().Downgrade(testedPackageName, testedPackageVersion)
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *ManagerSuite) TestDowngradeNotAvailable(c *gc.C) {
+	state := os.ProcessState{}
+	cmdError := &exec.ExitError{ProcessState: &state}
+	s.PatchValue(&manager.ProcessStateSys, func(*os.ProcessState) interface{} {
+		return mockExitStatuser(1)
+	})
+	s.HookCommandOutput(&manager.CommandOutput, []byte("No package test-package-1.0.0-1 available."), error(cmdError))
+
+	err := s.yum.Downgrade(testedPackageName, testedPackageVersion)
+	c.Assert(err, jc.Satisfies, manager.IsVersionNotAvailable)
+}
+
+func (s *ManagerSuite) TestRollback(c *gc.C) {
+	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&s.calledCommand))
+
+	err := s.yum.Rollback("42")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, yumCmder.RollbackCmd("42"))
+
+	err = manager.NewSnapPackageManager().Rollback("juju")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, "snap revert juju")
+
+	s.calledCommand = ""
+	err = s.yum.Rollback("")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(s.calledCommand, gc.Equals, "")
+
+	err = s.apt.Rollback("42")
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *ManagerSuite) TestInstallLocal(c *gc.C) {
+	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&s.calledCommand))
+	dir := c.MkDir()
+	deb := filepath.Join(dir, "test-package_1.0.0-1_amd64.deb")
+	err := ioutil.WriteFile(deb, nil, 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = s.apt.InstallLocal(deb)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, aptCmder.InstallLocalCmd(deb))
+
+	err = s.yum.InstallLocal(filepath.Join(dir, "missing.rpm"))
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+
+	err = s.yum.InstallLocal()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+
+	err = manager.NewBrewPackageManager().InstallLocal(deb)
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *ManagerSuite) TestDependsNotSupported(c *gc.C) {
+	_, err := manager.NewBrewPackageManager().Depends(testedPackageName)
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+
+	_, err = manager.NewBrewPackageManager().RDepends(testedPackageName)
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *ManagerSuite) TestAutoRemove(c *gc.C) {
+	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&s.calledCommand))
+
+	err := s.apt.AutoRemove()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, aptCmder.AutoRemoveCmd())
+
+	err = manager.NewZypperPackageManager().AutoRemove()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *ManagerSuite) TestUpdateIfOlderThan(c *gc.C) {
+	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&s.calledCommand))
+	dir := c.MkDir()
+	stamp := filepath.Join(dir, "update-success-stamp")
+	lists := filepath.Join(dir, "lists")
+	err := os.Mkdir(lists, 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	s.PatchValue(manager.AptCacheStamps, []string{stamp, lists})
+
+	// the lists directory is used in the absence of the stamp.
+	old := time.Now().Add(-2 * time.Hour)
+	err = os.Chtimes(lists, old, old)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.apt.UpdateIfOlderThan(time.Hour)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, aptCmder.UpdateCmd())
+
+	s.calledCommand = ""
+	err = s.apt.UpdateIfOlderThan(3 * time.Hour)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, "")
+
+	err = ioutil.WriteFile(stamp, nil, 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.apt.UpdateIfOlderThan(time.Hour)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, "")
+}
+
+func (s *ManagerSuite) TestUpdateIfOlderThanOldestRepository(c *gc.C) {
+	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&s.calledCommand))
+	dir := c.MkDir()
+	s.PatchValue(manager.YumCacheStamps, []string{filepath.Join(dir, "*.solv")})
+
+	s.calledCommand = ""
+	err := s.yum.UpdateIfOlderThan(time.Hour)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, yumCmder.UpdateCmd())
+
+	for _, repo := range []string{"base", "updates"} {
+		err := ioutil.WriteFile(filepath.Join(dir, repo+".solv"), nil, 0644)
+		c.Assert(err, jc.ErrorIsNil)
+	}
+	s.calledCommand = ""
+	err = s.yum.UpdateIfOlderThan(time.Hour)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, "")
+
+	old := time.Now().Add(-2 * time.Hour)
+	err = os.Chtimes(filepath.Join(dir, "updates.solv"), old, old)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.yum.UpdateIfOlderThan(time.Hour)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, yumCmder.UpdateCmd())
+}
+
+func (s *ManagerSuite) TestUpgradeOnly(c *gc.C) {
+	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&s.calledCommand))
+
+	err := s.yum.UpgradeOnly("juju", "lxd")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, yumCmder.UpgradeOnlyCmd("juju", "lxd"))
+
+	err = s.apt.UpgradeOnly("juju")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, aptCmder.UpgradeOnlyCmd("juju"))
+
+	// without any packages, the whole system would get upgraded.
+	s.calledCommand = ""
+	err = s.yum.UpgradeOnly()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(s.calledCommand, gc.Equals, "")
+
+	err = manager.NewPacmanPackageManager().UpgradeOnly("juju")
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *ManagerSuite) TestSetInstallRecommends(c *gc.C) {
+	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&s.calledCommand))
+
+	apt := manager.NewAptPackageManager()
+	apt.SetInstallRecommends(false)
+	err := apt.Install(testedPackageName)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, aptCmder.InstallNoRecommendsCmd(testedPackageName))
+
+	apt.SetInstallRecommends(true)
+	err = apt.Install(testedPackageName)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, aptCmder.InstallCmd(testedPackageName))
+
+	// package managers without recommended packages install as usual.
+	pacman := manager.NewPacmanPackageManager()
+	pacman.SetInstallRecommends(false)
+	err = pacman.Install(testedPackageName)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, commands.NewPacmanPackageCommander().InstallCmd(testedPackageName))
+}
+
+func (s *ManagerSuite) TestHistoryNotSupported(c *gc.C) {
+	_, err := manager.NewPacmanPackageManager().History()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *ManagerSuite) TestSetOutputCallback(c *gc.C) {
+	s.PatchValue(&manager.RunCommandWithRetry, func(cmd string, _ func(string) error) (string, int, error) {
+		if strings.Contains(cmd, "remove") {
+			return "E: Unable to locate package juju\n", 100, errors.New("packaging command failed: exit status 100")
+		}
+		return "Setting up juju (2.0.0-0ubuntu1) ...\n", 0, nil
+	})
+
+	type report struct {
+		cmd, output, err string
+	}
+	var reports []report
+	apt := manager.NewAptPackageManager()
+	apt.SetOutputCallback(func(cmd, output string, err error) {
+		r := report{cmd: cmd, output: output}
+		if err != nil {
+			r.err = err.Error()
+		}
+		reports = append(reports, r)
+	})
+
+	err := apt.Install("juju")
+	c.Assert(err, jc.ErrorIsNil)
+	err = apt.Remove("juju")
+	c.Assert(err, gc.NotNil)
+
+	c.Assert(reports, jc.DeepEquals, []report{{
+		cmd:    aptCmder.InstallCmd("juju"),
+		output: "Setting up juju (2.0.0-0ubuntu1) ...\n",
+	}, {
+		cmd:    aptCmder.RemoveCmd("juju"),
+		output: "E: Unable to locate package juju\n",
+		err:    "packaging command failed: exit status 100",
+	}})
+}
+
+func (s *ManagerSuite) TestSetOutputCallbackSingleAttemptCommands(c *gc.C) {
+	s.PatchValue(&manager.CommandOutput, func(cmd *exec.Cmd) ([]byte, error) {
+		return []byte("output of " + cmd.Args[0]), nil
+	})
+
+	var cmds []string
+	apt := manager.NewAptPackageManager()
+	apt.SetOutputCallback(func(cmd, output string, err error) {
+		c.Check(err, jc.ErrorIsNil)
+		c.Check(output, gc.Equals, "output of "+strings.Fields(cmd)[0])
+		cmds = append(cmds, cmd)
+	})
+
+	c.Assert(apt.IsInstalled("juju"), jc.IsTrue)
+	_, err := apt.GetProxySettings()
+	c.Assert(err, jc.ErrorIsNil)
+	err = apt.SetProxy(testedProxySettings)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(cmds, jc.DeepEquals, append([]string{
+		aptCmder.IsInstalledCmd("juju"),
+		aptCmder.GetProxyCmd(),
+	}, aptCmder.SetProxyCmds(testedProxySettings)...))
+}
+
+func (s *ManagerSuite) TestSetEnvironment(c *gc.C) {
+	s.PatchEnvironment("LANG", "C")
+	var env []string
+	s.PatchValue(&manager.CommandOutput, func(cmd *exec.Cmd) ([]byte, error) {
+		env = cmd.Env
+		return nil, nil
+	})
+
+	apt := manager.NewAptPackageManager()
+	apt.SetEnvironment([]string{"ACCEPT_EULA=Y", "LANG=en_US.UTF-8"})
+	err := apt.Install("msodbcsql")
+	c.Assert(err, jc.ErrorIsNil)
+
+	// the isolated environment only holds LANG, which gets overridden.
+	c.Assert(env, jc.SameContents, []string{"LANG=en_US.UTF-8", "ACCEPT_EULA=Y"})
+
+	// so do the commands which are not retried.
+	env = nil
+	c.Assert(apt.IsInstalled("msodbcsql"), jc.IsTrue)
+	c.Assert(env, jc.SameContents, []string{"LANG=en_US.UTF-8", "ACCEPT_EULA=Y"})
+
+	env = nil
+	_, err = apt.GetProxySettings()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(env, jc.SameContents, []string{"LANG=en_US.UTF-8", "ACCEPT_EULA=Y"})
+}
+
+func (s *ManagerSuite) TestVerifyNotSupported(c *gc.C) {
+	_, err := manager.NewPacmanPackageManager().Verify()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *ManagerSuite) TestOwnerOfNotSupported(c *gc.C) {
+	_, err := manager.NewBrewPackageManager().OwnerOf("/usr/local/bin/juju")
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
@@ -0,0 +1,86 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils/proxy"
+)
+
+// NixManager is the PackageManager for nix, extended with the operations
+// which are specific to nix profiles.
+type NixManager interface {
+	PackageManager
+
+	// Rollback switches the profile to the given generation, or to the
+	// generation preceding the current one if no generation is given.
+	Rollback(generation string) error
+}
+
+// nix is the PackageManager implementation for the nix package manager.
+type nix struct {
+	basePackageManager
+}
+
+// ListInstalled is defined on the PackageManager interface.
+func (nix *nix) ListInstalled() ([]PackageInfo, error) {
+	out, _, err := nix.runCommand(nix.cmder.ListInstalledCmd(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// nix-env --query outputs "<name>-<version>" lines.
+	var res []PackageInfo
+	for _, line := range strings.Split(out, "\n") {
+		name, version := splitNameVersion(strings.TrimSpace(line))
+		if version == "" {
+			continue
+		}
+		res = append(res, PackageInfo{Name: name, Version: version})
+	}
+
+	return res, nil
+}
+
+// Search is defined on the PackageManager interface.
+func (nix *nix) Search(pack string) (bool, error) {
+	_, code, err := nix.runCommand(nix.cmder.SearchCmd(pack), nil)
+
+	// nix-env --query returns 1 when no derivation matches the package.
+	if code == 1 {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Rollback is defined on the NixManager interface.
+func (nix *nix) Rollback(generation string) error {
+	cmd := "nix-env --quiet --rollback"
+	if generation != "" {
+		if _, err := strconv.ParseUint(generation, 10, 64); err != nil {
+			return errors.NotValidf("nix generation %q", generation)
+		}
+		cmd = nix.cmder.RollbackCmd(generation)
+	}
+
+	_, _, err := nix.runCommand(cmd, nil)
+	return err
+}
+
+// GetProxySettings is defined on the PackageManager interface.
+// nix has no proxy configuration of its own; it downloads through curl,
+// which uses the proxy settings found in the environment.
+func (nix *nix) GetProxySettings() (proxy.Settings, error) {
+	return DetectProxies(), nil
+}
+
+// SetProxy is defined on the PackageManager interface.
+func (nix *nix) SetProxy(proxy.Settings) error {
+	return errors.NotSupportedf("setting a proxy for nix")
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager_test
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils/packaging/manager"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&NixSuite{})
+
+type NixSuite struct {
+	testing.IsolationSuite
+	pacman manager.NixManager
+}
+
+func (s *NixSuite) SetUpSuite(c *gc.C) {
+	s.IsolationSuite.SetUpSuite(c)
+	s.pacman = manager.NewNixPackageManager()
+}
+
+func (s *NixSuite) TestNewPackageManager(c *gc.C) {
+	pacman, err := manager.NewPackageManager("nixos")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(pacman, gc.FitsTypeOf, s.pacman)
+}
+
+func (s *NixSuite) TestSearchNotFound(c *gc.C) {
+	s.PatchValue(&manager.RunCommandWithRetry, func(string, func(string) error) (string, int, error) {
+		return "", 1, errors.New("packaging command failed: exit status 1")
+	})
+
+	found, err := s.pacman.Search(testedPackageName)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(found, jc.IsFalse)
+}
+
+func (s *NixSuite) TestRollback(c *gc.C) {
+	var calledCommand string
+	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&calledCommand))
+
+	err := s.pacman.Rollback("")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(calledCommand, gc.Equals, "nix-env --quiet --rollback")
+
+	err = s.pacman.Rollback("42")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(calledCommand, gc.Equals, "nix-env --quiet --switch-generation 42")
+}
+
+func (s *NixSuite) TestRollbackInvalidGeneration(c *gc.C) {
+	err := s.pacman.Rollback("latest")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *NixSuite) TestSetProxyNotSupported(c *gc.C) {
+	err := s.pacman.SetProxy(testedProxySettings)
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
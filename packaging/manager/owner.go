@@ -0,0 +1,77 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"path"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// validateOwnedPath returns an error if the given path cannot be looked
+// up by OwnerOf. The path must be absolute, as dpkg would otherwise take
+// it as a pattern matching the paths of any package.
+func validateOwnedPath(p string) error {
+	if !path.IsAbs(p) || strings.ContainsAny(p, " \t\n*?[") {
+		return errors.NotValidf("path %q", p)
+	}
+	return nil
+}
+
+// OwnerOf is defined on the PackageManager interface.
+func (apt *apt) OwnerOf(p string) (string, error) {
+	if err := validateOwnedPath(p); err != nil {
+		return "", err
+	}
+
+	out, _, err := apt.runCommand(apt.cmder.OwnerCmd(p), nil)
+	if strings.Contains(out, "no path found matching pattern") {
+		return "", errors.NotFoundf("package owning %q", p)
+	} else if err != nil {
+		return "", err
+	}
+
+	// dpkg --search outputs "<package>[:<arch>][, ...]: <path>" lines,
+	// along with the diversions of the path.
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "diversion ") || !strings.HasSuffix(line, ": "+p) {
+			continue
+		}
+		packs := strings.Split(strings.TrimSuffix(line, ": "+p), ", ")
+		return strings.SplitN(packs[0], ":", 2)[0], nil
+	}
+	return "", errors.NotFoundf("package owning %q", p)
+}
+
+// OwnerOf is defined on the PackageManager interface.
+func (yum *yum) OwnerOf(p string) (string, error) {
+	return yum.ownerRPM(p)
+}
+
+// OwnerOf is defined on the PackageManager interface.
+func (zypper *zypper) OwnerOf(p string) (string, error) {
+	return zypper.ownerRPM(p)
+}
+
+// ownerRPM returns the package owning the given path according to rpm.
+func (pm *basePackageManager) ownerRPM(p string) (string, error) {
+	if err := validateOwnedPath(p); err != nil {
+		return "", err
+	}
+
+	out, _, err := pm.runCommand(pm.cmder.OwnerCmd(p), nil)
+	if strings.Contains(out, "is not owned by any package") || strings.Contains(out, "No such file or directory") {
+		return "", errors.NotFoundf("package owning %q", p)
+	} else if err != nil {
+		return "", err
+	}
+
+	// rpm outputs the name of every package owning the path on its own line.
+	if fields := strings.Fields(out); len(fields) > 0 {
+		return fields[0], nil
+	}
+	return "", errors.NotFoundf("package owning %q", p)
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils"
+	"github.com/juju/utils/packaging/commands"
+	"github.com/juju/utils/proxy"
+)
+
+// pacmanConfigFile is the pacman configuration file which repositories
+// are added to and removed from. It is a variable for testing purposes.
+var pacmanConfigFile = commands.PacmanConfigFilePath
+
+// pacmanRepoHeaderRE matches the header of a repository section
+// in pacman.conf, capturing the name of the repository.
+var pacmanRepoHeaderRE = regexp.MustCompile(`^\s*\[([^\]]+)\]\s*$`)
+
+// pacman is the PackageManager implementation for Arch-based systems.
+type pacman struct {
+	basePackageManager
+}
+
+// Search is defined on the PackageManager interface.
+func (pacman *pacman) Search(pack string) (bool, error) {
+	_, code, err := pacman.runCommand(pacman.cmder.SearchCmd(pack), nil)
+
+	// pacman -Si returns 1 when it cannot find the package.
+	if code == 1 {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// AddRepository is defined on the PackageManager interface.
+// The repository is expected to be a complete pacman.conf(5) repository
+// section, such as:
+//
+//	[custom]
+//	Server = http://repo.example.com/$arch
+func (pacman *pacman) AddRepository(repo string) error {
+	lines := strings.Split(strings.TrimSpace(repo), "\n")
+	match := pacmanRepoHeaderRE.FindStringSubmatch(lines[0])
+	if match == nil {
+		return errors.NotValidf("pacman repository section %q", repo)
+	}
+
+	return updatePacmanConfig(func(conf string) (string, error) {
+		if _, _, ok := findPacmanRepoSection(conf, match[1]); ok {
+			return "", errors.AlreadyExistsf("pacman repository %q", match[1])
+		}
+		return strings.TrimRight(conf, "\n") + "\n\n" + strings.Join(lines, "\n") + "\n", nil
+	})
+}
+
+// RemoveRepository is defined on the PackageManager interface.
+// The repository is given by its name, as used in its section header.
+func (pacman *pacman) RemoveRepository(repo string) error {
+	return updatePacmanConfig(func(conf string) (string, error) {
+		start, end, ok := findPacmanRepoSection(conf, repo)
+		if !ok {
+			return "", errors.NotFoundf("pacman repository %q", repo)
+		}
+
+		lines := strings.Split(conf, "\n")
+		lines = append(lines[:start], lines[end:]...)
+		return strings.Join(lines, "\n"), nil
+	})
+}
+
+// updatePacmanConfig atomically replaces the contents of pacman.conf
+// with those the given function returns for its current contents.
+func updatePacmanConfig(update func(conf string) (string, error)) error {
+	return errors.Trace(utils.AtomicUpdateFile(pacmanConfigFile, 0644, func(conf []byte) ([]byte, error) {
+		if conf == nil {
+			return nil, errors.NotFoundf("pacman configuration %q", pacmanConfigFile)
+		}
+		contents, err := update(string(conf))
+		return []byte(contents), err
+	}))
+}
+
+// findPacmanRepoSection returns the range of lines [start, end) spanned by the
+// section of the given repository in the contents of pacman.conf, and whether
+// such a section was found at all.
+func findPacmanRepoSection(conf, repo string) (int, int, bool) {
+	lines := strings.Split(conf, "\n")
+
+	start := -1
+	for i, line := range lines {
+		match := pacmanRepoHeaderRE.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if start != -1 {
+			return start, i, true
+		}
+		if match[1] == repo {
+			start = i
+		}
+	}
+	if start == -1 {
+		return 0, 0, false
+	}
+
+	return start, len(lines), true
+}
+
+// GetProxySettings is defined on the PackageManager interface.
+func (pacman *pacman) GetProxySettings() (proxy.Settings, error) {
+	var res proxy.Settings
+
+	args := strings.Fields(pacman.cmder.GetProxyCmd())
+	if len(args) <= 1 {
+		return proxy.Settings{}, fmt.Errorf("expected at least 2 arguments, got %d %v", len(args), args)
+	}
+
+	out, err := pacman.runCommandOnce(args...)
+
+	if err != nil {
+		// grep exits with 1 when no proxy has been configured.
+		if len(out) == 0 {
+			return res, nil
+		}
+		logger.Errorf("command failed: %v\nargs: %#v\n%s",
+			err, args, string(out))
+		return res, fmt.Errorf("command failed: %v", err)
+	}
+
+	return parseProxySettings(string(out)), nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils/packaging/commands"
+	"github.com/juju/utils/packaging/manager"
+	"github.com/juju/utils/proxy"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&PacmanSuite{})
+
+type PacmanSuite struct {
+	testing.IsolationSuite
+	paccmder commands.PackageCommander
+	pacman   manager.PackageManager
+	confFile string
+}
+
+func (s *PacmanSuite) SetUpSuite(c *gc.C) {
+	s.IsolationSuite.SetUpSuite(c)
+	s.paccmder = commands.NewPacmanPackageCommander()
+	s.pacman = manager.NewPacmanPackageManager()
+}
+
+const testedPacmanConf = `[options]
+HoldPkg = pacman glibc
+
+[core]
+Include = /etc/pacman.d/mirrorlist
+
+[extra]
+Include = /etc/pacman.d/mirrorlist
+`
+
+func (s *PacmanSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.confFile = filepath.Join(c.MkDir(), "pacman.conf")
+	err := ioutil.WriteFile(s.confFile, []byte(testedPacmanConf), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	s.PatchValue(manager.PacmanConfigFile, s.confFile)
+}
+
+func (s *PacmanSuite) readConf(c *gc.C) string {
+	data, err := ioutil.ReadFile(s.confFile)
+	c.Assert(err, jc.ErrorIsNil)
+	return string(data)
+}
+
+func (s *PacmanSuite) TestNewPackageManager(c *gc.C) {
+	pacman, err := manager.NewPackageManager("arch")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(pacman, gc.FitsTypeOf, s.pacman)
+}
+
+func (s *PacmanSuite) TestAddRepository(c *gc.C) {
+	err := s.pacman.AddRepository("[custom]\nServer = http://repo.example.com/$arch")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.readConf(c), gc.Equals, testedPacmanConf+`
+[custom]
+Server = http://repo.example.com/$arch
+`)
+}
+
+func (s *PacmanSuite) TestAddRepositoryInvalid(c *gc.C) {
+	err := s.pacman.AddRepository("Server = http://repo.example.com/$arch")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(s.readConf(c), gc.Equals, testedPacmanConf)
+}
+
+func (s *PacmanSuite) TestAddRepositoryExists(c *gc.C) {
+	err := s.pacman.AddRepository("[extra]\nServer = http://repo.example.com/$arch")
+	c.Assert(err, jc.Satisfies, errors.IsAlreadyExists)
+	c.Assert(s.readConf(c), gc.Equals, testedPacmanConf)
+}
+
+func (s *PacmanSuite) TestRemoveRepository(c *gc.C) {
+	err := s.pacman.RemoveRepository("core")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.readConf(c), gc.Equals, `[options]
+HoldPkg = pacman glibc
+
+[extra]
+Include = /etc/pacman.d/mirrorlist
+`)
+}
+
+func (s *PacmanSuite) TestRemoveRepositoryLast(c *gc.C) {
+	err := s.pacman.RemoveRepository("extra")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.readConf(c), gc.Equals, `[options]
+HoldPkg = pacman glibc
+
+[core]
+Include = /etc/pacman.d/mirrorlist
+`)
+}
+
+func (s *PacmanSuite) TestRemoveRepositoryNotFound(c *gc.C) {
+	err := s.pacman.RemoveRepository("community")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *PacmanSuite) TestRepositoryChangesKeepPermissions(c *gc.C) {
+	err := os.Chmod(s.confFile, 0600)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = s.pacman.RemoveRepository("core")
+	c.Assert(err, jc.ErrorIsNil)
+	info, err := os.Stat(s.confFile)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode().Perm(), gc.Equals, os.FileMode(0600))
+}
+
+func (s *PacmanSuite) TestRepositoryChangesConfigMissing(c *gc.C) {
+	err := os.Remove(s.confFile)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = s.pacman.AddRepository("[custom]\nServer = http://repo.example.com/$arch")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(s.confFile, jc.DoesNotExist)
+}
+
+func (s *PacmanSuite) TestGetProxySettingsConfigured(c *gc.C) {
+	const expected = `http_proxy=10.0.3.1:3142
+https_proxy=false`
+	cmdChan := s.HookCommandOutput(&manager.CommandOutput, []byte(expected), nil)
+
+	out, err := s.pacman.GetProxySettings()
+	c.Assert(err, jc.ErrorIsNil)
+
+	cmd := <-cmdChan
+	c.Assert(cmd.Args, gc.DeepEquals, strings.Fields(s.paccmder.GetProxyCmd()))
+	c.Assert(out, gc.Equals, proxy.Settings{
+		Http:  "10.0.3.1:3142",
+		Https: "false",
+	})
+}
+
+func (s *PacmanSuite) TestSearchPackagesNotSupported(c *gc.C) {
+	_, err := s.pacman.SearchPackages("lxc")
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *PacmanSuite) TestHoldNotSupported(c *gc.C) {
+	err := s.pacman.Hold("linux")
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+
+	err = s.pacman.Unhold("linux")
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *PacmanSuite) TestSimulateNotSupported(c *gc.C) {
+	_, err := s.pacman.SimulateUpgrade()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
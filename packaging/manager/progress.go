@@ -0,0 +1,116 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ProgressStage is the stage of a packaging operation a ProgressEvent
+// reports on.
+type ProgressStage string
+
+const (
+	// ProgressDownload is the stage in which packages are downloaded.
+	ProgressDownload ProgressStage = "download"
+
+	// ProgressUnpack is the stage in which packages are unpacked.
+	ProgressUnpack ProgressStage = "unpack"
+
+	// ProgressConfigure is the stage in which packages are configured.
+	ProgressConfigure ProgressStage = "configure"
+
+	// ProgressRemove is the stage in which packages are removed.
+	ProgressRemove ProgressStage = "remove"
+)
+
+// ProgressEvent reports the progress of a packaging operation.
+type ProgressEvent struct {
+	// Stage is the stage of the operation.
+	Stage ProgressStage
+
+	// Package is the package being processed, if known.
+	Package string
+
+	// Percent is the overall progress of the stage, from 0 to 100.
+	Percent float64
+
+	// Message is the human-readable description of the event.
+	Message string
+}
+
+// ProgressFunc is the function which is called with the progress
+// events of a packaging operation.
+type ProgressFunc func(ProgressEvent)
+
+// parseAptStatus parses the given line output to the APT::Status-Fd of an
+// apt-get operation, such as "pmstatus:juju:20.0:Unpacking juju (amd64)",
+// into a ProgressEvent. It returns false if the line does not report progress.
+func parseAptStatus(line string) (ProgressEvent, bool) {
+	fields := strings.SplitN(strings.TrimSpace(line), ":", 4)
+	if len(fields) != 4 {
+		return ProgressEvent{}, false
+	}
+	percent, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return ProgressEvent{}, false
+	}
+
+	event := ProgressEvent{Percent: percent, Message: fields[3]}
+	switch fields[0] {
+	case "dlstatus":
+		// the second field is the index of the downloaded file.
+		event.Stage = ProgressDownload
+	case "pmstatus":
+		event.Package = fields[1]
+		message := strings.ToLower(fields[3])
+		switch {
+		case strings.Contains(message, "remov"):
+			event.Stage = ProgressRemove
+		case strings.Contains(message, "unpack"):
+			event.Stage = ProgressUnpack
+		default:
+			event.Stage = ProgressConfigure
+		}
+	default:
+		return ProgressEvent{}, false
+	}
+
+	return event, true
+}
+
+// lineWriter is an io.Writer which calls a function
+// with each complete line written to it.
+type lineWriter struct {
+	partial []byte
+	onLine  func(string)
+}
+
+// Write implements io.Writer.
+func (w *lineWriter) Write(data []byte) (int, error) {
+	w.partial = append(w.partial, data...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.onLine(string(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+	}
+	return len(data), nil
+}
+
+// outputWithProgress returns a function which, like CommandOutput, runs a
+// command and returns its combined output, but also calls the given function
+// with each line of output as soon as the command writes it. The command is
+// killed when the given context is canceled.
+func outputWithProgress(ctx context.Context, onLine func(string)) func(*exec.Cmd) ([]byte, error) {
+	return func(cmd *exec.Cmd) ([]byte, error) {
+		return runContext(ctx, cmd, &lineWriter{onLine: onLine})
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils"
+	"github.com/juju/utils/packaging/commands"
+	"github.com/juju/utils/proxy"
+)
+
+var (
+	// aptProxyConfigFile is the file which apt proxy settings are
+	// written to. It is a variable for testing purposes.
+	aptProxyConfigFile = commands.AptConfFilePath
+
+	// yumProxyConfigFiles are the configuration files of yum and dnf,
+	// which their proxy settings are written to. It is a variable for
+	// testing purposes.
+	yumProxyConfigFiles = []string{commands.YumConfigFilePath, commands.DnfConfigFilePath}
+
+	// zypperProxyConfigFile is the file which zypper proxy settings are
+	// written to. It is a variable for testing purposes.
+	zypperProxyConfigFile = commands.ZypperProxyConfigFilePath
+
+	// pacmanProxyConfigFile is the file which pacman proxy settings are
+	// written to. It is a variable for testing purposes.
+	pacmanProxyConfigFile = commands.PacmanProxyConfigFilePath
+)
+
+// unsafeProxyChars are the characters which are left out of proxy URLs, as
+// they may be interpreted by the shells sourcing some of the configuration
+// files, or end the quoted values of others. Such characters are escaped in
+// the credentials of the URLs.
+const unsafeProxyChars = "\"'`\\$;&|<>(){}*?!#"
+
+// normalizeProxySettings returns the given settings with the credentials
+// of their proxy URLs escaped, or an error satisfying errors.IsNotValid if
+// they cannot be written safely to configuration files or command lines.
+func normalizeProxySettings(settings proxy.Settings) (proxy.Settings, error) {
+	for _, value := range []*string{&settings.Http, &settings.Https, &settings.Ftp} {
+		normalized, err := normalizeProxyURL(*value)
+		if err != nil {
+			return proxy.Settings{}, errors.Trace(err)
+		}
+		*value = normalized
+	}
+	return settings, nil
+}
+
+// normalizeProxyURL percent-encodes the credentials of the given proxy URL,
+// such as "http://user:p@ss@proxy.example.com:3128", if it has any. Already
+// percent-encoded credentials are kept as they are.
+func normalizeProxyURL(value string) (string, error) {
+	start := 0
+	if i := strings.Index(value, "://"); i != -1 {
+		start = i + len("://")
+	}
+	// the credentials end at the last "@", as passwords may contain others.
+	if end := strings.LastIndex(value, "@"); end >= start {
+		parts := strings.SplitN(value[start:end], ":", 2)
+		userinfo := escapeUserinfo(parts[0])
+		if len(parts) == 2 {
+			userinfo += ":" + escapeUserinfo(parts[1])
+		}
+		value = value[:start] + userinfo + value[end:]
+	}
+
+	for _, r := range value {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune(unsafeProxyChars, r) {
+			return "", errors.NotValidf("proxy URL containing %q", r)
+		}
+	}
+	return value, nil
+}
+
+// escapeUserinfo percent-encodes all but the unreserved characters of the
+// given user name or password, first decoding it if it is already encoded.
+func escapeUserinfo(s string) string {
+	if unescaped, err := url.PathUnescape(s); err == nil {
+		s = unescaped
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+			buf.WriteByte(c)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+// writeProxyConfig writes the given proxy settings to the given
+// configuration file in the format of the given PackageCommander.
+func writeProxyConfig(cmder commands.PackageCommander, path string, settings proxy.Settings, extra ...string) error {
+	settings, err := normalizeProxySettings(settings)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	contents := cmder.ProxyConfigContents(settings)
+	if contents == "" {
+		return nil
+	}
+	lines := append(strings.Split(contents, "\n"), extra...)
+	return errors.Annotatef(updateConfigLines(path, lines), "cannot write proxy settings to %q", path)
+}
+
+// updateConfigLines atomically replaces the lines of the given file which
+// set the same options as the given lines with them, appending those
+// which are not set yet. The file is created if it does not exist.
+func updateConfigLines(path string, lines []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Trace(err)
+	}
+	replaced := make(map[string]bool)
+	for _, line := range lines {
+		replaced[configOption(line)] = true
+	}
+
+	return errors.Trace(utils.AtomicUpdateFile(path, 0644, func(contents []byte) ([]byte, error) {
+		var res []string
+		if len(contents) > 0 {
+			for _, line := range strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n") {
+				if !replaced[configOption(line)] {
+					res = append(res, line)
+				}
+			}
+		}
+		res = append(res, lines...)
+		return []byte(strings.Join(res, "\n") + "\n"), nil
+	}))
+}
+
+// updateConfigSection atomically replaces the lines of the given section
+// of the given INI style file which set the same options as the given
+// lines, or one of the given obsolete options, with the lines, which are
+// written at the end of the section. The section is added at the end of
+// the file if it does not exist, and the file is created if it does not
+// exist either.
+func updateConfigSection(path, section string, lines, obsolete []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Trace(err)
+	}
+	replaced := make(map[string]bool)
+	for _, line := range lines {
+		replaced[configOption(line)] = true
+	}
+	for _, option := range obsolete {
+		replaced[option] = true
+	}
+	header := "[" + section + "]"
+
+	return errors.Trace(utils.AtomicUpdateFile(path, 0644, func(contents []byte) ([]byte, error) {
+		var res []string
+		// end is the index of the line following the last
+		// one of the section which is not blank.
+		end := -1
+		inSection := false
+		if len(contents) > 0 {
+			for _, line := range strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n") {
+				trimmed := strings.TrimSpace(line)
+				if strings.HasPrefix(trimmed, "[") {
+					inSection = trimmed == header
+				}
+				if inSection && replaced[configOption(line)] {
+					continue
+				}
+				res = append(res, line)
+				if inSection && trimmed != "" {
+					end = len(res)
+				}
+			}
+		}
+		if end == -1 {
+			res = append(res, header)
+			end = len(res)
+		}
+		res = append(res[:end], append(append([]string(nil), lines...), res[end:]...)...)
+		return []byte(strings.Join(res, "\n") + "\n"), nil
+	}))
+}
+
+// configOption returns the option the given line of a
+// configuration file sets, such as "export http_proxy" or
+// "Acquire::http::Proxy".
+func configOption(line string) string {
+	if i := strings.IndexAny(line, `="`); i != -1 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
+
+// SetProxy is defined on the PackageManager interface.
+func (apt *apt) SetProxy(settings proxy.Settings) error {
+	return writeProxyConfig(apt.cmder, aptProxyConfigFile, settings)
+}
+
+// yumObsoleteProxyOptions are the options which previous versions of
+// SetProxy wrote for yum, which does not read them.
+var yumObsoleteProxyOptions = []string{"http_proxy", "https_proxy", "ftp_proxy"}
+
+// SetProxy is defined on the PackageManager interface.
+// yum and dnf have a single proxy option, which is set to the http proxy,
+// or to the https one if there is none, in the [main] section of their
+// configuration files, along with the credentials of the proxy.
+func (yum *yum) SetProxy(settings proxy.Settings) error {
+	settings, err := normalizeProxySettings(settings)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	value := settings.Http
+	if value == "" {
+		value = settings.Https
+	}
+	if value == "" {
+		return nil
+	}
+	lines, err := yumProxyLines(value)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, path := range yumProxyConfigPaths() {
+		err := updateConfigSection(path, "main", lines, yumObsoleteProxyOptions)
+		if err != nil {
+			return errors.Annotatef(err, "cannot write proxy settings to %q", path)
+		}
+	}
+	return nil
+}
+
+// yumProxyLines returns the lines of the yum configuration
+// setting the given normalized proxy URL, with the credentials
+// it holds set by their own options.
+func yumProxyLines(value string) ([]string, error) {
+	start := 0
+	if i := strings.Index(value, "://"); i != -1 {
+		start = i + len("://")
+	}
+	end := strings.LastIndex(value, "@")
+	if end < start {
+		return []string{"proxy=" + value}, nil
+	}
+	lines := []string{"proxy=" + value[:start] + value[end+1:]}
+	parts := strings.SplitN(value[start:end], ":", 2)
+	for i, option := range []string{"proxy_username", "proxy_password"} {
+		if i >= len(parts) {
+			break
+		}
+		// the credentials are escaped by normalizeProxyURL.
+		credential, err := url.PathUnescape(parts[i])
+		if err != nil {
+			return nil, errors.NotValidf("proxy credentials")
+		}
+		if strings.IndexFunc(credential, unicode.IsControl) != -1 {
+			return nil, errors.NotValidf("proxy credentials containing control characters")
+		}
+		lines = append(lines, option+"="+credential)
+	}
+	return lines, nil
+}
+
+// yumProxyConfigPaths returns those of yumProxyConfigFiles which exist,
+// with their symlinks resolved, as yum.conf is a symlink to dnf.conf on
+// some systems, or the first of them if none does.
+func yumProxyConfigPaths() []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, path := range yumProxyConfigFiles {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil || seen[resolved] {
+			continue
+		}
+		seen[resolved] = true
+		paths = append(paths, resolved)
+	}
+	if len(paths) == 0 {
+		return yumProxyConfigFiles[:1]
+	}
+	return paths
+}
+
+// SetProxy is defined on the PackageManager interface.
+func (zypper *zypper) SetProxy(settings proxy.Settings) error {
+	// /etc/sysconfig/proxy ships with PROXY_ENABLED="no"; the settings
+	// are ignored unless the proxy is explicitly enabled.
+	return writeProxyConfig(zypper.cmder, zypperProxyConfigFile, settings, `PROXY_ENABLED="yes"`)
+}
+
+// SetProxy is defined on the PackageManager interface.
+func (pacman *pacman) SetProxy(settings proxy.Settings) error {
+	return writeProxyConfig(pacman.cmder, pacmanProxyConfigFile, settings)
+}
+
+// SetProxy is defined on the PackageManager interface.
+func (apk *apk) SetProxy(settings proxy.Settings) error {
+	return writeProxyConfig(apk.cmder, apkProxyConfigFile, settings)
+}
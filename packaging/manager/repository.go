@@ -0,0 +1,227 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// RepositoryKind is the kind of repository a RepositorySpec describes.
+type RepositoryKind string
+
+const (
+	// RepositoryPPA is a Launchpad Personal Package Archive,
+	// given as "ppa:<owner>/<name>".
+	RepositoryPPA RepositoryKind = "ppa"
+
+	// RepositoryDeb is an apt repository, given as a one-line-style
+	// sources.list entry such as "deb http://archive.ubuntu.com/ubuntu
+	// xenial main".
+	RepositoryDeb RepositoryKind = "deb"
+
+	// RepositoryURL is a yum/dnf repository, given as the URL of either
+	// its base directory or of its .repo file.
+	RepositoryURL RepositoryKind = "url"
+)
+
+// ppaNameRE matches the valid names of Launchpad users and PPAs.
+var ppaNameRE = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]*$`)
+
+// debURISchemes are the URI schemes apt supports in sources.list entries.
+var debURISchemes = []string{"http", "https", "ftp", "file", "cdrom", "copy", "mirror", "tor+http", "tor+https"}
+
+// RepositorySpec is the parsed form of a repository, as it may be passed
+// to AddRepository.
+type RepositorySpec struct {
+	// Kind is the kind of the repository.
+	Kind RepositoryKind
+
+	// Owner is the Launchpad user or team owning a PPA.
+	Owner string
+
+	// Name is the name of a PPA.
+	Name string
+
+	// Type is either "deb" or "deb-src" for apt repositories.
+	Type string
+
+	// Options holds the options of an apt repository, such as
+	// "arch=amd64" or "signed-by=/etc/apt/keyrings/juju.gpg".
+	Options []string
+
+	// URI is the URI of an apt repository, or the URL of a yum/dnf one.
+	URI string
+
+	// Suite is the suite of an apt repository, such as "xenial-updates".
+	// Flat repositories have a suite ending with a "/".
+	Suite string
+
+	// Components holds the components of an apt repository, such as
+	// "main" or "universe". Flat repositories have none.
+	Components []string
+}
+
+// ParseRepositorySpec validates the given repository and returns its parsed,
+// normalized form. PPAs and one-line-style sources.list entries are
+// recognised by their prefixes, and anything else is expected to be the
+// URL of a yum/dnf repository.
+func ParseRepositorySpec(spec string) (RepositorySpec, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case strings.HasPrefix(spec, "ppa:"):
+		return parsePPASpec(spec)
+	case strings.HasPrefix(spec, "deb ") || strings.HasPrefix(spec, "deb-src "):
+		return parseDebSpec(spec)
+	default:
+		return parseURLSpec(spec)
+	}
+}
+
+// invalidSpec returns a NotValid error for the given repository
+// spec which gives the reason why it is invalid.
+func invalidSpec(spec, format string, args ...interface{}) error {
+	return errors.NewNotValid(nil, fmt.Sprintf("invalid repository %q: ", spec)+fmt.Sprintf(format, args...))
+}
+
+// parsePPASpec parses a "ppa:<owner>/<name>" repository. As with
+// add-apt-repository, the name defaults to "ppa" if it is omitted.
+func parsePPASpec(spec string) (RepositorySpec, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimPrefix(spec, "ppa:")), "/")
+	if len(parts) == 1 {
+		parts = append(parts, "ppa")
+	}
+	if len(parts) != 2 {
+		return RepositorySpec{}, invalidSpec(spec, "expected ppa:<owner>/<name>")
+	}
+	for _, part := range parts {
+		if !ppaNameRE.MatchString(part) {
+			return RepositorySpec{}, invalidSpec(spec, "%q is not a valid Launchpad name", part)
+		}
+	}
+
+	return RepositorySpec{
+		Kind:  RepositoryPPA,
+		Owner: parts[0],
+		Name:  parts[1],
+	}, nil
+}
+
+// parseDebSpec parses a one-line-style sources.list entry:
+// "deb [ option=value ... ] uri suite [component ...]".
+func parseDebSpec(spec string) (RepositorySpec, error) {
+	fields := strings.Fields(spec)
+	res := RepositorySpec{
+		Kind: RepositoryDeb,
+		Type: fields[0],
+	}
+	fields = fields[1:]
+
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "[") {
+		end := -1
+		for i, field := range fields {
+			if strings.HasSuffix(field, "]") {
+				end = i
+				break
+			}
+		}
+		if end == -1 {
+			return RepositorySpec{}, invalidSpec(spec, "unterminated options")
+		}
+		options := strings.Fields(strings.Trim(strings.Join(fields[:end+1], " "), "[]"))
+		for _, option := range options {
+			if !strings.Contains(option, "=") {
+				return RepositorySpec{}, invalidSpec(spec, "option %q is not of the form name=value", option)
+			}
+		}
+		res.Options = options
+		fields = fields[end+1:]
+	}
+
+	if len(fields) < 2 {
+		return RepositorySpec{}, invalidSpec(spec, "expected a URI and a suite")
+	}
+	uri, err := url.Parse(fields[0])
+	if err != nil || !isDebURIScheme(uri.Scheme) {
+		return RepositorySpec{}, invalidSpec(spec, "%q is not a valid repository URI", fields[0])
+	}
+	res.URI = strings.TrimSuffix(fields[0], "/")
+	res.Suite = fields[1]
+	res.Components = fields[2:]
+
+	flat := strings.HasSuffix(res.Suite, "/")
+	switch {
+	case flat && len(res.Components) > 0:
+		return RepositorySpec{}, invalidSpec(spec, "flat repositories have no components")
+	case !flat && len(res.Components) == 0:
+		return RepositorySpec{}, invalidSpec(spec, "expected at least one component")
+	}
+	if len(res.Components) == 0 {
+		res.Components = nil
+	}
+
+	return res, nil
+}
+
+// isDebURIScheme returns whether apt supports the given URI scheme.
+func isDebURIScheme(scheme string) bool {
+	for _, s := range debURISchemes {
+		if s == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// parseURLSpec parses the URL of a yum/dnf repository.
+func parseURLSpec(spec string) (RepositorySpec, error) {
+	if spec == "" {
+		return RepositorySpec{}, invalidSpec(spec, "empty repository")
+	}
+
+	u, err := url.Parse(spec)
+	if err != nil {
+		return RepositorySpec{}, invalidSpec(spec, "%v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "ftp":
+		if u.Host == "" {
+			return RepositorySpec{}, invalidSpec(spec, "missing host")
+		}
+	case "file":
+		if u.Path == "" {
+			return RepositorySpec{}, invalidSpec(spec, "missing path")
+		}
+	default:
+		return RepositorySpec{}, invalidSpec(spec, "expected a ppa:, deb or http(s), ftp or file URL")
+	}
+	u.Host = strings.ToLower(u.Host)
+
+	return RepositorySpec{
+		Kind: RepositoryURL,
+		URI:  u.String(),
+	}, nil
+}
+
+// String returns the normalized form of the repository, as accepted
+// by AddRepository.
+func (s RepositorySpec) String() string {
+	switch s.Kind {
+	case RepositoryPPA:
+		return fmt.Sprintf("ppa:%s/%s", s.Owner, s.Name)
+	case RepositoryDeb:
+		fields := []string{s.Type}
+		if len(s.Options) > 0 {
+			fields = append(fields, "["+strings.Join(s.Options, " ")+"]")
+		}
+		fields = append(fields, s.URI, s.Suite)
+		return strings.Join(append(fields, s.Components...), " ")
+	default:
+		return s.URI
+	}
+}
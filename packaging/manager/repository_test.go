@@ -0,0 +1,121 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager_test
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/packaging/manager"
+)
+
+var _ = gc.Suite(&RepositorySuite{})
+
+type RepositorySuite struct {
+	testing.IsolationSuite
+}
+
+func (s *RepositorySuite) TestParseRepositorySpec(c *gc.C) {
+	for i, test := range []struct {
+		spec       string
+		expected   manager.RepositorySpec
+		normalized string
+	}{{
+		spec: "ppa:juju/stable",
+		expected: manager.RepositorySpec{
+			Kind:  manager.RepositoryPPA,
+			Owner: "juju",
+			Name:  "stable",
+		},
+		normalized: "ppa:juju/stable",
+	}, {
+		spec: " ppa:Juju ",
+		expected: manager.RepositorySpec{
+			Kind:  manager.RepositoryPPA,
+			Owner: "juju",
+			Name:  "ppa",
+		},
+		normalized: "ppa:juju/ppa",
+	}, {
+		spec: "deb  http://archive.ubuntu.com/ubuntu/ xenial-updates main universe",
+		expected: manager.RepositorySpec{
+			Kind:       manager.RepositoryDeb,
+			Type:       "deb",
+			URI:        "http://archive.ubuntu.com/ubuntu",
+			Suite:      "xenial-updates",
+			Components: []string{"main", "universe"},
+		},
+		normalized: "deb http://archive.ubuntu.com/ubuntu xenial-updates main universe",
+	}, {
+		spec: "deb-src [ arch=amd64 signed-by=/etc/apt/keyrings/juju.gpg ] https://example.com/repo ./",
+		expected: manager.RepositorySpec{
+			Kind:    manager.RepositoryDeb,
+			Type:    "deb-src",
+			Options: []string{"arch=amd64", "signed-by=/etc/apt/keyrings/juju.gpg"},
+			URI:     "https://example.com/repo",
+			Suite:   "./",
+		},
+		normalized: "deb-src [arch=amd64 signed-by=/etc/apt/keyrings/juju.gpg] https://example.com/repo ./",
+	}, {
+		spec: "HTTPS://Mirror.CentOS.org/centos/7/os/x86_64/",
+		expected: manager.RepositorySpec{
+			Kind: manager.RepositoryURL,
+			URI:  "https://mirror.centos.org/centos/7/os/x86_64/",
+		},
+		normalized: "https://mirror.centos.org/centos/7/os/x86_64/",
+	}} {
+		c.Logf("test %d: %q", i, test.spec)
+		spec, err := manager.ParseRepositorySpec(test.spec)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(spec, jc.DeepEquals, test.expected)
+		c.Check(spec.String(), gc.Equals, test.normalized)
+	}
+}
+
+func (s *RepositorySuite) TestParseRepositorySpecInvalid(c *gc.C) {
+	for i, test := range []struct {
+		spec string
+		err  string
+	}{{
+		spec: "ppa:juju/stable/extra",
+		err:  `invalid repository "ppa:juju/stable/extra": expected ppa:<owner>/<name>`,
+	}, {
+		spec: "ppa:ju ju/stable",
+		err:  `invalid repository "ppa:ju ju/stable": "ju ju" is not a valid Launchpad name`,
+	}, {
+		spec: "deb [arch=amd64 http://example.com xenial main",
+		err:  `invalid repository .*: unterminated options`,
+	}, {
+		spec: "deb [trusted] http://example.com xenial main",
+		err:  `invalid repository .*: option "trusted" is not of the form name=value`,
+	}, {
+		spec: "deb http://example.com",
+		err:  `invalid repository .*: expected a URI and a suite`,
+	}, {
+		spec: "deb example.com xenial main",
+		err:  `invalid repository .*: "example.com" is not a valid repository URI`,
+	}, {
+		spec: "deb http://example.com xenial",
+		err:  `invalid repository .*: expected at least one component`,
+	}, {
+		spec: "deb http://example.com ./ main",
+		err:  `invalid repository .*: flat repositories have no components`,
+	}, {
+		spec: "",
+		err:  `invalid repository "": empty repository`,
+	}, {
+		spec: "http:///centos",
+		err:  `invalid repository .*: missing host`,
+	}, {
+		spec: "some-repo",
+		err:  `invalid repository .*: expected a ppa:, deb or http\(s\), ftp or file URL`,
+	}} {
+		c.Logf("test %d: %q", i, test.spec)
+		_, err := manager.ParseRepositorySpec(test.spec)
+		c.Check(err, gc.ErrorMatches, test.err)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+	}
+}
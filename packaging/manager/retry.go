@@ -0,0 +1,171 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/juju/utils/clock"
+	"github.com/juju/utils/retry"
+)
+
+// RetryStrategy configures how a package manager retries the packaging
+// commands which fail because of transient issues, such as another process
+// holding the package database lock or a momentary network failure.
+type RetryStrategy struct {
+	// Attempts is the maximum number of times a command is run.
+	Attempts int
+
+	// Backoff returns the delay to wait before the given retry, numbered
+	// from 1. No delay is waited for if it is nil.
+	Backoff func(retry int) time.Duration
+
+	// RetryableOutput holds the expressions which, when matched by the
+	// output of a failed command, make it be retried regardless of the
+	// exit code it returned.
+	RetryableOutput []*regexp.Regexp
+
+	// Clock is used for waiting between attempts. It defaults to
+	// clock.WallClock if nil.
+	Clock clock.Clock
+
+	// LockTimeout is how long commands which fail because another process
+	// holds the lock of the package management system are rerun for, every
+	// LockPollInterval, waiting for the lock to be released. These reruns
+	// do not count as attempts. Commands failing for the lock are only
+	// retried like other transient failures if it is zero.
+	LockTimeout time.Duration
+
+	// LockPollInterval is the delay between the reruns of a command
+	// waiting for the lock. It defaults to 5 seconds.
+	LockPollInterval time.Duration
+}
+
+// DefaultRetryStrategy returns the strategy used by package managers which
+// have not been given one. It is derived from AttemptStrategy, which makes
+// up to 30 attempts, 10 seconds apart, unless it was changed.
+func DefaultRetryStrategy() RetryStrategy {
+	attempts := AttemptStrategy.Min
+	if AttemptStrategy.Total > 0 && AttemptStrategy.Delay > 0 {
+		// AttemptStrategy keeps attempting until its total duration
+		// elapses, after making its minimum number of attempts.
+		if n := int(AttemptStrategy.Total/AttemptStrategy.Delay) + 1; n > attempts {
+			attempts = n
+		}
+	}
+	if attempts < 1 {
+		attempts = 1
+	}
+	return RetryStrategy{
+		Attempts: attempts,
+		Backoff:  ConstantBackoff(AttemptStrategy.Delay),
+		Clock:    clock.WallClock,
+	}
+}
+
+// ConstantBackoff returns a backoff function which always waits for the
+// given delay.
+func ConstantBackoff(delay time.Duration) func(int) time.Duration {
+	return func(int) time.Duration {
+		return delay
+	}
+}
+
+// ExponentialBackoff returns a backoff function which starts by waiting for
+// the given initial delay, doubling it on every retry up to the given maximum.
+func ExponentialBackoff(initial, max time.Duration) func(int) time.Duration {
+	backoff := retry.ExpBackoff(initial, max, 2, false)
+	return func(n int) time.Duration {
+		return backoff(initial, n)
+	}
+}
+
+// isRetryableOutput returns whether the given output of a failed
+// command matches any of the retryable output expressions.
+func (s *RetryStrategy) isRetryableOutput(out string) bool {
+	for _, re := range s.RetryableOutput {
+		if re.MatchString(out) {
+			return true
+		}
+	}
+	return false
+}
+
+// clock returns the clock of the strategy.
+func (s *RetryStrategy) clock() clock.Clock {
+	if s.Clock == nil {
+		return clock.WallClock
+	}
+	return s.Clock
+}
+
+// callArgs returns the arguments of the retry.Call which makes the
+// attempts of a command.
+func (s *RetryStrategy) callArgs() retry.CallArgs {
+	args := retry.CallArgs{
+		Attempts: s.Attempts,
+		Clock:    s.clock(),
+	}
+	if args.Attempts < 1 {
+		args.Attempts = 1
+	}
+	if s.Backoff != nil {
+		args.Delay = s.Backoff(1)
+		args.BackoffFunc = func(_ time.Duration, attempt int) time.Duration {
+			return s.Backoff(attempt)
+		}
+	}
+	return args
+}
+
+// waitForLock returns a function which makes the given attempt of a command,
+// rerunning it every poll interval while it fails, with the output returned
+// by the given function, because another process holds the lock. It stops
+// rerunning it once the lock timeout, which starts when the command first
+// fails for the lock, would expire, or the given context is canceled.
+func (s *RetryStrategy) waitForLock(ctx context.Context, attempt func() error, output func() string) func() error {
+	if s.LockTimeout <= 0 {
+		return attempt
+	}
+	clk := s.clock()
+	interval := s.LockPollInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	var deadline time.Time
+	return func() error {
+		var err error
+		retry.Call(ctx, retry.CallArgs{
+			Func: func() error {
+				err = attempt()
+				return err
+			},
+			// failures for any other reason, or past the
+			// deadline, are left to the retry strategy.
+			IsFatalError: func(error) bool {
+				if classifyOutput(output()) != ErrorLockHeld {
+					return true
+				}
+				if deadline.IsZero() {
+					deadline = clk.Now().Add(s.LockTimeout)
+				}
+				return clk.Now().Add(interval).After(deadline)
+			},
+			NotifyFunc: func(error, int) {
+				if holder := findLockHolder(output()); holder != nil {
+					logger.Infof("waiting for %v to release the lock", holder)
+				} else {
+					logger.Infof("waiting for the lock to be released")
+				}
+			},
+			Delay:       interval,
+			MaxDuration: s.LockTimeout,
+			Clock:       clk,
+		})
+		return err
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils"
+	"github.com/juju/utils/clock"
+	"github.com/juju/utils/packaging/manager"
+)
+
+var _ = gc.Suite(&RetrySuite{})
+
+type RetrySuite struct {
+	testing.IsolationSuite
+}
+
+func (s *RetrySuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.PatchValue(manager.LockPIDFiles, []string(nil))
+}
+
+// recordingClock is a clock.Clock which records the delays it is asked
+// to wait for without sleeping, advancing its time by them instead.
+type recordingClock struct {
+	now    time.Time
+	delays []time.Duration
+}
+
+func (r *recordingClock) Now() time.Time {
+	return r.now
+}
+
+func (r *recordingClock) After(d time.Duration) <-chan time.Time {
+	r.delays = append(r.delays, d)
+	r.now = r.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- r.now
+	return ch
+}
+
+func (*recordingClock) AfterFunc(d time.Duration, f func()) clock.Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (s *RetrySuite) patchFailingCommand(output string, code int) *int {
+	var calls int
+	state := os.ProcessState{}
+	s.PatchValue(&manager.ProcessStateSys, func(*os.ProcessState) interface{} {
+		return mockExitStatuser(code)
+	})
+	s.PatchValue(&manager.CommandOutput, func(cmd *exec.Cmd) ([]byte, error) {
+		calls++
+		return []byte(output), &exec.ExitError{ProcessState: &state}
+	})
+	return &calls
+}
+
+func (s *RetrySuite) TestDefaultRetryStrategy(c *gc.C) {
+	strategy := manager.DefaultRetryStrategy()
+	c.Check(strategy.Attempts, gc.Equals, 30)
+	c.Check(strategy.Backoff(1), gc.Equals, 10*time.Second)
+	c.Check(strategy.Clock, gc.Equals, clock.WallClock)
+}
+
+func (s *RetrySuite) TestDefaultRetryStrategyFollowsAttemptStrategy(c *gc.C) {
+	s.PatchValue(&manager.AttemptStrategy, utils.AttemptStrategy{Min: 3})
+	strategy := manager.DefaultRetryStrategy()
+	c.Check(strategy.Attempts, gc.Equals, 3)
+	c.Check(strategy.Backoff(1), gc.Equals, time.Duration(0))
+
+	s.PatchValue(&manager.AttemptStrategy, utils.AttemptStrategy{Total: time.Minute, Delay: 20 * time.Second})
+	strategy = manager.DefaultRetryStrategy()
+	c.Check(strategy.Attempts, gc.Equals, 4)
+	c.Check(strategy.Backoff(1), gc.Equals, 20*time.Second)
+}
+
+func (s *RetrySuite) TestContextCommandsFollowAttemptStrategy(c *gc.C) {
+	s.patchFailingCommand("", 100)
+	runner := &failingRunner{output: "E: Some index files failed to download."}
+	s.PatchValue(&manager.CommandRunner, utils.ContextCommandRunner(runner))
+	s.PatchValue(&manager.AttemptStrategy, utils.AttemptStrategy{Min: 3})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	err := manager.NewAptPackageManager().UpdateContext(ctx)
+	c.Assert(err, gc.NotNil)
+	c.Assert(runner.calls, gc.Equals, 3)
+	c.Assert(time.Since(start) < 5*time.Second, jc.IsTrue)
+}
+
+// failingRunner is a utils.ContextCommandRunner
+// whose commands all fail with the given output.
+type failingRunner struct {
+	output string
+	calls  int
+}
+
+func (r *failingRunner) RunCommand(ctx context.Context, args utils.RunCommandArgs) (*utils.CommandResult, error) {
+	r.calls++
+	return &utils.CommandResult{Stdout: []byte(r.output), ExitCode: 100}, &exec.ExitError{ProcessState: &os.ProcessState{}}
+}
+
+func (s *RetrySuite) TestExponentialBackoff(c *gc.C) {
+	backoff := manager.ExponentialBackoff(time.Second, 5*time.Second)
+
+	var delays []time.Duration
+	for retry := 1; retry <= 5; retry++ {
+		delays = append(delays, backoff(retry))
+	}
+	c.Assert(delays, jc.DeepEquals, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second,
+	})
+}
+
+func (s *RetrySuite) TestSetRetryStrategy(c *gc.C) {
+	calls := s.patchFailingCommand("E: Could not get lock /var/lib/dpkg/lock", 100)
+	clk := &recordingClock{}
+
+	apt := manager.NewAptPackageManager()
+	apt.SetRetryStrategy(manager.RetryStrategy{
+		Attempts: 4,
+		Backoff:  manager.ExponentialBackoff(time.Second, time.Minute),
+		Clock:    clk,
+	})
+
+	err := apt.Install(testedPackageName)
+	c.Check(err, gc.ErrorMatches, "packaging command failed: exit status.*")
+	c.Check(*calls, gc.Equals, 4)
+	c.Check(clk.delays, jc.DeepEquals, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second,
+	})
+}
+
+func (s *RetrySuite) TestRetryableOutput(c *gc.C) {
+	calls := s.patchFailingCommand("Error: rpmdb open failed", 1)
+	clk := &recordingClock{}
+
+	yum := manager.NewYumPackageManager()
+	strategy := manager.RetryStrategy{
+		Attempts: 3,
+		Backoff:  manager.ConstantBackoff(time.Second),
+		Clock:    clk,
+	}
+	yum.SetRetryStrategy(strategy)
+
+	// the exit code alone does not warrant retrying the command.
+	err := yum.Install(testedPackageName)
+	c.Check(err, gc.ErrorMatches, "packaging command failed: exit status.*")
+	c.Check(*calls, gc.Equals, 1)
+	c.Check(clk.delays, gc.HasLen, 0)
+
+	*calls = 0
+	strategy.RetryableOutput = []*regexp.Regexp{regexp.MustCompile("rpmdb open failed")}
+	yum.SetRetryStrategy(strategy)
+
+	err = yum.Install(testedPackageName)
+	c.Check(err, gc.ErrorMatches, "packaging command failed: exit status.*")
+	c.Check(*calls, gc.Equals, 3)
+	c.Check(clk.delays, jc.DeepEquals, []time.Duration{time.Second, time.Second})
+}
+
+func (s *RetrySuite) TestLockTimeout(c *gc.C) {
+	calls := s.patchFailingCommand("E: Could not get lock /var/lib/dpkg/lock-frontend. It is held by process 1234 (unattended-upgr)", 100)
+	clk := &recordingClock{}
+
+	apt := manager.NewAptPackageManager()
+	apt.SetRetryStrategy(manager.RetryStrategy{
+		Attempts:         2,
+		Backoff:          manager.ConstantBackoff(time.Second),
+		Clock:            clk,
+		LockTimeout:      10 * time.Second,
+		LockPollInterval: 2 * time.Second,
+	})
+
+	err := apt.Install(testedPackageName)
+	c.Check(err, gc.ErrorMatches, `packaging command failed: .*: lock held by process 1234 \(unattended-upgr\)`)
+	c.Check(manager.ErrorKindOf(err), gc.Equals, manager.ErrorLockHeld)
+	c.Check(errors.Cause(err).(*manager.Error).LockHolder, jc.DeepEquals, &manager.LockHolder{PID: 1234, Name: "unattended-upgr"})
+
+	// the command is rerun for the lock until it times out, and then
+	// retried once more as the second attempt.
+	c.Check(*calls, gc.Equals, 7)
+	c.Check(clk.delays, jc.DeepEquals, []time.Duration{
+		2 * time.Second, 2 * time.Second, 2 * time.Second, 2 * time.Second, 2 * time.Second,
+		time.Second,
+	})
+}
+
+func (s *RetrySuite) TestLockHolderFromPIDFile(c *gc.C) {
+	dir := c.MkDir()
+	pidFile := filepath.Join(dir, "yum.pid")
+	err := ioutil.WriteFile(pidFile, []byte("4321\n"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	err = os.MkdirAll(filepath.Join(dir, "proc", "4321"), 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	err = ioutil.WriteFile(filepath.Join(dir, "proc", "4321", "comm"), []byte("packagekitd\n"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	s.PatchValue(manager.LockPIDFiles, []string{filepath.Join(dir, "missing.pid"), pidFile})
+	s.PatchValue(manager.ProcDir, filepath.Join(dir, "proc"))
+
+	s.patchFailingCommand("Another app is currently holding the yum lock; waiting for it to exit...", 1)
+	yum := manager.NewYumPackageManager()
+	yum.SetRetryStrategy(manager.RetryStrategy{Attempts: 1})
+
+	err = yum.Install(testedPackageName)
+	c.Check(err, gc.ErrorMatches, `packaging command failed: .*: lock held by process 4321 \(packagekitd\)`)
+	c.Check(errors.Cause(err).(*manager.Error).LockHolder, jc.DeepEquals, &manager.LockHolder{PID: 4321, Name: "packagekitd"})
+}
@@ -0,0 +1,205 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils/proxy"
+)
+
+// SnapManager is the PackageManager for snaps, extended with the operations
+// which are specific to snaps.
+type SnapManager interface {
+	PackageManager
+
+	// InstallWithOptions installs the given snap(s) according to the
+	// given options. Options other than the zero value may only be
+	// used when installing a single snap.
+	InstallWithOptions(opts SnapOptions, snaps ...string) error
+
+	// Refresh refreshes the given snap(s), or all installed snaps if none
+	// are given, according to the given options. A Channel option switches
+	// the snap to tracking the given channel. As with InstallWithOptions,
+	// options other than the zero value may only be used when refreshing
+	// a single snap.
+	Refresh(opts SnapOptions, snaps ...string) error
+}
+
+// SnapOptions holds the snap-specific options of an installation.
+type SnapOptions struct {
+	// Channel is the channel the snap is installed from and which it
+	// tracks afterwards, e.g. "stable" or "2.0/edge".
+	Channel string
+
+	// Classic signals whether the snap should be installed with
+	// classic confinement.
+	Classic bool
+
+	// DevMode signals whether the snap should be installed in development
+	// mode, in which confinement violations are only logged. It cannot be
+	// combined with Classic.
+	DevMode bool
+
+	// Revision pins the snap to the given revision.
+	Revision string
+}
+
+// args returns the command line arguments for the options, checking that
+// they may be used for the given number of snaps.
+func (opts SnapOptions) args(snaps int) ([]string, error) {
+	if opts.Classic && opts.DevMode {
+		return nil, errors.NotValidf("both classic and devmode confinement")
+	}
+
+	var args []string
+	if opts.Channel != "" {
+		args = append(args, "--channel="+opts.Channel)
+	}
+	if opts.Classic {
+		args = append(args, "--classic")
+	}
+	if opts.DevMode {
+		args = append(args, "--devmode")
+	}
+	if opts.Revision != "" {
+		args = append(args, "--revision="+opts.Revision)
+	}
+
+	if len(args) > 0 && snaps != 1 {
+		return nil, errors.NotValidf("using options with %d snaps", snaps)
+	}
+	return args, nil
+}
+
+// snap is the PackageManager implementation for snapd.
+type snap struct {
+	basePackageManager
+}
+
+// Update is defined on the PackageManager interface.
+func (snap *snap) Update() error {
+	return snap.UpdateContext(context.Background())
+}
+
+// UpdateIfOlderThan is defined on the PackageManager interface.
+func (snap *snap) UpdateIfOlderThan(time.Duration) error {
+	return nil
+}
+
+// UpdateContext is defined on the PackageManager interface.
+func (snap *snap) UpdateContext(context.Context) error {
+	// snaps are always looked up in the store; there is no local list.
+	return nil
+}
+
+// Search is defined on the PackageManager interface.
+func (snap *snap) Search(pack string) (bool, error) {
+	_, code, err := snap.runCommand(snap.cmder.SearchCmd(pack), nil)
+
+	// snap info returns 1 when it cannot find the snap.
+	if code == 1 {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// InstallWithOptions is defined on the SnapManager interface.
+func (snap *snap) InstallWithOptions(opts SnapOptions, snaps ...string) error {
+	args, err := opts.args(len(snaps))
+	if err != nil {
+		return errors.Annotate(err, "installing snaps")
+	}
+
+	_, _, err = snap.runCommand(snap.cmder.InstallCmd(append(args, snaps...)...), nil)
+	return err
+}
+
+// Refresh is defined on the SnapManager interface.
+func (snap *snap) Refresh(opts SnapOptions, snaps ...string) error {
+	args, err := opts.args(len(snaps))
+	if err != nil {
+		return errors.Annotate(err, "refreshing snaps")
+	}
+
+	_, _, err = snap.runCommand(snap.cmder.UpgradeOnlyCmd(append(args, snaps...)...), nil)
+	return err
+}
+
+// ListInstalled is defined on the PackageManager interface.
+func (snap *snap) ListInstalled() ([]PackageInfo, error) {
+	out, _, err := snap.runCommand(snap.cmder.ListInstalledCmd(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// snap list outputs a table whose first two columns are the name
+	// and version of the snap, following a header line.
+	var res []PackageInfo
+	for i, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 2 {
+			continue
+		}
+		res = append(res, PackageInfo{Name: fields[0], Version: fields[1]})
+	}
+
+	return res, nil
+}
+
+// AddRepository is defined on the PackageManager interface.
+func (snap *snap) AddRepository(string) error {
+	return errors.NotSupportedf("adding repositories for snaps")
+}
+
+// RemoveRepository is defined on the PackageManager interface.
+func (snap *snap) RemoveRepository(string) error {
+	return errors.NotSupportedf("removing repositories for snaps")
+}
+
+// Cleanup is defined on the PackageManager interface.
+func (snap *snap) Cleanup() error {
+	// snapd garbage collects old revisions on its own.
+	return nil
+}
+
+// GetProxySettings is defined on the PackageManager interface.
+func (snap *snap) GetProxySettings() (proxy.Settings, error) {
+	var res proxy.Settings
+
+	args := strings.Fields(snap.cmder.GetProxyCmd())
+	out, err := snap.runCommandOnce(args...)
+
+	if err != nil {
+		logger.Errorf("command failed: %v\nargs: %#v\n%s",
+			err, args, string(out))
+		return res, fmt.Errorf("command failed: %v", err)
+	}
+
+	var conf struct {
+		Proxy struct {
+			Http  string `json:"http"`
+			Https string `json:"https"`
+			Ftp   string `json:"ftp"`
+		} `json:"proxy"`
+	}
+	if len(out) > 0 {
+		if err := json.Unmarshal(out, &conf); err != nil {
+			return res, errors.Annotate(err, "cannot parse snap proxy settings")
+		}
+	}
+
+	res.Http = conf.Proxy.Http
+	res.Https = conf.Proxy.Https
+	res.Ftp = conf.Proxy.Ftp
+
+	return res, nil
+}
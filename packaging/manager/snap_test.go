@@ -0,0 +1,157 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager_test
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils/packaging/manager"
+	"github.com/juju/utils/proxy"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&SnapSuite{})
+
+type SnapSuite struct {
+	testing.IsolationSuite
+	pacman        manager.SnapManager
+	calledCommand string
+}
+
+func (s *SnapSuite) SetUpSuite(c *gc.C) {
+	s.IsolationSuite.SetUpSuite(c)
+	s.pacman = manager.NewSnapPackageManager()
+}
+
+func (s *SnapSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.calledCommand = ""
+	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&s.calledCommand))
+}
+
+func (s *SnapSuite) TestInstall(c *gc.C) {
+	err := s.pacman.Install(testedPackageNames...)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, "snap install "+strings.Join(testedPackageNames, " "))
+}
+
+func (s *SnapSuite) TestInstallWithOptions(c *gc.C) {
+	opts := manager.SnapOptions{
+		Channel:  "2.0/edge",
+		Classic:  true,
+		Revision: "42",
+	}
+	err := s.pacman.InstallWithOptions(opts, testedPackageName)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, "snap install --channel=2.0/edge --classic --revision=42 "+testedPackageName)
+}
+
+func (s *SnapSuite) TestInstallWithOptionsMultipleSnaps(c *gc.C) {
+	err := s.pacman.InstallWithOptions(manager.SnapOptions{Classic: true}, testedPackageNames...)
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(s.calledCommand, gc.Equals, "")
+}
+
+func (s *SnapSuite) TestInstallWithOptionsDevMode(c *gc.C) {
+	err := s.pacman.InstallWithOptions(manager.SnapOptions{DevMode: true}, testedPackageName)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, "snap install --devmode "+testedPackageName)
+}
+
+func (s *SnapSuite) TestInstallWithOptionsConflictingConfinement(c *gc.C) {
+	err := s.pacman.InstallWithOptions(manager.SnapOptions{Classic: true, DevMode: true}, testedPackageName)
+	c.Assert(err, gc.ErrorMatches, "installing snaps: both classic and devmode confinement not valid")
+	c.Assert(errors.Cause(err), jc.Satisfies, errors.IsNotValid)
+	c.Assert(s.calledCommand, gc.Equals, "")
+}
+
+func (s *SnapSuite) TestRefresh(c *gc.C) {
+	err := s.pacman.Refresh(manager.SnapOptions{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, "snap refresh")
+
+	err = s.pacman.Refresh(manager.SnapOptions{}, testedPackageNames...)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, "snap refresh "+strings.Join(testedPackageNames, " "))
+}
+
+func (s *SnapSuite) TestRefreshSwitchingChannel(c *gc.C) {
+	err := s.pacman.Refresh(manager.SnapOptions{Channel: "2.1/stable"}, testedPackageName)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, "snap refresh --channel=2.1/stable "+testedPackageName)
+}
+
+func (s *SnapSuite) TestRefreshWithOptionsMultipleSnaps(c *gc.C) {
+	err := s.pacman.Refresh(manager.SnapOptions{Channel: "edge"})
+	c.Assert(err, gc.ErrorMatches, "refreshing snaps: using options with 0 snaps not valid")
+	c.Assert(s.calledCommand, gc.Equals, "")
+}
+
+func (s *SnapSuite) TestUpdateAndCleanupAreNoops(c *gc.C) {
+	c.Assert(s.pacman.Update(), jc.ErrorIsNil)
+	c.Assert(s.pacman.Cleanup(), jc.ErrorIsNil)
+	c.Assert(s.calledCommand, gc.Equals, "")
+}
+
+func (s *SnapSuite) TestRepositoriesNotSupported(c *gc.C) {
+	err := s.pacman.AddRepository(testedRepoName)
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	err = s.pacman.RemoveRepository(testedRepoName)
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *SnapSuite) TestSearchNotFound(c *gc.C) {
+	s.PatchValue(&manager.RunCommandWithRetry, func(string, func(string) error) (string, int, error) {
+		return "", 1, errors.New("packaging command failed: exit status 1")
+	})
+
+	found, err := s.pacman.Search(testedPackageName)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(found, jc.IsFalse)
+}
+
+func (s *SnapSuite) TestSetProxy(c *gc.C) {
+	var cmds []string
+	s.PatchValue(&manager.CommandOutput, func(cmd *exec.Cmd) ([]byte, error) {
+		cmds = append(cmds, strings.Join(cmd.Args, " "))
+		return nil, nil
+	})
+
+	err := s.pacman.SetProxy(proxy.Settings{Http: "http://some-proxy.domain"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmds, gc.DeepEquals, []string{"snap set system proxy.http=http://some-proxy.domain"})
+}
+
+func (s *SnapSuite) TestGetProxySettings(c *gc.C) {
+	const output = `{
+	"proxy": {
+		"http": "10.0.3.1:3142",
+		"https": "https://some-proxy.domain"
+	}
+}`
+	cmdChan := s.HookCommandOutput(&manager.CommandOutput, []byte(output), nil)
+
+	out, err := s.pacman.GetProxySettings()
+	c.Assert(err, jc.ErrorIsNil)
+
+	cmd := <-cmdChan
+	c.Assert(cmd.Args, gc.DeepEquals, []string{"snap", "get", "-d", "system", "proxy"})
+	c.Assert(out, gc.Equals, proxy.Settings{
+		Http:  "10.0.3.1:3142",
+		Https: "https://some-proxy.domain",
+	})
+}
+
+func (s *SnapSuite) TestGetProxySettingsError(c *gc.C) {
+	state := os.ProcessState{}
+	s.HookCommandOutput(&manager.CommandOutput, []byte("error: snap not found"), &exec.ExitError{ProcessState: &state})
+
+	_, err := s.pacman.GetProxySettings()
+	c.Assert(err, gc.ErrorMatches, "command failed: .*")
+}
@@ -6,7 +6,13 @@
 // interface which always returns positive outcomes and a nil error.
 package testing
 
-import "github.com/juju/utils/proxy"
+import (
+	"context"
+	"time"
+
+	"github.com/juju/utils/packaging/manager"
+	"github.com/juju/utils/proxy"
+)
 
 // MockPackageManager is a struct which always returns a positive outcome,
 // constant ProxySettings and a nil error.
@@ -24,36 +30,171 @@ func (pm *MockPackageManager) Update() error {
 	return nil
 }
 
+// UpdateContext is defined on the PackageManager interface.
+func (pm *MockPackageManager) UpdateContext(context.Context) error {
+	return nil
+}
+
 // Upgrade is defined on the PackageManager interface.
 func (pm *MockPackageManager) Upgrade() error {
 	return nil
 }
 
+// UpdateIfOlderThan is defined on the PackageManager interface.
+func (pm *MockPackageManager) UpdateIfOlderThan(time.Duration) error {
+	return nil
+}
+
+// UpgradeContext is defined on the PackageManager interface.
+func (pm *MockPackageManager) UpgradeContext(context.Context) error {
+	return nil
+}
+
+// UpgradeOnly is defined on the PackageManager interface.
+func (pm *MockPackageManager) UpgradeOnly(...string) error {
+	return nil
+}
+
 // Install is defined on the PackageManager interface.
 func (pm *MockPackageManager) Install(...string) error {
 	return nil
 }
 
+// InstallContext is defined on the PackageManager interface.
+func (pm *MockPackageManager) InstallContext(context.Context, ...string) error {
+	return nil
+}
+
+// InstallVersion is defined on the PackageManager interface.
+func (pm *MockPackageManager) InstallVersion(string, string) error {
+	return nil
+}
+
+// Downgrade is defined on the PackageManager interface.
+func (pm *MockPackageManager) Downgrade(string, string) error {
+	return nil
+}
+
+// Rollback is defined on the PackageManager interface.
+func (pm *MockPackageManager) Rollback(string) error {
+	return nil
+}
+
+// InstallLocal is defined on the PackageManager interface.
+func (pm *MockPackageManager) InstallLocal(...string) error {
+	return nil
+}
+
 // Remove is defined on the PackageManager interface.
 func (pm *MockPackageManager) Remove(...string) error {
 	return nil
 }
 
+// RemoveContext is defined on the PackageManager interface.
+func (pm *MockPackageManager) RemoveContext(context.Context, ...string) error {
+	return nil
+}
+
 // Purge is defined on the PackageManager interface.
 func (pm *MockPackageManager) Purge(...string) error {
 	return nil
 }
 
+// PurgeContext is defined on the PackageManager interface.
+func (pm *MockPackageManager) PurgeContext(context.Context, ...string) error {
+	return nil
+}
+
+// Fetch is defined on the PackageManager interface.
+func (pm *MockPackageManager) Fetch(string, ...string) error {
+	return nil
+}
+
+// FetchWithDependencies is defined on the PackageManager interface.
+func (pm *MockPackageManager) FetchWithDependencies(string, ...string) error {
+	return nil
+}
+
+// Hold is defined on the PackageManager interface.
+func (pm *MockPackageManager) Hold(...string) error {
+	return nil
+}
+
+// Unhold is defined on the PackageManager interface.
+func (pm *MockPackageManager) Unhold(...string) error {
+	return nil
+}
+
+// SimulateInstall is defined on the PackageManager interface.
+func (pm *MockPackageManager) SimulateInstall(...string) (*manager.Transaction, error) {
+	return &manager.Transaction{}, nil
+}
+
+// SimulateRemove is defined on the PackageManager interface.
+func (pm *MockPackageManager) SimulateRemove(...string) (*manager.Transaction, error) {
+	return &manager.Transaction{}, nil
+}
+
+// SimulateUpgrade is defined on the PackageManager interface.
+func (pm *MockPackageManager) SimulateUpgrade() (*manager.Transaction, error) {
+	return &manager.Transaction{}, nil
+}
+
 // Search is defined on the PackageManager interface.
 func (pm *MockPackageManager) Search(string) (bool, error) {
 	return true, nil
 }
 
+// SearchPackages is defined on the PackageManager interface.
+func (pm *MockPackageManager) SearchPackages(string) ([]manager.PackageInfo, error) {
+	return nil, nil
+}
+
+// AvailableVersions is defined on the PackageManager interface.
+func (pm *MockPackageManager) AvailableVersions(string) ([]manager.PackageVersion, error) {
+	return nil, nil
+}
+
+// CandidateVersion is defined on the PackageManager interface.
+func (pm *MockPackageManager) CandidateVersion(string) (string, error) {
+	return "", nil
+}
+
+// Depends is defined on the PackageManager interface.
+func (pm *MockPackageManager) Depends(pack string) (manager.DependencyGraph, error) {
+	return manager.DependencyGraph{pack: nil}, nil
+}
+
+// RDepends is defined on the PackageManager interface.
+func (pm *MockPackageManager) RDepends(pack string) (manager.DependencyGraph, error) {
+	return manager.DependencyGraph{pack: nil}, nil
+}
+
+// History is defined on the PackageManager interface.
+func (pm *MockPackageManager) History() ([]manager.HistoryEntry, error) {
+	return nil, nil
+}
+
+// OwnerOf is defined on the PackageManager interface.
+func (pm *MockPackageManager) OwnerOf(string) (string, error) {
+	return "", nil
+}
+
+// Verify is defined on the PackageManager interface.
+func (pm *MockPackageManager) Verify(...string) ([]manager.VerifyResult, error) {
+	return nil, nil
+}
+
 // IsInstalled is defined on the PackageManager interface.
 func (pm *MockPackageManager) IsInstalled(string) bool {
 	return true
 }
 
+// ListInstalled is defined on the PackageManager interface.
+func (pm *MockPackageManager) ListInstalled() ([]manager.PackageInfo, error) {
+	return nil, nil
+}
+
 // AddRepository is defined on the PackageManager interface.
 func (pm *MockPackageManager) AddRepository(string) error {
 	return nil
@@ -64,6 +205,16 @@ func (pm *MockPackageManager) RemoveRepository(string) error {
 	return nil
 }
 
+// AddRepositoryKey is defined on the PackageManager interface.
+func (pm *MockPackageManager) AddRepositoryKey(string, manager.RepositoryKey) error {
+	return nil
+}
+
+// RemoveRepositoryKey is defined on the PackageManager interface.
+func (pm *MockPackageManager) RemoveRepositoryKey(string) error {
+	return nil
+}
+
 // Cleanup is defined on the PackageManager interface.
 func (pm *MockPackageManager) Cleanup() error {
 	return nil
@@ -74,6 +225,35 @@ func (pm *MockPackageManager) GetProxySettings() (proxy.Settings, error) {
 	return proxy.Settings{"http proxy", "https proxy", "ftp proxy", "no proxy"}, nil
 }
 
+// AutoRemove is defined on the PackageManager interface.
+func (pm *MockPackageManager) AutoRemove() error {
+	return nil
+}
+
+// SetInstallRecommends is defined on the PackageManager interface.
+func (pm *MockPackageManager) SetInstallRecommends(bool) {
+}
+
+// SetAutoRemoveOnCleanup is defined on the PackageManager interface.
+func (pm *MockPackageManager) SetAutoRemoveOnCleanup(bool) {
+}
+
+// SetEnvironment is defined on the PackageManager interface.
+func (pm *MockPackageManager) SetEnvironment([]string) {
+}
+
+// SetOutputCallback is defined on the PackageManager interface.
+func (pm *MockPackageManager) SetOutputCallback(manager.OutputFunc) {
+}
+
+// SetProgressCallback is defined on the PackageManager interface.
+func (pm *MockPackageManager) SetProgressCallback(manager.ProgressFunc) {
+}
+
+// SetRetryStrategy is defined on the PackageManager interface.
+func (pm *MockPackageManager) SetRetryStrategy(manager.RetryStrategy) {
+}
+
 // SetProxy is defined on the PackageManager interface.
 func (pm *MockPackageManager) SetProxy(proxy.Settings) error {
 	return nil
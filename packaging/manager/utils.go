@@ -5,9 +5,12 @@
 package manager
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -15,6 +18,9 @@ import (
 	"github.com/juju/loggo"
 
 	"github.com/juju/utils"
+	"github.com/juju/utils/packaging/commands"
+	"github.com/juju/utils/proxy"
+	"github.com/juju/utils/retry"
 )
 
 var (
@@ -26,14 +32,284 @@ var (
 	}
 )
 
-// CommandOutput is cmd.Output. It was aliased for testing purposes.
-var CommandOutput = (*exec.Cmd).CombinedOutput
+// CommandOutput runs the given command with CommandRunner, in its working
+// directory and environment and with its standard input, and returns its
+// combined output. It was aliased for testing purposes.
+var CommandOutput = func(cmd *exec.Cmd) ([]byte, error) {
+	return runContext(context.Background(), cmd, nil)
+}
 
 // processStateSys is ps.Sys. It was aliased for testing purposes.
 var ProcessStateSys = (*os.ProcessState).Sys
 
-// RunCommand is utils.RunCommand. It was aliased for testing purposes.
-var RunCommand = utils.RunCommand
+// CommandRunner is utils.DefaultCommandRunner. It was aliased for testing
+// purposes.
+var CommandRunner = utils.DefaultCommandRunner
+
+// DetectProxies is proxy.DetectProxies. It was aliased for testing purposes.
+var DetectProxies = proxy.DetectProxies
+
+// cacheUpdatedWithin returns whether the local package lists were updated
+// less than the given duration ago, according to the modification times of
+// the files matching the given patterns. The patterns are tried in order
+// and the first one matching any file is used; as each file it matches may
+// stand for a distinct repository, the oldest of them decides.
+func cacheUpdatedWithin(patterns []string, d time.Duration) bool {
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil || len(paths) == 0 {
+			continue
+		}
+
+		var oldest time.Time
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return false
+			}
+			if oldest.IsZero() || info.ModTime().Before(oldest) {
+				oldest = info.ModTime()
+			}
+		}
+		return time.Since(oldest) < d
+	}
+	return false
+}
+
+// retryableExitCodes maps package management binaries to the exit codes
+// they return on transient failures which warrant retrying the command.
+var retryableExitCodes = map[string][]int{
+	// zypper returns 7 when another process holds the package database lock.
+	"zypper": []int{zypperExitZyppLocked},
+}
+
+// isRetryableExitCode returns whether the given exit code of the given
+// binary denotes a transient failure.
+func isRetryableExitCode(binary string, code int) bool {
+	codes, ok := retryableExitCodes[binary]
+	if !ok {
+		// Both apt-get and yum return 100 on abnormal execution due to
+		// outside issues (ex: momentary dns failure).
+		return code == 100
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// versionNotAvailableMessages are the messages the supported package
+// management systems output when a requested version of a package cannot
+// be found in the configured repositories.
+var versionNotAvailableMessages = []string{
+	"was not found",                // apt-get: Version '1.0' for 'pkg' was not found
+	"No package",                   // yum: No package pkg-1.0 available.
+	"No provider of",               // zypper: No provider of 'pkg=1.0' found.
+	"unable to select packages",    // apk
+	"No available formula",         // brew
+	"not found with the source(s)", // choco
+}
+
+// isVersionNotAvailable returns whether the given output of a versioned
+// installation reports that the requested version is not available.
+func isVersionNotAvailable(output string) bool {
+	for _, msg := range versionNotAvailableMessages {
+		if strings.Contains(output, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// versionNotAvailableFatalError is the getFatalError function for
+// RunCommandWithRetry which stops retrying an installation of a version
+// which is not available.
+func versionNotAvailableFatalError(output string) error {
+	if isVersionNotAvailable(output) {
+		return errors.New("version not available")
+	}
+	return nil
+}
+
+// installVersionCmd validates the given package and version and returns the
+// command of the given PackageCommander which installs them.
+func installVersionCmd(cmder commands.PackageCommander, pack, version string) (string, error) {
+	if err := validatePackageVersion(pack, version); err != nil {
+		return "", err
+	}
+
+	cmd := cmder.InstallVersionCmd(pack, version)
+	if cmd == "" {
+		return "", errors.NotSupportedf("installing a specific version of a package")
+	}
+	return cmd, nil
+}
+
+// downgradeCmd validates the given package and version and returns the
+// command of the given PackageCommander which downgrades to them.
+func downgradeCmd(cmder commands.PackageCommander, pack, version string) (string, error) {
+	if err := validatePackageVersion(pack, version); err != nil {
+		return "", err
+	}
+
+	cmd := cmder.DowngradeCmd(pack, version)
+	if cmd == "" {
+		return "", errors.NotSupportedf("downgrading a package")
+	}
+	return cmd, nil
+}
+
+// validatePackageVersion returns a NotValid error if either the given
+// package name or version is empty or contains whitespace.
+func validatePackageVersion(pack, version string) error {
+	if pack == "" || strings.ContainsAny(pack, " \t\n") {
+		return errors.NotValidf("package name %q", pack)
+	}
+	if version == "" || strings.ContainsAny(version, " \t\n") {
+		return errors.NotValidf("version %q of package %q", version, pack)
+	}
+	return nil
+}
+
+// rollbackCmd validates the given transaction and returns the command
+// of the given PackageCommander which reverts it.
+func rollbackCmd(cmder commands.PackageCommander, transaction string) (string, error) {
+	if transaction == "" || strings.ContainsAny(transaction, " \t\n") {
+		return "", errors.NotValidf("transaction %q", transaction)
+	}
+
+	cmd := cmder.RollbackCmd(transaction)
+	if cmd == "" {
+		return "", errors.NotSupportedf("rolling back transactions")
+	}
+	return cmd, nil
+}
+
+// installLocalCmd validates the given package files and returns the command
+// of the given PackageCommander which installs them. The paths are made
+// absolute, which apt-get requires to tell them apart from package names.
+func installLocalCmd(cmder commands.PackageCommander, paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", errors.NotValidf("empty list of package files")
+	}
+
+	var abs []string
+	for _, path := range paths {
+		if path == "" || strings.ContainsAny(path, " \t\n") {
+			return "", errors.NotValidf("package file %q", path)
+		}
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return "", errors.NotFoundf("package file %q", path)
+		} else if err != nil {
+			return "", errors.Trace(err)
+		}
+		abs = append(abs, path)
+	}
+
+	cmd := cmder.InstallLocalCmd(abs...)
+	if cmd == "" {
+		return "", errors.NotSupportedf("installing package files")
+	}
+	return cmd, nil
+}
+
+// upgradeOnlyCmd returns the command which upgrades only the given packages.
+// As the upgrade commands of most package managers upgrade all packages when
+// given none, an empty list of packages is rejected.
+func upgradeOnlyCmd(cmder commands.PackageCommander, packs []string) (string, error) {
+	if len(packs) == 0 {
+		return "", errors.NotValidf("empty list of packages")
+	}
+
+	cmd := cmder.UpgradeOnlyCmd(packs...)
+	if cmd == "" {
+		return "", errors.NotSupportedf("upgrading specific packages")
+	}
+	return cmd, nil
+}
+
+// versionNotAvailableError returns a *VersionNotAvailableError if the given
+// error and output of an installation of the given version of a package
+// report the version as unavailable, and the given error otherwise.
+func versionNotAvailableError(pack, version, output string, err error) error {
+	if err != nil && isVersionNotAvailable(output) {
+		return &VersionNotAvailableError{Package: pack, Version: version}
+	}
+	return err
+}
+
+// parseInstalledPackages is a helper function which extracts the installed
+// packages from the given output of "<name> <version> [<architecture>]" lines.
+func parseInstalledPackages(output string) []PackageInfo {
+	var res []PackageInfo
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		info := PackageInfo{Name: fields[0], Version: fields[1]}
+		if len(fields) > 2 {
+			info.Architecture = fields[2]
+		}
+		res = append(res, info)
+	}
+
+	return res
+}
+
+// splitNameVersion is a helper function which splits the given
+// "<name>-<version>" string at the first dash which is followed by a digit.
+func splitNameVersion(s string) (string, string) {
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] == '-' && s[i+1] >= '0' && s[i+1] <= '9' {
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+// parseProxySettings is a helper function which extracts the proxy settings
+// from the given output of "[export ]<protocol>_proxy=<value>" lines.
+func parseProxySettings(output string) proxy.Settings {
+	var res proxy.Settings
+
+	for _, match := range strings.Split(output, "\n") {
+		fields := strings.Split(match, "=")
+		if len(fields) != 2 {
+			continue
+		}
+
+		key := strings.TrimPrefix(strings.TrimSpace(fields[0]), "export ")
+		if strings.HasPrefix(key, "https") {
+			res.Https = strings.TrimSpace(fields[1])
+		} else if strings.HasPrefix(key, "http") {
+			res.Http = strings.TrimSpace(fields[1])
+		} else if strings.HasPrefix(key, "ftp") {
+			res.Ftp = strings.TrimSpace(fields[1])
+		}
+	}
+
+	return res
+}
+
+// appendUnique appends the given string to the given slice
+// unless the slice already contains it.
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
 
 // exitStatuser is a mini-interface for the ExitStatus() method.
 type exitStatuser interface {
@@ -41,11 +317,21 @@ type exitStatuser interface {
 }
 
 // RunCommandWithRetry is a helper function which tries to execute the given command.
-// It tries to do so for 30 times with a 10 second sleep between commands.
+// It tries to do so for 30 times with a 10 second sleep between commands; package
+// managers which were given a RetryStrategy use theirs instead.
 // It returns the output of the command, the exit code, and an error, if one occurs,
-// logging along the way.
+// logging along the way. The output is returned even if the command failed, in
+// which case the error is an *Error.
 // It was aliased for testing purposes.
 var RunCommandWithRetry = func(cmd string, getFatalError func(string) error) (output string, code int, err error) {
+	return runCommandWithRetry(context.Background(), cmd, getFatalError, CommandOutput, nil)
+}
+
+// runCommandWithRetry implements RunCommandWithRetry, running each attempt
+// of the command with the given function, which returns its combined output.
+// The command is retried according to the given strategy or, if it is nil,
+// to DefaultRetryStrategy, until the given context is canceled.
+func runCommandWithRetry(ctx context.Context, cmd string, getFatalError func(string) error, run func(*exec.Cmd) ([]byte, error), strategy *RetryStrategy) (output string, code int, err error) {
 	var out []byte
 
 	// split the command for use with exec
@@ -56,53 +342,126 @@ var RunCommandWithRetry = func(cmd string, getFatalError func(string) error) (ou
 
 	logger.Infof("Running: %s", cmd)
 
-	// Retry operation 30 times, sleeping every 10 seconds between attempts.
-	// This avoids failure in the case of something else having the dpkg lock
-	// (e.g. a charm on the machine we're deploying containers to).
-	for a := AttemptStrategy.Start(); a.Next(); {
-		// Create the command for each attempt, because we need to
-		// call cmd.CombinedOutput only once. See http://pad.lv/1394524.
-		cmd := exec.Command(args[0], args[1:]...)
+	// Retry the operation, by default 30 times, sleeping 10 seconds between
+	// attempts. This avoids failure in the case of something else having the
+	// dpkg lock (e.g. a charm on the machine we're deploying containers to).
+	if strategy == nil {
+		defaults := DefaultRetryStrategy()
+		strategy = &defaults
+	}
 
-		out, err = CommandOutput(cmd)
+	// fatal records whether the last attempt failed in a way
+	// which retrying the command cannot fix.
+	fatal := false
+	attempt := func() error {
+		fatal = true
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
+		// Create the command for each attempt, because we need to
+		// call cmd.CombinedOutput only once. See http://pad.lv/1394524.
+		out, err = run(exec.Command(args[0], args[1:]...))
 		if err == nil {
-			return string(out), 0, nil
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
 		}
 
 		exitError, ok := err.(*exec.ExitError)
 		if !ok {
-			err = errors.Annotatef(err, "unexpected error type %T", err)
-			break
+			return errors.Annotatef(err, "unexpected error type %T", err)
 		}
 		waitStatus, ok := ProcessStateSys(exitError.ProcessState).(exitStatuser)
 		if !ok {
-			err = errors.Annotatef(err, "unexpected process state type %T", exitError.ProcessState.Sys())
-			break
+			return errors.Annotatef(err, "unexpected process state type %T", exitError.ProcessState.Sys())
 		}
 
-		// Both apt-get and yum return 100 on abnormal execution due to outside
-		// issues (ex: momentary dns failure).
 		code = waitStatus.ExitStatus()
-		if code != 100 {
-			break
+		if !isRetryableExitCode(args[0], code) && !strategy.isRetryableOutput(string(out)) {
+			return err
 		}
 
 		if getFatalError != nil {
 			if fatalErr := getFatalError(string(out)); fatalErr != nil {
-				err = errors.Annotatef(fatalErr, "encountered fatal error")
-				break
+				return errors.Annotatef(fatalErr, "encountered fatal error")
 			}
 		}
 
+		fatal = false
+		return err
+	}
+
+	callArgs := strategy.callArgs()
+	callArgs.Func = strategy.waitForLock(ctx, func() error {
+		err = attempt()
+		return err
+	}, func() string {
+		return string(out)
+	})
+	callArgs.IsFatalError = func(error) bool {
+		return fatal
+	}
+	callArgs.NotifyFunc = func(error, int) {
 		logger.Infof("Retrying: %s", cmd)
 	}
+	// the error of the last attempt is reported, rather than the
+	// wrapping one of retry.Call.
+	retry.Call(ctx, callArgs)
 
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		// the context may have been canceled while waiting between attempts.
+		err = ctxErr
+	}
 	if err != nil {
 		logger.Errorf("packaging command failed: %v; cmd: %q; output: %s",
 			err, cmd, string(out))
-		return "", code, errors.Errorf("packaging command failed: %v", err)
+		kind := classifyOutput(string(out))
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			kind = ErrorCanceled
+		}
+		var holder *LockHolder
+		if kind == ErrorLockHeld {
+			holder = findLockHolder(string(out))
+		}
+		return string(out), code, &Error{
+			Command:    cmd,
+			ExitCode:   code,
+			Output:     string(out),
+			Kind:       kind,
+			LockHolder: holder,
+			err:        err,
+		}
 	}
 
 	return string(out), 0, nil
 }
+
+// outputContext returns a function which runs commands like CommandOutput,
+// killing them along with the processes they spawned when the given context
+// is canceled.
+func outputContext(ctx context.Context) func(*exec.Cmd) ([]byte, error) {
+	return func(cmd *exec.Cmd) ([]byte, error) {
+		return runContext(ctx, cmd, nil)
+	}
+}
+
+// runContext runs the given command with CommandRunner until it completes
+// or the given context is canceled, and returns its combined output, which
+// is also written to the given writer, if any.
+func runContext(ctx context.Context, cmd *exec.Cmd, w io.Writer) ([]byte, error) {
+	result, err := CommandRunner.RunCommand(ctx, utils.RunCommandArgs{
+		Command:        cmd.Args[0],
+		Args:           cmd.Args[1:],
+		Dir:            cmd.Dir,
+		Env:            cmd.Env,
+		Stdin:          cmd.Stdin,
+		Stdout:         w,
+		CombinedOutput: true,
+	})
+	if result == nil {
+		return nil, err
+	}
+	return result.Stdout, err
+}
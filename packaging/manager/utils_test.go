@@ -5,11 +5,17 @@
 package manager_test
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"runtime"
+	"strings"
+	"time"
 
+	"github.com/juju/errors"
 	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
 	"github.com/juju/utils"
 	"github.com/juju/utils/packaging/manager"
 	gc "gopkg.in/check.v1"
@@ -103,3 +109,126 @@ func (s *UtilsSuite) TestRunCommandWithRetryStopsWithFatalError(c *gc.C) {
 	c.Check(err, gc.ErrorMatches, "packaging command failed: encountered fatal error: unable to locate package")
 	c.Check(calls, gc.Equals, 1)
 }
+
+func (s *UtilsSuite) TestRunCommandWithRetryClassifiesErrors(c *gc.C) {
+	state := os.ProcessState{}
+	cmdError := &exec.ExitError{ProcessState: &state}
+	s.PatchValue(&manager.ProcessStateSys, func(*os.ProcessState) interface{} {
+		return mockExitStatuser(1) // never retried.
+	})
+
+	for i, test := range []struct {
+		output string
+		kind   manager.ErrorKind
+	}{{
+		output: "E: Could not get lock /var/lib/dpkg/lock - open (11: Resource temporarily unavailable)",
+		kind:   manager.ErrorLockHeld,
+	}, {
+		output: "W: Failed to fetch http://archive.ubuntu.com/ubuntu/dists/xenial/InRelease  Temporary failure resolving 'archive.ubuntu.com'",
+		kind:   manager.ErrorNetwork,
+	}, {
+		output: "E: Unable to locate package no-such-package",
+		kind:   manager.ErrorUnknownPackage,
+	}, {
+		output: "E: Failed to fetch http://archive.ubuntu.com/ubuntu/pool/main/j/juju.deb  Hash Sum mismatch",
+		kind:   manager.ErrorHashSumMismatch,
+	}, {
+		output: "E: I done failed :(",
+		kind:   manager.ErrorFatal,
+	}} {
+		c.Logf("test %d: %s", i, test.kind)
+		s.HookCommandOutput(&manager.CommandOutput, []byte(test.output), cmdError)
+
+		out, code, err := manager.RunCommandWithRetry("apt-get install juju", nil)
+		c.Check(out, gc.Equals, test.output)
+		c.Check(code, gc.Equals, 1)
+		c.Check(err, gc.ErrorMatches, "packaging command failed: exit status.*")
+		c.Check(manager.ErrorKindOf(err), gc.Equals, test.kind)
+		perr, ok := errors.Cause(err).(*manager.Error)
+		c.Assert(ok, gc.Equals, true)
+		c.Check(perr.Command, gc.Equals, "apt-get install juju")
+		c.Check(perr.ExitCode, gc.Equals, 1)
+		c.Check(perr.Output, gc.Equals, test.output)
+	}
+}
+
+func (s *UtilsSuite) TestInstallContextCanceled(c *gc.C) {
+	s.PatchValue(&manager.CommandOutput, func(cmd *exec.Cmd) ([]byte, error) {
+		c.Fatalf("command run despite the context being canceled")
+		return nil, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	apt := manager.NewAptPackageManager()
+	err := apt.InstallContext(ctx, testedPackageName)
+	c.Check(err, gc.ErrorMatches, "packaging command failed: context canceled")
+	c.Check(manager.ErrorKindOf(err), gc.Equals, manager.ErrorCanceled)
+}
+
+func (s *UtilsSuite) TestOutputContextKillsCommand(c *gc.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("no sleep command on windows")
+	}
+	// the shell spawns sleep, which would keep the output of the
+	// command open if only the shell itself was killed.
+	cmd := exec.Command("/bin/sh", "-c", "/bin/sleep 60; true")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := manager.OutputContext(ctx)(cmd)
+	c.Check(err, gc.Equals, context.DeadlineExceeded)
+	c.Check(time.Since(start) < 10*time.Second, jc.IsTrue)
+}
+
+// recordingRunner is a utils.ContextCommandRunner which records the
+// commands it is asked to run instead of running them.
+type recordingRunner struct {
+	args []utils.RunCommandArgs
+}
+
+func (r *recordingRunner) RunCommand(ctx context.Context, args utils.RunCommandArgs) (*utils.CommandResult, error) {
+	r.args = append(r.args, args)
+	return &utils.CommandResult{Stdout: []byte("output")}, nil
+}
+
+func (s *UtilsSuite) TestOutputContextUsesCommandRunner(c *gc.C) {
+	runner := &recordingRunner{}
+	s.PatchValue(&manager.CommandRunner, utils.ContextCommandRunner(runner))
+
+	cmd := exec.Command("apt-get", "--assume-yes", "update")
+	cmd.Env = []string{"DEBIAN_FRONTEND=noninteractive"}
+	out, err := manager.OutputContext(context.Background())(cmd)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(out), gc.Equals, "output")
+	c.Assert(runner.args, jc.DeepEquals, []utils.RunCommandArgs{{
+		Command:        "apt-get",
+		Args:           []string{"--assume-yes", "update"},
+		Env:            []string{"DEBIAN_FRONTEND=noninteractive"},
+		CombinedOutput: true,
+	}})
+}
+
+func (s *UtilsSuite) TestCommandsUseCommandRunner(c *gc.C) {
+	runner := &recordingRunner{}
+	s.PatchValue(&manager.CommandRunner, utils.ContextCommandRunner(runner))
+
+	apt := manager.NewAptPackageManager()
+	apt.SetEnvironment([]string{"DEBIAN_FRONTEND=noninteractive"})
+	err := apt.Install(testedPackageName)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(apt.IsInstalled(testedPackageName), jc.IsTrue)
+
+	c.Assert(runner.args, gc.HasLen, 2)
+	for i, cmd := range []string{
+		aptCmder.InstallCmd(testedPackageName),
+		aptCmder.IsInstalledCmd(testedPackageName),
+	} {
+		args := strings.Fields(cmd)
+		c.Check(runner.args[i].Command, gc.Equals, args[0])
+		c.Check(runner.args[i].Args, jc.DeepEquals, args[1:])
+		c.Check(runner.args[i].Env, jc.DeepEquals, []string{"DEBIAN_FRONTEND=noninteractive"})
+		c.Check(runner.args[i].CombinedOutput, jc.IsTrue)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+var (
+	// debsumsFileRE matches the files debsums reports as failing the
+	// check, capturing whether they were changed or are missing, their
+	// path and their package, such as:
+	//
+	//	debsums: changed file /usr/bin/juju (from juju package)
+	debsumsFileRE = regexp.MustCompile(`^debsums: (changed|missing) file (.+) \(from (\S+) package\)$`)
+
+	// rpmVerifyFileRE matches the files rpm reports as failing the check,
+	// capturing either their failed attributes or "missing", and their
+	// path, such as:
+	//
+	//	S.5....T.  c /etc/juju.conf
+	rpmVerifyFileRE = regexp.MustCompile(`^(missing|[.?SM5DLUGTP]{8,9})\s+(?:[cdglr]\s+)?(/.*)$`)
+
+	// notInstalledRE matches the packages debsums and
+	// rpm report as not being installed.
+	notInstalledRE = regexp.MustCompile(`package (\S+) is not installed`)
+)
+
+// Verify is defined on the PackageManager interface.
+func (apt *apt) Verify(packs ...string) ([]VerifyResult, error) {
+	out, _, err := apt.runCommand(apt.cmder.VerifyCmd(packs...), nil)
+	return verifyResults(parseDebsums(out), out, err)
+}
+
+// Verify is defined on the PackageManager interface.
+func (yum *yum) Verify(packs ...string) ([]VerifyResult, error) {
+	return yum.verifyRPM(packs)
+}
+
+// Verify is defined on the PackageManager interface.
+func (zypper *zypper) Verify(packs ...string) ([]VerifyResult, error) {
+	return zypper.verifyRPM(packs)
+}
+
+// verifyRPM verifies the given packages with rpm. As rpm does not report
+// the packages of the files failing the check, the packages are verified
+// one at a time.
+func (pm *basePackageManager) verifyRPM(packs []string) ([]VerifyResult, error) {
+	if len(packs) == 0 {
+		out, _, err := pm.runCommand(pm.cmder.VerifyCmd(), nil)
+		return verifyResults(parseRPMVerify("", out), out, err)
+	}
+
+	var res []VerifyResult
+	for _, pack := range packs {
+		out, _, err := pm.runCommand(pm.cmder.VerifyCmd(pack), nil)
+		results, err := verifyResults(parseRPMVerify(pack, out), out, err)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, results...)
+	}
+	return res, nil
+}
+
+// verifyResults returns the given results of a verification command, which
+// returned the given output and error. Both debsums and rpm fail when they
+// find any file failing the check, so the error is only returned when no
+// such file was found.
+func verifyResults(results []VerifyResult, out string, err error) ([]VerifyResult, error) {
+	if len(results) > 0 {
+		return results, nil
+	}
+	if m := notInstalledRE.FindStringSubmatch(out); m != nil {
+		return nil, errors.NotFoundf("package %q", m[1])
+	}
+	return nil, err
+}
+
+// parseDebsums parses the output of debsums --silent.
+func parseDebsums(out string) []VerifyResult {
+	var res []VerifyResult
+	for _, line := range strings.Split(out, "\n") {
+		m := debsumsFileRE.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		res = append(res, VerifyResult{
+			Package:  m[3],
+			Path:     m[2],
+			Missing:  m[1] == "missing",
+			Modified: m[1] == "changed",
+		})
+	}
+	return res
+}
+
+// parseRPMVerify parses the output of rpm --verify for the given package.
+// Files whose only failed attributes do not denote tampering, such as
+// their modification time, are left out.
+func parseRPMVerify(pack, out string) []VerifyResult {
+	var res []VerifyResult
+	for _, line := range strings.Split(out, "\n") {
+		m := rpmVerifyFileRE.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+
+		result := VerifyResult{
+			Package:            pack,
+			Path:               m[2],
+			Missing:            m[1] == "missing",
+			Modified:           strings.ContainsAny(m[1], "S5L"),
+			PermissionsChanged: strings.ContainsAny(m[1], "MUG"),
+		}
+		if result.Missing || result.Modified || result.PermissionsChanged {
+			res = append(res, result)
+		}
+	}
+	return res
+}
@@ -6,20 +6,183 @@ package manager
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/juju/errors"
+
+	"github.com/juju/utils"
+	"github.com/juju/utils/packaging/commands"
 	"github.com/juju/utils/proxy"
 )
 
+// yumKeyfileDir is the directory repository keys are installed into.
+// It is a variable for testing purposes.
+var yumKeyfileDir = commands.CentOSYumKeyfileDir
+
+// yumCacheStamps are the files whose modification time tells when the
+// repository metadata was last updated: the marker of dnf's makecache,
+// the solv files dnf regenerates along with the metadata, and the cookies
+// yum leaves in the cache of every repository.
+// It is a variable for testing purposes.
+var yumCacheStamps = []string{
+	"/var/cache/dnf/last_makecache",
+	"/var/cache/dnf/*.solv",
+	"/var/cache/yum/*/*/*/cachecookie",
+}
+
 // yum is the PackageManager implementations for rpm-based systems.
 type yum struct {
 	basePackageManager
 }
 
+// UpdateIfOlderThan is defined on the PackageManager interface.
+func (yum *yum) UpdateIfOlderThan(d time.Duration) error {
+	if cacheUpdatedWithin(yumCacheStamps, d) {
+		return nil
+	}
+	return yum.Update()
+}
+
+// AvailableVersions is defined on the PackageManager interface.
+func (yum *yum) AvailableVersions(pack string) ([]PackageVersion, error) {
+	out, _, err := yum.runCommand(yum.cmder.ListVersionsCmd(pack), nil)
+	if err != nil {
+		if strings.Contains(out, "No matching Packages") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseYumVersions(out), nil
+}
+
+// CandidateVersion is defined on the PackageManager interface.
+func (yum *yum) CandidateVersion(pack string) (string, error) {
+	versions, err := yum.AvailableVersions(pack)
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", errors.NotFoundf("candidate version of package %q", pack)
+	}
+
+	// yum lists the versions in ascending order.
+	return versions[len(versions)-1].Version, nil
+}
+
+// parseYumVersions parses the output of yum --showduplicates list, which
+// lists the installed and available versions of a package under respective
+// headings as "<name>.<arch> <version> <repository>" lines. yum wraps these
+// lines when the name is too long. Installed versions are reported with the
+// repository they were installed from, prefixed with an "@".
+func parseYumVersions(out string) []PackageVersion {
+	var versions []PackageVersion
+
+	var inList, installed bool
+	var fields []string
+	for _, line := range strings.Split(out, "\n") {
+		switch strings.TrimSpace(line) {
+		case "Installed Packages":
+			inList, installed, fields = true, true, nil
+			continue
+		case "Available Packages":
+			inList, installed, fields = true, false, nil
+			continue
+		}
+		if !inList {
+			continue
+		}
+
+		fields = append(fields, strings.Fields(line)...)
+		if len(fields) < 3 {
+			continue
+		}
+		version, repo := fields[1], strings.TrimPrefix(fields[2], "@")
+		fields = nil
+
+		i := 0
+		for i < len(versions) && versions[i].Version != version {
+			i++
+		}
+		if i == len(versions) {
+			versions = append(versions, PackageVersion{Version: version})
+		}
+		versions[i].Installed = versions[i].Installed || installed
+		versions[i].Repositories = appendUnique(versions[i].Repositories, repo)
+	}
+
+	return versions
+}
+
+// SimulateInstall is defined on the PackageManager interface.
+func (yum *yum) SimulateInstall(packs ...string) (*Transaction, error) {
+	return yum.simulate(yum.cmder.SimulateInstallCmd(packs...))
+}
+
+// SimulateRemove is defined on the PackageManager interface.
+func (yum *yum) SimulateRemove(packs ...string) (*Transaction, error) {
+	return yum.simulate(yum.cmder.SimulateRemoveCmd(packs...))
+}
+
+// SimulateUpgrade is defined on the PackageManager interface.
+func (yum *yum) SimulateUpgrade() (*Transaction, error) {
+	return yum.simulate(yum.cmder.SimulateUpgradeCmd())
+}
+
+// simulate runs the given simulated yum command and
+// parses the changes it reports into a Transaction.
+func (yum *yum) simulate(cmd string) (*Transaction, error) {
+	out, _, err := yum.runCommand(cmd, nil)
+
+	// yum --assumeno fails after declining to run the transaction.
+	if err != nil && !strings.Contains(out, "Exiting on user command") {
+		return nil, err
+	}
+
+	// the planned transaction is output as a table of packages, which is
+	// divided into sections such as "Installing:" or "Updating for dependencies:".
+	var res Transaction
+	var section *[]PackageInfo
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == "" {
+			section = nil
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			switch strings.TrimSuffix(strings.TrimSuffix(line, ":"), " for dependencies") {
+			case "Installing":
+				section = &res.Install
+			case "Updating", "Upgrading":
+				section = &res.Upgrade
+			case "Removing", "Erasing":
+				section = &res.Remove
+			default:
+				section = nil
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if section == nil || len(fields) < 3 {
+			continue
+		}
+		*section = append(*section, PackageInfo{
+			Name:         fields[0],
+			Architecture: fields[1],
+			Version:      fields[2],
+		})
+	}
+
+	return &res, nil
+}
+
 // Search is defined on the PackageManager interface.
 func (yum *yum) Search(pack string) (bool, error) {
-	_, code, err := RunCommandWithRetry(yum.cmder.SearchCmd(pack), nil)
+	_, code, err := yum.runCommand(yum.cmder.SearchCmd(pack), nil)
 
 	// yum list package returns 1 when it cannot find the package.
 	if code == 1 {
@@ -38,29 +201,100 @@ func (yum *yum) GetProxySettings() (proxy.Settings, error) {
 		return proxy.Settings{}, fmt.Errorf("expected at least 2 arguments, got %d %v", len(args), args)
 	}
 
-	cmd := exec.Command(args[0], args[1:]...)
-	out, err := CommandOutput(cmd)
+	out, err := yum.runCommandOnce(args...)
 
-	if err != nil {
+	// grep exits with 1 when no proxy has been configured, and with 2 when
+	// either configuration file is missing; what it output still holds.
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
 		logger.Errorf("command failed: %v\nargs: %#v\n%s",
 			err, args, string(out))
 		return res, fmt.Errorf("command failed: %v", err)
 	}
 
-	for _, match := range strings.Split(string(out), "\n") {
-		fields := strings.Split(match, "=")
-		if len(fields) != 2 {
+	return parseYumProxySettings(string(out)), nil
+}
+
+// parseYumProxySettings parses the proxy settings of yum and dnf configuration
+// files. Besides the settings which SetProxy writes, operators may have set
+// the native proxy option, which applies to both http and https repositories.
+func parseYumProxySettings(output string) proxy.Settings {
+	res := parseProxySettings(output)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, "=", 2)
+		if len(fields) != 2 || strings.TrimSpace(fields[0]) != "proxy" {
 			continue
 		}
+		// "_none_" explicitly disables the proxy.
+		value := strings.TrimSpace(fields[1])
+		if value == "" || value == "_none_" {
+			continue
+		}
+		if res.Http == "" {
+			res.Http = value
+		}
+		if res.Https == "" {
+			res.Https = value
+		}
+	}
+	return res
+}
+
+// AddRepositoryKey is defined on the PackageManager interface.
+// The key is installed as the keyfile <CentOSYumKeyfileDir>/RPM-GPG-KEY-<name>,
+// which the repositories it signs may reference via their gpgkey option,
+// and imported into the rpm database.
+func (yum *yum) AddRepositoryKey(name string, key RepositoryKey) error {
+	if err := validateKeyName(name); err != nil {
+		return errors.Trace(err)
+	}
+	armored, _, err := readRepositoryKey(key)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if err := os.MkdirAll(yumKeyfileDir, 0755); err != nil {
+		return errors.Trace(err)
+	}
+	path := filepath.Join(yumKeyfileDir, "RPM-GPG-KEY-"+name)
+	if err := utils.AtomicWriteFile(path, []byte(armored), 0644); err != nil {
+		return errors.Trace(err)
+	}
+
+	out, err := yum.runCommandOnce("rpm", "--import", path)
+	if err != nil {
+		logger.Errorf("command failed: %v\nargs: %#v\n%s", err, path, out)
+		return fmt.Errorf("command failed: %v", err)
+	}
+	return nil
+}
+
+// RemoveRepositoryKey is defined on the PackageManager interface.
+func (yum *yum) RemoveRepositoryKey(name string) error {
+	if err := validateKeyName(name); err != nil {
+		return errors.Trace(err)
+	}
+	path := filepath.Join(yumKeyfileDir, "RPM-GPG-KEY-"+name)
+	armored, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return errors.NotFoundf("repository key %q", name)
+	} else if err != nil {
+		return errors.Trace(err)
+	}
+	_, entities, err := readRepositoryKey(RepositoryKey{Armored: string(armored)})
+	if err != nil {
+		return errors.Trace(err)
+	}
 
-		if strings.HasPrefix(fields[0], "https") {
-			res.Https = strings.TrimSpace(fields[1])
-		} else if strings.HasPrefix(fields[0], "http") {
-			res.Http = strings.TrimSpace(fields[1])
-		} else if strings.HasPrefix(fields[0], "ftp") {
-			res.Ftp = strings.TrimSpace(fields[1])
+	// rpm records imported keys as gpg-pubkey packages,
+	// whose version is the short ID of the key.
+	for _, entity := range entities {
+		pack := fmt.Sprintf("gpg-pubkey-%08x", uint32(entity.PrimaryKey.KeyId))
+		out, err := yum.runCommandOnce("rpm", "--erase", "--allmatches", pack)
+		if err != nil {
+			logger.Errorf("command failed: %v\nargs: %#v\n%s", err, pack, out)
+			return fmt.Errorf("command failed: %v", err)
 		}
 	}
 
-	return res, nil
+	return errors.Trace(os.Remove(path))
 }
@@ -0,0 +1,82 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/juju/utils/proxy"
+)
+
+const (
+	// zypperExitZyppLocked is the exit code zypper returns when the package
+	// database is locked by another process.
+	zypperExitZyppLocked = 7
+
+	// zypperExitInfCapNotFound is the exit code zypper returns when a
+	// requested package or capability could not be found.
+	zypperExitInfCapNotFound = 104
+)
+
+// zypper is the PackageManager implementation for SUSE-based systems.
+type zypper struct {
+	basePackageManager
+}
+
+// Search is defined on the PackageManager interface.
+func (zypper *zypper) Search(pack string) (bool, error) {
+	_, code, err := zypper.runCommand(zypper.cmder.SearchCmd(pack), nil)
+
+	// zypper search returns 104 when it cannot find the package.
+	if code == zypperExitInfCapNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// GetProxySettings is defined on the PackageManager interface.
+func (zypper *zypper) GetProxySettings() (proxy.Settings, error) {
+	var res proxy.Settings
+
+	args := strings.Fields(zypper.cmder.GetProxyCmd())
+	if len(args) <= 1 {
+		return proxy.Settings{}, fmt.Errorf("expected at least 2 arguments, got %d %v", len(args), args)
+	}
+
+	out, err := zypper.runCommandOnce(args...)
+
+	if err != nil {
+		// grep exits with 1 when no proxy has been configured.
+		if len(out) == 0 {
+			return res, nil
+		}
+		logger.Errorf("command failed: %v\nargs: %#v\n%s",
+			err, args, string(out))
+		return res, fmt.Errorf("command failed: %v", err)
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.SplitN(line, "=", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		key := strings.ToUpper(strings.TrimSpace(fields[0]))
+		value := strings.Trim(strings.TrimSpace(fields[1]), `"'`)
+		switch key {
+		case "HTTP_PROXY":
+			res.Http = value
+		case "HTTPS_PROXY":
+			res.Https = value
+		case "FTP_PROXY":
+			res.Ftp = value
+		case "NO_PROXY":
+			res.NoProxy = value
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package manager_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils"
+	"github.com/juju/utils/packaging/commands"
+	"github.com/juju/utils/packaging/manager"
+	"github.com/juju/utils/proxy"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&ZypperSuite{})
+
+type ZypperSuite struct {
+	testing.IsolationSuite
+	paccmder commands.PackageCommander
+	pacman   manager.PackageManager
+}
+
+func (s *ZypperSuite) SetUpSuite(c *gc.C) {
+	s.IsolationSuite.SetUpSuite(c)
+	s.paccmder = commands.NewZypperPackageCommander()
+	s.pacman = manager.NewZypperPackageManager()
+}
+
+func (s *ZypperSuite) TestNewPackageManager(c *gc.C) {
+	pacman, err := manager.NewPackageManager("opensuseleap")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(pacman, gc.FitsTypeOf, s.pacman)
+}
+
+func (s *ZypperSuite) TestInstallPrerequisiteIsNoop(c *gc.C) {
+	var calledCommand string
+	s.PatchValue(&manager.RunCommandWithRetry, getMockRunCommandWithRetry(&calledCommand))
+
+	err := s.pacman.InstallPrerequisite()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(calledCommand, gc.Equals, "")
+}
+
+func (s *ZypperSuite) TestSearchNotFound(c *gc.C) {
+	state := os.ProcessState{}
+	cmdError := &exec.ExitError{ProcessState: &state}
+	s.PatchValue(&manager.ProcessStateSys, func(*os.ProcessState) interface{} {
+		return mockExitStatuser(104)
+	})
+	cmdChan := s.HookCommandOutput(&manager.CommandOutput, []byte("No matching items found."), cmdError)
+
+	found, err := s.pacman.Search(testedPackageName)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(found, jc.IsFalse)
+
+	cmd := <-cmdChan
+	c.Assert(strings.Join(cmd.Args, " "), gc.Equals, s.paccmder.SearchCmd(testedPackageName))
+}
+
+func (s *ZypperSuite) TestGetProxySettingsEmpty(c *gc.C) {
+	cmdChan := s.HookCommandOutput(&manager.CommandOutput, []byte{}, nil)
+
+	out, err := s.pacman.GetProxySettings()
+	c.Assert(err, jc.ErrorIsNil)
+
+	cmd := <-cmdChan
+	c.Assert(cmd.Args, gc.DeepEquals, strings.Fields(s.paccmder.GetProxyCmd()))
+	c.Assert(out, gc.Equals, proxy.Settings{})
+}
+
+func (s *ZypperSuite) TestGetProxySettingsConfigured(c *gc.C) {
+	const expected = `HTTP_PROXY="10.0.3.1:3142"
+HTTPS_PROXY="false"
+FTP_PROXY=""
+GOPHER_PROXY=""
+NO_PROXY="localhost, 127.0.0.1"`
+	cmdChan := s.HookCommandOutput(&manager.CommandOutput, []byte(expected), nil)
+
+	out, err := s.pacman.GetProxySettings()
+	c.Assert(err, jc.ErrorIsNil)
+
+	cmd := <-cmdChan
+	c.Assert(cmd.Args, gc.DeepEquals, strings.Fields(s.paccmder.GetProxyCmd()))
+
+	c.Assert(out, gc.Equals, proxy.Settings{
+		Http:    "10.0.3.1:3142",
+		Https:   "false",
+		NoProxy: "localhost, 127.0.0.1",
+	})
+}
+
+func (s *ZypperSuite) TestProxySettingsRoundTrip(c *gc.C) {
+	initial := proxy.Settings{
+		Http:  "some-proxy.local:8080",
+		Https: "some-secure-proxy.local:9696",
+		Ftp:   "some-ftp-proxy.local:1212",
+	}
+
+	expected := s.paccmder.ProxyConfigContents(initial)
+	cmdChan := s.HookCommandOutput(&manager.CommandOutput, []byte(expected), nil)
+
+	result, err := s.pacman.GetProxySettings()
+	c.Assert(err, jc.ErrorIsNil)
+	<-cmdChan
+
+	c.Assert(result, gc.Equals, initial)
+}
+
+func (s *ZypperSuite) TestSetProxyEnablesProxy(c *gc.C) {
+	path := filepath.Join(c.MkDir(), "proxy")
+	err := ioutil.WriteFile(path, []byte(`PROXY_ENABLED="no"
+HTTP_PROXY=""
+NO_PROXY="localhost, 127.0.0.1"
+`), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	s.PatchValue(manager.ZypperProxyConfig, path)
+
+	err = s.pacman.SetProxy(proxy.Settings{Http: "some-proxy.local:8080"})
+	c.Assert(err, jc.ErrorIsNil)
+	contents, err := ioutil.ReadFile(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(contents), gc.Equals, `NO_PROXY="localhost, 127.0.0.1"
+HTTP_PROXY="some-proxy.local:8080"
+PROXY_ENABLED="yes"
+`)
+}
+
+func (s *ZypperSuite) TestRunCommandWithRetryRetriesWhenLocked(c *gc.C) {
+	const minRetries = 3
+	var calls int
+	state := os.ProcessState{}
+	cmdError := &exec.ExitError{ProcessState: &state}
+	s.PatchValue(&manager.AttemptStrategy, utils.AttemptStrategy{Min: minRetries})
+	s.PatchValue(&manager.ProcessStateSys, func(*os.ProcessState) interface{} {
+		return mockExitStatuser(7) // zypp is locked; retry each time.
+	})
+	s.PatchValue(&manager.CommandOutput, func(cmd *exec.Cmd) ([]byte, error) {
+		calls++
+		return []byte("System management is locked by the application with pid 1234 (zypper)."), cmdError
+	})
+
+	err := s.pacman.Install(testedPackageName)
+	c.Check(err, gc.ErrorMatches, "packaging command failed: exit status.*")
+	c.Check(calls, gc.Equals, minRetries)
+}
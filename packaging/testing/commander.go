@@ -0,0 +1,222 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package testing
+
+import (
+	jujutesting "github.com/juju/testing"
+
+	"github.com/juju/utils/proxy"
+)
+
+// StubPackageCommander is a commands.PackageCommander which records the
+// calls made on it on its Stub, with their variadic arguments recorded as
+// a single slice, and returns the commands of ReturnCmds.
+type StubPackageCommander struct {
+	Stub *jujutesting.Stub
+
+	// ReturnCmds maps the names of the methods to the commands they
+	// return. The methods missing from it return an empty command, as
+	// for operations which are not supported.
+	ReturnCmds map[string]string
+
+	ReturnSetProxyCmds []string
+}
+
+// NewStubPackageCommander returns a StubPackageCommander recording
+// its calls on the given Stub.
+func NewStubPackageCommander(stub *jujutesting.Stub) *StubPackageCommander {
+	return &StubPackageCommander{Stub: stub}
+}
+
+// cmd records the call of the given method and returns its command.
+func (s *StubPackageCommander) cmd(funcName string, args ...interface{}) string {
+	s.Stub.AddCall(funcName, args...)
+	return s.ReturnCmds[funcName]
+}
+
+// InstallPrerequisiteCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) InstallPrerequisiteCmd() string {
+	return s.cmd("InstallPrerequisiteCmd")
+}
+
+// UpdateCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) UpdateCmd() string {
+	return s.cmd("UpdateCmd")
+}
+
+// UpgradeCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) UpgradeCmd() string {
+	return s.cmd("UpgradeCmd")
+}
+
+// UpgradeOnlyCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) UpgradeOnlyCmd(packs ...string) string {
+	return s.cmd("UpgradeOnlyCmd", packs)
+}
+
+// InstallCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) InstallCmd(packs ...string) string {
+	return s.cmd("InstallCmd", packs)
+}
+
+// InstallNoRecommendsCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) InstallNoRecommendsCmd(packs ...string) string {
+	return s.cmd("InstallNoRecommendsCmd", packs)
+}
+
+// InstallVersionCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) InstallVersionCmd(pack, version string) string {
+	return s.cmd("InstallVersionCmd", pack, version)
+}
+
+// DowngradeCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) DowngradeCmd(pack, version string) string {
+	return s.cmd("DowngradeCmd", pack, version)
+}
+
+// RollbackCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) RollbackCmd(transaction string) string {
+	return s.cmd("RollbackCmd", transaction)
+}
+
+// InstallLocalCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) InstallLocalCmd(paths ...string) string {
+	return s.cmd("InstallLocalCmd", paths)
+}
+
+// RemoveCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) RemoveCmd(packs ...string) string {
+	return s.cmd("RemoveCmd", packs)
+}
+
+// PurgeCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) PurgeCmd(packs ...string) string {
+	return s.cmd("PurgeCmd", packs)
+}
+
+// FetchCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) FetchCmd(dir string, packs ...string) string {
+	return s.cmd("FetchCmd", dir, packs)
+}
+
+// FetchWithDependenciesCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) FetchWithDependenciesCmd(dir string, packs ...string) string {
+	return s.cmd("FetchWithDependenciesCmd", dir, packs)
+}
+
+// HoldCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) HoldCmd(packs ...string) string {
+	return s.cmd("HoldCmd", packs)
+}
+
+// UnholdCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) UnholdCmd(packs ...string) string {
+	return s.cmd("UnholdCmd", packs)
+}
+
+// SimulateInstallCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) SimulateInstallCmd(packs ...string) string {
+	return s.cmd("SimulateInstallCmd", packs)
+}
+
+// SimulateRemoveCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) SimulateRemoveCmd(packs ...string) string {
+	return s.cmd("SimulateRemoveCmd", packs)
+}
+
+// SimulateUpgradeCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) SimulateUpgradeCmd() string {
+	return s.cmd("SimulateUpgradeCmd")
+}
+
+// VerifyCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) VerifyCmd(packs ...string) string {
+	return s.cmd("VerifyCmd", packs)
+}
+
+// OwnerCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) OwnerCmd(path string) string {
+	return s.cmd("OwnerCmd", path)
+}
+
+// IsInstalledCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) IsInstalledCmd(pack string) string {
+	return s.cmd("IsInstalledCmd", pack)
+}
+
+// SearchCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) SearchCmd(pack string) string {
+	return s.cmd("SearchCmd", pack)
+}
+
+// SearchPackagesCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) SearchPackagesCmd(term string) string {
+	return s.cmd("SearchPackagesCmd", term)
+}
+
+// DependsCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) DependsCmd(pack string) string {
+	return s.cmd("DependsCmd", pack)
+}
+
+// RDependsCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) RDependsCmd(pack string) string {
+	return s.cmd("RDependsCmd", pack)
+}
+
+// ListVersionsCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) ListVersionsCmd(pack string) string {
+	return s.cmd("ListVersionsCmd", pack)
+}
+
+// ListAvailableCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) ListAvailableCmd() string {
+	return s.cmd("ListAvailableCmd")
+}
+
+// ListInstalledCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) ListInstalledCmd() string {
+	return s.cmd("ListInstalledCmd")
+}
+
+// ListRepositoriesCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) ListRepositoriesCmd() string {
+	return s.cmd("ListRepositoriesCmd")
+}
+
+// AddRepositoryCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) AddRepositoryCmd(repo string) string {
+	return s.cmd("AddRepositoryCmd", repo)
+}
+
+// RemoveRepositoryCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) RemoveRepositoryCmd(repo string) string {
+	return s.cmd("RemoveRepositoryCmd", repo)
+}
+
+// CleanupCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) CleanupCmd() string {
+	return s.cmd("CleanupCmd")
+}
+
+// AutoRemoveCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) AutoRemoveCmd() string {
+	return s.cmd("AutoRemoveCmd")
+}
+
+// GetProxyCmd is defined on the PackageCommander interface.
+func (s *StubPackageCommander) GetProxyCmd() string {
+	return s.cmd("GetProxyCmd")
+}
+
+// ProxyConfigContents is defined on the PackageCommander interface.
+func (s *StubPackageCommander) ProxyConfigContents(settings proxy.Settings) string {
+	return s.cmd("ProxyConfigContents", settings)
+}
+
+// SetProxyCmds is defined on the PackageCommander interface.
+func (s *StubPackageCommander) SetProxyCmds(settings proxy.Settings) []string {
+	s.Stub.AddCall("SetProxyCmds", settings)
+	return s.ReturnSetProxyCmds
+}
@@ -0,0 +1,378 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package testing contains stub implementations of the packaging
+// interfaces, which record the calls made on them and return scripted
+// results, so that code driving package management can be tested
+// without running any packaging commands.
+package testing
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	jujutesting "github.com/juju/testing"
+
+	"github.com/juju/utils/packaging/manager"
+	"github.com/juju/utils/proxy"
+)
+
+// StubPackageManager is a manager.PackageManager which records the calls
+// made on it on its Stub. The methods returning an error pop the next one
+// off the Stub, so failures are scripted with Stub.SetErrors, and return
+// the value of the matching Return field otherwise.
+//
+// The variadic arguments of the methods are recorded as a single slice,
+// and their contexts are not recorded at all.
+type StubPackageManager struct {
+	Stub *jujutesting.Stub
+
+	// Delay is the time the methods returning an error block for before
+	// returning, simulating slow packaging commands. The *Context methods
+	// return early if their context is done.
+	Delay time.Duration
+
+	ReturnSimulateInstall   *manager.Transaction
+	ReturnSimulateRemove    *manager.Transaction
+	ReturnSimulateUpgrade   *manager.Transaction
+	ReturnSearch            bool
+	ReturnSearchPackages    []manager.PackageInfo
+	ReturnAvailableVersions []manager.PackageVersion
+	ReturnCandidateVersion  string
+	ReturnDepends           manager.DependencyGraph
+	ReturnRDepends          manager.DependencyGraph
+	ReturnHistory           []manager.HistoryEntry
+	ReturnOwnerOf           string
+	ReturnVerify            []manager.VerifyResult
+	ReturnIsInstalled       bool
+	ReturnListInstalled     []manager.PackageInfo
+	ReturnGetProxySettings  proxy.Settings
+}
+
+// NewStubPackageManager returns a StubPackageManager recording
+// its calls on the given Stub.
+func NewStubPackageManager(stub *jujutesting.Stub) *StubPackageManager {
+	return &StubPackageManager{Stub: stub}
+}
+
+// call records the call of the given method and returns
+// the next error of the Stub once Delay elapsed.
+func (s *StubPackageManager) call(funcName string, args ...interface{}) error {
+	return s.callContext(context.Background(), funcName, args...)
+}
+
+// callContext records the call of the given method and returns the next
+// error of the Stub once Delay elapsed, or the error of the given context
+// if it is done first.
+func (s *StubPackageManager) callContext(ctx context.Context, funcName string, args ...interface{}) error {
+	s.Stub.AddCall(funcName, args...)
+	if s.Delay > 0 {
+		timer := time.NewTimer(s.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := s.Stub.NextErr(); err != nil {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// InstallPrerequisite is defined on the PackageManager interface.
+func (s *StubPackageManager) InstallPrerequisite() error {
+	return s.call("InstallPrerequisite")
+}
+
+// Update is defined on the PackageManager interface.
+func (s *StubPackageManager) Update() error {
+	return s.call("Update")
+}
+
+// UpdateIfOlderThan is defined on the PackageManager interface.
+func (s *StubPackageManager) UpdateIfOlderThan(d time.Duration) error {
+	return s.call("UpdateIfOlderThan", d)
+}
+
+// UpdateContext is defined on the PackageManager interface.
+func (s *StubPackageManager) UpdateContext(ctx context.Context) error {
+	return s.callContext(ctx, "UpdateContext")
+}
+
+// Upgrade is defined on the PackageManager interface.
+func (s *StubPackageManager) Upgrade() error {
+	return s.call("Upgrade")
+}
+
+// UpgradeContext is defined on the PackageManager interface.
+func (s *StubPackageManager) UpgradeContext(ctx context.Context) error {
+	return s.callContext(ctx, "UpgradeContext")
+}
+
+// UpgradeOnly is defined on the PackageManager interface.
+func (s *StubPackageManager) UpgradeOnly(packs ...string) error {
+	return s.call("UpgradeOnly", packs)
+}
+
+// Install is defined on the PackageManager interface.
+func (s *StubPackageManager) Install(packs ...string) error {
+	return s.call("Install", packs)
+}
+
+// InstallContext is defined on the PackageManager interface.
+func (s *StubPackageManager) InstallContext(ctx context.Context, packs ...string) error {
+	return s.callContext(ctx, "InstallContext", packs)
+}
+
+// InstallVersion is defined on the PackageManager interface.
+func (s *StubPackageManager) InstallVersion(pack, version string) error {
+	return s.call("InstallVersion", pack, version)
+}
+
+// Downgrade is defined on the PackageManager interface.
+func (s *StubPackageManager) Downgrade(pack, version string) error {
+	return s.call("Downgrade", pack, version)
+}
+
+// Rollback is defined on the PackageManager interface.
+func (s *StubPackageManager) Rollback(transaction string) error {
+	return s.call("Rollback", transaction)
+}
+
+// InstallLocal is defined on the PackageManager interface.
+func (s *StubPackageManager) InstallLocal(paths ...string) error {
+	return s.call("InstallLocal", paths)
+}
+
+// Remove is defined on the PackageManager interface.
+func (s *StubPackageManager) Remove(packs ...string) error {
+	return s.call("Remove", packs)
+}
+
+// RemoveContext is defined on the PackageManager interface.
+func (s *StubPackageManager) RemoveContext(ctx context.Context, packs ...string) error {
+	return s.callContext(ctx, "RemoveContext", packs)
+}
+
+// Purge is defined on the PackageManager interface.
+func (s *StubPackageManager) Purge(packs ...string) error {
+	return s.call("Purge", packs)
+}
+
+// PurgeContext is defined on the PackageManager interface.
+func (s *StubPackageManager) PurgeContext(ctx context.Context, packs ...string) error {
+	return s.callContext(ctx, "PurgeContext", packs)
+}
+
+// Fetch is defined on the PackageManager interface.
+func (s *StubPackageManager) Fetch(dir string, packs ...string) error {
+	return s.call("Fetch", dir, packs)
+}
+
+// FetchWithDependencies is defined on the PackageManager interface.
+func (s *StubPackageManager) FetchWithDependencies(dir string, packs ...string) error {
+	return s.call("FetchWithDependencies", dir, packs)
+}
+
+// Hold is defined on the PackageManager interface.
+func (s *StubPackageManager) Hold(packs ...string) error {
+	return s.call("Hold", packs)
+}
+
+// Unhold is defined on the PackageManager interface.
+func (s *StubPackageManager) Unhold(packs ...string) error {
+	return s.call("Unhold", packs)
+}
+
+// SimulateInstall is defined on the PackageManager interface.
+func (s *StubPackageManager) SimulateInstall(packs ...string) (*manager.Transaction, error) {
+	if err := s.call("SimulateInstall", packs); err != nil {
+		return nil, err
+	}
+	return s.ReturnSimulateInstall, nil
+}
+
+// SimulateRemove is defined on the PackageManager interface.
+func (s *StubPackageManager) SimulateRemove(packs ...string) (*manager.Transaction, error) {
+	if err := s.call("SimulateRemove", packs); err != nil {
+		return nil, err
+	}
+	return s.ReturnSimulateRemove, nil
+}
+
+// SimulateUpgrade is defined on the PackageManager interface.
+func (s *StubPackageManager) SimulateUpgrade() (*manager.Transaction, error) {
+	if err := s.call("SimulateUpgrade"); err != nil {
+		return nil, err
+	}
+	return s.ReturnSimulateUpgrade, nil
+}
+
+// Search is defined on the PackageManager interface.
+func (s *StubPackageManager) Search(pack string) (bool, error) {
+	if err := s.call("Search", pack); err != nil {
+		return false, err
+	}
+	return s.ReturnSearch, nil
+}
+
+// SearchPackages is defined on the PackageManager interface.
+func (s *StubPackageManager) SearchPackages(term string) ([]manager.PackageInfo, error) {
+	if err := s.call("SearchPackages", term); err != nil {
+		return nil, err
+	}
+	return s.ReturnSearchPackages, nil
+}
+
+// AvailableVersions is defined on the PackageManager interface.
+func (s *StubPackageManager) AvailableVersions(pack string) ([]manager.PackageVersion, error) {
+	if err := s.call("AvailableVersions", pack); err != nil {
+		return nil, err
+	}
+	return s.ReturnAvailableVersions, nil
+}
+
+// CandidateVersion is defined on the PackageManager interface.
+func (s *StubPackageManager) CandidateVersion(pack string) (string, error) {
+	if err := s.call("CandidateVersion", pack); err != nil {
+		return "", err
+	}
+	return s.ReturnCandidateVersion, nil
+}
+
+// Depends is defined on the PackageManager interface.
+func (s *StubPackageManager) Depends(pack string) (manager.DependencyGraph, error) {
+	if err := s.call("Depends", pack); err != nil {
+		return nil, err
+	}
+	return s.ReturnDepends, nil
+}
+
+// RDepends is defined on the PackageManager interface.
+func (s *StubPackageManager) RDepends(pack string) (manager.DependencyGraph, error) {
+	if err := s.call("RDepends", pack); err != nil {
+		return nil, err
+	}
+	return s.ReturnRDepends, nil
+}
+
+// History is defined on the PackageManager interface.
+func (s *StubPackageManager) History() ([]manager.HistoryEntry, error) {
+	if err := s.call("History"); err != nil {
+		return nil, err
+	}
+	return s.ReturnHistory, nil
+}
+
+// IsInstalled is defined on the PackageManager interface. As it cannot
+// return an error, a scripted failure makes it report false.
+func (s *StubPackageManager) IsInstalled(pack string) bool {
+	if err := s.call("IsInstalled", pack); err != nil {
+		return false
+	}
+	return s.ReturnIsInstalled
+}
+
+// OwnerOf is defined on the PackageManager interface.
+func (s *StubPackageManager) OwnerOf(path string) (string, error) {
+	if err := s.call("OwnerOf", path); err != nil {
+		return "", err
+	}
+	return s.ReturnOwnerOf, nil
+}
+
+// Verify is defined on the PackageManager interface.
+func (s *StubPackageManager) Verify(packs ...string) ([]manager.VerifyResult, error) {
+	if err := s.call("Verify", packs); err != nil {
+		return nil, err
+	}
+	return s.ReturnVerify, nil
+}
+
+// ListInstalled is defined on the PackageManager interface.
+func (s *StubPackageManager) ListInstalled() ([]manager.PackageInfo, error) {
+	if err := s.call("ListInstalled"); err != nil {
+		return nil, err
+	}
+	return s.ReturnListInstalled, nil
+}
+
+// AddRepository is defined on the PackageManager interface.
+func (s *StubPackageManager) AddRepository(repo string) error {
+	return s.call("AddRepository", repo)
+}
+
+// RemoveRepository is defined on the PackageManager interface.
+func (s *StubPackageManager) RemoveRepository(repo string) error {
+	return s.call("RemoveRepository", repo)
+}
+
+// AddRepositoryKey is defined on the PackageManager interface.
+func (s *StubPackageManager) AddRepositoryKey(name string, key manager.RepositoryKey) error {
+	return s.call("AddRepositoryKey", name, key)
+}
+
+// RemoveRepositoryKey is defined on the PackageManager interface.
+func (s *StubPackageManager) RemoveRepositoryKey(name string) error {
+	return s.call("RemoveRepositoryKey", name)
+}
+
+// Cleanup is defined on the PackageManager interface.
+func (s *StubPackageManager) Cleanup() error {
+	return s.call("Cleanup")
+}
+
+// AutoRemove is defined on the PackageManager interface.
+func (s *StubPackageManager) AutoRemove() error {
+	return s.call("AutoRemove")
+}
+
+// SetAutoRemoveOnCleanup is defined on the PackageManager interface.
+func (s *StubPackageManager) SetAutoRemoveOnCleanup(enabled bool) {
+	s.Stub.AddCall("SetAutoRemoveOnCleanup", enabled)
+}
+
+// SetInstallRecommends is defined on the PackageManager interface.
+func (s *StubPackageManager) SetInstallRecommends(enabled bool) {
+	s.Stub.AddCall("SetInstallRecommends", enabled)
+}
+
+// SetProgressCallback is defined on the PackageManager interface. As
+// functions cannot be compared, the callback is not recorded.
+func (s *StubPackageManager) SetProgressCallback(manager.ProgressFunc) {
+	s.Stub.AddCall("SetProgressCallback")
+}
+
+// SetEnvironment is defined on the PackageManager interface.
+func (s *StubPackageManager) SetEnvironment(env []string) {
+	s.Stub.AddCall("SetEnvironment", env)
+}
+
+// SetOutputCallback is defined on the PackageManager interface. As
+// functions cannot be compared, the callback is not recorded.
+func (s *StubPackageManager) SetOutputCallback(manager.OutputFunc) {
+	s.Stub.AddCall("SetOutputCallback")
+}
+
+// SetRetryStrategy is defined on the PackageManager interface.
+func (s *StubPackageManager) SetRetryStrategy(strategy manager.RetryStrategy) {
+	s.Stub.AddCall("SetRetryStrategy", strategy)
+}
+
+// GetProxySettings is defined on the PackageManager interface.
+func (s *StubPackageManager) GetProxySettings() (proxy.Settings, error) {
+	if err := s.call("GetProxySettings"); err != nil {
+		return proxy.Settings{}, err
+	}
+	return s.ReturnGetProxySettings, nil
+}
+
+// SetProxy is defined on the PackageManager interface.
+func (s *StubPackageManager) SetProxy(settings proxy.Settings) error {
+	return s.call("SetProxy", settings)
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package testing_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	jujutesting "github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/packaging/commands"
+	"github.com/juju/utils/packaging/manager"
+	"github.com/juju/utils/packaging/testing"
+)
+
+var (
+	_ manager.PackageManager    = (*testing.StubPackageManager)(nil)
+	_ commands.PackageCommander = (*testing.StubPackageCommander)(nil)
+)
+
+var _ = gc.Suite(&StubSuite{})
+
+type StubSuite struct {
+	jujutesting.IsolationSuite
+	stub *jujutesting.Stub
+}
+
+func (s *StubSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.stub = &jujutesting.Stub{}
+}
+
+func (s *StubSuite) TestPackageManagerRecordsCalls(c *gc.C) {
+	pm := testing.NewStubPackageManager(s.stub)
+	pm.SetInstallRecommends(false)
+	c.Assert(pm.Update(), jc.ErrorIsNil)
+	c.Assert(pm.Install("juju", "lxd"), jc.ErrorIsNil)
+	c.Assert(pm.InstallVersion("juju", "2.0.0"), jc.ErrorIsNil)
+
+	s.stub.CheckCalls(c, []jujutesting.StubCall{
+		{"SetInstallRecommends", []interface{}{false}},
+		{"Update", nil},
+		{"Install", []interface{}{[]string{"juju", "lxd"}}},
+		{"InstallVersion", []interface{}{"juju", "2.0.0"}},
+	})
+}
+
+func (s *StubSuite) TestPackageManagerScriptedResults(c *gc.C) {
+	pm := testing.NewStubPackageManager(s.stub)
+	pm.ReturnCandidateVersion = "2.0.0"
+	pm.ReturnIsInstalled = true
+	s.stub.SetErrors(nil, errors.New("boom"))
+
+	version, err := pm.CandidateVersion("juju")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(version, gc.Equals, "2.0.0")
+
+	version, err = pm.CandidateVersion("juju")
+	c.Assert(err, gc.ErrorMatches, "boom")
+	c.Assert(version, gc.Equals, "")
+
+	c.Assert(pm.IsInstalled("juju"), jc.IsTrue)
+	s.stub.CheckCallNames(c, "CandidateVersion", "CandidateVersion", "IsInstalled")
+}
+
+func (s *StubSuite) TestPackageManagerDelay(c *gc.C) {
+	pm := testing.NewStubPackageManager(s.stub)
+	pm.Delay = 50 * time.Millisecond
+
+	start := time.Now()
+	c.Assert(pm.Upgrade(), jc.ErrorIsNil)
+	c.Assert(time.Since(start) >= pm.Delay, jc.IsTrue)
+}
+
+func (s *StubSuite) TestPackageManagerDelayCancelled(c *gc.C) {
+	pm := testing.NewStubPackageManager(s.stub)
+	pm.Delay = time.Hour
+	s.stub.SetErrors(errors.New("boom"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := pm.InstallContext(ctx, "juju")
+	c.Assert(errors.Cause(err), gc.Equals, context.DeadlineExceeded)
+
+	// The scripted error is left for the next call.
+	pm.Delay = 0
+	c.Assert(pm.Cleanup(), gc.ErrorMatches, "boom")
+	s.stub.CheckCallNames(c, "InstallContext", "Cleanup")
+}
+
+func (s *StubSuite) TestPackageCommander(c *gc.C) {
+	cmder := testing.NewStubPackageCommander(s.stub)
+	cmder.ReturnCmds = map[string]string{"InstallCmd": "install juju"}
+
+	c.Assert(cmder.InstallCmd("juju"), gc.Equals, "install juju")
+	c.Assert(cmder.RemoveCmd("juju"), gc.Equals, "")
+	s.stub.CheckCalls(c, []jujutesting.StubCall{
+		{"InstallCmd", []interface{}{[]string{"juju"}}},
+		{"RemoveCmd", []interface{}{[]string{"juju"}}},
+	})
+}
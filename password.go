@@ -6,10 +6,14 @@ package utils
 import (
 	"crypto/rand"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"strings"
 
+	"github.com/juju/errors"
+	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/crypto/pbkdf2"
 )
 
@@ -90,3 +94,62 @@ func AgentPasswordHash(password string) string {
 	h := sum.Sum(nil)
 	return base64.StdEncoding.EncodeToString(h[:18])
 }
+
+// PasswordHashCost is the bcrypt cost of the hashes returned by
+// PasswordHash. Every increment doubles the time hashing takes.
+var PasswordHashCost = 12
+
+// passwordHashCost returns the cost PasswordHash uses.
+func passwordHashCost() int {
+	if FastInsecureHash {
+		return bcrypt.MinCost
+	}
+	return PasswordHashCost
+}
+
+// PasswordHash returns a bcrypt hash of the given password, such as
+// "$2a$12$<salt><hash>". The hash embeds its random salt and cost, so only
+// it needs to be stored to verify the password with ComparePassword.
+// Only the first 72 bytes of the password are taken into account.
+func PasswordHash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost())
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return string(hash), nil
+}
+
+// ComparePassword returns nil if the given password matches the given
+// hash, and an error satisfying errors.IsUnauthorized otherwise. The hash
+// is either one returned by PasswordHash, or a legacy one returned by
+// UserPasswordHash for the given salt or, if the salt is empty, by
+// AgentPasswordHash. Legacy hashes should be replaced once the password
+// is verified; see NeedsRehash.
+func ComparePassword(hash, salt, password string) error {
+	if !strings.HasPrefix(hash, "$") {
+		legacy := AgentPasswordHash(password)
+		if salt != "" {
+			legacy = UserPasswordHash(password, salt)
+		}
+		if subtle.ConstantTimeCompare([]byte(legacy), []byte(hash)) != 1 {
+			return errors.Unauthorizedf("password mismatch")
+		}
+		return nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return errors.Unauthorizedf("password mismatch")
+	} else if err != nil {
+		return errors.NewNotValid(err, "password hash not valid")
+	}
+	return nil
+}
+
+// NeedsRehash returns whether the given hash is a legacy one, or one of
+// another cost than that PasswordHash now uses, and should be replaced by
+// the result of PasswordHash once the password is verified.
+func NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != passwordHashCost()
+}
@@ -4,6 +4,7 @@
 package utils_test
 
 import (
+	"github.com/juju/errors"
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
@@ -92,3 +93,57 @@ func (*passwordSuite) TestAgentPasswordHash(c *gc.C) {
 		c.Assert(hashed, gc.Matches, base64Chars)
 	}
 }
+
+func (s *passwordSuite) TestPasswordHash(c *gc.C) {
+	s.PatchValue(&utils.FastInsecureHash, true)
+	for i, password := range testPasswords {
+		c.Logf("test %d: %q", i, password)
+		hash, err := utils.PasswordHash(password)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(hash, gc.Matches, `\$2a\$04\$.{53}`)
+		c.Assert(utils.ComparePassword(hash, "", password), jc.ErrorIsNil)
+		c.Assert(utils.NeedsRehash(hash), jc.IsFalse)
+
+		other, err := utils.PasswordHash(password)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(other, gc.Not(gc.Equals), hash)
+
+		err = utils.ComparePassword(hash, "", password+"x")
+		c.Assert(err, jc.Satisfies, errors.IsUnauthorized)
+		c.Assert(err, gc.ErrorMatches, "password mismatch")
+	}
+}
+
+func (s *passwordSuite) TestNeedsRehash(c *gc.C) {
+	s.PatchValue(&utils.FastInsecureHash, true)
+	hash, err := utils.PasswordHash("secret")
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.PatchValue(&utils.FastInsecureHash, false)
+	c.Assert(utils.NeedsRehash(hash), jc.IsTrue)
+	c.Assert(utils.NeedsRehash(utils.AgentPasswordHash("secret")), jc.IsTrue)
+}
+
+func (s *passwordSuite) TestComparePasswordLegacy(c *gc.C) {
+	s.PatchValue(&utils.FastInsecureHash, true)
+	for i, password := range testPasswords {
+		for j, salt := range testSalts {
+			c.Logf("test %d, %d %s %s", i, j, password, salt)
+			hash := utils.UserPasswordHash(password, salt)
+			c.Assert(utils.ComparePassword(hash, salt, password), jc.ErrorIsNil)
+			err := utils.ComparePassword(hash, salt, password+"x")
+			c.Assert(err, jc.Satisfies, errors.IsUnauthorized)
+		}
+	}
+
+	password, err := utils.RandomPassword()
+	c.Assert(err, jc.ErrorIsNil)
+	hash := utils.AgentPasswordHash(password)
+	c.Assert(utils.ComparePassword(hash, "", password), jc.ErrorIsNil)
+	c.Assert(utils.ComparePassword(hash, "", "x"), jc.Satisfies, errors.IsUnauthorized)
+}
+
+func (*passwordSuite) TestComparePasswordNotValid(c *gc.C) {
+	err := utils.ComparePassword("$2a$10$short", "", "secret")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
@@ -5,6 +5,9 @@ package proxy
 
 import (
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"strings"
 )
@@ -101,3 +104,78 @@ func (s *Settings) SetEnvironmentValues() {
 	setenv(ftp_proxy, s.Ftp)
 	setenv(no_proxy, s.NoProxy)
 }
+
+// ProxyForRequest returns the URL of the proxy to use for the given
+// request according to the settings, or nil if the request is not to be
+// proxied, in the form of http.Transport.Proxy. Requests to loopback
+// addresses and to the hosts matching NoProxy are never proxied.
+//
+// NoProxy is a comma separated list of host names, which also match
+// their subdomains, IP addresses and CIDR ranges, optionally followed by
+// a port; "*" matches all hosts.
+func (s *Settings) ProxyForRequest(req *http.Request) (*url.URL, error) {
+	var proxy string
+	switch req.URL.Scheme {
+	case "http":
+		proxy = s.Http
+	case "https":
+		proxy = s.Https
+	case "ftp":
+		proxy = s.Ftp
+	}
+	if proxy == "" || s.noProxy(req.URL.Hostname(), req.URL.Port()) {
+		return nil, nil
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy address %q: %v", proxy, err)
+	}
+	return proxyURL, nil
+}
+
+// noProxy returns whether the given host and
+// port, if any, must not be proxied.
+func (s *Settings) noProxy(host, port string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	if host == "localhost" || ip != nil && ip.IsLoopback() {
+		return true
+	}
+	for _, entry := range strings.Split(s.NoProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		}
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		entryHost := entry
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entryHost = h
+		}
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		entryHost = strings.TrimPrefix(entryHost, "*")
+		entryHost = strings.TrimPrefix(entryHost, ".")
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
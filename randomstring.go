@@ -4,9 +4,14 @@
 package utils
 
 import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"io"
 	"math/rand"
 	"sync"
 	"time"
+
+	"github.com/juju/errors"
 )
 
 // Can be used as a sane default argument for RandomString
@@ -14,6 +19,17 @@ var (
 	LowerAlpha = []rune("abcdefghijklmnopqrstuvwxyz")
 	UpperAlpha = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
 	Digits     = []rune("0123456789")
+
+	// HexDigits holds the lower case hexadecimal digits.
+	HexDigits = []rune("0123456789abcdef")
+
+	// Base32Alpha holds the alphabet of base32, as defined by RFC 4648.
+	Base32Alpha = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ234567")
+
+	// PasswordSafe holds the letters and digits which cannot be mistaken
+	// for one another, such as "l", "1" and "I", and which need no quoting
+	// in shells or configuration files.
+	PasswordSafe = []rune("abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789")
 )
 
 var (
@@ -28,7 +44,8 @@ func init() {
 }
 
 // RandomString will return a string of length n that will only
-// contain runes inside validRunes
+// contain runes inside validRunes. It is predictable, and must not be used
+// for passwords, tokens and the like: use SecureRandomString instead.
 func RandomString(n int, validRunes []rune) string {
 	randomStringMu.Lock()
 	defer randomStringMu.Unlock()
@@ -40,3 +57,32 @@ func RandomString(n int, validRunes []rune) string {
 
 	return string(runes)
 }
+
+// SecureRandomString returns a string of length n made of runes picked
+// uniformly at random from validRunes, using a cryptographically secure
+// random number generator.
+func SecureRandomString(n int, validRunes []rune) (string, error) {
+	if len(validRunes) == 0 {
+		return "", errors.NotValidf("empty set of runes")
+	}
+
+	// Random values at or above the limit are rejected, as taking them
+	// modulo the number of runes would favour the first runes.
+	count := uint64(len(validRunes))
+	limit := 1 << 32 / count * count
+
+	runes := make([]rune, 0, n)
+	buf := make([]byte, 4*n)
+	for len(runes) < n {
+		if _, err := io.ReadFull(cryptorand.Reader, buf); err != nil {
+			return "", errors.Annotate(err, "cannot read random bytes")
+		}
+		for i := 0; i+4 <= len(buf) && len(runes) < n; i += 4 {
+			value := uint64(binary.BigEndian.Uint32(buf[i:]))
+			if value < limit {
+				runes = append(runes, validRunes[value%count])
+			}
+		}
+	}
+	return string(runes), nil
+}
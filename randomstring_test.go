@@ -33,3 +33,38 @@ func (randomStringSuite) TestContentInValidRunes(c *gc.C) {
 		c.Assert(string(validChars), jc.Contains, string(char))
 	}
 }
+
+func (randomStringSuite) TestSecureRandomString(c *gc.C) {
+	s, err := utils.SecureRandomString(length, validChars)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s, gc.HasLen, length)
+	for _, char := range s {
+		c.Assert(string(validChars), jc.Contains, string(char))
+	}
+}
+
+func (randomStringSuite) TestSecureRandomStringUniform(c *gc.C) {
+	// With 3 runes, picking them modulo a random byte or word would
+	// favour the first one.
+	s, err := utils.SecureRandomString(30000, []rune("abc"))
+	c.Assert(err, jc.ErrorIsNil)
+	counts := make(map[rune]int)
+	for _, char := range s {
+		counts[char]++
+	}
+	c.Assert(counts, gc.HasLen, 3)
+	for char, count := range counts {
+		c.Check(count > 9000 && count < 11000, jc.IsTrue, gc.Commentf("%c picked %d times", char, count))
+	}
+}
+
+func (randomStringSuite) TestSecureRandomStringEmptyRunes(c *gc.C) {
+	_, err := utils.SecureRandomString(length, nil)
+	c.Assert(err, gc.ErrorMatches, "empty set of runes not valid")
+}
+
+func (randomStringSuite) TestPasswordSafe(c *gc.C) {
+	for _, char := range "lI1O0'\"$ " {
+		c.Check(string(utils.PasswordSafe), gc.Not(jc.Contains), string(char))
+	}
+}
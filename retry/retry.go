@@ -0,0 +1,222 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// The retry package provides a way of calling a function until it
+// succeeds, waiting between the attempts according to a backoff, and
+// giving up once a number of attempts, a duration or a context runs out.
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils/clock"
+)
+
+// BackoffFunc returns the delay to wait for before retrying the given
+// failed attempt, numbered from 2, given the delay waited for before
+// retrying the previous one.
+type BackoffFunc func(delay time.Duration, attempt int) time.Duration
+
+// CallArgs configures the calls made by Call.
+type CallArgs struct {
+	// Func is the function which is called until it succeeds.
+	Func func() error
+
+	// IsFatalError returns whether the given error, returned by Func,
+	// stops the retries. All errors are retried if it is nil.
+	IsFatalError func(error) bool
+
+	// NotifyFunc, if set, is called with the error of every failed
+	// attempt, numbered from 1, before it is retried.
+	NotifyFunc func(lastError error, attempt int)
+
+	// Attempts is the maximum number of times Func is called. There is
+	// no such maximum if it is zero, in which case MaxDuration must be
+	// set.
+	Attempts int
+
+	// Delay is the delay waited for before the first retry.
+	Delay time.Duration
+
+	// MaxDelay, if positive, caps the delays returned by BackoffFunc.
+	MaxDelay time.Duration
+
+	// MaxDuration, if positive, is how long after the first attempt
+	// further attempts may start.
+	MaxDuration time.Duration
+
+	// BackoffFunc computes the delays waited for before all retries
+	// but the first. The delay stays the same if it is nil.
+	BackoffFunc BackoffFunc
+
+	// Clock is used for waiting between attempts. It defaults to
+	// clock.WallClock if nil.
+	Clock clock.Clock
+}
+
+// Validate returns an error satisfying errors.IsNotValid
+// if the arguments cannot be used by Call.
+func (args *CallArgs) Validate() error {
+	if args.Func == nil {
+		return errors.NotValidf("missing Func")
+	}
+	if args.Attempts < 0 {
+		return errors.NotValidf("negative Attempts")
+	}
+	if args.Attempts == 0 && args.MaxDuration <= 0 {
+		return errors.NotValidf("missing Attempts or MaxDuration")
+	}
+	if args.Delay < 0 {
+		return errors.NotValidf("negative Delay")
+	}
+	return nil
+}
+
+// Call calls the function given in the arguments until it succeeds,
+// returns a fatal error, or the attempts, duration or given context run
+// out. In the latter cases, the returned error satisfies respectively
+// IsAttemptsExceeded, IsDurationExceeded or IsRetryStopped, and the
+// error of the last attempt is available from LastError.
+func Call(ctx context.Context, args CallArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	clk := args.Clock
+	if clk == nil {
+		clk = clock.WallClock
+	}
+
+	start := clk.Now()
+	delay := args.Delay
+	for attempt := 1; ; attempt++ {
+		err := args.Func()
+		if err == nil {
+			return nil
+		}
+		if args.IsFatalError != nil && args.IsFatalError(err) {
+			return errors.Trace(err)
+		}
+		if args.Attempts > 0 && attempt >= args.Attempts {
+			return &attemptsExceeded{err}
+		}
+
+		if attempt > 1 && args.BackoffFunc != nil {
+			delay = args.BackoffFunc(delay, attempt)
+		}
+		if args.MaxDelay > 0 && delay > args.MaxDelay {
+			delay = args.MaxDelay
+		}
+		if args.MaxDuration > 0 && clk.Now().Add(delay).Sub(start) > args.MaxDuration {
+			return &durationExceeded{err}
+		}
+
+		if args.NotifyFunc != nil {
+			args.NotifyFunc(err, attempt)
+		}
+		if ctx.Err() != nil {
+			return &retryStopped{ctx.Err(), err}
+		}
+		select {
+		case <-clk.After(delay):
+		case <-ctx.Done():
+			return &retryStopped{ctx.Err(), err}
+		}
+	}
+}
+
+// DoubleDelay is a BackoffFunc which doubles the delay on every retry.
+func DoubleDelay(delay time.Duration, attempt int) time.Duration {
+	return delay * 2
+}
+
+// ExpBackoff returns a BackoffFunc which waits for the given minimum delay
+// multiplied by the given exponent on every retry, up to the given maximum.
+// If jitter is true, the delays are picked at random between the minimum
+// and the exponential delay, so that callers retrying in step spread out.
+func ExpBackoff(min, max time.Duration, exp float64, jitter bool) BackoffFunc {
+	return func(_ time.Duration, attempt int) time.Duration {
+		delay := time.Duration(float64(min) * math.Pow(exp, float64(attempt-1)))
+		if delay > max || delay < 0 {
+			delay = max
+		}
+		if jitter && delay > min {
+			delay = min + time.Duration(rand.Int63n(int64(delay-min)))
+		}
+		return delay
+	}
+}
+
+// attemptsExceeded is returned by Call when Func failed on every attempt.
+type attemptsExceeded struct {
+	lastError error
+}
+
+// Error implements error.
+func (e *attemptsExceeded) Error() string {
+	return fmt.Sprintf("attempt count exceeded: %v", e.lastError)
+}
+
+// durationExceeded is returned by Call when Func failed until
+// MaxDuration elapsed.
+type durationExceeded struct {
+	lastError error
+}
+
+// Error implements error.
+func (e *durationExceeded) Error() string {
+	return fmt.Sprintf("max duration exceeded: %v", e.lastError)
+}
+
+// retryStopped is returned by Call when its context is done
+// before Func succeeded.
+type retryStopped struct {
+	err       error
+	lastError error
+}
+
+// Error implements error.
+func (e *retryStopped) Error() string {
+	return fmt.Sprintf("retry stopped: %v: %v", e.err, e.lastError)
+}
+
+// IsAttemptsExceeded returns whether the given error was returned by Call
+// because the function failed on every attempt.
+func IsAttemptsExceeded(err error) bool {
+	_, ok := errors.Cause(err).(*attemptsExceeded)
+	return ok
+}
+
+// IsDurationExceeded returns whether the given error was returned by Call
+// because the function kept failing for the maximum duration.
+func IsDurationExceeded(err error) bool {
+	_, ok := errors.Cause(err).(*durationExceeded)
+	return ok
+}
+
+// IsRetryStopped returns whether the given error was returned by Call
+// because its context was done before the function succeeded.
+func IsRetryStopped(err error) bool {
+	_, ok := errors.Cause(err).(*retryStopped)
+	return ok
+}
+
+// LastError returns the error of the last attempt made by Call if the
+// given error was returned by it after running out of attempts, duration
+// or context, and the given error otherwise.
+func LastError(err error) error {
+	switch cause := errors.Cause(err).(type) {
+	case *attemptsExceeded:
+		return cause.lastError
+	case *durationExceeded:
+		return cause.lastError
+	case *retryStopped:
+		return cause.lastError
+	}
+	return err
+}
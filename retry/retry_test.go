@@ -0,0 +1,200 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package retry_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/clock"
+	"github.com/juju/utils/retry"
+)
+
+type retrySuite struct {
+	testing.IsolationSuite
+	clock *recordingClock
+}
+
+var _ = gc.Suite(&retrySuite{})
+
+func (s *retrySuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.clock = &recordingClock{now: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// recordingClock is a clock.Clock which records the delays it is asked
+// to wait for without sleeping, advancing its time by them instead.
+type recordingClock struct {
+	now    time.Time
+	delays []time.Duration
+}
+
+func (r *recordingClock) Now() time.Time {
+	return r.now
+}
+
+func (r *recordingClock) After(d time.Duration) <-chan time.Time {
+	r.delays = append(r.delays, d)
+	r.now = r.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- r.now
+	return ch
+}
+
+func (*recordingClock) AfterFunc(d time.Duration, f func()) clock.Timer {
+	return time.AfterFunc(d, f)
+}
+
+// failing returns a function which fails the given number of
+// times before succeeding, and the count of its calls.
+func failing(failures int) (func() error, *int) {
+	var calls int
+	return func() error {
+		calls++
+		if calls <= failures {
+			return errors.Errorf("failure %d", calls)
+		}
+		return nil
+	}, &calls
+}
+
+func (s *retrySuite) TestCallSucceeds(c *gc.C) {
+	f, calls := failing(2)
+	var notified []int
+	err := retry.Call(context.Background(), retry.CallArgs{
+		Func:     f,
+		Attempts: 5,
+		Delay:    time.Second,
+		NotifyFunc: func(err error, attempt int) {
+			c.Check(err, gc.ErrorMatches, "failure .*")
+			notified = append(notified, attempt)
+		},
+		Clock: s.clock,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(*calls, gc.Equals, 3)
+	c.Assert(notified, jc.DeepEquals, []int{1, 2})
+	c.Assert(s.clock.delays, jc.DeepEquals, []time.Duration{time.Second, time.Second})
+}
+
+func (s *retrySuite) TestCallAttemptsExceeded(c *gc.C) {
+	f, calls := failing(10)
+	err := retry.Call(context.Background(), retry.CallArgs{
+		Func:     f,
+		Attempts: 3,
+		Clock:    s.clock,
+	})
+	c.Assert(err, jc.Satisfies, retry.IsAttemptsExceeded)
+	c.Assert(err, gc.ErrorMatches, "attempt count exceeded: failure 3")
+	c.Assert(retry.LastError(err), gc.ErrorMatches, "failure 3")
+	c.Assert(*calls, gc.Equals, 3)
+}
+
+func (s *retrySuite) TestCallDurationExceeded(c *gc.C) {
+	f, calls := failing(10)
+	err := retry.Call(context.Background(), retry.CallArgs{
+		Func:        f,
+		Delay:       time.Second,
+		BackoffFunc: retry.DoubleDelay,
+		MaxDuration: 10 * time.Second,
+		Clock:       s.clock,
+	})
+	c.Assert(err, jc.Satisfies, retry.IsDurationExceeded)
+	c.Assert(retry.LastError(err), gc.ErrorMatches, "failure 4")
+	c.Assert(*calls, gc.Equals, 4)
+	c.Assert(s.clock.delays, jc.DeepEquals, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second})
+}
+
+func (s *retrySuite) TestCallFatalError(c *gc.C) {
+	f, calls := failing(10)
+	err := retry.Call(context.Background(), retry.CallArgs{
+		Func:         f,
+		IsFatalError: func(err error) bool { return err.Error() == "failure 2" },
+		Attempts:     5,
+		Clock:        s.clock,
+	})
+	c.Assert(err, gc.ErrorMatches, "failure 2")
+	c.Assert(retry.LastError(err), gc.Equals, err)
+	c.Assert(*calls, gc.Equals, 2)
+}
+
+func (s *retrySuite) TestCallStopped(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	err := retry.Call(ctx, retry.CallArgs{
+		Func: func() error {
+			cancel()
+			return errors.New("boom")
+		},
+		Attempts: 5,
+		Clock:    s.clock,
+	})
+	c.Assert(err, jc.Satisfies, retry.IsRetryStopped)
+	c.Assert(err, gc.ErrorMatches, "retry stopped: context canceled: boom")
+	c.Assert(retry.LastError(err), gc.ErrorMatches, "boom")
+}
+
+func (s *retrySuite) TestCallMaxDelay(c *gc.C) {
+	f, _ := failing(4)
+	err := retry.Call(context.Background(), retry.CallArgs{
+		Func:        f,
+		Attempts:    5,
+		Delay:       time.Second,
+		MaxDelay:    3 * time.Second,
+		BackoffFunc: retry.DoubleDelay,
+		Clock:       s.clock,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.clock.delays, jc.DeepEquals, []time.Duration{
+		time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second,
+	})
+}
+
+func (s *retrySuite) TestExpBackoff(c *gc.C) {
+	backoff := retry.ExpBackoff(time.Second, 10*time.Second, 3, false)
+	var delays []time.Duration
+	for attempt := 2; attempt <= 5; attempt++ {
+		delays = append(delays, backoff(0, attempt))
+	}
+	c.Assert(delays, jc.DeepEquals, []time.Duration{
+		3 * time.Second, 9 * time.Second, 10 * time.Second, 10 * time.Second,
+	})
+}
+
+func (s *retrySuite) TestExpBackoffJitter(c *gc.C) {
+	backoff := retry.ExpBackoff(time.Second, time.Minute, 2, true)
+	for i := 0; i < 100; i++ {
+		delay := backoff(0, 4)
+		c.Assert(delay >= time.Second, jc.IsTrue)
+		c.Assert(delay < 8*time.Second, jc.IsTrue)
+	}
+}
+
+func (s *retrySuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		args retry.CallArgs
+		err  string
+	}{{
+		args: retry.CallArgs{Attempts: 1},
+		err:  "missing Func not valid",
+	}, {
+		args: retry.CallArgs{Func: func() error { return nil }},
+		err:  "missing Attempts or MaxDuration not valid",
+	}, {
+		args: retry.CallArgs{Func: func() error { return nil }, Attempts: -1},
+		err:  "negative Attempts not valid",
+	}, {
+		args: retry.CallArgs{Func: func() error { return nil }, Attempts: 1, Delay: -time.Second},
+		err:  "negative Delay not valid",
+	}} {
+		c.Logf("test %d", i)
+		err := retry.Call(context.Background(), test.args)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, test.err)
+	}
+}
@@ -5,6 +5,7 @@ package utils
 
 import (
 	"math"
+	"math/big"
 	"strconv"
 	"strings"
 	"unicode"
@@ -17,6 +18,9 @@ import (
 // The string must be a is a non-negative number with
 // an optional multiplier suffix (M, G, T, P, E, Z, or Y).
 // If the suffix is not specified, "M" is implied.
+//
+// ParseSize rounds sizes up to whole mebibytes; use ParseSizeBytes
+// to parse sizes in bytes.
 func ParseSize(str string) (MB uint64, err error) {
 	// Find the first non-digit/period:
 	i := strings.IndexFunc(str, func(r rune) bool {
@@ -55,6 +59,119 @@ func sizeSuffixMultiplier(i int) int {
 	return 1 << uint(i*10)
 }
 
+// SizeBase selects the multipliers of the size suffixes which do not
+// explicitly name one.
+type SizeBase int
+
+const (
+	// SizeIEC reads all suffixes as powers of 1024, so that "M", "MB"
+	// and "MiB" are all mebibytes, as ParseSize does.
+	SizeIEC SizeBase = iota
+
+	// SizeSI reads the suffixes without an "i" as powers of 1000,
+	// so that "M" and "MB" are megabytes while "MiB" is a mebibyte.
+	SizeSI
+)
+
+// byteSizeSuffixes are the size suffixes understood by ParseSizeBytes
+// and used by FormatSize, in increasing order of magnitude. Larger ones
+// do not fit in a uint64.
+var byteSizeSuffixes = "KMGTPE"
+
+// ParseSizeBytes parses the string as a size, in bytes.
+//
+// The string must be a non-negative number with an optional multiplier
+// suffix (K, M, G, T, P or E), optionally followed by "B" or "iB" and
+// separated from the number by spaces. Suffixes ending in "iB" are
+// always powers of 1024, the others are read according to the given
+// base. The suffixes are case insensitive. If no suffix is specified,
+// the size is in bytes; a plain "B" suffix is accepted too.
+func ParseSizeBytes(str string, base SizeBase) (uint64, error) {
+	orig := str
+	str = strings.TrimSpace(str)
+	i := strings.IndexFunc(str, func(r rune) bool {
+		return r != '.' && !unicode.IsDigit(r)
+	})
+	multiplier := big.NewInt(1)
+	if i > 0 {
+		suffix := strings.TrimSpace(str[i:])
+		str = str[:i]
+		m, err := sizeMultiplier(suffix, base)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		multiplier = m
+	}
+
+	// the number is scaled with enough precision for fractions of
+	// exbibytes not to be rounded, and only then rounded up.
+	val, ok := new(big.Float).SetPrec(128).SetString(str)
+	if !ok || val.Sign() < 0 || str == "" {
+		return 0, errors.Errorf("expected a non-negative number, got %q", str)
+	}
+	val.Mul(val, new(big.Float).SetInt(multiplier))
+	size, _ := val.Int(nil)
+	if !val.IsInt() {
+		size.Add(size, big.NewInt(1))
+	}
+	if !size.IsUint64() {
+		return 0, errors.Errorf("size %q overflows 64 bits", orig)
+	}
+	return size.Uint64(), nil
+}
+
+// sizeMultiplier returns the multiplier of the given size suffix,
+// read according to the given base.
+func sizeMultiplier(suffix string, base SizeBase) (*big.Int, error) {
+	upper := strings.ToUpper(suffix)
+	if upper == "B" {
+		return big.NewInt(1), nil
+	}
+	for j := 0; j < len(byteSizeSuffixes); j++ {
+		unit := string(byteSizeSuffixes[j])
+		step := int64(1024)
+		switch upper {
+		case unit + "IB":
+		case unit, unit + "B":
+			if base == SizeSI {
+				step = 1000
+			}
+		default:
+			continue
+		}
+		return new(big.Int).Exp(big.NewInt(step), big.NewInt(int64(j+1)), nil), nil
+	}
+	return nil, errors.Errorf("invalid multiplier suffix %q, expected one of %s", suffix, []byte(byteSizeSuffixes))
+}
+
+// FormatSize returns the given size, in bytes, in a human readable form
+// such as "512B", "1.5KiB" or "3.2GB", using the largest unit it is at
+// least one of. With SizeIEC the units are powers of 1024 with an "iB"
+// suffix, with SizeSI powers of 1000 with a "B" suffix. The result is
+// rounded to one decimal place.
+func FormatSize(size uint64, base SizeBase) string {
+	step, suffix := 1024.0, "iB"
+	if base == SizeSI {
+		step, suffix = 1000.0, "B"
+	}
+	val := float64(size)
+	unit := -1
+	for unit+1 < len(byteSizeSuffixes) && val >= step {
+		val /= step
+		unit++
+	}
+	if unit == -1 {
+		return strconv.FormatUint(size, 10) + "B"
+	}
+	formatted := strconv.FormatFloat(val, 'f', 1, 64)
+	if formatted == strconv.FormatFloat(step, 'f', 1, 64) && unit+1 < len(byteSizeSuffixes) {
+		// the value was rounded up to the next unit.
+		formatted, unit = "1.0", unit+1
+	}
+	formatted = strings.TrimSuffix(formatted, ".0")
+	return formatted + string(byteSizeSuffixes[unit]) + suffix
+}
+
 // SizeTracker tracks the number of bytes passing through
 // its Write method (which is otherwise a no-op).
 //
@@ -83,6 +83,125 @@ func (*sizeSuite) TestParseSize(c *gc.C) {
 	}
 }
 
+func (*sizeSuite) TestParseSizeBytes(c *gc.C) {
+	type test struct {
+		in   string
+		base utils.SizeBase
+		out  uint64
+		err  string
+	}
+	tests := []test{{
+		in:  "",
+		err: `expected a non-negative number, got ""`,
+	}, {
+		in:  "-1K",
+		err: `expected a non-negative number, got "-1K"`,
+	}, {
+		in:  "1kZ",
+		err: `invalid multiplier suffix "kZ", expected one of KMGTPE`,
+	}, {
+		in:  "1Z",
+		err: `invalid multiplier suffix "Z", expected one of KMGTPE`,
+	}, {
+		in:  "16E",
+		err: `size "16E" overflows 64 bits`,
+	}, {
+		in:  "0",
+		out: 0,
+	}, {
+		in:  "123",
+		out: 123,
+	}, {
+		in:  "123B",
+		out: 123,
+	}, {
+		in:  "1K",
+		out: 1024,
+	}, {
+		in:   "1K",
+		base: utils.SizeSI,
+		out:  1000,
+	}, {
+		in:   "1kB",
+		base: utils.SizeSI,
+		out:  1000,
+	}, {
+		in:   "1KiB",
+		base: utils.SizeSI,
+		out:  1024,
+	}, {
+		in:  "1.5 MiB",
+		out: 1572864,
+	}, {
+		in:   "1.5 MB",
+		base: utils.SizeSI,
+		out:  1500000,
+	}, {
+		in:   "0.0001K",
+		base: utils.SizeSI,
+		out:  1,
+	}, {
+		in:  "15.999999999999999999E",
+		out: 18446744073709551615,
+	}, {
+		in:  "18446744073709551615",
+		out: 18446744073709551615,
+	}}
+	for i, test := range tests {
+		c.Logf("test %d: %+v", i, test)
+		size, err := utils.ParseSizeBytes(test.in, test.base)
+		if test.err != "" {
+			c.Check(err, gc.ErrorMatches, test.err)
+		} else {
+			c.Check(err, jc.ErrorIsNil)
+			c.Check(size, gc.Equals, test.out)
+		}
+	}
+}
+
+func (*sizeSuite) TestFormatSize(c *gc.C) {
+	type test struct {
+		in   uint64
+		base utils.SizeBase
+		out  string
+	}
+	tests := []test{{
+		in:  0,
+		out: "0B",
+	}, {
+		in:  1023,
+		out: "1023B",
+	}, {
+		in:   999,
+		base: utils.SizeSI,
+		out:  "999B",
+	}, {
+		in:  1024,
+		out: "1KiB",
+	}, {
+		in:   1000,
+		base: utils.SizeSI,
+		out:  "1KB",
+	}, {
+		in:  1536,
+		out: "1.5KiB",
+	}, {
+		in:   3200000000,
+		base: utils.SizeSI,
+		out:  "3.2GB",
+	}, {
+		in:  1048575,
+		out: "1MiB",
+	}, {
+		in:  18446744073709551615,
+		out: "16EiB",
+	}}
+	for i, test := range tests {
+		c.Logf("test %d: %+v", i, test)
+		c.Check(utils.FormatSize(test.in, test.base), gc.Equals, test.out)
+	}
+}
+
 func (*sizeSuite) TestSizingReaderOkay(c *gc.C) {
 	expected := "some data"
 	stub := &testing.Stub{}
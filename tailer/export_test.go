@@ -4,6 +4,15 @@
 package tailer
 
 var (
-	BufferSize    = &bufferSize
-	NewTestTailer = newTailer
+	BufferSize        = &bufferSize
+	NewTestTailer     = newTailer
+	NewTestFileTailer = newFileTailer
+	NotifyPolltime    = &notifyPolltime
+	NewWatcher        = &newWatcher
 )
+
+// NoWatcher makes file tailers poll their files when patched as
+// the NewWatcher function.
+func NoWatcher(string) (watcher, error) {
+	return nil, errNotifyNotSupported
+}
@@ -0,0 +1,34 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tailer
+
+import (
+	"errors"
+	"time"
+)
+
+// errNotifyNotSupported is returned by newNotifyWatcher on the
+// platforms without file system notifications.
+var errNotifyNotSupported = errors.New("file system notifications not supported")
+
+var (
+	// newWatcher returns a watcher of the file with the given name,
+	// or an error if the file cannot be watched, in which case it is
+	// polled. It is a variable for testing purposes.
+	newWatcher = newNotifyWatcher
+
+	// notifyPolltime is the time between pollings of watched files,
+	// which catch the changes the notifications might miss.
+	notifyPolltime = 30 * time.Second
+)
+
+// watcher signals the changes of a followed file.
+type watcher interface {
+	// Changes returns a channel receiving a value when the file,
+	// or the directory holding it, may have changed.
+	Changes() <-chan struct{}
+
+	// Close stops the watcher.
+	Close() error
+}
@@ -0,0 +1,107 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tailer
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyWatcher implements watcher with inotify, watching the directory
+// holding the file, so that the file being replaced is noticed as well
+// as the file being written to.
+type inotifyWatcher struct {
+	file    *os.File
+	name    string
+	changes chan struct{}
+	done    chan struct{}
+}
+
+// inotifyMask holds the events of the directory which are watched.
+const inotifyMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE |
+	syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// newNotifyWatcher returns a watcher of the file with the given name
+// using inotify.
+func newNotifyWatcher(filename string) (watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	dir, name := filepath.Split(filepath.Clean(filename))
+	if dir == "" {
+		dir = "."
+	}
+	if _, err := syscall.InotifyAddWatch(fd, dir, inotifyMask); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("inotify_add_watch", err)
+	}
+	w := &inotifyWatcher{
+		// The file is non-blocking, so that reading
+		// it is interrupted by closing it.
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		name:    name,
+		changes: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go w.loop()
+	return w, nil
+}
+
+// Changes implements watcher.
+func (w *inotifyWatcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+// Close implements watcher.
+func (w *inotifyWatcher) Close() error {
+	err := w.file.Close()
+	<-w.done
+	return err
+}
+
+// loop reads the events until the watcher is closed, signalling those
+// which concern the file. Entries being created or renamed are always
+// signalled, as they may be the file being rotated.
+func (w *inotifyWatcher) loop() {
+	defer close(w.done)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		signal := false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+			name := string(trimNul(nameBytes))
+			switch {
+			case event.Mask&syscall.IN_Q_OVERFLOW != 0,
+				event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_FROM|syscall.IN_MOVED_TO) != 0,
+				name == w.name:
+				signal = true
+			}
+		}
+		if signal {
+			select {
+			case w.changes <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// trimNul returns the given name without its NUL padding.
+func trimNul(name []byte) []byte {
+	for i, b := range name {
+		if b == 0 {
+			return name[:i]
+		}
+	}
+	return name
+}
@@ -0,0 +1,12 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// +build !linux
+
+package tailer
+
+// newNotifyWatcher returns errNotifyNotSupported, so that files are
+// polled on the platforms other than linux.
+func newNotifyWatcher(filename string) (watcher, error) {
+	return nil, errNotifyNotSupported
+}
@@ -6,10 +6,14 @@ package tailer
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"io"
+	"io/ioutil"
 	"os"
+	"sync/atomic"
 	"time"
 
+	"github.com/juju/errors"
 	"launchpad.net/tomb"
 )
 
@@ -28,9 +32,64 @@ var (
 // returns true) of shall be omitted (func returns false).
 type TailerFilterFunc func(line []byte) bool
 
+// OverflowPolicy decides what a tailer does with the lines it reads
+// while its buffer of lines to write is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes the tailer stop reading until there
+	// is room in the buffer.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest makes the tailer drop the oldest line
+	// of the buffer to make room for the new one.
+	OverflowDropOldest
+
+	// OverflowError makes the tailer stop with ErrOverflow.
+	OverflowError
+)
+
+// ErrOverflow is the error of the tailers using OverflowError
+// whose buffer overflowed.
+var ErrOverflow = errors.New("tailer buffer overflow")
+
+// Config holds the configuration of the tailers started with
+// NewTailerContext and NewFileTailerContext.
+type Config struct {
+	// Filter, if set, decides which lines are written.
+	Filter TailerFilterFunc
+
+	// BufferLines is the maximum number of lines read but not yet
+	// written to the writer. If it is zero, the lines are written as
+	// they are read and reading waits for the writer.
+	BufferLines int
+
+	// Overflow decides what happens to the lines read
+	// while the buffer is full.
+	Overflow OverflowPolicy
+}
+
+// Validate returns an error satisfying errors.IsNotValid
+// if the configuration cannot be used by a tailer.
+func (config Config) Validate() error {
+	if config.BufferLines < 0 {
+		return errors.NotValidf("negative BufferLines")
+	}
+	switch config.Overflow {
+	case OverflowBlock, OverflowDropOldest, OverflowError:
+	default:
+		return errors.NotValidf("overflow policy %d", config.Overflow)
+	}
+	return nil
+}
+
 // Tailer reads an input line by line an tails them into the passed Writer.
 // The lines have to be terminated with a newline.
 type Tailer struct {
+	// dropped is accessed atomically, and first
+	// so that it is aligned on 32 bit platforms.
+	dropped uint64
+
 	tomb        tomb.Tomb
 	readSeeker  io.ReadSeeker
 	reader      *bufio.Reader
@@ -38,6 +97,17 @@ type Tailer struct {
 	writer      *bufio.Writer
 	filter      TailerFilterFunc
 	polltime    time.Duration
+
+	// file is the followed file, if the tailer follows a file
+	// by name, and watcher signals its changes if it is watched
+	// rather than polled.
+	file    *os.File
+	watcher watcher
+
+	// lines buffers the lines to write if the
+	// tailer has a buffer, with the policy overflow.
+	lines    chan []byte
+	overflow OverflowPolicy
 }
 
 // NewTailer starts a Tailer which reads strings from the passed
@@ -52,20 +122,115 @@ func NewTailer(readSeeker io.ReadSeeker, writer io.Writer, filter TailerFilterFu
 // the read buffer size and the time between pollings for testing.
 func newTailer(readSeeker io.ReadSeeker, writer io.Writer,
 	filter TailerFilterFunc, polltime time.Duration) *Tailer {
+	return startTailer(context.Background(), readSeeker, nil, writer, Config{Filter: filter}, polltime)
+}
+
+// NewFileTailer starts a Tailer which reads the given file like
+// NewTailer does, from its current position, and follows it by name: when
+// the file is rotated by being renamed and replaced by a new one, the
+// rest of the old file is read before the new one is read from its start.
+// Files truncated in place are also read again from their start, as all
+// the inputs of tailers are.
+//
+// The file is watched with the notifications of the file system where they
+// are supported (inotify on linux), and otherwise polled.
+//
+// The tailer takes ownership of the file, and closes the files it reads
+// when they are rotated or the tailer stops.
+func NewFileTailer(file *os.File, writer io.Writer, filter TailerFilterFunc) *Tailer {
+	return newFileTailer(file, writer, filter, polltime)
+}
+
+// newFileTailer starts a Tailer like NewFileTailer but allows the
+// setting of the time between pollings for testing.
+func newFileTailer(file *os.File, writer io.Writer,
+	filter TailerFilterFunc, polltime time.Duration) *Tailer {
+	return startTailer(context.Background(), file, file, writer, Config{Filter: filter}, polltime)
+}
+
+// NewTailerContext starts a Tailer like NewTailer, with the given
+// configuration, which stops with the error of the given context when
+// the context is done.
+func NewTailerContext(ctx context.Context, readSeeker io.ReadSeeker, writer io.Writer, config Config) (*Tailer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return startTailer(ctx, readSeeker, nil, writer, config, polltime), nil
+}
+
+// NewFileTailerContext starts a Tailer like NewFileTailer, with the given
+// configuration, which stops with the error of the given context when
+// the context is done.
+func NewFileTailerContext(ctx context.Context, file *os.File, writer io.Writer, config Config) (*Tailer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return startTailer(ctx, file, file, writer, config, polltime), nil
+}
+
+// startTailer starts a Tailer reading the given ReadSeeker, which
+// follows the given file by name if it is not nil.
+func startTailer(ctx context.Context, readSeeker io.ReadSeeker, file *os.File,
+	writer io.Writer, config Config, polltime time.Duration) *Tailer {
 	t := &Tailer{
 		readSeeker: readSeeker,
 		reader:     bufio.NewReaderSize(readSeeker, bufferSize),
 		writer:     bufio.NewWriter(writer),
-		filter:     filter,
+		filter:     config.Filter,
 		polltime:   polltime,
+		file:       file,
+		overflow:   config.Overflow,
+	}
+	if config.BufferLines > 0 {
+		t.lines = make(chan []byte, config.BufferLines)
+	}
+	if file != nil {
+		if w, err := newWatcher(file.Name()); err == nil {
+			t.watcher = w
+		}
 	}
 	go func() {
 		defer t.tomb.Done()
+		defer func() {
+			if t.watcher != nil {
+				t.watcher.Close()
+			}
+			if t.file != nil {
+				t.file.Close()
+			}
+		}()
+		readDone := make(chan struct{})
+		if t.lines != nil {
+			writerDone := make(chan struct{})
+			go func() {
+				defer close(writerDone)
+				t.tomb.Kill(t.writeLoop(readDone))
+			}()
+			defer func() {
+				<-writerDone
+			}()
+		}
+		if ctx.Done() != nil {
+			go func() {
+				select {
+				case <-ctx.Done():
+					t.tomb.Kill(ctx.Err())
+				case <-t.tomb.Dying():
+				}
+			}()
+		}
 		t.tomb.Kill(t.loop())
+		close(readDone)
 	}()
 	return t
 }
 
+// Dropped returns the number of lines the tailer dropped because its
+// buffer was full, with OverflowDropOldest.
+func (t *Tailer) Dropped() uint64 {
+	return atomic.LoadUint64(&t.dropped)
+}
+
 // Stop tells the tailer to stop working.
 func (t *Tailer) Stop() error {
 	t.tomb.Kill(nil)
@@ -94,36 +259,223 @@ func (t *Tailer) Err() error {
 // writer too.
 func (t *Tailer) loop() error {
 	// Start polling.
-	// TODO(mue) 2013-12-06
-	// Handling of read-seeker/files being truncated during
-	// tailing is currently missing!
+	polltime := t.polltime
+	var changes <-chan struct{}
+	if t.watcher != nil {
+		polltime = notifyPolltime
+		changes = t.watcher.Changes()
+	}
 	timer := time.NewTimer(0)
 	for {
 		select {
 		case <-t.tomb.Dying():
 			return nil
-		case <-timer.C:
-			for {
-				line, readErr := t.readLine()
-				_, writeErr := t.writer.Write(line)
-				if writeErr != nil {
-					return writeErr
+		case <-changes:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
 				}
-				if readErr != nil {
-					if readErr != io.EOF {
-						return readErr
-					}
-					break
+			}
+		case <-timer.C:
+		}
+		if err := t.writeLines(); err != nil {
+			return err
+		}
+		rotated, err := t.checkRotation()
+		if err != nil {
+			return err
+		}
+		if rotated {
+			// The new input may already hold lines.
+			timer.Reset(0)
+		} else {
+			timer.Reset(polltime)
+		}
+	}
+}
+
+// writeLines writes the lines read until the end of the input
+// to the writer.
+func (t *Tailer) writeLines() error {
+	for {
+		line, readErr := t.readLine()
+		if writeErr := t.writeLine(line); writeErr != nil {
+			return writeErr
+		}
+		if readErr != nil {
+			if readErr != io.EOF {
+				return readErr
+			}
+			break
+		}
+	}
+	if t.lines != nil {
+		// writeLoop flushes the writer.
+		return nil
+	}
+	return t.writer.Flush()
+}
+
+// writeLine writes the given line to the writer, or adds it to the
+// buffer if the tailer has one, applying its overflow policy.
+func (t *Tailer) writeLine(line []byte) error {
+	if t.lines == nil {
+		_, err := t.writer.Write(line)
+		return err
+	}
+	if len(line) == 0 {
+		return nil
+	}
+	// The line is overwritten by the next read.
+	line = append([]byte(nil), line...)
+	for {
+		select {
+		case t.lines <- line:
+			return nil
+		default:
+		}
+		switch t.overflow {
+		case OverflowDropOldest:
+			select {
+			case <-t.lines:
+				atomic.AddUint64(&t.dropped, 1)
+			default:
+			}
+		case OverflowError:
+			return ErrOverflow
+		default:
+			select {
+			case t.lines <- line:
+				return nil
+			case <-t.tomb.Dying():
+				return tomb.ErrDying
+			}
+		}
+	}
+}
+
+// writeLoop writes the buffered lines to the writer until the tailer
+// stops, flushing the writer whenever the buffer is empty. Once the
+// tailer is stopping, the lines read before readDone is closed are
+// still written.
+func (t *Tailer) writeLoop(readDone <-chan struct{}) error {
+	for {
+		select {
+		case <-t.tomb.Dying():
+			<-readDone
+			if err := t.flushLines(); err != nil {
+				return err
+			}
+			return tomb.ErrDying
+		case line := <-t.lines:
+			if _, err := t.writer.Write(line); err != nil {
+				return err
+			}
+			if len(t.lines) == 0 {
+				if err := t.writer.Flush(); err != nil {
+					return err
 				}
 			}
-			if writeErr := t.writer.Flush(); writeErr != nil {
-				return writeErr
+		}
+	}
+}
+
+// flushLines writes the lines left in the buffer
+// to the writer and flushes it.
+func (t *Tailer) flushLines() error {
+	for {
+		select {
+		case line := <-t.lines:
+			if _, err := t.writer.Write(line); err != nil {
+				return err
 			}
-			timer.Reset(t.polltime)
+		default:
+			return t.writer.Flush()
 		}
 	}
 }
 
+// checkRotation checks, at the end of the input, whether the input was
+// truncated, in which case it is read again from its start, or whether
+// the followed file was replaced by a new one, in which case the rest of
+// the old file is written, including an unterminated last line, and the
+// new file is read from its start. It returns whether the tailer reads a
+// new input.
+func (t *Tailer) checkRotation() (bool, error) {
+	offset, err := t.readSeeker.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return false, err
+	}
+	size, err := t.readSeeker.Seek(0, os.SEEK_END)
+	if err != nil {
+		return false, err
+	}
+	if size < offset {
+		// Truncated, the lines before the current
+		// offset were replaced.
+		if _, err := t.readSeeker.Seek(0, os.SEEK_SET); err != nil {
+			return false, err
+		}
+		t.reader.Reset(t.readSeeker)
+		return true, nil
+	}
+	if _, err := t.readSeeker.Seek(offset, os.SEEK_SET); err != nil {
+		return false, err
+	}
+	if t.file == nil {
+		return false, nil
+	}
+
+	current, err := t.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	newFile, err := os.Open(t.file.Name())
+	if os.IsNotExist(err) {
+		// Renamed but not replaced yet.
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	latest, err := newFile.Stat()
+	if err != nil || os.SameFile(current, latest) {
+		newFile.Close()
+		return false, err
+	}
+	// The old file may have been written to until it was replaced.
+	if err := t.writeLines(); err != nil {
+		newFile.Close()
+		return false, err
+	}
+	if err := t.writeUnterminatedLine(); err != nil {
+		newFile.Close()
+		return false, err
+	}
+	t.file.Close()
+	t.file = newFile
+	t.readSeeker = newFile
+	t.reader.Reset(newFile)
+	return true, nil
+}
+
+// writeUnterminatedLine writes the unterminated last line of the input,
+// if any, to the writer, terminating it.
+func (t *Tailer) writeUnterminatedLine() error {
+	line, err := ioutil.ReadAll(t.reader)
+	if err != nil || len(line) == 0 {
+		return err
+	}
+	line = append(line, delimiter)
+	if !t.isValid(line) {
+		return nil
+	}
+	if err := t.writeLine(line); err != nil || t.lines != nil {
+		return err
+	}
+	return t.writer.Flush()
+}
+
 // SeekLastLines sets the read position of the ReadSeeker to the
 // wanted number of filtered lines before the end.
 func SeekLastLines(readSeeker io.ReadSeeker, lines uint, filter TailerFilterFunc) error {
@@ -6,13 +6,23 @@ package tailer_test
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/juju/errors"
 	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
+	"launchpad.net/tomb"
 
 	"github.com/juju/utils/tailer"
 )
@@ -364,6 +374,259 @@ func (s *tailerSuite) TestTailer(c *gc.C) {
 	}
 }
 
+func (s *tailerSuite) TestFileTailerTruncated(c *gc.C) {
+	filename := filepath.Join(c.MkDir(), "test.log")
+	err := ioutil.WriteFile(filename, []byte("alpha alpha\nbravo bravo\n"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	file, err := os.Open(filename)
+	c.Assert(err, jc.ErrorIsNil)
+
+	reader, writer := io.Pipe()
+	tailer := tailer.NewTestFileTailer(file, writer, nil, 2*time.Millisecond)
+	linec := startReading(c, tailer, reader, writer)
+	assertCollected(c, linec, alphabetData[:2], nil)
+
+	// Truncate the file in place with shorter contents.
+	err = ioutil.WriteFile(filename, []byte("zulu zulu\n"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	assertCollected(c, linec, []string{"zulu zulu\n"}, nil)
+	c.Assert(tailer.Stop(), jc.ErrorIsNil)
+}
+
+func (s *tailerSuite) TestFileTailerRotated(c *gc.C) {
+	s.PatchValue(tailer.NewWatcher, tailer.NoWatcher)
+	assertFollowsRotation(c, 2*time.Millisecond)
+}
+
+func (s *tailerSuite) TestFileTailerRotatedNotified(c *gc.C) {
+	if runtime.GOOS != "linux" {
+		c.Skip("file system notifications are only used on linux")
+	}
+	// The changes are only noticed by being notified.
+	s.PatchValue(tailer.NotifyPolltime, time.Hour)
+	assertFollowsRotation(c, time.Hour)
+}
+
+// assertFollowsRotation checks that a file tailer polling its file
+// with the given period follows it as it is rotated.
+func assertFollowsRotation(c *gc.C, polltime time.Duration) {
+	filename := filepath.Join(c.MkDir(), "test.log")
+	logFile, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	defer logFile.Close()
+	_, err = logFile.WriteString("alpha alpha\nbravo bravo\n")
+	c.Assert(err, jc.ErrorIsNil)
+	file, err := os.Open(filename)
+	c.Assert(err, jc.ErrorIsNil)
+
+	reader, writer := io.Pipe()
+	tailer := tailer.NewTestFileTailer(file, writer, nil, polltime)
+	linec := startReading(c, tailer, reader, writer)
+	assertCollected(c, linec, alphabetData[:2], nil)
+
+	// Rotate the file, with the logger writing to the old one
+	// until it is replaced.
+	err = os.Rename(filename, filename+".1")
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = logFile.WriteString("charlie charlie\ndelta")
+	c.Assert(err, jc.ErrorIsNil)
+	err = ioutil.WriteFile(filename, []byte("echo echo\n"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	assertCollected(c, linec, []string{
+		"charlie charlie\n",
+		"delta\n",
+		"echo echo\n",
+	}, nil)
+
+	// The new file keeps being followed.
+	newLogFile, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	defer newLogFile.Close()
+	_, err = newLogFile.WriteString("foxtrott foxtrott\n")
+	c.Assert(err, jc.ErrorIsNil)
+	assertCollected(c, linec, []string{"foxtrott foxtrott\n"}, nil)
+	c.Assert(tailer.Stop(), jc.ErrorIsNil)
+}
+
+func (s *tailerSuite) TestNewTailerContextInvalidConfig(c *gc.C) {
+	_, err := tailer.NewTailerContext(context.Background(), &readSeeker{}, ioutil.Discard, tailer.Config{
+		BufferLines: -1,
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	_, err = tailer.NewTailerContext(context.Background(), &readSeeker{}, ioutil.Discard, tailer.Config{
+		Overflow: tailer.OverflowPolicy(42),
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *tailerSuite) TestTailerContextCancelled(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rs := startReadSeeker(c, alphabetData, 3, make(chan struct{}))
+	reader, writer := io.Pipe()
+	tail, err := tailer.NewTailerContext(ctx, rs, writer, tailer.Config{})
+	c.Assert(err, jc.ErrorIsNil)
+	linec := startReading(c, tail, reader, writer)
+	assertCollected(c, linec, alphabetData[:3], nil)
+
+	cancel()
+	c.Assert(tail.Wait(), gc.Equals, context.Canceled)
+}
+
+func (s *tailerSuite) TestTailerBufferBlocks(c *gc.C) {
+	w := newGatedWriter()
+	rs := newFullReadSeeker(alphabetData)
+	tail, err := tailer.NewTailerContext(context.Background(), rs, w, tailer.Config{
+		BufferLines: 2,
+		Overflow:    tailer.OverflowBlock,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	close(w.gate)
+	w.waitForLines(c, len(alphabetData))
+	c.Assert(w.lines(), gc.DeepEquals, alphabetData)
+	c.Assert(tail.Dropped(), gc.Equals, uint64(0))
+	c.Assert(tail.Stop(), jc.ErrorIsNil)
+}
+
+func (s *tailerSuite) TestTailerBufferDropsOldest(c *gc.C) {
+	w := newGatedWriter()
+	rs := newFullReadSeeker(alphabetData)
+	tail, err := tailer.NewTailerContext(context.Background(), rs, w, tailer.Config{
+		BufferLines: 2,
+		Overflow:    tailer.OverflowDropOldest,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	// All the lines are read while the writer is blocked, with
+	// at most one line taken by the writer before the buffer.
+	waitFor(c, "lines to be dropped", func() bool {
+		return tail.Dropped() >= uint64(len(alphabetData)-3)
+	})
+	close(w.gate)
+	written := len(alphabetData) - int(tail.Dropped())
+	w.waitForLines(c, written)
+	lines := w.lines()
+	c.Assert(lines[len(lines)-2:], gc.DeepEquals, alphabetData[len(alphabetData)-2:])
+	c.Assert(tail.Stop(), jc.ErrorIsNil)
+}
+
+func (s *tailerSuite) TestTailerBufferOverflowError(c *gc.C) {
+	w := newGatedWriter()
+	rs := newFullReadSeeker(alphabetData)
+	tail, err := tailer.NewTailerContext(context.Background(), rs, w, tailer.Config{
+		BufferLines: 2,
+		Overflow:    tailer.OverflowError,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The tailer stops reading as soon as the buffer overflows,
+	// but waits for the blocked writer to finish.
+	waitFor(c, "the buffer to overflow", func() bool {
+		return tail.Err() == tailer.ErrOverflow
+	})
+	close(w.gate)
+	c.Assert(tail.Wait(), gc.Equals, tailer.ErrOverflow)
+}
+
+func (s *tailerSuite) TestTailerStopWritesBufferedLines(c *gc.C) {
+	w := newGatedWriter()
+	rs := newFullReadSeeker(alphabetData)
+	var read int32
+	filter := func(line []byte) bool {
+		if atomic.AddInt32(&read, 1) == 2 {
+			// The writer blocks on the first line while
+			// the others are buffered.
+			<-w.writing
+		}
+		return true
+	}
+	tail, err := tailer.NewTailerContext(context.Background(), rs, w, tailer.Config{
+		Filter:      filter,
+		BufferLines: len(alphabetData),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	waitFor(c, "the lines to be read", func() bool {
+		return atomic.LoadInt32(&read) == int32(len(alphabetData))
+	})
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- tail.Stop()
+	}()
+	waitFor(c, "the tailer to stop", func() bool {
+		return tail.Err() != tomb.ErrStillAlive
+	})
+	close(w.gate)
+	c.Assert(<-stopped, jc.ErrorIsNil)
+	c.Assert(w.lines(), gc.DeepEquals, alphabetData)
+}
+
+// gatedWriter records the lines written to it once its gate is closed.
+type gatedWriter struct {
+	gate chan struct{}
+
+	// writing is closed when the first write starts.
+	writing     chan struct{}
+	writingOnce sync.Once
+
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{
+		gate:    make(chan struct{}),
+		writing: make(chan struct{}),
+	}
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	w.writingOnce.Do(func() {
+		close(w.writing)
+	})
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buffer.Write(p)
+}
+
+func (w *gatedWriter) lines() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	lines := strings.SplitAfter(w.buffer.String(), "\n")
+	return lines[:len(lines)-1]
+}
+
+func (w *gatedWriter) waitForLines(c *gc.C, n int) {
+	waitFor(c, "lines to be written", func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return bytes.Count(w.buffer.Bytes(), []byte("\n")) >= n
+	})
+}
+
+// waitFor waits for the given condition to be true.
+func waitFor(c *gc.C, what string, condition func() bool) {
+	timeout := time.After(10 * time.Second)
+	for !condition() {
+		select {
+		case <-timeout:
+			c.Fatalf("timeout waiting for %s", what)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// newFullReadSeeker returns a ReadSeeker holding all the given lines.
+func newFullReadSeeker(data []string) *readSeeker {
+	var rs readSeeker
+	for _, line := range data {
+		rs.write(line)
+	}
+	return &rs
+}
+
 // startReading starts a goroutine receiving the lines out of the reader
 // in the background and passing them to a created string channel. This
 // will used in the assertions.
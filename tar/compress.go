@@ -0,0 +1,318 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tar
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// Compression is a compression format of tar archives.
+type Compression string
+
+const (
+	// NoCompression leaves tar archives uncompressed.
+	NoCompression Compression = ""
+
+	// Gzip is the gzip format of .tar.gz and .tgz archives.
+	Gzip Compression = "gzip"
+
+	// Xz is the xz format of .tar.xz and .txz archives. It is
+	// compressed and uncompressed by the xz command.
+	Xz Compression = "xz"
+
+	// Zstd is the Zstandard format of .tar.zst and .tzst archives.
+	// It is compressed and uncompressed by the zstd command.
+	Zstd Compression = "zstd"
+)
+
+// DefaultCompressionLevel selects the default level of a compression.
+const DefaultCompressionLevel = 0
+
+// compressionLevels holds the range of the levels of each compression.
+var compressionLevels = map[Compression][2]int{
+	Gzip: {gzip.BestSpeed, gzip.BestCompression},
+	Xz:   {1, 9},
+	Zstd: {1, 19},
+}
+
+// compressionCommands holds the commands used for the compressions
+// which are not implemented by the standard library.
+// It is a variable for testing purposes.
+var compressionCommands = map[Compression]string{
+	Xz:   "xz",
+	Zstd: "zstd",
+}
+
+// compressionMagics holds the bytes starting the streams of
+// each compression.
+var compressionMagics = map[Compression][]byte{
+	Gzip: {0x1f, 0x8b},
+	Xz:   {0xfd, '7', 'z', 'X', 'Z', 0x00},
+	Zstd: {0x28, 0xb5, 0x2f, 0xfd},
+}
+
+// compressionExtensions holds the file name extensions of the
+// archives of each compression.
+var compressionExtensions = map[Compression][]string{
+	Gzip: {".tar.gz", ".tgz"},
+	Xz:   {".tar.xz", ".txz"},
+	Zstd: {".tar.zst", ".tar.zstd", ".tzst"},
+}
+
+// Validate returns an error satisfying errors.IsNotValid
+// if the compression is not a known one.
+func (c Compression) Validate() error {
+	if c == NoCompression {
+		return nil
+	}
+	if _, ok := compressionLevels[c]; !ok {
+		return errors.NotValidf("compression %q", string(c))
+	}
+	return nil
+}
+
+// CompressionFromName returns the compression of the archive
+// with the given file name, according to its extension.
+func CompressionFromName(name string) Compression {
+	name = strings.ToLower(name)
+	for c, extensions := range compressionExtensions {
+		for _, extension := range extensions {
+			if strings.HasSuffix(name, extension) {
+				return c
+			}
+		}
+	}
+	return NoCompression
+}
+
+// DetectCompression returns the compression of the archive
+// starting with the given bytes.
+func DetectCompression(header []byte) Compression {
+	for c, magic := range compressionMagics {
+		if bytes.HasPrefix(header, magic) {
+			return c
+		}
+	}
+	return NoCompression
+}
+
+// NewCompressWriter returns a writer compressing what is written to
+// it with the given compression and level into w. The level is
+// between 1 and 9 with gzip and xz, and between 1 and 19 with
+// zstd; DefaultCompressionLevel selects the default one of the
+// compression. Closing the writer flushes the compressed stream,
+// but does not close w.
+//
+// An error satisfying errors.IsNotSupported is returned if the
+// compression requires a command which cannot be found.
+func NewCompressWriter(w io.Writer, c Compression, level int) (io.WriteCloser, error) {
+	if err := validateCompression(c, level); err != nil {
+		return nil, errors.Trace(err)
+	}
+	switch c {
+	case NoCompression:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		if level == DefaultCompressionLevel {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	}
+	args := []string{"--compress", "--stdout"}
+	if level != DefaultCompressionLevel {
+		args = append(args, fmt.Sprintf("-%d", level))
+	}
+	cmd, err := compressionCommand(c, args...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	cw := &commandWriter{cmd: cmd}
+	cmd.Stdout = w
+	cmd.Stderr = &cw.stderr
+	if cw.stdin, err = cmd.StdinPipe(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Annotatef(err, "cannot start %s compression", c)
+	}
+	return cw, nil
+}
+
+// NewDecompressReader returns a reader uncompressing what is read from
+// r, with the compression detected from its first bytes. Uncompressed
+// streams are read as they are. Closing the reader releases its
+// resources, but does not close r.
+//
+// An error satisfying errors.IsNotSupported is returned if the
+// compression requires a command which cannot be found.
+func NewDecompressReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(6)
+	if err != nil && err != io.EOF {
+		return nil, errors.Annotate(err, "cannot read archive")
+	}
+	switch c := DetectCompression(header); c {
+	case NoCompression:
+		return ioutil.NopCloser(br), nil
+	case Gzip:
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, errors.Annotate(err, "cannot read gzip archive")
+		}
+		return gr, nil
+	default:
+		cmd, err := compressionCommand(c, "--decompress", "--stdout")
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		cr := &commandReader{cmd: cmd}
+		cmd.Stdin = br
+		cmd.Stderr = &cr.stderr
+		if cr.stdout, err = cmd.StdoutPipe(); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, errors.Annotatef(err, "cannot start %s decompression", c)
+		}
+		return cr, nil
+	}
+}
+
+// validateCompression returns an error satisfying errors.IsNotValid
+// if the given compression or level are not valid.
+func validateCompression(c Compression, level int) error {
+	if err := c.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if level == DefaultCompressionLevel {
+		return nil
+	}
+	levels, ok := compressionLevels[c]
+	if !ok || level < levels[0] || level > levels[1] {
+		return errors.NotValidf("%s compression level %d", c, level)
+	}
+	return nil
+}
+
+// compressionCommand returns the command running the
+// program of the given compression with the given arguments.
+func compressionCommand(c Compression, args ...string) (*exec.Cmd, error) {
+	path, err := exec.LookPath(compressionCommands[c])
+	if err != nil {
+		return nil, errors.NewNotSupported(err, fmt.Sprintf("%s compression requires the %q command", c, compressionCommands[c]))
+	}
+	return exec.Command(path, args...), nil
+}
+
+// waitCommand waits for the given compression command to exit
+// and returns its error, along with what it wrote to its standard
+// error, if it failed.
+func waitCommand(cmd *exec.Cmd, stderr *bytes.Buffer) error {
+	err := cmd.Wait()
+	if err == nil {
+		return nil
+	}
+	name := filepath.Base(cmd.Path)
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return errors.Errorf("%s failed: %v: %s", name, err, msg)
+	}
+	return errors.Errorf("%s failed: %v", name, err)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// commandWriter is an io.WriteCloser compressing
+// what is written to it with a command.
+type commandWriter struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr bytes.Buffer
+
+	once    sync.Once
+	waitErr error
+}
+
+// Write implements io.Writer.
+func (w *commandWriter) Write(p []byte) (int, error) {
+	n, err := w.stdin.Write(p)
+	if err != nil {
+		// the command exited, and its error is the one of interest.
+		if waitErr := w.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Close implements io.Closer, waiting for the
+// command to write the end of the stream.
+func (w *commandWriter) Close() error {
+	w.stdin.Close()
+	return w.wait()
+}
+
+func (w *commandWriter) wait() error {
+	w.once.Do(func() {
+		w.waitErr = waitCommand(w.cmd, &w.stderr)
+	})
+	return w.waitErr
+}
+
+// commandReader is an io.ReadCloser reading
+// what a command uncompresses.
+type commandReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+
+	once    sync.Once
+	waitErr error
+}
+
+// Read implements io.Reader. It returns the error of the command
+// instead of io.EOF if it failed.
+func (r *commandReader) Read(p []byte) (int, error) {
+	n, err := r.stdout.Read(p)
+	if err == io.EOF {
+		if waitErr := r.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Close implements io.Closer, stopping the
+// command if it did not complete.
+func (r *commandReader) Close() error {
+	r.stdout.Close()
+	r.once.Do(func() {
+		r.cmd.Process.Kill()
+		r.cmd.Wait()
+	})
+	return nil
+}
+
+func (r *commandReader) wait() error {
+	r.once.Do(func() {
+		r.waitErr = waitCommand(r.cmd, &r.stderr)
+	})
+	return r.waitErr
+}
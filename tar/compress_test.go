@@ -0,0 +1,163 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tar
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&CompressSuite{})
+
+type CompressSuite struct {
+	testing.IsolationSuite
+	originalPath string
+}
+
+func (s *CompressSuite) SetUpSuite(c *gc.C) {
+	s.originalPath = os.Getenv("PATH")
+	s.IsolationSuite.SetUpSuite(c)
+}
+
+func (s *CompressSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	// The xz and zstd commands are looked for in the original PATH.
+	s.PatchEnvironment("PATH", s.originalPath)
+}
+
+// hasCompression returns whether the given compression can be used,
+// logging why it cannot when it requires a command which is not
+// installed.
+func hasCompression(c *gc.C, compression Compression) bool {
+	if command, ok := compressionCommands[compression]; ok {
+		if _, err := exec.LookPath(command); err != nil {
+			c.Logf("skipping %s compression: %s is not installed", compression, command)
+			return false
+		}
+	}
+	return true
+}
+
+func compress(c *gc.C, compression Compression, level int, data string) []byte {
+	var buf bytes.Buffer
+	w, err := NewCompressWriter(&buf, compression, level)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = w.Write([]byte(data))
+	c.Assert(err, jc.ErrorIsNil)
+	err = w.Close()
+	c.Assert(err, jc.ErrorIsNil)
+	return buf.Bytes()
+}
+
+func (s *CompressSuite) TestRoundTrip(c *gc.C) {
+	data := strings.Repeat("some data to compress\n", 1000)
+	for _, compression := range []Compression{NoCompression, Gzip, Xz, Zstd} {
+		levels := []int{DefaultCompressionLevel}
+		if compression != NoCompression {
+			levels = append(levels, 1, 9)
+		}
+		if !hasCompression(c, compression) {
+			continue
+		}
+		for _, level := range levels {
+			c.Logf("compression %q, level %d", compression, level)
+			compressed := compress(c, compression, level, data)
+			c.Check(DetectCompression(compressed), gc.Equals, compression)
+			if compression != NoCompression {
+				c.Check(len(compressed) < len(data), jc.IsTrue)
+			}
+
+			r, err := NewDecompressReader(bytes.NewReader(compressed))
+			c.Assert(err, jc.ErrorIsNil)
+			uncompressed, err := ioutil.ReadAll(r)
+			c.Assert(err, jc.ErrorIsNil)
+			c.Check(string(uncompressed), gc.Equals, data)
+			c.Check(r.Close(), jc.ErrorIsNil)
+		}
+	}
+}
+
+func (s *CompressSuite) TestDecompressCorrupted(c *gc.C) {
+	for _, compression := range []Compression{Xz, Zstd} {
+		if !hasCompression(c, compression) {
+			continue
+		}
+		compressed := compress(c, compression, DefaultCompressionLevel, "some data")
+		corrupted := append(compressed[:len(compressed)/2:len(compressed)/2], bytes.Repeat([]byte{0xff}, 10)...)
+
+		r, err := NewDecompressReader(bytes.NewReader(corrupted))
+		c.Assert(err, jc.ErrorIsNil)
+		_, err = ioutil.ReadAll(r)
+		c.Check(err, gc.ErrorMatches, string(compression)+" failed: exit status .*")
+		c.Check(r.Close(), jc.ErrorIsNil)
+	}
+}
+
+func (s *CompressSuite) TestCloseUnread(c *gc.C) {
+	if !hasCompression(c, Zstd) {
+		c.Skip("zstd is not installed")
+	}
+	compressed := compress(c, Zstd, DefaultCompressionLevel, strings.Repeat("data", 1<<20))
+	r, err := NewDecompressReader(bytes.NewReader(compressed))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(r.Close(), jc.ErrorIsNil)
+}
+
+func (s *CompressSuite) TestInvalidCompression(c *gc.C) {
+	_, err := NewCompressWriter(ioutil.Discard, "bzip2", DefaultCompressionLevel)
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, `compression "bzip2" not valid`)
+}
+
+func (s *CompressSuite) TestInvalidCompressionLevel(c *gc.C) {
+	for _, test := range []struct {
+		compression Compression
+		level       int
+	}{
+		{NoCompression, 1},
+		{Gzip, 10},
+		{Xz, -1},
+		{Zstd, 20},
+	} {
+		_, err := NewCompressWriter(ioutil.Discard, test.compression, test.level)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, ".*compression level .* not valid")
+	}
+}
+
+func (s *CompressSuite) TestMissingCommand(c *gc.C) {
+	s.PatchValue(&compressionCommands, map[Compression]string{
+		Xz:   "no-such-xz",
+		Zstd: "no-such-zstd",
+	})
+	_, err := NewCompressWriter(ioutil.Discard, Xz, DefaultCompressionLevel)
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(err, gc.ErrorMatches, `xz compression requires the "no-such-xz" command: .*`)
+
+	_, err = NewDecompressReader(bytes.NewReader(compressionMagics[Zstd]))
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(err, gc.ErrorMatches, `zstd compression requires the "no-such-zstd" command: .*`)
+}
+
+func (s *CompressSuite) TestCompressionFromName(c *gc.C) {
+	for name, expected := range map[string]Compression{
+		"backup.tar":     NoCompression,
+		"backup.tar.gz":  Gzip,
+		"backup.TGZ":     Gzip,
+		"backup.tar.xz":  Xz,
+		"backup.txz":     Xz,
+		"backup.tar.zst": Zstd,
+		"backup.tzst":    Zstd,
+	} {
+		c.Check(CompressionFromName(name), gc.Equals, expected, gc.Commentf("%s", name))
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tar
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// PathFilter selects the entries of an archive by matching their slash
+// separated names, relative to the root of the archive, against
+// include and exclude patterns.
+//
+// The patterns follow the rules of .gitignore files:
+//   - blank lines and lines starting with "#" are ignored;
+//   - "*" matches any run of characters but "/", "?" matches any
+//     character but "/" and "[...]" matches a character class, which
+//     is negated by "[!...]" or "[^...]";
+//   - "**/" at the start of a pattern matches any leading directories,
+//     "/**" at its end anything beneath a directory and "/**/" any
+//     intermediate directories;
+//   - a pattern with a "/" at its start or in its middle is matched
+//     against the whole name, otherwise against its last element at
+//     any depth;
+//   - a pattern ending with "/" only matches directories;
+//   - a pattern starting with "!" negates a previous match, and the
+//     last matching pattern wins;
+//   - a pattern matching a directory also matches everything beneath it.
+//
+// "\" escapes the character following it, so that "\#" and "\!" match
+// names starting with "#" and "!".
+type PathFilter struct {
+	include []pathRule
+	exclude []pathRule
+}
+
+// pathRule holds a compiled pattern of a PathFilter.
+type pathRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// NewPathFilter returns a PathFilter matching the entries which match
+// one of the include patterns, or all the entries if there are none,
+// and none of the exclude patterns. It returns an error satisfying
+// errors.IsNotValid if a pattern is malformed.
+func NewPathFilter(include, exclude []string) (*PathFilter, error) {
+	includeRules, err := compileRules(include)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	excludeRules, err := compileRules(exclude)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &PathFilter{
+		include: includeRules,
+		exclude: excludeRules,
+	}, nil
+}
+
+// Match returns whether the entry with the given name, which is a
+// directory if isDir is true, is selected by the filter.
+func (f *PathFilter) Match(name string, isDir bool) bool {
+	return !f.Excluded(name, isDir) && f.Included(name, isDir)
+}
+
+// Included returns whether the entry with the given name matches the
+// include patterns of the filter, or whether there are none.
+func (f *PathFilter) Included(name string, isDir bool) bool {
+	return len(f.include) == 0 || matchRules(f.include, name, isDir)
+}
+
+// Excluded returns whether the entry with the given name matches the
+// exclude patterns of the filter.
+func (f *PathFilter) Excluded(name string, isDir bool) bool {
+	return matchRules(f.exclude, name, isDir)
+}
+
+// ReadPatterns returns the patterns of a .gitignore style file,
+// one per line, to be given to NewPathFilter.
+func ReadPatterns(r io.Reader) ([]string, error) {
+	var patterns []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return patterns, nil
+}
+
+// matchRules returns whether the given name, or one of the
+// directories it is beneath, is matched by the given rules.
+func matchRules(rules []pathRule, name string, isDir bool) bool {
+	if len(rules) == 0 {
+		return false
+	}
+	name = strings.Trim(name, "/")
+	for i := 0; i < len(name); i++ {
+		if name[i] == '/' && lastMatch(rules, name[:i], true) {
+			return true
+		}
+	}
+	return lastMatch(rules, name, isDir)
+}
+
+// lastMatch returns whether the last of the given rules
+// matching the given name is not a negated one.
+func lastMatch(rules []pathRule, name string, isDir bool) bool {
+	matched := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.negate == matched && rule.re.MatchString(name) {
+			matched = !rule.negate
+		}
+	}
+	return matched
+}
+
+// compileRules compiles the given patterns,
+// skipping blank lines and comments.
+func compileRules(patterns []string) ([]pathRule, error) {
+	var rules []pathRule
+	for _, pattern := range patterns {
+		rule, ok, err := compileRule(pattern)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// compileRule compiles the given pattern. It returns false
+// if the pattern is a blank line or a comment.
+func compileRule(pattern string) (pathRule, bool, error) {
+	var rule pathRule
+	p := strings.TrimRight(pattern, " \t\r")
+	if strings.HasSuffix(p, `\`) && len(p) < len(pattern) {
+		// an escaped trailing space is kept.
+		p = pattern[:len(p)+1]
+	}
+	if p == "" || strings.HasPrefix(p, "#") {
+		return rule, false, nil
+	}
+	if strings.HasPrefix(p, "!") {
+		rule.negate = true
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") && !strings.HasSuffix(p, `\/`) {
+		rule.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if p == "" {
+		return rule, false, errors.NotValidf("pattern %q", pattern)
+	}
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+
+	expr, err := patternToRegexp(p)
+	if err != nil {
+		return rule, false, errors.NotValidf("pattern %q", pattern)
+	}
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	rule.re, err = regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return rule, false, errors.NotValidf("pattern %q", pattern)
+	}
+	return rule, true, nil
+}
+
+// patternToRegexp translates the given pattern,
+// without its leading "!" and trailing "/", into a regular expression.
+func patternToRegexp(p string) (string, error) {
+	var expr strings.Builder
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			if !strings.HasPrefix(p[i:], "**") {
+				expr.WriteString("[^/]*")
+				continue
+			}
+			atStart := i == 0 || p[i-1] == '/'
+			rest := p[i+2:]
+			switch {
+			case atStart && rest == "":
+				expr.WriteString(".*")
+				i++
+			case atStart && strings.HasPrefix(rest, "/"):
+				expr.WriteString("(?:.*/)?")
+				i += 2
+			default:
+				// "**" within an element is a plain "*".
+				expr.WriteString("[^/]*")
+				i++
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(p[i+1:], ']')
+			if end == 0 {
+				// a leading "]" is part of the class.
+				end = 1 + strings.IndexByte(p[i+2:], ']')
+			}
+			if end <= 0 {
+				return "", errors.Errorf("unterminated character class")
+			}
+			class := p[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 == len(p) {
+				return "", errors.Errorf("trailing backslash")
+			}
+			i++
+			expr.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expr.String(), nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tar
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(&PathFilterSuite{})
+
+type PathFilterSuite struct {
+	testing.IsolationSuite
+}
+
+var pathFilterTests = []struct {
+	about    string
+	include  []string
+	exclude  []string
+	name     string
+	isDir    bool
+	expected bool
+}{{
+	about:    "no patterns",
+	name:     "a/b",
+	expected: true,
+}, {
+	about:    "basename at any depth",
+	exclude:  []string{"*.pyc"},
+	name:     "a/b/c.pyc",
+	expected: false,
+}, {
+	about:    "star does not match slashes",
+	exclude:  []string{"a/*.pyc"},
+	name:     "a/b/c.pyc",
+	expected: true,
+}, {
+	about:    "anchored pattern",
+	exclude:  []string{"/c.pyc"},
+	name:     "a/c.pyc",
+	expected: true,
+}, {
+	about:    "anchored pattern at the root",
+	exclude:  []string{"/c.pyc"},
+	name:     "c.pyc",
+	expected: false,
+}, {
+	about:    "beneath an excluded directory",
+	exclude:  []string{"cache/"},
+	name:     "a/cache/b/c",
+	expected: false,
+}, {
+	about:    "directory only pattern and file",
+	exclude:  []string{"cache/"},
+	name:     "a/cache",
+	expected: true,
+}, {
+	about:    "leading double star",
+	exclude:  []string{"**/secrets/*.key"},
+	name:     "a/b/secrets/id.key",
+	expected: false,
+}, {
+	about:    "intermediate double star",
+	exclude:  []string{"a/**/id.key"},
+	name:     "a/id.key",
+	expected: false,
+}, {
+	about:    "trailing double star",
+	exclude:  []string{"a/**"},
+	name:     "a/b/c",
+	expected: false,
+}, {
+	about:    "negated pattern",
+	exclude:  []string{"*.log", "!keep.log"},
+	name:     "a/keep.log",
+	expected: true,
+}, {
+	about:    "last match wins",
+	exclude:  []string{"*.log", "!keep.log", "a/*.log"},
+	name:     "a/keep.log",
+	expected: false,
+}, {
+	about:    "no reinclusion beneath an excluded directory",
+	exclude:  []string{"logs/", "!logs/keep.log"},
+	name:     "logs/keep.log",
+	expected: false,
+}, {
+	about:    "character classes",
+	exclude:  []string{"file[0-9]", "other[!0-9]"},
+	name:     "file1",
+	expected: false,
+}, {
+	about:    "negated character classes",
+	exclude:  []string{"file[!0-9]"},
+	name:     "file1",
+	expected: true,
+}, {
+	about:    "comments and escapes",
+	exclude:  []string{"# comment", "", `\#file`},
+	name:     "#file",
+	expected: false,
+}, {
+	about:    "included",
+	include:  []string{"*.go"},
+	name:     "a/b.go",
+	expected: true,
+}, {
+	about:    "not included",
+	include:  []string{"*.go"},
+	name:     "a/b.txt",
+	expected: false,
+}, {
+	about:    "beneath an included directory",
+	include:  []string{"src/"},
+	name:     "src/a/b.txt",
+	expected: true,
+}, {
+	about:    "included and excluded",
+	include:  []string{"*.go"},
+	exclude:  []string{"*_test.go"},
+	name:     "a/b_test.go",
+	expected: false,
+}}
+
+func (s *PathFilterSuite) TestMatch(c *gc.C) {
+	for i, test := range pathFilterTests {
+		c.Logf("test %d: %s", i, test.about)
+		filter, err := NewPathFilter(test.include, test.exclude)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(filter.Match(test.name, test.isDir), gc.Equals, test.expected)
+	}
+}
+
+func (s *PathFilterSuite) TestInvalidPatterns(c *gc.C) {
+	for _, pattern := range []string{"[a-", "a\\", "!/", "[z-a]"} {
+		_, err := NewPathFilter(nil, []string{pattern})
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, `pattern ".*" not valid`)
+	}
+}
+
+func (s *PathFilterSuite) TestReadPatterns(c *gc.C) {
+	patterns, err := ReadPatterns(strings.NewReader("# caches\n*.pyc\n\n!keep.pyc\n"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(patterns, jc.DeepEquals, []string{"# caches", "*.pyc", "", "!keep.pyc"})
+
+	filter, err := NewPathFilter(nil, patterns)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(filter.Match("a/b.pyc", false), jc.IsFalse)
+	c.Check(filter.Match("a/keep.pyc", false), jc.IsTrue)
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tar
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+)
+
+// Manifest holds the digests of the regular files of
+// an archive, in the order they are archived.
+type Manifest []ManifestEntry
+
+// ManifestEntry holds the digest of a regular file of an archive.
+type ManifestEntry struct {
+	// Name is the name of the file in the archive.
+	Name string
+
+	// Size is the size of the file, in bytes.
+	Size int64
+
+	// SHA256 is the hex encoded SHA256 hash of
+	// the contents of the file.
+	SHA256 string
+}
+
+// Progress describes how far the writing or
+// extraction of an archive has got.
+type Progress struct {
+	// Name is the name of the last entry processed.
+	Name string
+
+	// Entries is the number of entries processed,
+	// including directories and links.
+	Entries int
+
+	// Bytes is the size of the contents of
+	// the regular files processed.
+	Bytes int64
+}
+
+// ProgressFunc is called after each entry
+// of an archive is processed.
+type ProgressFunc func(Progress)
+
+// entryRecorder records the digests of the regular files of an
+// archive into a manifest, and reports the progress of the entries
+// it is told about.
+type entryRecorder struct {
+	manifest *Manifest
+	progress ProgressFunc
+	current  Progress
+
+	// hash holds the hash of the contents of
+	// the current entry, if they are digested.
+	hash hash.Hash
+}
+
+// newEntryRecorder returns an entryRecorder filling the given manifest,
+// if not nil, and calling the given function, if not nil.
+func newEntryRecorder(manifest *Manifest, progress ProgressFunc) *entryRecorder {
+	if manifest != nil {
+		*manifest = nil
+	}
+	return &entryRecorder{
+		manifest: manifest,
+		progress: progress,
+	}
+}
+
+// digest returns a reader of the contents of the current
+// entry, which are digested if a manifest is recorded.
+func (r *entryRecorder) digest(contents io.Reader) io.Reader {
+	if r.manifest == nil {
+		return contents
+	}
+	r.hash = sha256.New()
+	return io.TeeReader(contents, r.hash)
+}
+
+// done records the entry with the given header,
+// once its contents are processed.
+func (r *entryRecorder) done(h *tar.Header) {
+	regular := h.Typeflag == tar.TypeReg || h.Typeflag == tar.TypeRegA
+	if regular && r.hash != nil {
+		*r.manifest = append(*r.manifest, ManifestEntry{
+			Name:   h.Name,
+			Size:   h.Size,
+			SHA256: hex.EncodeToString(r.hash.Sum(nil)),
+		})
+	}
+	r.hash = nil
+	r.current.Name = h.Name
+	r.current.Entries++
+	if regular {
+		r.current.Bytes += h.Size
+	}
+	if r.progress != nil {
+		r.progress(r.current)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tar
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// geteuid returns the effective user id of the process.
+// It is a variable for testing purposes.
+var geteuid = os.Geteuid
+
+// xattrPrefix prefixes the names of the PAX records
+// holding the extended attributes of entries.
+const xattrPrefix = "SCHILY.xattr."
+
+// defaultXattrNamespaces holds the namespaces of the extended
+// attributes restored unless UntarOptions.XattrNamespaces is set.
+var defaultXattrNamespaces = []string{"user"}
+
+// cleanEntryName returns the given name of an entry, or target of a
+// hardlink, as a clean relative path. It returns an error if the name
+// is absolute or refers to a path outside of the root of the archive.
+func cleanEntryName(name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || strings.HasPrefix(name, "/") || filepath.VolumeName(clean) != "" {
+		return "", errors.Errorf("absolute path %q", name)
+	}
+	if escapesRoot(clean) {
+		return "", errors.Errorf("path %q is outside of the archive", name)
+	}
+	return clean, nil
+}
+
+// escapesRoot returns whether the given clean
+// relative path refers to a parent directory.
+func escapesRoot(clean string) bool {
+	return clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator))
+}
+
+// checkNoSymlinks returns an error if one of the directories
+// between outputFolder and the given path beneath it is a symlink,
+// so that nothing is extracted through a symlink of the archive.
+func checkNoSymlinks(outputFolder, clean string) error {
+	dir := outputFolder
+	elements := strings.Split(clean, string(filepath.Separator))
+	for _, element := range elements[:len(elements)-1] {
+		dir = filepath.Join(dir, element)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return errors.Errorf("path %q is beneath a symlink", filepath.ToSlash(clean))
+		}
+	}
+	return nil
+}
+
+// isSymlink returns whether the given path is a symlink.
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// safeExtractPath returns the path the entry with the given header is
+// extracted to beneath outputFolder. It returns an error if the entry
+// would be extracted outside of outputFolder, through a symlink or over
+// one, or if it is a symlink pointing outside of outputFolder, once
+// resolved against the symlinks extracted beforehand.
+func safeExtractPath(outputFolder string, hdr *tar.Header) (string, error) {
+	clean, err := cleanEntryName(hdr.Name)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if err := checkNoSymlinks(outputFolder, clean); err != nil {
+		return "", errors.Trace(err)
+	}
+	fullPath := filepath.Join(outputFolder, clean)
+	if hdr.Typeflag == tar.TypeSymlink {
+		if err := checkSymlinkTarget(outputFolder, clean, hdr.Linkname); err != nil {
+			return "", errors.Trace(err)
+		}
+	} else if isSymlink(fullPath) {
+		// directories would be created, and the metadata of
+		// any entry restored, through the symlink.
+		return "", errors.Errorf("path %q is a symlink", hdr.Name)
+	}
+	return fullPath, nil
+}
+
+// checkSymlinkTarget returns an error if the symlink with the given
+// clean name and target points outside of outputFolder.
+func checkSymlinkTarget(outputFolder, clean, linkname string) error {
+	target := filepath.FromSlash(linkname)
+	if filepath.IsAbs(target) || strings.HasPrefix(linkname, "/") || filepath.VolumeName(target) != "" {
+		return errors.Errorf("symlink to absolute path %q", linkname)
+	}
+	// The target is not cleaned, as ".." elements following
+	// symlinks refer to the parents of their targets.
+	dir := filepath.Dir(clean)
+	if dir == "." {
+		dir = ""
+	}
+	if !resolvesInRoot(outputFolder, dir+string(filepath.Separator)+target) {
+		return errors.Errorf("symlink to %q is outside of the archive", linkname)
+	}
+	return nil
+}
+
+// maxSymlinks is the number of symlinks resolvesInRoot follows
+// before giving up, which protects against symlink loops.
+const maxSymlinks = 255
+
+// resolvesInRoot returns whether the given relative path stays beneath
+// root as it is resolved, following the symlinks found beneath root.
+// The elements of the path which do not exist are resolved lexically.
+func resolvesInRoot(root, path string) bool {
+	var resolved []string
+	pending := strings.Split(path, string(filepath.Separator))
+	followed := 0
+	for len(pending) > 0 {
+		element := pending[0]
+		pending = pending[1:]
+		switch element {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return false
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+		resolved = append(resolved, element)
+		current := filepath.Join(append([]string{root}, resolved...)...)
+		info, err := os.Lstat(current)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		target, err := os.Readlink(current)
+		if err != nil || filepath.IsAbs(target) || filepath.VolumeName(target) != "" {
+			return false
+		}
+		if followed++; followed > maxSymlinks {
+			return false
+		}
+		resolved = resolved[:len(resolved)-1]
+		pending = append(strings.Split(target, string(filepath.Separator)), pending...)
+	}
+	return true
+}
+
+// recheckSymlinks returns an error if one of the extracted symlinks with
+// the given clean names points outside of outputFolder, as every symlink
+// extracted may change how the targets of the previous ones are resolved.
+// Such a symlink is removed. It must be called after each symlink is
+// extracted, before any other entry is.
+func recheckSymlinks(outputFolder string, symlinks []string) error {
+	for _, clean := range symlinks {
+		fullPath := filepath.Join(outputFolder, clean)
+		info, err := os.Lstat(fullPath)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			// the symlink was replaced by a later entry.
+			continue
+		}
+		linkname, err := os.Readlink(fullPath)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err := checkSymlinkTarget(outputFolder, clean, linkname); err != nil {
+			os.Remove(fullPath)
+			return errors.Annotatef(err, "cannot extract %q", filepath.ToSlash(clean))
+		}
+	}
+	return nil
+}
+
+// hardlinkTarget returns the path of the file the hardlink with the
+// given header links to. The file must have been extracted from the
+// archive beforehand, and not through a symlink. It must not be a
+// symlink either, as the hardlink would be another symlink, which
+// is not checked.
+func hardlinkTarget(outputFolder string, hdr *tar.Header) (string, error) {
+	clean, err := cleanEntryName(hdr.Linkname)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if err := checkNoSymlinks(outputFolder, clean); err != nil {
+		return "", errors.Trace(err)
+	}
+	target := filepath.Join(outputFolder, clean)
+	if isSymlink(target) {
+		return "", errors.Errorf("path %q is a symlink", hdr.Linkname)
+	}
+	return target, nil
+}
+
+// restoreOwnership sets the owner and extended attributes of the
+// extracted entry with the given header to those it has in the
+// archive, as requested by the given options. Nothing is done
+// unless running as root.
+func restoreOwnership(fullPath string, hdr *tar.Header, options UntarOptions) error {
+	if geteuid() != 0 {
+		return nil
+	}
+	if options.PreserveOwner {
+		if err := os.Lchown(fullPath, hdr.Uid, hdr.Gid); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if options.PreserveXattrs && hdr.Typeflag != tar.TypeSymlink {
+		namespaces := options.XattrNamespaces
+		if len(namespaces) == 0 {
+			namespaces = defaultXattrNamespaces
+		}
+		for key, value := range hdr.PAXRecords {
+			if !strings.HasPrefix(key, xattrPrefix) {
+				continue
+			}
+			name := strings.TrimPrefix(key, xattrPrefix)
+			if !inXattrNamespaces(name, namespaces) {
+				continue
+			}
+			if err := setXattr(fullPath, name, []byte(value)); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	return nil
+}
+
+// inXattrNamespaces returns whether the extended attribute
+// with the given name is in one of the given namespaces.
+func inXattrNamespaces(name string, namespaces []string) bool {
+	for _, namespace := range namespaces {
+		if strings.HasPrefix(name, namespace+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -51,44 +51,137 @@ func FindFile(tarFile io.Reader, filename string) (*tar.Header, io.Reader, error
 // We use a base64 encoded sha1 hash, because this is the hash
 // used by RFC 3230 Digest headers in http responses
 func TarFiles(fileList []string, target io.Writer, strip string) (shaSum string, err error) {
+	return TarFilesWithOptions(fileList, target, TarOptions{Strip: strip})
+}
+
+// TarOptions holds the options of TarFilesWithOptions.
+type TarOptions struct {
+	// Strip is removed from the beginning of all the paths when
+	// stored, as with TarFiles.
+	Strip string
+
+	// Include, if not empty, holds the patterns of the entries to
+	// archive. The directories which do not match them are only
+	// archived if they hold entries which do.
+	//
+	// The patterns are matched against the names of the entries in
+	// the archive and follow the rules of .gitignore files, as
+	// described by PathFilter.
+	Include []string
+
+	// Exclude holds the patterns of the entries not to archive.
+	// The excluded directories are not walked.
+	Exclude []string
+
+	// SkipSpecialFiles makes sockets, named pipes and devices be
+	// left out of the archive, rather than failing it.
+	SkipSpecialFiles bool
+
+	// Compression is the compression of the tar stream,
+	// which is left uncompressed by default.
+	Compression Compression
+
+	// CompressionLevel is the level of the compression, as
+	// described by NewCompressWriter. It defaults to the default
+	// level of the compression.
+	CompressionLevel int
+
+	// Manifest, if not nil, is set to the digests
+	// of the regular files archived.
+	Manifest *Manifest
+
+	// Progress, if not nil, is called after each entry is archived.
+	Progress ProgressFunc
+}
+
+// TarFilesWithOptions writes a tar stream into target holding the files
+// listed in fileList and selected by the given options, and returns its
+// sha sum as TarFiles does. The sum is the one of the compressed stream
+// when the options specify a compression.
+//
+// The patterns and compression of the options are validated before
+// anything is written, and an error satisfying errors.IsNotValid is
+// returned if they are not.
+func TarFilesWithOptions(fileList []string, target io.Writer, options TarOptions) (shaSum string, err error) {
+	filter, err := NewPathFilter(options.Include, options.Exclude)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if err := validateCompression(options.Compression, options.CompressionLevel); err != nil {
+		return "", errors.Trace(err)
+	}
 	shahash := sha1.New()
-	if err := tarAndHashFiles(fileList, target, strip, shahash); err != nil {
+	w := &archiveWriter{
+		strip:       options.Strip,
+		filter:      filter,
+		skipSpecial: options.SkipSpecialFiles,
+		recorder:    newEntryRecorder(options.Manifest, options.Progress),
+	}
+	if err := w.tarAndHashFiles(fileList, target, shahash, options); err != nil {
 		return "", err
 	}
 	encodedHash := base64.StdEncoding.EncodeToString(shahash.Sum(nil))
 	return encodedHash, nil
 }
 
-func tarAndHashFiles(fileList []string, target io.Writer, strip string, hashw io.Writer) (err error) {
+// archiveWriter writes the entries selected by a filter into a tar stream.
+type archiveWriter struct {
+	tarw        *tar.Writer
+	strip       string
+	filter      *PathFilter
+	skipSpecial bool
+	recorder    *entryRecorder
+
+	// pending holds the headers of the directories being walked
+	// which are only written once an entry beneath them is.
+	pending []*tar.Header
+}
+
+func (w *archiveWriter) tarAndHashFiles(fileList []string, target io.Writer, hashw io.Writer, options TarOptions) (err error) {
 	checkClose := func(w io.Closer) {
 		if closeErr := w.Close(); closeErr != nil && err == nil {
 			err = fmt.Errorf("error closing tar writer: %v", closeErr)
 		}
 	}
 
-	w := io.MultiWriter(target, hashw)
-	tarw := tar.NewWriter(w)
-	defer checkClose(tarw)
+	compressw, err := NewCompressWriter(io.MultiWriter(target, hashw), options.Compression, options.CompressionLevel)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer checkClose(compressw)
+	w.tarw = tar.NewWriter(compressw)
+	defer checkClose(w.tarw)
 	for _, ent := range fileList {
-		if err := writeContents(ent, strip, tarw); err != nil {
+		if err := w.writeContents(ent); err != nil {
 			return fmt.Errorf("write to tar file failed: %v", err)
 		}
 	}
 	return nil
 }
 
+// specialFileModes holds the modes of the files left out
+// by TarOptions.SkipSpecialFiles.
+const specialFileModes = os.ModeSocket | os.ModeNamedPipe | os.ModeDevice | os.ModeCharDevice
+
 // writeContents creates an entry for the given file
-// or directory in the given tar archive.
-func writeContents(fileName, strip string, tarw *tar.Writer) error {
-	f, err := os.Open(fileName)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+// or directory in the tar archive, if it is selected.
+func (w *archiveWriter) writeContents(fileName string) error {
 	fInfo, err := os.Lstat(fileName)
 	if err != nil {
 		return err
 	}
+	if w.skipSpecial && fInfo.Mode()&specialFileModes != 0 {
+		return nil
+	}
+	name := filepath.ToSlash(strings.TrimPrefix(fileName, w.strip))
+	isDir := fInfo.IsDir()
+	if w.filter.Excluded(name, isDir) {
+		return nil
+	}
+	included := w.filter.Included(name, isDir)
+	if !included && !isDir {
+		return nil
+	}
 	link := ""
 
 	if fInfo.Mode()&os.ModeSymlink == os.ModeSymlink {
@@ -103,20 +196,61 @@ func writeContents(fileName, strip string, tarw *tar.Writer) error {
 	if err != nil {
 		return fmt.Errorf("cannot create tar header for %q: %v", fileName, err)
 	}
-	h.Name = filepath.ToSlash(strings.TrimPrefix(fileName, strip))
-	if err := tarw.WriteHeader(h); err != nil {
+	h.Name = name
+	if !included {
+		// the directory is walked for included entries.
+		depth := len(w.pending)
+		w.pending = append(w.pending, h)
+		err := w.writeDirectory(fileName)
+		if len(w.pending) > depth {
+			w.pending = w.pending[:depth]
+		}
+		return err
+	}
+	if err := w.writeHeader(h); err != nil {
 		return fmt.Errorf("cannot write header for %q: %v", fileName, err)
 	}
 	if fInfo.Mode()&os.ModeSymlink == os.ModeSymlink {
+		w.recorder.done(h)
 		return nil
 	}
-	if !fInfo.IsDir() {
-		if _, err := io.Copy(tarw, f); err != nil {
+	if !isDir {
+		f, err := os.Open(fileName)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(w.tarw, w.recorder.digest(f)); err != nil {
 			return fmt.Errorf("failed to write %q: %v", fileName, err)
 		}
+		w.recorder.done(h)
 		return nil
 	}
+	w.recorder.done(h)
+	return w.writeDirectory(fileName)
+}
 
+// writeHeader writes the given header into the tar archive,
+// after those of the directories it is beneath which are pending.
+func (w *archiveWriter) writeHeader(h *tar.Header) error {
+	for _, dir := range w.pending {
+		if err := w.tarw.WriteHeader(dir); err != nil {
+			return err
+		}
+		w.recorder.done(dir)
+	}
+	w.pending = w.pending[:0]
+	return w.tarw.WriteHeader(h)
+}
+
+// writeDirectory creates entries for the
+// contents of the given directory.
+func (w *archiveWriter) writeDirectory(fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 	for {
 		names, err := f.Readdirnames(100)
 		// will return at most 100 names and if less than 100 remaining
@@ -128,12 +262,11 @@ func writeContents(fileName, strip string, tarw *tar.Writer) error {
 			return fmt.Errorf("error reading directory %q: %v", fileName, err)
 		}
 		for _, name := range names {
-			if err := writeContents(filepath.Join(fileName, name), strip, tarw); err != nil {
+			if err := w.writeContents(filepath.Join(fileName, name)); err != nil {
 				return err
 			}
 		}
 	}
-
 }
 
 func createAndFill(filePath string, mode int64, content io.Reader) error {
@@ -146,17 +279,78 @@ func createAndFill(filePath string, mode int64, content io.Reader) error {
 	if err != nil {
 		return fmt.Errorf("failed while reading tar contents: %v", err)
 	}
-	err = os.Chmod(fh.Name(), os.FileMode(mode))
+	err = fh.Chmod(os.FileMode(mode))
 	if err != nil {
 		return fmt.Errorf("cannot set proper mode on file %q: %v", filePath, err)
 	}
 	return nil
 }
 
+// UntarCompressedFiles extracts the contents of tarFile using
+// outputFolder as root, as UntarFiles does, after uncompressing it
+// with the compression detected from its first bytes. It returns an
+// error satisfying errors.IsNotSupported if that compression requires
+// a command which cannot be found.
+func UntarCompressedFiles(tarFile io.Reader, outputFolder string) error {
+	r, err := NewDecompressReader(tarFile)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer r.Close()
+	return UntarFiles(r, outputFolder)
+}
+
 // UntarFiles will extract the contents of tarFile using
 // outputFolder as root
 func UntarFiles(tarFile io.Reader, outputFolder string) error {
+	return UntarFilesWithOptions(tarFile, outputFolder, UntarOptions{})
+}
+
+// UntarOptions holds the options of UntarFilesWithOptions.
+type UntarOptions struct {
+	// Safe makes the extraction fail, rather than write outside of
+	// the output folder, on entries with absolute paths or paths
+	// referring to parent directories, on symlinks pointing to such
+	// paths and on entries which would be written through a symlink
+	// extracted beforehand. It is meant for untrusted archives.
+	Safe bool
+
+	// PreserveOwner makes the extracted entries be owned by the
+	// users and groups recorded in the archive, by id. It is only
+	// honoured when running as root.
+	PreserveOwner bool
+
+	// PreserveXattrs makes the extended attributes recorded in the
+	// archive be set on the extracted files and directories. It is
+	// only honoured when running as root on linux.
+	PreserveXattrs bool
+
+	// XattrNamespaces holds the namespaces of the extended attributes
+	// set with PreserveXattrs. It defaults to the "user" namespace
+	// only; others, such as "security" or "trusted", which affect how
+	// the system treats the files, must be given explicitly.
+	XattrNamespaces []string
+
+	// Manifest, if not nil, is set to the digests
+	// of the regular files extracted.
+	Manifest *Manifest
+
+	// Progress, if not nil, is called after each entry is extracted.
+	Progress ProgressFunc
+}
+
+// UntarFilesWithOptions extracts the contents of tarFile using
+// outputFolder as root, as UntarFiles does, with the given options.
+//
+// When extracting safely, hardlinks are extracted as links to the files
+// extracted beforehand, and the extraction fails if they link to files
+// outside of the output folder. Otherwise they are skipped.
+func UntarFilesWithOptions(tarFile io.Reader, outputFolder string, options UntarOptions) error {
+	recorder := newEntryRecorder(options.Manifest, options.Progress)
 	tr := tar.NewReader(tarFile)
+	// symlinks holds the clean names of the symlinks
+	// extracted, to be checked again as more are.
+	var symlinks []string
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
@@ -167,6 +361,11 @@ func UntarFiles(tarFile io.Reader, outputFolder string) error {
 			return fmt.Errorf("failed while reading tar header: %v", err)
 		}
 		fullPath := filepath.Join(outputFolder, hdr.Name)
+		if options.Safe {
+			if fullPath, err = safeExtractPath(outputFolder, hdr); err != nil {
+				return fmt.Errorf("cannot extract %q: %v", hdr.Name, err)
+			}
+		}
 		switch hdr.Typeflag {
 		case tar.TypeDir:
 			if err = os.MkdirAll(fullPath, os.FileMode(hdr.Mode)); err != nil {
@@ -176,12 +375,33 @@ func UntarFiles(tarFile io.Reader, outputFolder string) error {
 			if err = symlink.New(hdr.Linkname, fullPath); err != nil {
 				return fmt.Errorf("cannot extract symlink %q to %q: %v", hdr.Linkname, fullPath, err)
 			}
-			continue
+			if options.Safe {
+				symlinks = append(symlinks, filepath.Clean(filepath.FromSlash(hdr.Name)))
+				if err := recheckSymlinks(outputFolder, symlinks); err != nil {
+					return err
+				}
+			}
+		case tar.TypeLink:
+			if !options.Safe {
+				continue
+			}
+			target, err := hardlinkTarget(outputFolder, hdr)
+			if err == nil {
+				err = os.Link(target, fullPath)
+			}
+			if err != nil {
+				return fmt.Errorf("cannot extract hardlink %q to %q: %v", hdr.Linkname, fullPath, err)
+			}
 		case tar.TypeReg, tar.TypeRegA:
-			if err = createAndFill(fullPath, hdr.Mode, tr); err != nil {
+			if err = createAndFill(fullPath, hdr.Mode, recorder.digest(tr)); err != nil {
 				return fmt.Errorf("cannot extract file %q: %v", fullPath, err)
 			}
+		default:
+			continue
 		}
+		if err := restoreOwnership(fullPath, hdr, options); err != nil {
+			return fmt.Errorf("cannot restore ownership of %q: %v", fullPath, err)
+		}
+		recorder.done(hdr)
 	}
-	return nil
 }
@@ -0,0 +1,153 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	gc "gopkg.in/check.v1"
+)
+
+// ownedTar returns an archive holding a file
+// and a directory owned by nobody, with
+// extended attributes set on the file.
+func ownedTar(c *gc.C) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{{
+		Name:     "dir",
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+		Uid:      65534,
+		Gid:      65534,
+	}, {
+		Name:     "dir/file",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Uid:      65534,
+		Gid:      65534,
+		PAXRecords: map[string]string{
+			xattrPrefix + "user.origin":    "archive",
+			xattrPrefix + "trusted.origin": "archive",
+		},
+	}} {
+		err := tw.WriteHeader(hdr)
+		c.Assert(err, gc.IsNil)
+	}
+	err := tw.Close()
+	c.Assert(err, gc.IsNil)
+	return &buf
+}
+
+func (t *TarSuite) assertOwner(c *gc.C, path string, uid uint32) {
+	info, err := os.Lstat(path)
+	c.Assert(err, gc.IsNil)
+	c.Assert(info.Sys().(*syscall.Stat_t).Uid, gc.Equals, uid)
+	c.Assert(info.Sys().(*syscall.Stat_t).Gid, gc.Equals, uid)
+}
+
+func (t *TarSuite) TestUntarFilesPreserveOwnership(c *gc.C) {
+	if os.Geteuid() != 0 {
+		c.Skip("ownership can only be restored as root")
+	}
+	err := UntarFilesWithOptions(ownedTar(c), t.cwd, UntarOptions{
+		PreserveOwner:  true,
+		PreserveXattrs: true,
+	})
+	c.Assert(err, gc.IsNil)
+	t.assertOwner(c, filepath.Join(t.cwd, "dir"), 65534)
+	t.assertOwner(c, filepath.Join(t.cwd, "dir", "file"), 65534)
+
+	value := make([]byte, 64)
+	n, err := syscall.Getxattr(filepath.Join(t.cwd, "dir", "file"), "user.origin", value)
+	if err == syscall.ENOTSUP {
+		c.Skip("extended attributes not supported")
+	}
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(value[:n]), gc.Equals, "archive")
+
+	// Only the user namespace is restored by default.
+	_, err = syscall.Getxattr(filepath.Join(t.cwd, "dir", "file"), "trusted.origin", value)
+	c.Assert(err, gc.Equals, syscall.ENODATA)
+}
+
+func (t *TarSuite) TestUntarFilesPreserveXattrNamespaces(c *gc.C) {
+	if os.Geteuid() != 0 {
+		c.Skip("extended attributes can only be restored as root")
+	}
+	err := UntarFilesWithOptions(ownedTar(c), t.cwd, UntarOptions{
+		PreserveXattrs:  true,
+		XattrNamespaces: []string{"trusted"},
+	})
+	c.Assert(err, gc.IsNil)
+
+	value := make([]byte, 64)
+	n, err := syscall.Getxattr(filepath.Join(t.cwd, "dir", "file"), "trusted.origin", value)
+	if err == syscall.ENOTSUP {
+		c.Skip("extended attributes not supported")
+	}
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(value[:n]), gc.Equals, "archive")
+	_, err = syscall.Getxattr(filepath.Join(t.cwd, "dir", "file"), "user.origin", value)
+	c.Assert(err, gc.Equals, syscall.ENODATA)
+}
+
+func (t *TarSuite) TestUntarFilesPreserveOwnershipNotRoot(c *gc.C) {
+	t.PatchValue(&geteuid, func() int { return 1000 })
+	err := UntarFilesWithOptions(ownedTar(c), t.cwd, UntarOptions{
+		PreserveOwner:  true,
+		PreserveXattrs: true,
+	})
+	c.Assert(err, gc.IsNil)
+	uid := uint32(os.Getuid())
+	info, err := os.Lstat(filepath.Join(t.cwd, "dir", "file"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(info.Sys().(*syscall.Stat_t).Uid, gc.Equals, uid)
+
+	_, err = syscall.Getxattr(filepath.Join(t.cwd, "dir", "file"), "user.origin", make([]byte, 64))
+	c.Assert(err, gc.Equals, syscall.ENODATA)
+}
+
+func (t *TarSuite) TestUntarFilesSafeNoXattrsThroughSymlinks(c *gc.C) {
+	parentDir := filepath.Join(t.cwd, "parent")
+	outputDir := filepath.Join(parentDir, "output")
+	err := os.MkdirAll(outputDir, 0755)
+	c.Assert(err, gc.IsNil)
+
+	// "a" is within the archive until "d" is extracted, so the
+	// directory "a" would have its attributes set on parentDir.
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "d/x/../.."},
+		{Name: "d", Typeflag: tar.TypeSymlink, Linkname: "."},
+		{Name: "x", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "a", Typeflag: tar.TypeDir, Mode: 0755, PAXRecords: map[string]string{
+			xattrPrefix + "user.pwned": "archive",
+		}},
+		{Name: "z", Typeflag: tar.TypeLink, Linkname: "a"},
+	} {
+		err := tw.WriteHeader(hdr)
+		c.Assert(err, gc.IsNil)
+	}
+	err = tw.Close()
+	c.Assert(err, gc.IsNil)
+
+	err = UntarFilesWithOptions(&buf, outputDir, UntarOptions{
+		Safe:           true,
+		PreserveXattrs: true,
+	})
+	c.Assert(err, gc.ErrorMatches, `cannot extract "a": symlink to "d/x/../.." is outside of the archive`)
+
+	_, err = syscall.Getxattr(parentDir, "user.pwned", make([]byte, 64))
+	c.Assert(err, gc.NotNil)
+	for _, name := range []string{"a", "z"} {
+		_, err = os.Lstat(filepath.Join(outputDir, name))
+		c.Assert(os.IsNotExist(err), gc.Equals, true)
+	}
+}
@@ -7,16 +7,22 @@ import (
 	"archive/tar"
 	"bytes"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	stdtesting "testing"
 
+	"github.com/juju/errors"
 	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 )
 
@@ -28,13 +34,20 @@ var _ = gc.Suite(&TarSuite{})
 
 type TarSuite struct {
 	testing.IsolationSuite
-	cwd       string
-	testFiles []string
+	cwd          string
+	testFiles    []string
+	originalPath string
+}
+
+func (t *TarSuite) SetUpSuite(c *gc.C) {
+	t.originalPath = os.Getenv("PATH")
+	t.IsolationSuite.SetUpSuite(c)
 }
 
 func (t *TarSuite) SetUpTest(c *gc.C) {
 	t.cwd = c.MkDir()
 	t.IsolationSuite.SetUpTest(c)
+	t.PatchEnvironment("PATH", t.originalPath)
 }
 
 func (t *TarSuite) createTestFiles(c *gc.C) {
@@ -239,6 +252,108 @@ func (t *TarSuite) TestSymlinksTar(c *gc.C) {
 
 }
 
+// tarNames returns the names of the entries of the given tar archive.
+func tarNames(c *gc.C, tarFile io.Reader) []string {
+	var names []string
+	tr := tar.NewReader(tarFile)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		c.Assert(err, gc.IsNil)
+		names = append(names, hdr.Name)
+	}
+}
+
+func (t *TarSuite) TestTarFilesWithOptionsExclude(c *gc.C) {
+	t.createTestFiles(c)
+	var outputTar bytes.Buffer
+	shaSum, err := TarFilesWithOptions(t.testFiles, &outputTar, TarOptions{
+		Strip:   fmt.Sprintf("%s/", t.cwd),
+		Exclude: []string{"*Link", "/TarFile2", "TarDirectoryPopulatedSubDirectory/"},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(shaSum, gc.Equals, shaSumFile(c, bytes.NewReader(outputTar.Bytes())))
+	c.Assert(tarNames(c, &outputTar), jc.SameContents, []string{
+		"TarDirectoryEmpty",
+		"TarDirectoryPopulated",
+		"TarDirectoryPopulated/TarSubFile1",
+		"TarFile1",
+	})
+}
+
+func (t *TarSuite) TestTarFilesWithOptionsInclude(c *gc.C) {
+	t.createTestFiles(c)
+	var outputTar bytes.Buffer
+	_, err := TarFilesWithOptions(t.testFiles, &outputTar, TarOptions{
+		Strip:   fmt.Sprintf("%s/", t.cwd),
+		Include: []string{"*File1"},
+	})
+	c.Assert(err, gc.IsNil)
+	// The directories are only archived before the entries they hold.
+	c.Assert(tarNames(c, &outputTar), jc.SameContents, []string{
+		"TarDirectoryPopulated",
+		"TarDirectoryPopulated/TarSubFile1",
+		"TarFile1",
+	})
+}
+
+func (t *TarSuite) TestTarFilesWithOptionsIncludeDirectory(c *gc.C) {
+	t.createTestFiles(c)
+	var outputTar bytes.Buffer
+	_, err := TarFilesWithOptions(t.testFiles, &outputTar, TarOptions{
+		Strip:   fmt.Sprintf("%s/", t.cwd),
+		Include: []string{"/TarDirectoryPopulated/"},
+		Exclude: []string{"*Link"},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(tarNames(c, &outputTar), jc.SameContents, []string{
+		"TarDirectoryPopulated",
+		"TarDirectoryPopulated/TarSubFile1",
+		"TarDirectoryPopulated/TarDirectoryPopulatedSubDirectory",
+	})
+}
+
+func (t *TarSuite) TestTarFilesWithOptionsInvalidPattern(c *gc.C) {
+	var outputTar bytes.Buffer
+	_, err := TarFilesWithOptions([]string{t.cwd}, &outputTar, TarOptions{
+		Exclude: []string{"[a-"},
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(outputTar.Len(), gc.Equals, 0)
+}
+
+func (t *TarSuite) TestTarFilesWithOptionsSkipSpecialFiles(c *gc.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("no unix sockets on windows")
+	}
+	dir := filepath.Join(t.cwd, "TarDirectory")
+	err := os.Mkdir(dir, os.FileMode(0755))
+	c.Assert(err, gc.IsNil)
+	err = ioutil.WriteFile(filepath.Join(dir, "TarFile"), []byte("TarFile"), 0644)
+	c.Assert(err, gc.IsNil)
+	listener, err := net.Listen("unix", filepath.Join(dir, "TarSocket"))
+	c.Assert(err, gc.IsNil)
+	defer listener.Close()
+
+	trimPath := fmt.Sprintf("%s/", t.cwd)
+	var outputTar bytes.Buffer
+	_, err = TarFiles([]string{dir}, &outputTar, trimPath)
+	c.Assert(err, gc.ErrorMatches, "write to tar file failed: .*sockets not supported")
+
+	outputTar.Reset()
+	_, err = TarFilesWithOptions([]string{dir}, &outputTar, TarOptions{
+		Strip:            trimPath,
+		SkipSpecialFiles: true,
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(tarNames(c, &outputTar), jc.SameContents, []string{
+		"TarDirectory",
+		"TarDirectory/TarFile",
+	})
+}
+
 // UnTar
 func (t *TarSuite) TestUnTarFilesUncompressed(c *gc.C) {
 	t.createTestFiles(c)
@@ -256,6 +371,304 @@ func (t *TarSuite) TestUnTarFilesUncompressed(c *gc.C) {
 	t.assertFilesWhereUntared(c, testExpectedTarContents, outputDir)
 }
 
+func (t *TarSuite) TestUnTarCompressedFiles(c *gc.C) {
+	t.createTestFiles(c)
+	trimPath := fmt.Sprintf("%s/", t.cwd)
+	for i, compression := range []Compression{NoCompression, Gzip, Xz, Zstd} {
+		c.Logf("compression %q", compression)
+		if !hasCompression(c, compression) {
+			continue
+		}
+		var outputTar bytes.Buffer
+		shaSum, err := TarFilesWithOptions(t.testFiles, &outputTar, TarOptions{
+			Strip:       trimPath,
+			Compression: compression,
+		})
+		c.Assert(err, gc.IsNil)
+		c.Assert(DetectCompression(outputTar.Bytes()), gc.Equals, compression)
+		c.Assert(shaSum, gc.Equals, shaSumFile(c, bytes.NewReader(outputTar.Bytes())))
+
+		outputDir := filepath.Join(t.cwd, fmt.Sprintf("TarOuputFolder%d", i))
+		err = os.Mkdir(outputDir, os.FileMode(0755))
+		c.Assert(err, gc.IsNil)
+		err = UntarCompressedFiles(&outputTar, outputDir)
+		c.Assert(err, gc.IsNil)
+		t.assertFilesWhereUntared(c, testExpectedTarContents, outputDir)
+	}
+}
+
+func (t *TarSuite) TestTarFilesWithOptionsInvalidCompression(c *gc.C) {
+	var outputTar bytes.Buffer
+	_, err := TarFilesWithOptions([]string{t.cwd}, &outputTar, TarOptions{
+		Compression:      Gzip,
+		CompressionLevel: 12,
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(outputTar.Len(), gc.Equals, 0)
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *TarSuite) TestManifestAndProgress(c *gc.C) {
+	t.createTestFiles(c)
+	var tarred Manifest
+	var tarProgress []Progress
+	var outputTar bytes.Buffer
+	_, err := TarFilesWithOptions(t.testFiles, &outputTar, TarOptions{
+		Strip:    fmt.Sprintf("%s/", t.cwd),
+		Manifest: &tarred,
+		Progress: func(p Progress) {
+			tarProgress = append(tarProgress, p)
+		},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(tarred, jc.SameContents, Manifest{
+		{"TarDirectoryPopulated/TarSubFile1", 11, sha256Hex("TarSubFile1")},
+		{"TarFile1", 8, sha256Hex("TarFile1")},
+		{"TarFile2", 8, sha256Hex("TarFile2")},
+	})
+	names := tarNames(c, bytes.NewReader(outputTar.Bytes()))
+	c.Assert(tarProgress, gc.HasLen, len(names))
+	for i, p := range tarProgress {
+		c.Check(p.Name, gc.Equals, names[i])
+		c.Check(p.Entries, gc.Equals, i+1)
+	}
+	c.Assert(tarProgress[len(tarProgress)-1].Bytes, gc.Equals, int64(11+8+8))
+
+	var untarred Manifest
+	var untarProgress []Progress
+	outputDir := filepath.Join(t.cwd, "TarOuputFolder")
+	err = os.Mkdir(outputDir, os.FileMode(0755))
+	c.Assert(err, gc.IsNil)
+	err = UntarFilesWithOptions(&outputTar, outputDir, UntarOptions{
+		Manifest: &untarred,
+		Progress: func(p Progress) {
+			untarProgress = append(untarProgress, p)
+		},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(untarred, jc.DeepEquals, tarred)
+	c.Assert(untarProgress, jc.DeepEquals, tarProgress)
+}
+
+func (t *TarSuite) TestManifestWithInclude(c *gc.C) {
+	t.createTestFiles(c)
+	var manifest Manifest
+	var progress []Progress
+	_, err := TarFilesWithOptions(t.testFiles, ioutil.Discard, TarOptions{
+		Strip:    fmt.Sprintf("%s/", t.cwd),
+		Include:  []string{"TarSubFile1"},
+		Manifest: &manifest,
+		Progress: func(p Progress) {
+			progress = append(progress, p)
+		},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(manifest, jc.DeepEquals, Manifest{
+		{"TarDirectoryPopulated/TarSubFile1", 11, sha256Hex("TarSubFile1")},
+	})
+	// The pending directory is reported when it is written.
+	c.Assert(progress, jc.DeepEquals, []Progress{
+		{Name: "TarDirectoryPopulated", Entries: 1},
+		{Name: "TarDirectoryPopulated/TarSubFile1", Entries: 2, Bytes: 11},
+	})
+}
+
+// tarEntry describes an entry of the archives written by writeTar.
+type tarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	body     string
+}
+
+// writeTar returns an archive holding the given entries.
+func writeTar(c *gc.C, entries ...tarEntry) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		hdr := &tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Linkname: entry.linkname,
+			Mode:     0644,
+			Size:     int64(len(entry.body)),
+		}
+		if entry.typeflag == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+		err := tw.WriteHeader(hdr)
+		c.Assert(err, gc.IsNil)
+		_, err = tw.Write([]byte(entry.body))
+		c.Assert(err, gc.IsNil)
+	}
+	err := tw.Close()
+	c.Assert(err, gc.IsNil)
+	return &buf
+}
+
+func (t *TarSuite) TestUntarFilesSafe(c *gc.C) {
+	outputDir := filepath.Join(t.cwd, "TarOuputFolder")
+	err := os.Mkdir(outputDir, os.FileMode(0755))
+	c.Assert(err, gc.IsNil)
+	archive := writeTar(c,
+		tarEntry{name: "dir/", typeflag: tar.TypeDir},
+		tarEntry{name: "./dir/../file", typeflag: tar.TypeReg, body: "file"},
+		tarEntry{name: "dir/link", typeflag: tar.TypeSymlink, linkname: "../file"},
+		tarEntry{name: "dir/hardlink", typeflag: tar.TypeLink, linkname: "file"},
+	)
+	err = UntarFilesWithOptions(archive, outputDir, UntarOptions{Safe: true})
+	c.Assert(err, gc.IsNil)
+
+	data, err := ioutil.ReadFile(filepath.Join(outputDir, "dir", "link"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, "file")
+	fileInfo, err := os.Stat(filepath.Join(outputDir, "file"))
+	c.Assert(err, gc.IsNil)
+	hardlinkInfo, err := os.Stat(filepath.Join(outputDir, "dir", "hardlink"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(os.SameFile(fileInfo, hardlinkInfo), jc.IsTrue)
+}
+
+var unsafeTarTests = []struct {
+	about   string
+	entries []tarEntry
+	err     string
+}{{
+	about: "parent directory",
+	entries: []tarEntry{
+		{name: "dir/../../escaped", typeflag: tar.TypeReg, body: "escaped"},
+	},
+	err: `cannot extract "dir/../../escaped": path "dir/../../escaped" is outside of the archive`,
+}, {
+	about: "absolute path",
+	entries: []tarEntry{
+		{name: "/escaped", typeflag: tar.TypeReg, body: "escaped"},
+	},
+	err: `cannot extract "/escaped": absolute path "/escaped"`,
+}, {
+	about: "symlink to a parent directory",
+	entries: []tarEntry{
+		{name: "dir/link", typeflag: tar.TypeSymlink, linkname: "../.."},
+	},
+	err: `cannot extract "dir/link": symlink to "../.." is outside of the archive`,
+}, {
+	about: "symlink to an absolute path",
+	entries: []tarEntry{
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "/etc"},
+	},
+	err: `cannot extract "link": symlink to absolute path "/etc"`,
+}, {
+	about: "beneath a symlink",
+	entries: []tarEntry{
+		{name: "dir/", typeflag: tar.TypeDir},
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "dir"},
+		{name: "link/escaped", typeflag: tar.TypeReg, body: "escaped"},
+	},
+	err: `cannot extract "link/escaped": path "link/escaped" is beneath a symlink`,
+}, {
+	about: "over a symlink",
+	entries: []tarEntry{
+		{name: "file", typeflag: tar.TypeReg, body: "file"},
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "file"},
+		{name: "link", typeflag: tar.TypeReg, body: "escaped"},
+	},
+	err: `cannot extract "link": path "link" is a symlink`,
+}, {
+	about: "symlink through a symlink to a parent directory",
+	entries: []tarEntry{
+		{name: "q/", typeflag: tar.TypeDir},
+		{name: "q/l", typeflag: tar.TypeSymlink, linkname: ".."},
+		{name: "p", typeflag: tar.TypeSymlink, linkname: "q/l/.."},
+	},
+	err: `cannot extract "p": symlink to "q/l/.." is outside of the archive`,
+}, {
+	about: "symlink through a symlink extracted afterwards",
+	entries: []tarEntry{
+		{name: "p", typeflag: tar.TypeSymlink, linkname: "q/l/.."},
+		{name: "q/", typeflag: tar.TypeDir},
+		{name: "q/l", typeflag: tar.TypeSymlink, linkname: ".."},
+	},
+	err: `cannot extract "p": symlink to "q/l/.." is outside of the archive`,
+}, {
+	about: "symlink loop",
+	entries: []tarEntry{
+		{name: "a", typeflag: tar.TypeSymlink, linkname: "b"},
+		{name: "b", typeflag: tar.TypeSymlink, linkname: "a"},
+	},
+	err: `cannot extract "a": symlink to "b" is outside of the archive`,
+}, {
+	about: "directory over a symlink",
+	entries: []tarEntry{
+		{name: "dir/", typeflag: tar.TypeDir},
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "dir"},
+		{name: "link", typeflag: tar.TypeDir},
+	},
+	err: `cannot extract "link": path "link" is a symlink`,
+}, {
+	about: "symlink made to escape by a symlink extracted afterwards",
+	entries: []tarEntry{
+		{name: "a", typeflag: tar.TypeSymlink, linkname: "d/x/../.."},
+		{name: "d", typeflag: tar.TypeSymlink, linkname: "."},
+		{name: "x", typeflag: tar.TypeDir},
+		{name: "a", typeflag: tar.TypeDir},
+		{name: "z", typeflag: tar.TypeLink, linkname: "a"},
+	},
+	err: `cannot extract "a": symlink to "d/x/../.." is outside of the archive`,
+}, {
+	about: "hardlink to a symlink",
+	entries: []tarEntry{
+		{name: "file", typeflag: tar.TypeReg, body: "file"},
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "file"},
+		{name: "hardlink", typeflag: tar.TypeLink, linkname: "link"},
+	},
+	err: `cannot extract hardlink "link" to ".*": path "link" is a symlink`,
+}, {
+	about: "hardlink to a parent directory",
+	entries: []tarEntry{
+		{name: "hardlink", typeflag: tar.TypeLink, linkname: "../escaped"},
+	},
+	err: `cannot extract hardlink "../escaped" to ".*": path "../escaped" is outside of the archive`,
+}}
+
+func (t *TarSuite) TestUntarFilesSafeRejected(c *gc.C) {
+	for i, test := range unsafeTarTests {
+		c.Logf("test %d: %s", i, test.about)
+		outputDir := filepath.Join(t.cwd, fmt.Sprintf("TarOuputFolder%d", i), "output")
+		err := os.MkdirAll(outputDir, os.FileMode(0755))
+		c.Assert(err, gc.IsNil)
+		err = UntarFilesWithOptions(writeTar(c, test.entries...), outputDir, UntarOptions{Safe: true})
+		c.Check(err, gc.ErrorMatches, test.err)
+		_, err = os.Lstat(filepath.Join(outputDir, "..", "escaped"))
+		c.Check(os.IsNotExist(err), jc.IsTrue)
+	}
+}
+
+func (t *TarSuite) TestUntarFilesHardlinkSkipped(c *gc.C) {
+	outputDir := filepath.Join(t.cwd, "TarOuputFolder")
+	err := os.Mkdir(outputDir, os.FileMode(0755))
+	c.Assert(err, gc.IsNil)
+	err = ioutil.WriteFile(filepath.Join(t.cwd, "secret"), []byte("secret"), 0600)
+	c.Assert(err, gc.IsNil)
+
+	// Hardlinks are only extracted when extracting safely.
+	archive := writeTar(c,
+		tarEntry{name: "file", typeflag: tar.TypeReg, body: "file"},
+		tarEntry{name: "hardlink", typeflag: tar.TypeLink, linkname: "file"},
+		tarEntry{name: "outside", typeflag: tar.TypeLink, linkname: "../secret"},
+	)
+	err = UntarFiles(archive, outputDir)
+	c.Assert(err, gc.IsNil)
+	c.Assert(filepath.Join(outputDir, "file"), jc.IsNonEmptyFile)
+	_, err = os.Lstat(filepath.Join(outputDir, "hardlink"))
+	c.Assert(os.IsNotExist(err), jc.IsTrue)
+	_, err = os.Lstat(filepath.Join(outputDir, "outside"))
+	c.Assert(os.IsNotExist(err), jc.IsTrue)
+}
+
 func (t *TarSuite) TestFindFileFound(c *gc.C) {
 	t.createTestFiles(c)
 	var outputTar bytes.Buffer
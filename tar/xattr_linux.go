@@ -0,0 +1,43 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tar
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// setXattr sets the extended attribute with the given name of the
+// given file. If the file is a symlink, the attribute is set on the
+// symlink itself rather than on its target.
+func setXattr(path, name string, value []byte) error {
+	if err := lsetxattr(path, name, value, 0); err != nil {
+		return &os.PathError{Op: "lsetxattr", Path: path, Err: err}
+	}
+	return nil
+}
+
+// lsetxattr is like syscall.Setxattr, but does not follow symlinks.
+func lsetxattr(path, name string, value []byte, flags int) error {
+	pathPtr, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	namePtr, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+	var valuePtr unsafe.Pointer
+	if len(value) > 0 {
+		valuePtr = unsafe.Pointer(&value[0])
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_LSETXATTR,
+		uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(namePtr)),
+		uintptr(valuePtr), uintptr(len(value)), uintptr(flags), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
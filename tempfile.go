@@ -0,0 +1,92 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/juju/errors"
+)
+
+// SecureTempOptions holds the options of SecureTempFile and SecureTempDir.
+type SecureTempOptions struct {
+	// Dir is the directory the temporary file or directory is created
+	// in. It defaults to the directory returned by os.TempDir.
+	Dir string
+
+	// Prefix is the beginning of the name of the temporary file or
+	// directory, which ends with random characters.
+	Prefix string
+
+	// Owner, if set, is the name of the user who is given the
+	// ownership of the temporary file or directory.
+	Owner string
+
+	// SudoOwner signals whether the ownership of the temporary file or
+	// directory is given to the user who ran sudo, as given by the
+	// SUDO_UID and SUDO_GID environment variables, if they are set.
+	// It is ignored if Owner is set.
+	SudoOwner bool
+}
+
+// SecureTempFile creates a temporary file which only its owner may read
+// and write, suitable for credentials. It returns the file, opened for
+// writing, along with a function which closes and removes it.
+func SecureTempFile(options SecureTempOptions) (*os.File, func() error, error) {
+	f, err := ioutil.TempFile(options.Dir, options.Prefix)
+	if err != nil {
+		return nil, nil, errors.Annotate(err, "cannot create temp file")
+	}
+	cleanup := func() error {
+		f.Close()
+		if err := os.Remove(f.Name()); err != nil && !os.IsNotExist(err) {
+			return errors.Trace(err)
+		}
+		return nil
+	}
+	if err := secureTemp(f.Name(), 0600, options); err != nil {
+		cleanup()
+		return nil, nil, errors.Trace(err)
+	}
+	return f, cleanup, nil
+}
+
+// SecureTempDir creates a temporary directory which only its owner may
+// access, suitable for credentials. It returns the path of the directory,
+// along with a function which removes it and all its contents.
+func SecureTempDir(options SecureTempOptions) (string, func() error, error) {
+	dir, err := ioutil.TempDir(options.Dir, options.Prefix)
+	if err != nil {
+		return "", nil, errors.Annotate(err, "cannot create temp dir")
+	}
+	cleanup := func() error {
+		return errors.Trace(os.RemoveAll(dir))
+	}
+	if err := secureTemp(dir, 0700, options); err != nil {
+		cleanup()
+		return "", nil, errors.Trace(err)
+	}
+	return dir, cleanup, nil
+}
+
+// secureTemp sets the permissions and the owner of the given temporary
+// file or directory. ioutil creates them with the given permissions
+// already, but they are set explicitly in case that changes.
+func secureTemp(path string, perms os.FileMode, options SecureTempOptions) error {
+	if err := os.Chmod(path, perms); err != nil {
+		return errors.Annotatef(err, "cannot set permissions of %q", path)
+	}
+	switch {
+	case options.Owner != "":
+		if err := ChownPath(path, options.Owner); err != nil {
+			return errors.Annotatef(err, "cannot change owner of %q", path)
+		}
+	case options.SudoOwner:
+		if err := chownSudoUser(path); err != nil {
+			return errors.Annotatef(err, "cannot change owner of %q", path)
+		}
+	}
+	return nil
+}
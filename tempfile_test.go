@@ -0,0 +1,92 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package utils_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils"
+)
+
+type tempFileSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&tempFileSuite{})
+
+func (*tempFileSuite) TestSecureTempFile(c *gc.C) {
+	dir := c.MkDir()
+	f, cleanup, err := utils.SecureTempFile(utils.SecureTempOptions{Dir: dir, Prefix: "creds"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(filepath.Dir(f.Name()), gc.Equals, dir)
+	c.Assert(filepath.Base(f.Name()), jc.HasPrefix, "creds")
+
+	_, err = f.Write([]byte("secret"))
+	c.Assert(err, jc.ErrorIsNil)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(f.Name())
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(info.Mode().Perm(), gc.Equals, os.FileMode(0600))
+	}
+
+	c.Assert(cleanup(), jc.ErrorIsNil)
+	c.Assert(f.Name(), jc.DoesNotExist)
+	// Cleaning up twice is harmless.
+	c.Assert(cleanup(), jc.ErrorIsNil)
+}
+
+func (*tempFileSuite) TestSecureTempDir(c *gc.C) {
+	parent := c.MkDir()
+	dir, cleanup, err := utils.SecureTempDir(utils.SecureTempOptions{Dir: parent})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(filepath.Dir(dir), gc.Equals, parent)
+	c.Assert(dir, jc.IsDirectory)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(dir)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(info.Mode().Perm(), gc.Equals, os.FileMode(0700))
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "key"), []byte("secret"), 0600)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cleanup(), jc.ErrorIsNil)
+	c.Assert(dir, jc.DoesNotExist)
+}
+
+func (s *tempFileSuite) TestSecureTempDirSudoOwner(c *gc.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("sudo is not available on windows")
+	}
+	s.PatchEnvironment("SUDO_UID", "not-a-uid")
+	s.PatchEnvironment("SUDO_GID", "0")
+	parent := c.MkDir()
+
+	_, _, err := utils.SecureTempDir(utils.SecureTempOptions{Dir: parent, SudoOwner: true})
+	c.Assert(err, gc.ErrorMatches, `cannot change owner of ".*": invalid SUDO_UID "not-a-uid": .*`)
+	// The directory is not left behind.
+	entries, err := ioutil.ReadDir(parent)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(entries, gc.HasLen, 0)
+
+	// Without SudoOwner, the variables are ignored.
+	_, cleanup, err := utils.SecureTempDir(utils.SecureTempOptions{Dir: parent})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cleanup(), jc.ErrorIsNil)
+}
+
+func (s *tempFileSuite) TestSecureTempFileSudoOwnerNotSet(c *gc.C) {
+	// The isolation suite leaves SUDO_UID and SUDO_GID unset.
+	f, cleanup, err := utils.SecureTempFile(utils.SecureTempOptions{Dir: c.MkDir(), SudoOwner: true})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = os.Stat(f.Name())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cleanup(), jc.ErrorIsNil)
+}
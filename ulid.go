@@ -0,0 +1,179 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// ULID represents a universally unique lexicographically sortable
+// identifier, made of a 48 bit Unix time in milliseconds followed by 80
+// random bits. Its string form, 26 characters of Crockford's base32, is
+// shorter than that of a UUID and sorts in the order the ULIDs were
+// generated in.
+// https://github.com/ulid/spec
+type ULID [16]byte
+
+// crockfordAlphabet is the base32 alphabet of ULIDs, which leaves out the
+// letters I, L, O and U to avoid confusions and accidental obscenities.
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var (
+	// ulidNow returns the time ULIDs are generated at.
+	// It is a variable for testing purposes.
+	ulidNow = time.Now
+
+	// ulidMutex guards the last ULID generated by this process.
+	ulidMutex sync.Mutex
+	ulidLast  ULID
+)
+
+// NewULID generates a new ULID for the current time. The ULIDs generated
+// by this process within the same millisecond are ordered too, the random
+// bits of every one being those of the previous one incremented by one.
+func NewULID() (ULID, error) {
+	var ulid ULID
+	if _, err := io.ReadFull(rand.Reader, ulid[6:16]); err != nil {
+		return ULID{}, err
+	}
+
+	ulidMutex.Lock()
+	defer ulidMutex.Unlock()
+	msec := uint64(ulidNow().UnixNano() / int64(time.Millisecond))
+	if last := ulidLast.msec(); msec <= last {
+		// the clock did not move forward: increment the last ULID,
+		// moving on to the next millisecond if its random bits overflow.
+		ulid = ulidLast
+		for i := 15; i >= 6; i-- {
+			ulid[i]++
+			if ulid[i] != 0 {
+				break
+			}
+			if i == 6 {
+				last++
+			}
+		}
+		msec = last
+	}
+	var timestamp [8]byte
+	binary.BigEndian.PutUint64(timestamp[:], msec)
+	copy(ulid[0:6], timestamp[2:8])
+	ulidLast = ulid
+	return ulid, nil
+}
+
+// MustNewULID returns a new ULID, if an error occurs it panics.
+func MustNewULID() ULID {
+	ulid, err := NewULID()
+	if err != nil {
+		panic(err)
+	}
+	return ulid
+}
+
+// ParseULID parses the given ULID string. As specified by Crockford's
+// base32, it is case-insensitive, and "I" and "L" are read as "1" and "O"
+// as "0". It returns an error satisfying errors.IsNotValid if the ULID is
+// malformed.
+func ParseULID(s string) (ULID, error) {
+	if len(s) != 26 {
+		return ULID{}, errors.NotValidf("ULID %q", s)
+	}
+	var ulid ULID
+	for i := 0; i < len(s); i++ {
+		c := strings.ToUpper(s[i : i+1])
+		switch c {
+		case "I", "L":
+			c = "1"
+		case "O":
+			c = "0"
+		}
+		value := strings.Index(crockfordAlphabet, c)
+		// 26 characters hold 130 bits: the first one may only hold 3 of them.
+		if value == -1 || i == 0 && value > 7 {
+			return ULID{}, errors.NotValidf("ULID %q", s)
+		}
+		ulid.setBits(i*5-2, byte(value))
+	}
+	return ulid, nil
+}
+
+// MustParseULID parses the given ULID as ParseULID does,
+// if an error occurs it panics.
+func MustParseULID(s string) ULID {
+	ulid, err := ParseULID(s)
+	if err != nil {
+		panic(err)
+	}
+	return ulid
+}
+
+// Time returns the time the ULID was generated at,
+// with a precision of a millisecond.
+func (ulid ULID) Time() time.Time {
+	msec := int64(ulid.msec())
+	return time.Unix(msec/1000, (msec%1000)*int64(time.Millisecond))
+}
+
+// String returns the 26 characters long base32 representation of the ULID.
+func (ulid ULID) String() string {
+	buf := make([]byte, 26)
+	for i := range buf {
+		buf[i] = crockfordAlphabet[ulid.bits(i*5-2)]
+	}
+	return string(buf)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (ulid ULID) MarshalText() ([]byte, error) {
+	return []byte(ulid.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (ulid *ULID) UnmarshalText(text []byte) error {
+	parsed, err := ParseULID(string(text))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	*ulid = parsed
+	return nil
+}
+
+// msec returns the timestamp of the ULID.
+func (ulid ULID) msec() uint64 {
+	var timestamp [8]byte
+	copy(timestamp[2:8], ulid[0:6])
+	return binary.BigEndian.Uint64(timestamp[:])
+}
+
+// bits returns the 5 bits of the ULID starting at the given bit offset,
+// counting those before the first one, at negative offsets, as zero.
+func (ulid ULID) bits(offset int) byte {
+	var value byte
+	for i := offset; i < offset+5; i++ {
+		value <<= 1
+		if i >= 0 {
+			value |= ulid[i/8] >> uint(7-i%8) & 1
+		}
+	}
+	return value
+}
+
+// setBits sets the 5 bits of the ULID starting at the given bit offset
+// to the given value, ignoring those before the first one.
+func (ulid *ULID) setBits(offset int, value byte) {
+	for i := offset; i < offset+5; i++ {
+		bit := value >> uint(offset+4-i) & 1
+		if i >= 0 {
+			ulid[i/8] |= bit << uint(7-i%8)
+		}
+	}
+}
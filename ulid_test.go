@@ -0,0 +1,97 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package utils_test
+
+import (
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils"
+)
+
+type ulidSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&ulidSuite{})
+
+func (s *ulidSuite) TestNewULID(c *gc.C) {
+	// Move past the ULIDs other tests generated, which later ones
+	// never sort before.
+	now := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	s.PatchValue(utils.ULIDNow, func() time.Time { return now })
+
+	ulid, err := utils.NewULID()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ulid.Time().Equal(now), jc.IsTrue)
+	c.Assert(ulid.String(), gc.HasLen, 26)
+
+	parsed, err := utils.ParseULID(ulid.String())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(parsed, gc.Equals, ulid)
+}
+
+func (s *ulidSuite) TestNewULIDOrdered(c *gc.C) {
+	// The clock does not move forward, or even goes backwards.
+	now := time.Now()
+	s.PatchValue(utils.ULIDNow, func() time.Time { return now })
+
+	last := utils.MustNewULID()
+	for i := 0; i < 1000; i++ {
+		if i == 500 {
+			now = now.Add(-time.Second)
+		}
+		ulid := utils.MustNewULID()
+		c.Assert(ulid.String() > last.String(), jc.IsTrue, gc.Commentf("%s after %s", ulid, last))
+		last = ulid
+	}
+}
+
+func (*ulidSuite) TestString(c *gc.C) {
+	c.Assert(utils.ULID{}.String(), gc.Equals, "00000000000000000000000000")
+	max := utils.ULID{}
+	for i := range max {
+		max[i] = 0xff
+	}
+	c.Assert(max.String(), gc.Equals, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ")
+	c.Assert(utils.MustParseULID("7ZZZZZZZZZZZZZZZZZZZZZZZZZ"), gc.Equals, max)
+}
+
+func (*ulidSuite) TestParseULID(c *gc.C) {
+	ulid := utils.MustParseULID("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	c.Assert(ulid.Time(), gc.Equals, time.Unix(1469922850, 259000000))
+	c.Assert(utils.MustParseULID("01arz3ndektsv4rrffq69g5fav"), gc.Equals, ulid)
+	c.Assert(utils.MustParseULID("O1ARZ3NDEKTSV4RRFFQ69G5FAV"), gc.Equals, ulid)
+
+	// The example of the specification.
+	ulid = utils.MustParseULID("01ARYZ6S41TSV4RRFFQ69G5FAV")
+	c.Assert(ulid.Time(), gc.Equals, time.Unix(1469918176, 385000000))
+
+	for i, s := range []string{
+		"",
+		"01ARZ3NDEKTSV4RRFFQ69G5FA",
+		"01ARZ3NDEKTSV4RRFFQ69G5FAVV",
+		"81ARZ3NDEKTSV4RRFFQ69G5FAV",
+		"01ARZ3NDEKTSV4RRFFQ69G5FAU",
+	} {
+		c.Logf("test %d: %q", i, s)
+		_, err := utils.ParseULID(s)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+	}
+}
+
+func (*ulidSuite) TestText(c *gc.C) {
+	ulid := utils.MustNewULID()
+	text, err := ulid.MarshalText()
+	c.Assert(err, jc.ErrorIsNil)
+
+	var decoded utils.ULID
+	c.Assert(decoded.UnmarshalText(text), jc.ErrorIsNil)
+	c.Assert(decoded, gc.Equals, ulid)
+	c.Assert(decoded.UnmarshalText([]byte("blah")), gc.ErrorMatches, `ULID "blah" not valid`)
+}
@@ -5,11 +5,16 @@ package utils
 
 import (
 	"crypto/rand"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
 )
 
 // UUID represent a universal identifier with 16 octets.
@@ -58,6 +63,15 @@ func MustNewUUID() UUID {
 	return uuid
 }
 
+// MustNewUUIDv7 returns a new version 7 uuid, if an error occurs it panics.
+func MustNewUUIDv7() UUID {
+	uuid, err := NewUUIDv7()
+	if err != nil {
+		panic(err)
+	}
+	return uuid
+}
+
 // NewUUID generates a new version 4 UUID relying only on random numbers.
 func NewUUID() (UUID, error) {
 	uuid := UUID{}
@@ -72,6 +86,120 @@ func NewUUID() (UUID, error) {
 	return uuid, nil
 }
 
+var (
+	// uuidNow returns the time version 7 UUIDs are generated at.
+	// It is a variable for testing purposes.
+	uuidNow = time.Now
+
+	// uuidV7Mutex guards the last timestamp and sequence of
+	// the version 7 UUIDs generated by this process.
+	uuidV7Mutex    sync.Mutex
+	uuidV7LastMsec int64
+	uuidV7Seq      uint16
+)
+
+// NewUUIDv7 generates a new version 7 UUID, as specified by RFC 9562,
+// made of the current Unix time in milliseconds followed by random
+// numbers. Such UUIDs sort in the order they were generated in, which
+// makes them suitable for database keys. The 12 bits following the
+// timestamp hold a sequence, keeping the UUIDs generated by this process
+// within the same millisecond ordered too.
+func NewUUIDv7() (UUID, error) {
+	uuid := UUID{}
+	if _, err := io.ReadFull(rand.Reader, uuid[6:16]); err != nil {
+		return UUID{}, err
+	}
+
+	uuidV7Mutex.Lock()
+	msec := uuidNow().UnixNano() / int64(time.Millisecond)
+	if msec > uuidV7LastMsec {
+		// start the sequence at a random value, leaving room
+		// for the UUIDs generated within the same millisecond.
+		uuidV7Seq = binary.BigEndian.Uint16(uuid[6:8]) & 0x7ff
+	} else {
+		// the clock did not move forward: keep ordering the UUIDs
+		// after the last one, moving on to the next millisecond
+		// if the sequence overflows.
+		msec = uuidV7LastMsec
+		uuidV7Seq++
+		if uuidV7Seq > 0xfff {
+			msec++
+			uuidV7Seq = 0
+		}
+	}
+	uuidV7LastMsec = msec
+	seq := uuidV7Seq
+	uuidV7Mutex.Unlock()
+
+	var timestamp [8]byte
+	binary.BigEndian.PutUint64(timestamp[:], uint64(msec))
+	copy(uuid[0:6], timestamp[2:8])
+	binary.BigEndian.PutUint16(uuid[6:8], seq)
+
+	// Set version (7) and variant (2) according to RFC 9562.
+	var version byte = 7 << 4
+	var variant byte = 8 << 4
+	uuid[6] = version | (uuid[6] & 15)
+	uuid[8] = variant | (uuid[8] & 63)
+	return uuid, nil
+}
+
+// ParseUUID parses the given UUID, given either in its standard form, as
+// returned by String, in upper or lower case, or as a URN prefixed by
+// "urn:uuid:", or enclosed in braces. It returns an error satisfying
+// errors.IsNotValid if the UUID is malformed.
+func ParseUUID(s string) (UUID, error) {
+	str := strings.ToLower(s)
+	if strings.HasPrefix(str, "urn:uuid:") {
+		str = str[len("urn:uuid:"):]
+	} else if strings.HasPrefix(str, "{") && strings.HasSuffix(str, "}") {
+		str = str[1 : len(str)-1]
+	}
+	if !IsValidUUIDString(str) {
+		return UUID{}, errors.NotValidf("UUID %q", s)
+	}
+	return UUIDFromString(str)
+}
+
+// MustParseUUID parses the given UUID as ParseUUID does,
+// if an error occurs it panics.
+func MustParseUUID(s string) UUID {
+	uuid, err := ParseUUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return uuid
+}
+
+// Version returns the version of the UUID, such as 4
+// for random UUIDs or 7 for time-ordered ones.
+func (uuid UUID) Version() int {
+	return int(uuid[6] >> 4)
+}
+
+// IsValid returns whether the UUID is of the variant specified by RFC 4122
+// and of one of the versions it and RFC 9562 define.
+func (uuid UUID) IsValid() bool {
+	version := uuid.Version()
+	return uuid[8]&0xc0 == 0x80 && version >= 1 && version <= 8
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler,
+// encoding the UUID as its 16 octets.
+func (uuid UUID) MarshalBinary() ([]byte, error) {
+	return uuid[:], nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler,
+// decoding the UUID from its 16 octets.
+func (uuid *UUID) UnmarshalBinary(data []byte) error {
+	if len(data) != len(uuid) {
+		return errors.NotValidf("UUID of %d bytes", len(data))
+	}
+	copy(uuid[:], data)
+	return nil
+}
+
 // Copy returns a copy of the UUID.
 func (uuid UUID) Copy() UUID {
 	uuidCopy := uuid
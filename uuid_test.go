@@ -4,6 +4,10 @@
 package utils_test
 
 import (
+	"encoding/binary"
+	"time"
+
+	"github.com/juju/errors"
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
@@ -79,3 +83,81 @@ func (*uuidSuite) TestUUIDFromString(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 	c.Assert(uuid.String(), gc.Equals, validUUID)
 }
+
+func (s *uuidSuite) TestNewUUIDv7(c *gc.C) {
+	// Move past the UUIDs other tests generated, which later ones
+	// never sort before.
+	now := time.Now().Add(time.Hour)
+	s.PatchValue(utils.UUIDNow, func() time.Time { return now })
+
+	uuid, err := utils.NewUUIDv7()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(uuid.Version(), gc.Equals, 7)
+	c.Assert(uuid.IsValid(), jc.IsTrue)
+	c.Assert(uuid.String(), jc.Satisfies, utils.IsValidUUIDString)
+
+	msec := uint64(now.UnixNano() / int64(time.Millisecond))
+	var timestamp [8]byte
+	copy(timestamp[2:], uuid[0:6])
+	c.Assert(binary.BigEndian.Uint64(timestamp[:]), gc.Equals, msec)
+}
+
+func (s *uuidSuite) TestNewUUIDv7Ordered(c *gc.C) {
+	// The clock does not move forward, or even goes backwards.
+	now := time.Date(2016, 5, 4, 3, 2, 1, 0, time.UTC)
+	s.PatchValue(utils.UUIDNow, func() time.Time { return now })
+
+	last := utils.MustNewUUIDv7()
+	for i := 0; i < 5000; i++ {
+		if i == 2500 {
+			now = now.Add(-time.Second)
+		}
+		uuid := utils.MustNewUUIDv7()
+		c.Assert(uuid.String() > last.String(), jc.IsTrue, gc.Commentf("%s after %s", uuid, last))
+		last = uuid
+	}
+}
+
+func (*uuidSuite) TestParseUUID(c *gc.C) {
+	expected := utils.MustParseUUID("9f484882-2f18-4fd2-967d-db9663db7bea")
+	for i, s := range []string{
+		"9f484882-2f18-4fd2-967d-db9663db7bea",
+		"9F484882-2F18-4FD2-967D-DB9663DB7BEA",
+		"urn:uuid:9f484882-2f18-4fd2-967d-db9663db7bea",
+		"{9f484882-2f18-4fd2-967d-db9663db7bea}",
+	} {
+		c.Logf("test %d: %s", i, s)
+		uuid, err := utils.ParseUUID(s)
+		c.Check(err, jc.ErrorIsNil)
+		c.Check(uuid, gc.Equals, expected)
+	}
+	c.Assert(expected.Version(), gc.Equals, 4)
+	c.Assert(expected.IsValid(), jc.IsTrue)
+
+	_, err := utils.ParseUUID("{9f484882-2f18-4fd2-967d-db9663db7bea")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, `UUID "{9f484882-2f18-4fd2-967d-db9663db7bea" not valid`)
+	c.Assert(func() { utils.MustParseUUID("blah") }, gc.PanicMatches, `UUID "blah" not valid`)
+}
+
+func (*uuidSuite) TestIsValid(c *gc.C) {
+	c.Assert(utils.UUID{}.IsValid(), jc.IsFalse)
+	c.Assert(utils.MustNewUUID().IsValid(), jc.IsTrue)
+	// variant 6 (reserved for Microsoft)
+	c.Assert(utils.MustParseUUID("9f484882-2f18-4fd2-c67d-db9663db7bea").IsValid(), jc.IsFalse)
+}
+
+func (*uuidSuite) TestBinary(c *gc.C) {
+	uuid := utils.MustNewUUIDv7()
+	data, err := uuid.MarshalBinary()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(data, gc.HasLen, 16)
+
+	var decoded utils.UUID
+	c.Assert(decoded.UnmarshalBinary(data), jc.ErrorIsNil)
+	c.Assert(decoded, gc.Equals, uuid)
+
+	err = decoded.UnmarshalBinary(data[1:])
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, "UUID of 15 bytes not valid")
+}
@@ -0,0 +1,197 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package zip
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/utils/tar"
+)
+
+// CreateOptions holds the options of CreateZip.
+type CreateOptions struct {
+	// Include, if not empty, holds the patterns of the files to
+	// archive. The directories which do not match them are only
+	// archived if they hold files which do.
+	//
+	// The patterns are matched against the slash separated paths of
+	// the files relative to the archived directory, which are their
+	// names in the archive, as described by tar.PathFilter.
+	Include []string
+
+	// Exclude holds the patterns of the files not to archive.
+	// The excluded directories are not walked.
+	Exclude []string
+
+	// SkipSpecialFiles makes sockets, named pipes and devices be
+	// left out of the archive, rather than failing it.
+	SkipSpecialFiles bool
+}
+
+// specialFileModes holds the modes of the files left out
+// by CreateOptions.SkipSpecialFiles.
+const specialFileModes = os.ModeSocket | os.ModeNamedPipe | os.ModeDevice | os.ModeCharDevice
+
+// CreateZip writes into the supplied writer a zip archive of the
+// contents of the (external, OS-specific) source path, which are named
+// relative to it, or of the source path itself if it is not a directory.
+// It is the counterpart of ExtractAll.
+//
+// The archive records the Unix modes of the files in their external
+// attributes, as zip does, and holds symlinks rather than the files they
+// point to. Regular files are compressed with deflate.
+func CreateZip(writer io.Writer, sourceRoot string, options CreateOptions) error {
+	filter, err := tar.NewPathFilter(options.Include, options.Exclude)
+	if err != nil {
+		return err
+	}
+	zipWriter := zip.NewWriter(writer)
+	creator := &creator{
+		writer:     zipWriter,
+		sourceRoot: sourceRoot,
+		filter:     filter,
+		options:    options,
+	}
+	if err := filepath.Walk(sourceRoot, creator.walk); err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
+
+type creator struct {
+	writer     *zip.Writer
+	sourceRoot string
+	filter     *tar.PathFilter
+	options    CreateOptions
+
+	// pending holds the headers of the directories being walked
+	// which are only written once a file beneath them is.
+	pending []*zip.FileHeader
+}
+
+// walk implements filepath.WalkFunc.
+func (x *creator) walk(filePath string, fileInfo os.FileInfo, err error) error {
+	if err != nil {
+		return err
+	}
+	name, err := x.name(filePath, fileInfo)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		// The root of the archive is not archived itself.
+		return nil
+	}
+	isDir := fileInfo.IsDir()
+	skip := func() error {
+		if isDir {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+	if x.options.SkipSpecialFiles && fileInfo.Mode()&specialFileModes != 0 {
+		return nil
+	}
+	if x.filter.Excluded(name, isDir) {
+		return skip()
+	}
+	header, err := zip.FileInfoHeader(fileInfo)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if isDir {
+		header.Name += "/"
+	}
+
+	x.trimPending(name)
+	if !x.filter.Included(name, isDir) {
+		if isDir {
+			// The directory is walked for included files.
+			x.pending = append(x.pending, header)
+		}
+		return nil
+	}
+	if err := x.write(filePath, fileInfo, header); err != nil {
+		return fmt.Errorf("cannot archive %q: %v", name, err)
+	}
+	return nil
+}
+
+// name returns the name in the archive of the given file,
+// or an empty string if it is the root of the archive.
+func (x *creator) name(filePath string, fileInfo os.FileInfo) (string, error) {
+	relativePath, err := filepath.Rel(x.sourceRoot, filePath)
+	if err != nil {
+		return "", err
+	}
+	if relativePath == "." {
+		if fileInfo.IsDir() {
+			return "", nil
+		}
+		relativePath = filepath.Base(filePath)
+	}
+	return filepath.ToSlash(relativePath), nil
+}
+
+// trimPending forgets the pending directories which
+// are not parents of the file with the given name.
+func (x *creator) trimPending(name string) {
+	for len(x.pending) > 0 {
+		if strings.HasPrefix(name, x.pending[len(x.pending)-1].Name) {
+			return
+		}
+		x.pending = x.pending[:len(x.pending)-1]
+	}
+}
+
+// write writes the given file into the archive, after the
+// pending directories it is beneath.
+func (x *creator) write(filePath string, fileInfo os.FileInfo, header *zip.FileHeader) error {
+	for _, dir := range x.pending {
+		if _, err := x.writer.CreateHeader(dir); err != nil {
+			return err
+		}
+	}
+	x.pending = x.pending[:0]
+
+	mode := fileInfo.Mode()
+	switch modeType := mode & os.ModeType; modeType {
+	case os.ModeDir:
+		_, err := x.writer.CreateHeader(header)
+		return err
+	case os.ModeSymlink:
+		// The contents of symlinks are their targets.
+		symlinkTarget, err := os.Readlink(filePath)
+		if err != nil {
+			return err
+		}
+		w, err := x.writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, symlinkTarget)
+		return err
+	case 0:
+		header.Method = zip.Deflate
+		w, err := x.writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(w, file)
+		return err
+	default:
+		return fmt.Errorf("unknown file type %d", modeType)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package zip_test
+
+import (
+	stdzip "archive/zip"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	ft "github.com/juju/testing/filetesting"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/zip"
+)
+
+// createZip returns a reader of a zip archive of the given
+// directory, created by CreateZip with the given options.
+func createZip(c *gc.C, sourceRoot string, options zip.CreateOptions) *stdzip.Reader {
+	var buf bytes.Buffer
+	err := zip.CreateZip(&buf, sourceRoot, options)
+	c.Assert(err, gc.IsNil)
+	reader, err := stdzip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	c.Assert(err, gc.IsNil)
+	return reader
+}
+
+func zipNames(reader *stdzip.Reader) []string {
+	var names []string
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var createEntries = []ft.Entry{
+	ft.File{"some-file", "content 1", 0644},
+	ft.File{"another-file", "content 2", 0600},
+	ft.File{"script", "#!/bin/sh", 0755},
+	ft.Symlink{"some-symlink", "some-file"},
+	ft.Dir{"some-dir", 0750},
+	ft.File{"some-dir/another-file", "content 3", 0640},
+	ft.Dir{"some-dir/another-dir", 0711},
+	ft.Symlink{"some-dir/another-dir/another-symlink", "../../another-file"},
+}
+
+func (s *ZipSuite) TestCreateZip(c *gc.C) {
+	sourcePath := c.MkDir()
+	for _, entry := range createEntries {
+		entry.Create(c, sourcePath)
+	}
+	reader := createZip(c, sourcePath, zip.CreateOptions{})
+	c.Assert(zipNames(reader), jc.DeepEquals, []string{
+		"another-file",
+		"script",
+		"some-dir/",
+		"some-dir/another-dir/",
+		"some-dir/another-dir/another-symlink",
+		"some-dir/another-file",
+		"some-file",
+		"some-symlink",
+	})
+	for _, file := range reader.File {
+		// The modes are recorded as Unix ones.
+		c.Check(file.CreatorVersion>>8, gc.Equals, uint16(3))
+	}
+
+	targetPath := c.MkDir()
+	err := zip.ExtractAll(reader, targetPath)
+	c.Assert(err, gc.IsNil)
+	for i, entry := range createEntries {
+		c.Logf("test %d: %#v", i, entry)
+		entry.Check(c, targetPath)
+	}
+}
+
+func (s *ZipSuite) TestCreateZipExtractedByUnzip(c *gc.C) {
+	sourcePath := c.MkDir()
+	for _, entry := range createEntries {
+		entry.Create(c, sourcePath)
+	}
+	zipPath := filepath.Join(c.MkDir(), "test.zip")
+	file, err := os.Create(zipPath)
+	c.Assert(err, gc.IsNil)
+	err = zip.CreateZip(file, sourcePath, zip.CreateOptions{})
+	c.Assert(err, gc.IsNil)
+	err = file.Close()
+	c.Assert(err, gc.IsNil)
+
+	targetPath := c.MkDir()
+	cmd := exec.Command("/bin/sh", "-c", fmt.Sprintf("cd %q; unzip %q", targetPath, zipPath))
+	output, err := cmd.CombinedOutput()
+	c.Assert(err, gc.IsNil, gc.Commentf("Command output: %s", output))
+	for i, entry := range createEntries {
+		c.Logf("test %d: %#v", i, entry)
+		entry.Check(c, targetPath)
+	}
+}
+
+func (s *ZipSuite) TestCreateZipSingleFile(c *gc.C) {
+	sourcePath := c.MkDir()
+	ft.File{"some-file", "content", 0640}.Create(c, sourcePath)
+	reader := createZip(c, filepath.Join(sourcePath, "some-file"), zip.CreateOptions{})
+	c.Assert(zipNames(reader), jc.DeepEquals, []string{"some-file"})
+	c.Assert(reader.File[0].Mode(), gc.Equals, os.FileMode(0640))
+}
+
+func (s *ZipSuite) TestCreateZipFilters(c *gc.C) {
+	sourcePath := c.MkDir()
+	for _, entry := range []ft.Entry{
+		ft.File{"main.go", "package main", 0644},
+		ft.File{"README", "read me", 0644},
+		ft.File{"secret.key", "secret", 0600},
+		ft.Dir{"cache", 0755},
+		ft.File{"cache/main.go", "cached", 0644},
+		ft.Dir{"pkg", 0755},
+		ft.File{"pkg/lib.go", "package pkg", 0644},
+		ft.File{"pkg/lib_test.go", "package pkg", 0644},
+		ft.Dir{"docs", 0755},
+		ft.File{"docs/index.md", "docs", 0644},
+	} {
+		entry.Create(c, sourcePath)
+	}
+	for i, test := range []struct {
+		about   string
+		options zip.CreateOptions
+		expect  []string
+	}{{
+		about: "exclude",
+		options: zip.CreateOptions{
+			Exclude: []string{"cache/", "*.key", "*_test.go"},
+		},
+		expect: []string{"README", "docs/", "docs/index.md", "main.go", "pkg/", "pkg/lib.go"},
+	}, {
+		about: "include",
+		options: zip.CreateOptions{
+			Include: []string{"*.go"},
+		},
+		expect: []string{"cache/", "cache/main.go", "main.go", "pkg/", "pkg/lib.go", "pkg/lib_test.go"},
+	}, {
+		about: "include and exclude",
+		options: zip.CreateOptions{
+			Include: []string{"*.go", "/docs/"},
+			Exclude: []string{"/cache", "*_test.go"},
+		},
+		expect: []string{"docs/", "docs/index.md", "main.go", "pkg/", "pkg/lib.go"},
+	}} {
+		c.Logf("test %d: %s", i, test.about)
+		reader := createZip(c, sourcePath, test.options)
+		c.Check(zipNames(reader), jc.DeepEquals, test.expect)
+	}
+}
+
+func (s *ZipSuite) TestCreateZipInvalidPattern(c *gc.C) {
+	var buf bytes.Buffer
+	err := zip.CreateZip(&buf, c.MkDir(), zip.CreateOptions{
+		Exclude: []string{"[a-"},
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(buf.Len(), gc.Equals, 0)
+}
+
+func (s *ZipSuite) TestCreateZipSpecialFiles(c *gc.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("no unix sockets on windows")
+	}
+	sourcePath := c.MkDir()
+	ft.File{"some-file", "content", 0644}.Create(c, sourcePath)
+	listener, err := net.Listen("unix", filepath.Join(sourcePath, "some-socket"))
+	c.Assert(err, gc.IsNil)
+	defer listener.Close()
+
+	var buf bytes.Buffer
+	err = zip.CreateZip(&buf, sourcePath, zip.CreateOptions{})
+	c.Assert(err, gc.ErrorMatches, `cannot archive "some-socket": unknown file type .*`)
+
+	reader := createZip(c, sourcePath, zip.CreateOptions{SkipSpecialFiles: true})
+	c.Assert(zipNames(reader), jc.DeepEquals, []string{"some-file"})
+}
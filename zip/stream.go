@@ -0,0 +1,456 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package zip
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	fileHeaderSignature      = 0x04034b50
+	directoryHeaderSignature = 0x02014b50
+	directoryEndSignature    = 0x06054b50
+	dataDescriptorSignature  = 0x08074b50
+
+	fileHeaderLen      = 26 // without the signature
+	directoryHeaderLen = 42 // without the signature
+
+	flagEncrypted      = 0x1
+	flagDataDescriptor = 0x8
+
+	zip64ExtraID = 0x0001
+	uint32max    = 0xffffffff
+)
+
+// ExtractAllStream extracts the zip archive read from the supplied
+// reader to the target path, as ExtractAll does.
+func ExtractAllStream(reader io.Reader, targetRoot string) error {
+	return ExtractStream(reader, targetRoot, "")
+}
+
+// ExtractStream extracts files from the zip archive read from the
+// supplied reader, from the source path into the target path, as
+// Extract does, without requiring the whole archive to be available
+// upfront.
+//
+// The files are extracted as they are read. The modes of the files,
+// and which ones are symlinks, are only recorded in the central
+// directory at the end of the archive, so the files are given their
+// modes, and the symlinks created, once it is read. Only the names of
+// the files extracted are kept in memory meanwhile.
+//
+// The files must be stored or compressed with deflate, and not be
+// encrypted. The stored files whose sizes are recorded after their
+// contents, as zip does when writing to a pipe, are read up to their
+// data descriptor, which must then start with its optional signature.
+// Entries with paths leading out of the target path are rejected.
+func ExtractStream(reader io.Reader, targetRoot, sourceRoot string) error {
+	x, err := newExtractor(targetRoot, sourceRoot)
+	if err != nil {
+		return err
+	}
+	stream := &zipStream{
+		extractor: x,
+		reader:    bufio.NewReader(reader),
+		extracted: make(map[string]string),
+	}
+	return stream.extract()
+}
+
+// zipStream extracts the files of a zip archive read sequentially.
+type zipStream struct {
+	extractor
+	reader *bufio.Reader
+
+	// extracted maps the clean names of the files
+	// extracted to the paths they were written to.
+	extracted map[string]string
+}
+
+// directoryEntry holds what is needed of a
+// central directory header of a zip archive.
+type directoryEntry struct {
+	cleanName string
+	mode      os.FileMode
+}
+
+func (s *zipStream) extract() error {
+	for {
+		signature, err := s.readUint32()
+		if err != nil {
+			return fmt.Errorf("cannot read zip archive: %v", err)
+		}
+		switch signature {
+		case fileHeaderSignature:
+			if err := s.extractFile(); err != nil {
+				return err
+			}
+		case directoryHeaderSignature:
+			entries, err := s.readDirectory()
+			if err != nil {
+				return fmt.Errorf("cannot read zip archive: %v", err)
+			}
+			return s.finish(entries)
+		case directoryEndSignature:
+			// the archive is empty.
+			return nil
+		default:
+			return fmt.Errorf("cannot read zip archive: unexpected signature %#x", signature)
+		}
+	}
+}
+
+// extractFile extracts the file whose local header is read next, and
+// whose name, modes and symlink target are checked and applied later.
+func (s *zipStream) extractFile() error {
+	var buf [fileHeaderLen]byte
+	if _, err := io.ReadFull(s.reader, buf[:]); err != nil {
+		return fmt.Errorf("cannot read zip archive: %v", unexpectedEOF(err))
+	}
+	flags := binary.LittleEndian.Uint16(buf[2:4])
+	method := binary.LittleEndian.Uint16(buf[4:6])
+	crc := binary.LittleEndian.Uint32(buf[10:14])
+	compressedSize := uint64(binary.LittleEndian.Uint32(buf[14:18]))
+	size := uint64(binary.LittleEndian.Uint32(buf[18:22]))
+	name, err := s.readString(int(binary.LittleEndian.Uint16(buf[22:24])))
+	if err != nil {
+		return fmt.Errorf("cannot read zip archive: %v", err)
+	}
+	extra, err := s.readString(int(binary.LittleEndian.Uint16(buf[24:26])))
+	if err != nil {
+		return fmt.Errorf("cannot read zip archive: %v", err)
+	}
+	cleanName := path.Clean(name)
+	fail := func(err error) error {
+		return fmt.Errorf("cannot extract %q: %v", cleanName, err)
+	}
+
+	zip64 := compressedSize == uint32max || size == uint32max
+	if zip64 {
+		if size, compressedSize, err = zip64Sizes([]byte(extra)); err != nil {
+			return fail(err)
+		}
+	}
+	dataDescriptor := flags&flagDataDescriptor != 0
+	switch {
+	case flags&flagEncrypted != 0:
+		return fail(fmt.Errorf("encrypted files not supported"))
+	case !isSanePath(cleanName):
+		return fail(fmt.Errorf("path leads out of scope"))
+	}
+
+	hash := crc32.NewIEEE()
+	counter := &countingWriter{w: hash}
+	var content io.Reader
+	limited := &io.LimitedReader{R: s.reader, N: int64(compressedSize)}
+	switch method {
+	case zip.Store:
+		if dataDescriptor {
+			content = &storedReader{
+				reader:  s.reader,
+				hash:    hash,
+				counter: counter,
+				zip64:   zip64,
+			}
+		} else {
+			content = limited
+		}
+	case zip.Deflate:
+		if dataDescriptor {
+			// The deflate stream is read up to its end
+			// and no further, as the reader is buffered.
+			content = flate.NewReader(s.reader)
+		} else {
+			content = flate.NewReader(limited)
+		}
+	default:
+		return fail(fmt.Errorf("compression method %d not supported", method))
+	}
+	content = io.TeeReader(content, counter)
+
+	if err := s.writeFile(name, content); err != nil {
+		return fail(err)
+	}
+	if _, err := io.Copy(ioutil.Discard, content); err != nil {
+		return fail(unexpectedEOF(err))
+	}
+	if !dataDescriptor {
+		if _, err := io.Copy(ioutil.Discard, limited); err != nil {
+			return fail(unexpectedEOF(err))
+		}
+	} else {
+		// The local headers of the files of unknown size do not tell
+		// whether their data descriptor is in the zip64 format.
+		zip64 = zip64 || counter.n >= uint32max
+		if crc, size, err = s.readDataDescriptor(zip64); err != nil {
+			return fail(err)
+		}
+	}
+	if counter.n != size {
+		return fail(fmt.Errorf("file size mismatch"))
+	}
+	if hash.Sum32() != crc {
+		return fail(zip.ErrChecksum)
+	}
+	return nil
+}
+
+// writeFile writes the given content at the target path of the file
+// with the given name, if it is extracted. The modes of the files are
+// only known once the central directory is read, so they are extracted
+// as private files and directories meanwhile.
+func (s *zipStream) writeFile(name string, content io.Reader) error {
+	cleanName := path.Clean(name)
+	targetPath, ok := s.targetPath(cleanName)
+	if !ok {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0777); err != nil {
+		return err
+	}
+	s.extracted[cleanName] = targetPath
+	if strings.HasSuffix(name, "/") {
+		return s.writeDir(targetPath, 0700)
+	}
+	return s.extractor.writeFile(targetPath, content, 0600)
+}
+
+// readDataDescriptor reads the data descriptor following the contents
+// of a file, and returns the checksum and size of the file.
+func (s *zipStream) readDataDescriptor(zip64 bool) (crc uint32, size uint64, err error) {
+	// The signature of data descriptors is optional.
+	if crc, err = s.readUint32(); err == nil && crc == dataDescriptorSignature {
+		crc, err = s.readUint32()
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	sizesLen := 8
+	if zip64 {
+		sizesLen = 16
+	}
+	sizes := make([]byte, sizesLen)
+	if _, err := io.ReadFull(s.reader, sizes); err != nil {
+		return 0, 0, unexpectedEOF(err)
+	}
+	if zip64 {
+		return crc, binary.LittleEndian.Uint64(sizes[8:]), nil
+	}
+	return crc, uint64(binary.LittleEndian.Uint32(sizes[4:])), nil
+}
+
+// readDirectory reads the central directory of the archive, whose
+// first signature has been read, up to its last header.
+func (s *zipStream) readDirectory() ([]directoryEntry, error) {
+	var entries []directoryEntry
+	for {
+		var buf [directoryHeaderLen]byte
+		if _, err := io.ReadFull(s.reader, buf[:]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		name, err := s.readString(int(binary.LittleEndian.Uint16(buf[24:26])))
+		if err != nil {
+			return nil, err
+		}
+		skipLen := int64(binary.LittleEndian.Uint16(buf[26:28])) + int64(binary.LittleEndian.Uint16(buf[28:30]))
+		if _, err := io.CopyN(ioutil.Discard, s.reader, skipLen); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		header := zip.FileHeader{
+			Name:           name,
+			CreatorVersion: binary.LittleEndian.Uint16(buf[0:2]),
+			ExternalAttrs:  binary.LittleEndian.Uint32(buf[34:38]),
+		}
+		entries = append(entries, directoryEntry{
+			cleanName: path.Clean(name),
+			mode:      header.Mode(),
+		})
+
+		signature, err := s.readUint32()
+		if err != nil {
+			return nil, err
+		}
+		if signature != directoryHeaderSignature {
+			// The end of the archive is of no interest.
+			return entries, nil
+		}
+	}
+}
+
+// finish gives the extracted files the modes recorded in the given
+// entries of the central directory, and replaces those which are
+// symlinks by symlinks. The directories are dealt with last, so that
+// their modes do not prevent changing their contents.
+func (s *zipStream) finish(entries []directoryEntry) error {
+	for _, dirs := range []bool{false, true} {
+		for _, entry := range entries {
+			targetPath, ok := s.extracted[entry.cleanName]
+			if !ok || entry.mode.IsDir() != dirs {
+				continue
+			}
+			if err := s.applyMode(targetPath, entry.mode); err != nil {
+				return fmt.Errorf("cannot extract %q: %v", entry.cleanName, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *zipStream) applyMode(targetPath string, mode os.FileMode) error {
+	modePerm := mode & os.ModePerm
+	switch modeType := mode & os.ModeType; modeType {
+	case os.ModeDir:
+		return s.writeDir(targetPath, modePerm)
+	case os.ModeSymlink:
+		// The contents of symlinks are their targets.
+		symlinkTarget, err := ioutil.ReadFile(targetPath)
+		if err != nil {
+			return err
+		}
+		return s.writeSymlink(targetPath, string(symlinkTarget))
+	case 0:
+		return os.Chmod(targetPath, modePerm)
+	default:
+		if err := os.Remove(targetPath); err != nil {
+			return err
+		}
+		return fmt.Errorf("unknown file type %d", modeType)
+	}
+}
+
+func (s *zipStream) readUint32() (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(s.reader, buf[:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
+
+func (s *zipStream) readString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(s.reader, buf); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	return string(buf), nil
+}
+
+// zip64Sizes returns the uncompressed and compressed
+// sizes recorded in the given extra fields of a local
+// file header.
+func zip64Sizes(extra []byte) (size, compressedSize uint64, err error) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		fieldLen := int(binary.LittleEndian.Uint16(extra[2:4]))
+		extra = extra[4:]
+		if fieldLen > len(extra) {
+			break
+		}
+		if id == zip64ExtraID && fieldLen >= 16 {
+			return binary.LittleEndian.Uint64(extra[0:8]), binary.LittleEndian.Uint64(extra[8:16]), nil
+		}
+		extra = extra[fieldLen:]
+	}
+	return 0, 0, zip.ErrFormat
+}
+
+// unexpectedEOF returns io.ErrUnexpectedEOF instead of io.EOF,
+// as the archive is truncated wherever it ends before its
+// central directory.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// storedReader reads the contents of a stored file whose size is
+// recorded after them, up to the data descriptor matching them.
+type storedReader struct {
+	reader *bufio.Reader
+
+	// hash and counter get the contents read,
+	// once they are returned.
+	hash    hash.Hash32
+	counter *countingWriter
+	zip64   bool
+	done    bool
+}
+
+// dataDescriptorSignatureBytes holds the signature of
+// data descriptors, as it is found in archives.
+var dataDescriptorSignatureBytes = []byte{0x50, 0x4b, 0x07, 0x08}
+
+func (r *storedReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+	zip64 := r.zip64 || r.counter.n >= uint32max
+	descriptorLen := 16
+	if zip64 {
+		descriptorLen = 24
+	}
+	window, err := r.reader.Peek(descriptorLen)
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	if r.isDescriptor(window, zip64) {
+		r.done = true
+		return 0, io.EOF
+	}
+	if buffered := r.reader.Buffered(); buffered > len(window) {
+		window, _ = r.reader.Peek(buffered)
+	}
+	// Read up to the next possible signature, keeping
+	// what could be the start of one at the end of the window.
+	n := bytes.Index(window[1:], dataDescriptorSignatureBytes) + 1
+	if n == 0 {
+		n = len(window) - len(dataDescriptorSignatureBytes) + 1
+	}
+	if n > len(p) {
+		n = len(p)
+	}
+	return io.ReadFull(r.reader, p[:n])
+}
+
+// isDescriptor returns whether the given window starts with a
+// data descriptor matching the contents read so far.
+func (r *storedReader) isDescriptor(window []byte, zip64 bool) bool {
+	if !bytes.HasPrefix(window, dataDescriptorSignatureBytes) {
+		return false
+	}
+	if binary.LittleEndian.Uint32(window[4:8]) != r.hash.Sum32() {
+		return false
+	}
+	if zip64 {
+		return binary.LittleEndian.Uint64(window[8:16]) == r.counter.n &&
+			binary.LittleEndian.Uint64(window[16:24]) == r.counter.n
+	}
+	return uint64(binary.LittleEndian.Uint32(window[8:12])) == r.counter.n &&
+		uint64(binary.LittleEndian.Uint32(window[12:16])) == r.counter.n
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += uint64(n)
+	return n, err
+}
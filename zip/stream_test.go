@@ -0,0 +1,217 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package zip_test
+
+import (
+	stdzip "archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	ft "github.com/juju/testing/filetesting"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/zip"
+)
+
+// openStream returns a reader of the given file which
+// only implements io.Reader.
+func (s *ZipSuite) openStream(c *gc.C, path string) io.Reader {
+	file, err := os.Open(path)
+	c.Assert(err, gc.IsNil)
+	s.AddCleanup(func(c *gc.C) {
+		err := file.Close()
+		c.Assert(err, gc.IsNil)
+	})
+	return struct{ io.Reader }{file}
+}
+
+// goZip returns a zip archive written by archive/zip, which
+// records the sizes of the files after their contents.
+func goZip(c *gc.C, entries ...stdzip.FileHeader) *bytes.Buffer {
+	var buf bytes.Buffer
+	w := stdzip.NewWriter(&buf)
+	for _, entry := range entries {
+		entry := entry
+		body := entry.Comment
+		entry.Comment = ""
+		fw, err := w.CreateHeader(&entry)
+		c.Assert(err, gc.IsNil)
+		_, err = io.WriteString(fw, body)
+		c.Assert(err, gc.IsNil)
+	}
+	err := w.Close()
+	c.Assert(err, gc.IsNil)
+	return &buf
+}
+
+// zipHeader returns the header of a file with the given name,
+// mode and contents in the archives written by goZip.
+func zipHeader(name string, mode os.FileMode, body string) stdzip.FileHeader {
+	header := stdzip.FileHeader{
+		Name:    name,
+		Method:  stdzip.Deflate,
+		Comment: body,
+	}
+	header.SetMode(mode)
+	return header
+}
+
+var streamEntries = []ft.Entry{
+	ft.File{"some-file", "content 1", 0644},
+	ft.File{"another-file", "content 2", 0640},
+	ft.Symlink{"some-symlink", "some-file"},
+	ft.Dir{"some-dir", 0750},
+	ft.File{"some-dir/another-file", "content 3", 0644},
+	ft.Dir{"some-dir/another-dir", 0555},
+	ft.Symlink{"some-dir/another-dir/another-symlink", "../../another-file"},
+}
+
+func (s *ZipSuite) TestExtractAllStream(c *gc.C) {
+	stream := s.openStream(c, s.makeZipFile(c, streamEntries...))
+	targetPath := c.MkDir()
+	err := zip.ExtractAllStream(stream, targetPath)
+	c.Assert(err, gc.IsNil)
+	for i, entry := range streamEntries {
+		c.Logf("test %d: %#v", i, entry)
+		entry.Check(c, targetPath)
+	}
+}
+
+func (s *ZipSuite) TestExtractAllStreamPiped(c *gc.C) {
+	// zip cannot write symlinks, which are stored, to pipes.
+	entries := []ft.Entry{
+		ft.File{"some-file", "content 1", 0644},
+		ft.Dir{"some-dir", 0750},
+		ft.File{"some-dir/another-file", "content 3", 0600},
+	}
+	basePath := c.MkDir()
+	for _, entry := range entries {
+		entry.Create(c, basePath)
+	}
+	// zip writes the sizes of the files after their
+	// contents when writing to a pipe.
+	cmd := exec.Command("/bin/sh", "-c", "zip -r - .")
+	cmd.Dir = basePath
+	output, err := cmd.Output()
+	c.Assert(err, gc.IsNil)
+
+	targetPath := c.MkDir()
+	err = zip.ExtractAllStream(bytes.NewReader(output), targetPath)
+	c.Assert(err, gc.IsNil)
+	for i, entry := range entries {
+		c.Logf("test %d: %#v", i, entry)
+		entry.Check(c, targetPath)
+	}
+}
+
+func (s *ZipSuite) TestExtractAllStreamDataDescriptors(c *gc.C) {
+	stored := zipHeader("dir/stored", 0600, "stored "+string([]byte{0x50, 0x4b, 0x07, 0x08})+" content")
+	stored.Method = stdzip.Store
+	archive := goZip(c,
+		zipHeader("dir/", os.ModeDir|0751, ""),
+		zipHeader("dir/file", 0640, "content"),
+		stored,
+		zipHeader("dir/symlink", os.ModeSymlink|0777, "file"),
+	)
+	targetPath := c.MkDir()
+	err := zip.ExtractAllStream(archive, targetPath)
+	c.Assert(err, gc.IsNil)
+	for i, entry := range []ft.Entry{
+		ft.Dir{"dir", 0751},
+		ft.File{"dir/file", "content", 0640},
+		ft.File{"dir/stored", "stored PK\x07\x08 content", 0600},
+		ft.Symlink{"dir/symlink", "file"},
+	} {
+		c.Logf("test %d: %#v", i, entry)
+		entry.Check(c, targetPath)
+	}
+}
+
+func (s *ZipSuite) TestExtractStreamDir(c *gc.C) {
+	stream := s.openStream(c, s.makeZipFile(c,
+		ft.File{"bad-file", "xxx", 0644},
+		ft.Dir{"some-dir", 0751},
+		ft.File{"some-dir/some-file", "content 1", 0644},
+		ft.Dir{"some-dir/another-dir", 0750},
+		ft.Symlink{"some-dir/another-dir/some-symlink", "../some-file"},
+	))
+	targetParent := c.MkDir()
+	targetPath := filepath.Join(targetParent, "random-dir")
+	err := zip.ExtractStream(stream, targetPath, "some-dir")
+	c.Assert(err, gc.IsNil)
+
+	for i, test := range []ft.Entry{
+		ft.Dir{"random-dir", 0751},
+		ft.File{"random-dir/some-file", "content 1", 0644},
+		ft.Dir{"random-dir/another-dir", 0750},
+		ft.Symlink{"random-dir/another-dir/some-symlink", "../some-file"},
+	} {
+		c.Logf("test %d: %#v", i, test)
+		test.Check(c, targetParent)
+	}
+	fileInfos, err := ioutil.ReadDir(targetParent)
+	c.Check(err, gc.IsNil)
+	c.Check(fileInfos, gc.HasLen, 1)
+}
+
+func (s *ZipSuite) TestExtractAllStreamErrors(c *gc.C) {
+	for i, test := range []struct {
+		about   string
+		archive []byte
+		error   string
+	}{{
+		about:   "absolute symlink",
+		archive: goZip(c, zipHeader("symlink", os.ModeSymlink|0777, "/blah")).Bytes(),
+		error:   `cannot extract "symlink": symlink "/blah" is absolute`,
+	}, {
+		about:   "symlink out of scope",
+		archive: goZip(c, zipHeader("symlink", os.ModeSymlink|0777, "../blah")).Bytes(),
+		error:   `cannot extract "symlink": symlink "../blah" leads out of scope`,
+	}, {
+		about:   "path out of scope",
+		archive: goZip(c, zipHeader("dir/../../blah", 0644, "content")).Bytes(),
+		error:   `cannot extract "../blah": path leads out of scope`,
+	}, {
+		about:   "truncated archive",
+		archive: goZip(c, zipHeader("file", 0644, "content")).Bytes()[:40],
+		error:   `cannot extract "file": unexpected EOF`,
+	}, {
+		about:   "not an archive",
+		archive: []byte("this is not a zip archive"),
+		error:   `cannot read zip archive: unexpected signature .*`,
+	}} {
+		c.Logf("test %d: %s", i, test.about)
+		targetPath := filepath.Join(c.MkDir(), "target")
+		err := zip.ExtractAllStream(bytes.NewReader(test.archive), targetPath)
+		c.Check(err, gc.ErrorMatches, test.error)
+		_, err = os.Lstat(filepath.Join(targetPath, "..", "blah"))
+		c.Check(os.IsNotExist(err), gc.Equals, true)
+	}
+}
+
+func (s *ZipSuite) TestExtractAllStreamChecksum(c *gc.C) {
+	archive := s.makeZipFile(c, ft.File{"some-file", "content", 0644})
+	data, err := ioutil.ReadFile(archive)
+	c.Assert(err, gc.IsNil)
+	// The file is too small to be compressed by zip.
+	i := bytes.Index(data, []byte("content"))
+	c.Assert(i, gc.Not(gc.Equals), -1)
+	data[i] = 'C'
+
+	err = zip.ExtractAllStream(bytes.NewReader(data), c.MkDir())
+	c.Assert(err, gc.ErrorMatches, `cannot extract "some-file": zip: checksum error`)
+}
+
+func (s *ZipSuite) TestExtractAllStreamEmpty(c *gc.C) {
+	targetPath := c.MkDir()
+	err := zip.ExtractAllStream(goZip(c), targetPath)
+	c.Assert(err, gc.IsNil)
+	fileInfos, err := ioutil.ReadDir(targetPath)
+	c.Assert(err, gc.IsNil)
+	c.Assert(fileInfos, gc.HasLen, 0)
+}
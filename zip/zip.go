@@ -49,14 +49,10 @@ func ExtractAll(reader *zip.Reader, targetRoot string) error {
 // source path does not reference a directory, the referenced file will be written
 // directly to the target path.
 func Extract(reader *zip.Reader, targetRoot, sourceRoot string) error {
-	sourceRoot = path.Clean(sourceRoot)
-	if sourceRoot == "." {
-		sourceRoot = ""
-	}
-	if !isSanePath(sourceRoot) {
-		return fmt.Errorf("cannot extract files rooted at %q", sourceRoot)
+	extractor, err := newExtractor(targetRoot, sourceRoot)
+	if err != nil {
+		return err
 	}
-	extractor := extractor{targetRoot, sourceRoot}
 	for _, zipFile := range reader.File {
 		if err := extractor.extract(zipFile); err != nil {
 			cleanName := path.Clean(zipFile.Name)
@@ -71,10 +67,23 @@ type extractor struct {
 	sourceRoot string
 }
 
-// targetPath returns the target path for a given zip file and whether
-// it should be extracted.
-func (x extractor) targetPath(zipFile *zip.File) (string, bool) {
-	cleanPath := path.Clean(zipFile.Name)
+// newExtractor returns an extractor of the files of the given source
+// path into the given target path.
+func newExtractor(targetRoot, sourceRoot string) (extractor, error) {
+	sourceRoot = path.Clean(sourceRoot)
+	if sourceRoot == "." {
+		sourceRoot = ""
+	}
+	if !isSanePath(sourceRoot) {
+		return extractor{}, fmt.Errorf("cannot extract files rooted at %q", sourceRoot)
+	}
+	return extractor{targetRoot, sourceRoot}, nil
+}
+
+// targetPath returns the target path for the zip file with the given
+// name and whether it should be extracted.
+func (x extractor) targetPath(name string) (string, bool) {
+	cleanPath := path.Clean(name)
 	if cleanPath == x.sourceRoot {
 		return x.targetRoot, true
 	}
@@ -89,7 +98,7 @@ func (x extractor) targetPath(zipFile *zip.File) (string, bool) {
 }
 
 func (x extractor) extract(zipFile *zip.File) error {
-	targetPath, ok := x.targetPath(zipFile)
+	targetPath, ok := x.targetPath(zipFile.Name)
 	if !ok {
 		return nil
 	}
@@ -104,9 +113,18 @@ func (x extractor) extract(zipFile *zip.File) error {
 	case os.ModeDir:
 		return x.writeDir(targetPath, modePerm)
 	case os.ModeSymlink:
-		return x.writeSymlink(targetPath, zipFile)
+		var buffer bytes.Buffer
+		if err := copyTo(&buffer, zipFile); err != nil {
+			return err
+		}
+		return x.writeSymlink(targetPath, buffer.String())
 	case 0:
-		return x.writeFile(targetPath, zipFile, modePerm)
+		reader, err := zipFile.Open()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		return x.writeFile(targetPath, reader, modePerm)
 	}
 	return fmt.Errorf("unknown file type %d", modeType)
 }
@@ -131,7 +149,7 @@ func (x extractor) writeDir(targetPath string, modePerm os.FileMode) error {
 	return os.MkdirAll(targetPath, modePerm)
 }
 
-func (x extractor) writeFile(targetPath string, zipFile *zip.File, modePerm os.FileMode) error {
+func (x extractor) writeFile(targetPath string, content io.Reader, modePerm os.FileMode) error {
 	if _, err := os.Lstat(targetPath); !os.IsNotExist(err) {
 		if err := os.RemoveAll(targetPath); err != nil {
 			return err
@@ -142,12 +160,12 @@ func (x extractor) writeFile(targetPath string, zipFile *zip.File, modePerm os.F
 		return err
 	}
 	defer writer.Close()
-	return copyTo(writer, zipFile)
+	_, err = io.Copy(writer, content)
+	return err
 }
 
-func (x extractor) writeSymlink(targetPath string, zipFile *zip.File) error {
-	symlinkTarget, err := x.checkSymlink(targetPath, zipFile)
-	if err != nil {
+func (x extractor) writeSymlink(targetPath, symlinkTarget string) error {
+	if err := x.checkSymlink(targetPath, symlinkTarget); err != nil {
 		return err
 	}
 	if _, err := os.Lstat(targetPath); !os.IsNotExist(err) {
@@ -158,25 +176,20 @@ func (x extractor) writeSymlink(targetPath string, zipFile *zip.File) error {
 	return os.Symlink(symlinkTarget, targetPath)
 }
 
-func (x extractor) checkSymlink(targetPath string, zipFile *zip.File) (string, error) {
-	var buffer bytes.Buffer
-	if err := copyTo(&buffer, zipFile); err != nil {
-		return "", err
-	}
-	symlinkTarget := buffer.String()
+func (x extractor) checkSymlink(targetPath, symlinkTarget string) error {
 	if filepath.IsAbs(symlinkTarget) {
-		return "", fmt.Errorf("symlink %q is absolute", symlinkTarget)
+		return fmt.Errorf("symlink %q is absolute", symlinkTarget)
 	}
 	finalPath := filepath.Join(filepath.Dir(targetPath), symlinkTarget)
 	relativePath, err := filepath.Rel(x.targetRoot, finalPath)
 	if err != nil {
 		// Not tested, because I don't know how to trigger this condition.
-		return "", fmt.Errorf("symlink %q not comprehensible", symlinkTarget)
+		return fmt.Errorf("symlink %q not comprehensible", symlinkTarget)
 	}
 	if !isSanePath(relativePath) {
-		return "", fmt.Errorf("symlink %q leads out of scope", symlinkTarget)
+		return fmt.Errorf("symlink %q leads out of scope", symlinkTarget)
 	}
-	return symlinkTarget, nil
+	return nil
 }
 
 func copyTo(writer io.Writer, zipFile *zip.File) error {
@@ -28,18 +28,7 @@ type ZipSuite struct {
 var _ = gc.Suite(&ZipSuite{})
 
 func (s *ZipSuite) makeZip(c *gc.C, entries ...ft.Entry) *stdzip.Reader {
-	basePath := c.MkDir()
-	for _, entry := range entries {
-		entry.Create(c, basePath)
-	}
-	defer os.RemoveAll(basePath)
-
-	outPath := filepath.Join(c.MkDir(), "test.zip")
-	cmd := exec.Command("/bin/sh", "-c", fmt.Sprintf("cd %q; zip --fifo --symlinks -r %q .", basePath, outPath))
-	output, err := cmd.CombinedOutput()
-	c.Assert(err, gc.IsNil, gc.Commentf("Command output: %s", output))
-
-	file, err := os.Open(outPath)
+	file, err := os.Open(s.makeZipFile(c, entries...))
 	c.Assert(err, gc.IsNil)
 	s.AddCleanup(func(c *gc.C) {
 		err := file.Close()
@@ -52,6 +41,21 @@ func (s *ZipSuite) makeZip(c *gc.C, entries ...ft.Entry) *stdzip.Reader {
 	return reader
 }
 
+// makeZipFile returns the path of a zip file holding the given entries.
+func (s *ZipSuite) makeZipFile(c *gc.C, entries ...ft.Entry) string {
+	basePath := c.MkDir()
+	for _, entry := range entries {
+		entry.Create(c, basePath)
+	}
+	defer os.RemoveAll(basePath)
+
+	outPath := filepath.Join(c.MkDir(), "test.zip")
+	cmd := exec.Command("/bin/sh", "-c", fmt.Sprintf("cd %q; zip --fifo --symlinks -r %q .", basePath, outPath))
+	output, err := cmd.CombinedOutput()
+	c.Assert(err, gc.IsNil, gc.Commentf("Command output: %s", output))
+	return outPath
+}
+
 func (s *ZipSuite) TestFind(c *gc.C) {
 	reader := s.makeZip(c,
 		ft.File{"some-file", "", 0644},